@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// A changeScope is a best-effort description of which XRs a change to their
+// Composition can affect.
+type changeScope struct {
+	// All is why every XR may be affected. It's empty if only some can be.
+	All string
+
+	// Fields are the XR fields read by the changed patches. Patches whose
+	// source field isn't set do nothing, so only XRs that set one of them
+	// can be affected.
+	Fields []string
+}
+
+// affects returns whether the change may affect the supplied XR.
+func (s changeScope) affects(xr *unstructured.Unstructured) bool {
+	if s.All != "" {
+		return true
+	}
+	p := fieldpath.Pave(xr.Object)
+	for _, f := range s.Fields {
+		if _, err := p.GetValue(f); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compositionChangeScope returns which XRs the changes between two revisions
+// of a Composition can affect. Only changes to the patches of
+// function-patch-and-transform steps that read the XR are narrowed down to
+// the XRs setting their source fields; any other change may affect every XR.
+func compositionChangeScope(before, after *apiextensionsv1.Composition) changeScope {
+	bs, as := before.Spec.DeepCopy(), after.Spec.DeepCopy()
+	bs.Pipeline, as.Pipeline = nil, nil
+	if !reflect.DeepEqual(bs, as) {
+		return changeScope{All: "the Composition changed outside its pipeline"}
+	}
+	if len(before.Spec.Pipeline) != len(after.Spec.Pipeline) {
+		return changeScope{All: "pipeline steps were added or removed"}
+	}
+
+	fields := map[string]bool{}
+	for i := range after.Spec.Pipeline {
+		b, a := before.Spec.Pipeline[i], after.Spec.Pipeline[i]
+		bin, ain := b.Input, a.Input
+		b.Input, a.Input = nil, nil
+		if !reflect.DeepEqual(b, a) {
+			return changeScope{All: fmt.Sprintf("pipeline step %q changed", a.Step)}
+		}
+
+		var bm, am map[string]any
+		if bin != nil && len(bin.Raw) > 0 {
+			_ = json.Unmarshal(bin.Raw, &bm)
+		}
+		if ain != nil && len(ain.Raw) > 0 {
+			_ = json.Unmarshal(ain.Raw, &am)
+		}
+		if reflect.DeepEqual(bm, am) {
+			continue
+		}
+		sources, reason := changedPatchSources(bm, am)
+		if reason != "" {
+			return changeScope{All: fmt.Sprintf("the input of pipeline step %q changed: %s", a.Step, reason)}
+		}
+		for _, s := range sources {
+			fields[s] = true
+		}
+	}
+
+	s := changeScope{Fields: make([]string, 0, len(fields))}
+	for f := range fields {
+		s.Fields = append(s.Fields, f)
+	}
+	sort.Strings(s.Fields)
+	return s
+}
+
+// changedPatchSources returns the XR fields read by the patches that differ
+// between two function-patch-and-transform Resources inputs. It returns why
+// the change can't be narrowed down to them otherwise.
+func changedPatchSources(before, after map[string]any) ([]string, string) {
+	for _, in := range []map[string]any{before, after} {
+		apiVersion, _ := in["apiVersion"].(string)
+		if in["kind"] != "Resources" || !strings.HasPrefix(apiVersion, "pt.fn.crossplane.io/") {
+			return nil, "it isn't a function-patch-and-transform Resources input"
+		}
+	}
+	if !reflect.DeepEqual(withoutField(before, "resources"), withoutField(after, "resources")) {
+		return nil, "fields other than its resources changed"
+	}
+
+	br, ar := resourcesByName(before), resourcesByName(after)
+	if len(br) != len(ar) {
+		return nil, "resources were added or removed"
+	}
+	sources := []string{}
+	for name, a := range ar {
+		b, ok := br[name]
+		if !ok {
+			return nil, "resources were added or removed"
+		}
+		if !reflect.DeepEqual(withoutField(b, "patches"), withoutField(a, "patches")) {
+			return nil, fmt.Sprintf("resource %q changed outside its patches", name)
+		}
+		bp, _ := b["patches"].([]any)
+		ap, _ := a["patches"].([]any)
+		changed := append(missingPatches(bp, ap), missingPatches(ap, bp)...)
+		if len(changed) == 0 && !reflect.DeepEqual(bp, ap) {
+			return nil, fmt.Sprintf("the patches of resource %q were reordered", name)
+		}
+		for _, p := range changed {
+			s, reason := compositePatchSources(p)
+			if reason != "" {
+				return nil, fmt.Sprintf("resource %q has a changed patch that %s", name, reason)
+			}
+			sources = append(sources, s...)
+		}
+	}
+	return sources, ""
+}
+
+// compositePatchSources returns the XR fields read by a patch that only reads
+// the XR and does nothing if its source fields aren't set. It returns why the
+// patch isn't one otherwise.
+func compositePatchSources(patch any) ([]string, string) {
+	p, _ := patch.(map[string]any)
+	if policy, _ := p["policy"].(map[string]any); policy["fromFieldPath"] == "Required" {
+		return nil, "requires its source field"
+	}
+	switch t := p["type"]; t {
+	case nil, "", "FromCompositeFieldPath":
+		if from, _ := p["fromFieldPath"].(string); from != "" {
+			return []string{from}, ""
+		}
+	case "CombineFromComposite":
+		combine, _ := p["combine"].(map[string]any)
+		vars, _ := combine["variables"].([]any)
+		sources := []string{}
+		for _, v := range vars {
+			vm, _ := v.(map[string]any)
+			if from, _ := vm["fromFieldPath"].(string); from != "" {
+				sources = append(sources, from)
+			}
+		}
+		if len(sources) > 0 {
+			return sources, ""
+		}
+	default:
+		return nil, fmt.Sprintf("is a %v patch, which doesn't only read the XR", t)
+	}
+	return nil, "has no source field"
+}
+
+// resourcesByName returns the resources of a function-patch-and-transform
+// input by name.
+func resourcesByName(in map[string]any) map[string]map[string]any {
+	rs, _ := in["resources"].([]any)
+	byName := make(map[string]map[string]any, len(rs))
+	for _, r := range rs {
+		rm, _ := r.(map[string]any)
+		name, _ := rm["name"].(string)
+		byName[name] = rm
+	}
+	return byName
+}
+
+// missingPatches returns the patches of ps that aren't in other.
+func missingPatches(ps, other []any) []any {
+	missing := []any{}
+	for _, p := range ps {
+		found := false
+		for _, o := range other {
+			if reflect.DeepEqual(p, o) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// withoutField returns a shallow copy of m without the supplied field.
+func withoutField(m map[string]any, field string) map[string]any {
+	c := make(map[string]any, len(m))
+	for k, v := range m {
+		if k != field {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// changeScope returns which XRs the changes to the Composition and functions
+// file since the --changed-since git ref can affect.
+func (c *renderCmd) changeScope(args []string) (changeScope, error) {
+	if len(args) < 2 {
+		return changeScope{}, errors.New("--changed-since needs a composition file argument")
+	}
+	dir, err := os.MkdirTemp("", "crossbench-changed-since-")
+	if err != nil {
+		return changeScope{}, errors.Wrap(err, "cannot create directory for the previous revision")
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	before, err := checkoutFiles(dir, c.changedSince, args[1:])
+	if err != nil {
+		return changeScope{}, err
+	}
+	if len(args) > 2 {
+		old, err := os.ReadFile(before[1])
+		if err != nil {
+			return changeScope{}, errors.Wrapf(err, "cannot read %q", before[1])
+		}
+		cur, err := afero.ReadFile(c.fs, args[2])
+		if err != nil {
+			return changeScope{}, errors.Wrapf(err, "cannot read %q", args[2])
+		}
+		if !bytes.Equal(old, cur) {
+			return changeScope{All: "the functions file changed"}, nil
+		}
+	}
+
+	bc, err := loadComposition(afero.NewOsFs(), before[0])
+	if err != nil {
+		return changeScope{}, errors.Wrapf(err, "cannot load composition at %s", c.changedSince)
+	}
+	ac, err := loadComposition(c.fs, args[1])
+	if err != nil {
+		return changeScope{}, err
+	}
+	return compositionChangeScope(bc, ac), nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+func TestCompositionChangeScope(t *testing.T) {
+	composition := func(t *testing.T, fn string, patches ...any) *apiextensionsv1.Composition {
+		t.Helper()
+		input, err := json.Marshal(map[string]any{
+			"apiVersion": "pt.fn.crossplane.io/v1beta1",
+			"kind":       "Resources",
+			"resources": []any{map[string]any{
+				"name":    "bucket",
+				"base":    map[string]any{"apiVersion": "s3.aws.upbound.io/v1beta1", "kind": "Bucket"},
+				"patches": patches,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := &apiextensionsv1.Composition{}
+		c.Spec.CompositeTypeRef = apiextensionsv1.TypeReference{APIVersion: "example.org/v1", Kind: "XBucket"}
+		c.Spec.Pipeline = []apiextensionsv1.PipelineStep{{
+			Step:        "patch-and-transform",
+			FunctionRef: apiextensionsv1.FunctionReference{Name: fn},
+			Input:       &runtime.RawExtension{Raw: input},
+		}}
+		return c
+	}
+	region := map[string]any{"type": "FromCompositeFieldPath", "fromFieldPath": "spec.region", "toFieldPath": "spec.forProvider.region"}
+	acl := map[string]any{"fromFieldPath": "spec.acl", "toFieldPath": "spec.forProvider.acl"}
+	name := map[string]any{
+		"type": "CombineFromComposite",
+		"combine": map[string]any{
+			"variables": []any{map[string]any{"fromFieldPath": "spec.team"}, map[string]any{"fromFieldPath": "spec.env"}},
+			"strategy":  "string",
+			"string":    map[string]any{"fmt": "%s-%s"},
+		},
+		"toFieldPath": "metadata.annotations[crossplane.io/external-name]",
+	}
+	required := map[string]any{"fromFieldPath": "spec.acl", "toFieldPath": "spec.forProvider.acl", "policy": map[string]any{"fromFieldPath": "Required"}}
+	toComposite := map[string]any{"type": "ToCompositeFieldPath", "fromFieldPath": "status.atProvider.arn", "toFieldPath": "status.arn"}
+
+	cases := map[string]struct {
+		reason string
+		before *apiextensionsv1.Composition
+		after  *apiextensionsv1.Composition
+		want   changeScope
+	}{
+		"Unchanged": {
+			reason: "An unchanged Composition should affect no XRs.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform", region),
+			want:   changeScope{Fields: []string{}},
+		},
+		"AddedPatch": {
+			reason: "An added patch should only affect XRs setting the field it reads.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform", region, acl),
+			want:   changeScope{Fields: []string{"spec.acl"}},
+		},
+		"ChangedPatch": {
+			reason: "A changed patch should affect XRs setting the field it read before or reads now.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform", acl),
+			want:   changeScope{Fields: []string{"spec.acl", "spec.region"}},
+		},
+		"CombinePatch": {
+			reason: "A changed combine patch should affect XRs setting any of its variables.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform", region, name),
+			want:   changeScope{Fields: []string{"spec.env", "spec.team"}},
+		},
+		"RequiredPatch": {
+			reason: "A changed patch requiring its source field should affect every XR.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform", region, required),
+			want:   changeScope{All: `the input of pipeline step "patch-and-transform" changed: resource "bucket" has a changed patch that requires its source field`},
+		},
+		"ToCompositePatch": {
+			reason: "A changed patch that doesn't read the XR should affect every XR.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform", region, toComposite),
+			want:   changeScope{All: `the input of pipeline step "patch-and-transform" changed: resource "bucket" has a changed patch that is a ToCompositeFieldPath patch, which doesn't only read the XR`},
+		},
+		"ReorderedPatches": {
+			reason: "Reordered patches should affect every XR.",
+			before: composition(t, "function-patch-and-transform", region, acl),
+			after:  composition(t, "function-patch-and-transform", acl, region),
+			want:   changeScope{All: `the input of pipeline step "patch-and-transform" changed: the patches of resource "bucket" were reordered`},
+		},
+		"ChangedStep": {
+			reason: "A pipeline step calling another function should affect every XR.",
+			before: composition(t, "function-patch-and-transform", region),
+			after:  composition(t, "function-patch-and-transform-v2", region, acl),
+			want:   changeScope{All: `pipeline step "patch-and-transform" changed`},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := compositionChangeScope(tc.before, tc.after)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\ncompositionChangeScope(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
+
+func TestChangeScopeAffects(t *testing.T) {
+	xr := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "example.org/v1",
+		"kind":       "XBucket",
+		"spec":       map[string]any{"region": "us-east-2", "tags": map[string]any{"team": "a"}},
+	}}
+
+	cases := map[string]struct {
+		reason string
+		scope  changeScope
+		want   bool
+	}{
+		"All": {
+			reason: "A change that may affect every XR should affect this one.",
+			scope:  changeScope{All: "the functions file changed"},
+			want:   true,
+		},
+		"FieldSet": {
+			reason: "A change reading a field the XR sets should affect it.",
+			scope:  changeScope{Fields: []string{"spec.acl", "spec.tags.team"}},
+			want:   true,
+		},
+		"FieldsUnset": {
+			reason: "A change only reading fields the XR doesn't set shouldn't affect it.",
+			scope:  changeScope{Fields: []string{"spec.acl", "spec.tags.env"}},
+			want:   false,
+		},
+		"Unchanged": {
+			reason: "No change shouldn't affect the XR.",
+			scope:  changeScope{},
+			want:   false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tc.scope.affects(xr)); diff != "" {
+				t.Errorf("\n%s\naffects(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
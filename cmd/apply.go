@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+const checkAdmission = "admission"
+
+// applyFieldManager is the field manager of resources crossbench applies.
+const applyFieldManager = "crossbench"
+
+// NewApplyCommand creates a new apply command.
+func NewApplyCommand() *cobra.Command {
+	cmd := &applyCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "apply <composite-resource> [composition] [functions]",
+		Short: "Submit rendered resources to a cluster with a server-side dry-run",
+		Long: `Apply renders the XR exactly like the render command does, then submits each
+rendered composed resource to a live cluster with a server-side dry-run, so
+it passes through defaulting, validation and admission webhooks without being
+persisted. Resources the cluster would reject are reported with the reason,
+and fail the command.
+
+Resources with a name are server-side applied, resources that only have a
+generateName are created. The owner reference to the XR is set to the XR in
+the cluster if it exists, and left out otherwise.
+
+With --no-dry-run the resources are actually applied, with the field manager
+crossbench. Crossplane doesn't know about resources applied this way, so only
+use it against clusters you don't mind changing.`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	// Flags
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().BoolVar(&cmd.noDryRun, "no-dry-run", false, "Actually apply the rendered resources instead of only submitting them with a server-side dry-run.")
+
+	return cobraCmd
+}
+
+type applyCmd struct {
+	renderCmd
+
+	// Flags
+	noDryRun bool
+}
+
+func (c *applyCmd) run(cmd *cobra.Command, args []string) error {
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+	if c.networkDisabled() {
+		return errors.New("apply needs a cluster, so it can't be used with --no-network or --offline")
+	}
+
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return err
+	}
+
+	in, err := c.loadInputs(args)
+	if err != nil {
+		return err
+	}
+
+	out, err := c.render(in)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	mode := "dry run"
+	if c.noDryRun {
+		mode = "applied"
+	}
+	findings, accepted, err := submitComposed(ctx, cc, &in.CompositeResource.Unstructured, out.ComposedResources, !c.noDryRun)
+	if err != nil {
+		return err
+	}
+	for _, id := range accepted {
+		_, _ = fmt.Fprintf(os.Stdout, "%s accepted (%s)\n", id, mode)
+	}
+	infof("%d of %d composed resource(s) accepted by the cluster (%s)", len(accepted), len(out.ComposedResources), mode)
+
+	printFindings(os.Stderr, findings)
+	return findingsError(findings)
+}
+
+// checkAdmission submits the rendered composed resources to the cluster
+// selected by the cluster flags with a server-side dry-run, and returns the
+// rejections as findings.
+func (c *renderCmd) checkAdmission(in render.Inputs, out render.Outputs) ([]Finding, error) {
+	if c.networkDisabled() {
+		return nil, errors.New("--dry-run-validate can't be used with --no-network or --offline")
+	}
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(c.context(), c.timeout)
+	defer cancel()
+
+	findings, accepted, err := submitComposed(ctx, cc, &in.CompositeResource.Unstructured, out.ComposedResources, true)
+	if err != nil {
+		return nil, err
+	}
+	infof("%d of %d composed resource(s) accepted by the cluster (dry run)", len(accepted), len(out.ComposedResources))
+	return findings, nil
+}
+
+// submitComposed submits the supplied composed resources of the supplied XR
+// to the cluster, with a server-side dry-run if dryRun is set. Resources the
+// cluster rejects are returned as findings, and the IDs of those it accepts
+// as a list.
+func submitComposed(ctx context.Context, cc *clusterClient, xr *unstructured.Unstructured, cds []composed.Unstructured, dryRun bool) ([]Finding, []string, error) {
+	live, err := cc.get(ctx, xr.GroupVersionKind(), xr.GetNamespace(), xr.GetName())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot get composite resource from cluster")
+	}
+
+	findings := []Finding{}
+	accepted := []string{}
+	for i := range cds {
+		cd := cds[i].Unstructured.DeepCopy()
+		ownedBy(cd, live)
+		if cd.GetNamespace() == "" {
+			cd.SetNamespace(xr.GetNamespace())
+		}
+		if _, err := cc.apply(ctx, cd, dryRun); err != nil {
+			findings = append(findings, Finding{
+				Check:    checkAdmission,
+				Severity: SeverityError,
+				Resource: resourceID(cd),
+				Message:  err.Error(),
+			})
+			continue
+		}
+		accepted = append(accepted, resourceID(cd))
+	}
+	return findings, accepted, nil
+}
+
+// ownedBy points the controller reference of the supplied rendered resource
+// at the supplied XR from the cluster. Rendered owner references have no UID,
+// which the API server rejects, so they're dropped if the XR doesn't exist.
+func ownedBy(cd, xr *unstructured.Unstructured) {
+	refs := []metav1.OwnerReference{}
+	for _, ref := range cd.GetOwnerReferences() {
+		if ref.UID == "" {
+			if xr == nil || ref.Kind != xr.GetKind() || ref.Name != xr.GetName() {
+				continue
+			}
+			ref.UID = xr.GetUID()
+		}
+		refs = append(refs, ref)
+	}
+	cd.SetOwnerReferences(refs)
+}
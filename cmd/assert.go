@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+const checkAssertions = "assertions"
+
+// An assertion is a CEL expression that must be true of the rendered output.
+type assertion struct {
+	expression string
+	prg        cel.Program
+}
+
+// compileAssertions compiles the supplied CEL assertions. They can refer to
+// the rendered XR as xr, the composed resources as resources, the function
+// results as results and the context as context.
+func compileAssertions(expressions []string) ([]assertion, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("xr", cel.DynType),
+		cel.Variable("resources", cel.ListType(cel.DynType)),
+		cel.Variable("results", cel.ListType(cel.DynType)),
+		cel.Variable("context", cel.DynType),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CEL environment")
+	}
+
+	assertions := make([]assertion, 0, len(expressions))
+	for _, e := range expressions {
+		ast, iss := env.Compile(e)
+		if iss.Err() != nil {
+			return nil, errors.Wrapf(iss.Err(), "invalid assertion %q", e)
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, errors.Errorf("assertion %q must return a bool, not %s", e, ast.OutputType())
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid assertion %q", e)
+		}
+		assertions = append(assertions, assertion{expression: e, prg: prg})
+	}
+	return assertions, nil
+}
+
+// EvaluateAssertions evaluates the supplied assertions against the rendered
+// output and returns a description of each one that isn't true.
+func EvaluateAssertions(assertions []assertion, out render.Outputs) []string {
+	var xr any
+	if out.CompositeResource != nil {
+		xr = out.CompositeResource.Object
+	}
+	resources := make([]any, len(out.ComposedResources))
+	for i := range out.ComposedResources {
+		resources[i] = out.ComposedResources[i].Object
+	}
+	results := make([]any, len(out.Results))
+	for i := range out.Results {
+		results[i] = out.Results[i].Object
+	}
+	fctx := map[string]any{}
+	if out.Context != nil {
+		if fields, ok := out.Context.Object["fields"].(map[string]*structpb.Value); ok {
+			fctx = (&structpb.Struct{Fields: fields}).AsMap()
+		}
+	}
+	vars := map[string]any{"xr": xr, "resources": resources, "results": results, "context": fctx}
+
+	failures := []string{}
+	for _, a := range assertions {
+		v, _, err := a.prg.Eval(vars)
+		switch {
+		case err != nil:
+			failures = append(failures, fmt.Sprintf("cannot evaluate assertion %s: %v", a.expression, err))
+		case v.Value() == true:
+		case v.Value() == false:
+			failures = append(failures, fmt.Sprintf("assertion failed: %s", a.expression))
+		default:
+			failures = append(failures, fmt.Sprintf("assertion %s returned %v, not a bool", a.expression, v.Value()))
+		}
+	}
+	return failures
+}
+
+// CheckAssertions returns an error finding for each supplied assertion that
+// isn't true of the rendered output, against the rendered XR.
+func CheckAssertions(assertions []assertion, out render.Outputs) []Finding {
+	xr := ""
+	if out.CompositeResource != nil {
+		xr = resourceID(&out.CompositeResource.Unstructured)
+	}
+	findings := []Finding{}
+	for _, f := range EvaluateAssertions(assertions, out) {
+		findings = append(findings, Finding{
+			Check:    checkAssertions,
+			Severity: SeverityError,
+			Resource: xr,
+			Message:  f,
+		})
+	}
+	return findings
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+func TestCheckAssertions(t *testing.T) {
+	xr := composite.New()
+	xr.SetKind("Bucket")
+	xr.SetName("example-bucket")
+	out := render.Outputs{CompositeResource: xr}
+
+	assertions, err := compileAssertions([]string{"resources.size() == 0", "resources.size() > 0"})
+	if err != nil {
+		t.Fatalf("compileAssertions(...): %v", err)
+	}
+	want := []Finding{{
+		Check:    checkAssertions,
+		Severity: SeverityError,
+		Resource: "Bucket/example-bucket",
+		Message:  "assertion failed: resources.size() > 0",
+	}}
+	if diff := cmp.Diff(want, CheckAssertions(assertions, out)); diff != "" {
+		t.Errorf("\nFalse assertions should be reported against the rendered XR.\nCheckAssertions(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestRenderApplyBaselineKeepsAssertions(t *testing.T) {
+	failed := Finding{Check: checkAssertions, Severity: SeverityError, Resource: "Bucket/example-bucket", Message: "assertion failed: false"}
+	other := Finding{Check: checkFunctionResults, Severity: SeverityError, Resource: "step configure-bucket", Message: "fatal result: boom"}
+
+	fs := afero.NewMemMapFs()
+	w := &renderCmd{fs: fs, baseline: "baseline.yaml", writeBaseline: true}
+	got, err := w.applyBaseline([]Finding{failed, other})
+	if err != nil {
+		t.Fatalf("applyBaseline(...): %v", err)
+	}
+	if diff := cmp.Diff([]Finding{failed}, got); diff != "" {
+		t.Errorf("\nWriting a baseline should still report false assertions.\napplyBaseline(...): -want, +got:\n%s", diff)
+	}
+	w.checkedXRs++
+	if err := w.writeBaselineFile(1); err != nil {
+		t.Fatalf("writeBaselineFile(...): %v", err)
+	}
+
+	b, err := loadBaseline(fs, "baseline.yaml")
+	if err != nil {
+		t.Fatalf("loadBaseline(...): %v", err)
+	}
+	if diff := cmp.Diff([]Finding{other}, b.Findings); diff != "" {
+		t.Errorf("\nFalse assertions shouldn't be written to the baseline.\nwriteBaselineFile(...): -want, +got:\n%s", diff)
+	}
+
+	// Even a baseline that lists the assertion doesn't suppress it.
+	if err := writeBaseline(fs, "baseline.yaml", []Finding{failed, other}); err != nil {
+		t.Fatal(err)
+	}
+	r := &renderCmd{fs: fs, baseline: "baseline.yaml"}
+	got, err = r.applyBaseline([]Finding{failed, other})
+	if err != nil {
+		t.Fatalf("applyBaseline(...): %v", err)
+	}
+	if diff := cmp.Diff([]Finding{failed}, got); diff != "" {
+		t.Errorf("\nA baseline shouldn't suppress false assertions.\napplyBaseline(...): -want, +got:\n%s", diff)
+	}
+}
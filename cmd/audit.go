@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+)
+
+const checkPropagation = "propagation"
+
+// getRequiredLabels returns the XR labels that must be propagated to every composed resource
+// Default: none, configurable via CROSSBENCH_REQUIRED_LABELS env var (comma-separated)
+func getRequiredLabels() []string {
+	return splitEnvList("CROSSBENCH_REQUIRED_LABELS")
+}
+
+// getRequiredAnnotations returns the XR annotations that must be propagated to every composed resource
+// Default: none, configurable via CROSSBENCH_REQUIRED_ANNOTATIONS env var (comma-separated)
+func getRequiredAnnotations() []string {
+	return splitEnvList("CROSSBENCH_REQUIRED_ANNOTATIONS")
+}
+
+// splitEnvList splits a comma-separated environment variable, dropping empty entries
+func splitEnvList(name string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// AuditPropagation checks that the supplied labels and annotations of the XR
+// are propagated, with the same value, to every composed resource. Keys that
+// the XR itself doesn't carry are reported once against the XR.
+func AuditPropagation(xr *composite.Unstructured, cds []composed.Unstructured, labels, annotations []string) []Finding {
+	findings := []Finding{}
+	findings = append(findings, auditPropagation(xr, cds, "label", labels, func(u *composed.Unstructured) map[string]string { return u.GetLabels() }, xr.GetLabels())...)
+	findings = append(findings, auditPropagation(xr, cds, "annotation", annotations, func(u *composed.Unstructured) map[string]string { return u.GetAnnotations() }, xr.GetAnnotations())...)
+	return findings
+}
+
+func auditPropagation(xr *composite.Unstructured, cds []composed.Unstructured, what string, keys []string, get func(*composed.Unstructured) map[string]string, want map[string]string) []Finding {
+	findings := []Finding{}
+	for _, k := range keys {
+		wv, ok := want[k]
+		if !ok {
+			findings = append(findings, Finding{
+				Check:    checkPropagation,
+				Severity: SeverityError,
+				Resource: resourceID(&xr.Unstructured),
+				Message:  fmt.Sprintf("composite resource is missing required %s %q", what, k),
+			})
+			continue
+		}
+
+		for i := range cds {
+			v, ok := get(&cds[i])[k]
+			switch {
+			case !ok:
+				findings = append(findings, Finding{
+					Check:    checkPropagation,
+					Severity: SeverityError,
+					Resource: resourceID(&cds[i].Unstructured),
+					Message:  fmt.Sprintf("missing %s %q", what, k),
+				})
+			case v != wv:
+				findings = append(findings, Finding{
+					Check:    checkPropagation,
+					Severity: SeverityError,
+					Resource: resourceID(&cds[i].Unstructured),
+					Message:  fmt.Sprintf("%s %q has value %q, composite resource has %q", what, k, v, wv),
+				})
+			}
+		}
+	}
+	return findings
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// NewBenchCommand creates a new bench command.
+func NewBenchCommand() *cobra.Command {
+	cmd := &benchCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "bench <composite-resource> [composition] [functions]",
+		Short: "Measure how long a composition takes to render",
+		Long: `Bench renders the XR repeatedly and reports the min, average, p95 and max
+wall time of a render, of starting the functions, and of each function the
+pipeline calls, so the cost of a composition can be tracked as its pipeline
+grows.
+
+Function images are pulled once before the first render, and their pull time
+is reported separately. Warm-up renders run first and aren't measured. The
+rendered output is discarded.`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().IntVarP(&cmd.iterations, "iterations", "n", 10, "Number of measured renders.")
+	cobraCmd.Flags().IntVar(&cmd.warmup, "warmup", 1, "Number of renders to run before measuring.")
+	cobraCmd.Flags().StringVar(&cmd.output, "output", "table", "Output format: table or json.")
+
+	return cobraCmd
+}
+
+type benchCmd struct {
+	renderCmd
+
+	// Flags
+	iterations int
+	warmup     int
+	output     string
+}
+
+// BenchReport is the outcome of a benchmark. Durations are in milliseconds.
+type BenchReport struct {
+	Iterations int `json:"iterations"`
+	Warmup     int `json:"warmup"`
+
+	// Render is the wall time of each render.
+	Render BenchStats `json:"render"`
+
+	// Startup is the time taken to start the functions of each render.
+	Startup BenchStats `json:"startup"`
+
+	// Functions are the latencies of each function, by name. A function
+	// called by several steps is measured once per step.
+	Functions map[string]BenchStats `json:"functions"`
+
+	// Pulls are the time taken to pull each function image, by image. Images
+	// that weren't pulled, e.g. offline, aren't listed.
+	Pulls map[string]float64 `json:"pulls,omitempty"`
+}
+
+// BenchStats summarize a set of measurements, in milliseconds.
+type BenchStats struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	P95 float64 `json:"p95"`
+	Max float64 `json:"max"`
+}
+
+// newBenchStats summarizes the supplied measurements.
+func newBenchStats(ds []time.Duration) BenchStats {
+	if len(ds) == 0 {
+		return BenchStats{}
+	}
+	sorted := append([]time.Duration{}, ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	// The nearest-rank 95th percentile.
+	p95 := sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+	return BenchStats{
+		Min: milliseconds(sorted[0]),
+		Avg: milliseconds(sum / time.Duration(len(sorted))),
+		P95: milliseconds(p95),
+		Max: milliseconds(sorted[len(sorted)-1]),
+	}
+}
+
+// milliseconds returns d in milliseconds, to a microsecond.
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// A benchObserver records how long the phases of the measured renders took.
+type benchObserver struct {
+	mu        sync.Mutex
+	measuring bool
+	startup   []time.Duration
+	functions map[string][]time.Duration
+}
+
+func (o *benchObserver) FunctionsStarted(d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.measuring {
+		o.startup = append(o.startup, d)
+	}
+}
+
+func (o *benchObserver) StepRan(step apiextensionsv1.PipelineStep, d time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.measuring {
+		o.functions[step.FunctionRef.Name] = append(o.functions[step.FunctionRef.Name], d)
+	}
+}
+
+func (c *benchCmd) run(cmd *cobra.Command, args []string) error {
+	if c.iterations < 1 {
+		return errors.New("--iterations must be at least 1")
+	}
+	if c.warmup < 0 {
+		return errors.New("--warmup can't be negative")
+	}
+	if c.output != "table" && c.output != "json" {
+		return errors.Errorf("unknown output format %q, must be table or json", c.output)
+	}
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+	in, err := c.loadInputs(args)
+	if err != nil {
+		return err
+	}
+
+	report := &BenchReport{Iterations: c.iterations, Warmup: c.warmup, Functions: map[string]BenchStats{}}
+	if c.runtime != ContainerRuntimeKubernetes && !c.networkDisabled() {
+		if report.Pulls, err = pullFunctionImages(c.context(), in); err != nil {
+			return err
+		}
+	}
+	// Don't pull again while measuring.
+	neverPullFunctions(in.Functions)
+
+	o := &benchObserver{functions: map[string][]time.Duration{}}
+	c.observer = o
+	renders := make([]time.Duration, 0, c.iterations)
+	for i := 0; i < c.warmup+c.iterations; i++ {
+		o.measuring = i >= c.warmup
+		if o.measuring {
+			infof("Render %d of %d", i-c.warmup+1, c.iterations)
+		} else {
+			infof("Warm-up render %d of %d", i+1, c.warmup)
+		}
+		start := time.Now()
+		if _, err := c.render(in); err != nil {
+			return err
+		}
+		if o.measuring {
+			renders = append(renders, time.Since(start))
+		}
+	}
+
+	report.Render = newBenchStats(renders)
+	report.Startup = newBenchStats(o.startup)
+	for name, ds := range o.functions {
+		report.Functions[name] = newBenchStats(ds)
+	}
+
+	if c.output == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal benchmark report")
+		}
+		_, err = fmt.Fprintln(os.Stdout, string(data))
+		return err
+	}
+	return report.Print(os.Stdout)
+}
+
+// pullFunctionImages pulls the images of the supplied Functions that would be
+// run in Docker, and returns how long each pull took in milliseconds.
+func pullFunctionImages(ctx context.Context, in render.Inputs) (map[string]float64, error) {
+	pulls := map[string]float64{}
+	for _, fn := range dockerFunctions(in.Functions) {
+		image := fn.Spec.Package
+		if i := fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerImage]; i != "" {
+			image = i
+		}
+		if _, ok := pulls[image]; ok {
+			continue
+		}
+		infof("Pulling function %q image %q", fn.GetName(), image)
+		_, span := tracer.Start(ctx, "pull image", trace.WithAttributes(attribute.String("crossbench.image", image)))
+		start := time.Now()
+		pull := exec.Command(containerCLI, "pull", image)
+		pull.Stdout = os.Stderr
+		pull.Stderr = os.Stderr
+		err := pull.Run()
+		recordSpanError(span, err)
+		span.End()
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot pull function %q image %q", fn.GetName(), image)
+		}
+		pulls[image] = milliseconds(time.Since(start))
+	}
+	return pulls, nil
+}
+
+// Print writes the report as tables.
+func (r *BenchReport) Print(w io.Writer) error {
+	_, _ = fmt.Fprintf(w, "%d render(s), after %d warm-up render(s)\n\n", r.Iterations, r.Warmup)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PHASE\tMIN\tAVG\tP95\tMAX")
+	row := func(name string, s BenchStats) {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", name, formatMillis(s.Min), formatMillis(s.Avg), formatMillis(s.P95), formatMillis(s.Max))
+	}
+	row("render", r.Render)
+	row("function startup", r.Startup)
+	names := make([]string, 0, len(r.Functions))
+	for name := range r.Functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		row("function "+name, r.Functions[name])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Pulls) == 0 {
+		return nil
+	}
+	_, _ = fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "IMAGE\tPULL")
+	images := make([]string, 0, len(r.Pulls))
+	for image := range r.Pulls {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	for _, image := range images {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", image, formatMillis(r.Pulls[image]))
+	}
+	return tw.Flush()
+}
+
+// formatMillis formats a duration in milliseconds.
+func formatMillis(ms float64) string {
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Millisecond / 10).String()
+}
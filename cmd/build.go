@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// goFunctionDockerfile builds a Function written in Go, for sources without a
+// Dockerfile of their own.
+const goFunctionDockerfile = `FROM golang:1.24 AS build
+WORKDIR /fn
+COPY . .
+RUN CGO_ENABLED=0 go build -o /function .
+
+FROM gcr.io/distroless/static-debian12:nonroot
+COPY --from=build /function /function
+EXPOSE 9443
+USER nonroot:nonroot
+ENTRYPOINT ["/function"]
+`
+
+// NewFunctionsCommand creates a new functions command.
+func NewFunctionsCommand() *cobra.Command {
+	cobraCmd := &cobra.Command{
+		Use:   "functions",
+		Short: "Work with composition functions",
+	}
+	cobraCmd.AddCommand(newFunctionsBuildCommand())
+	cobraCmd.AddCommand(newFunctionsPullCommand())
+	cobraCmd.AddCommand(newFunctionsManifestCommand())
+	cobraCmd.AddCommand(newFunctionsApplyManifestCommand())
+	return cobraCmd
+}
+
+func newFunctionsBuildCommand() *cobra.Command {
+	cmd := &functionsBuildCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "build <function-source>",
+		Short: "Build a function image from local source and use it when rendering",
+		Long: `Build builds a function image from local source with Docker BuildKit and
+loads it into the local Docker daemon, or with Podman with --runtime podman. The
+source's Dockerfile is used if it has one; otherwise it must be a Go module,
+which is built into a distroless image.
+
+The image is recorded as an override for the function in the crossbench
+configuration file, so render, validate and diff run it instead of the
+function's package:
+
+  functions:
+    function-example:
+      image: function-example:dev`,
+		Args: cobra.ExactArgs(1),
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.name, "name", "", "Name of the function, as referenced by Composition pipeline steps. Defaults to the source directory name.")
+	cobraCmd.Flags().StringVar(&cmd.tag, "tag", "dev", "Tag of the built image.")
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Path to the crossbench configuration file to record the override in.")
+	cobraCmd.Flags().StringVar(&cmd.runtime, "runtime", getContainerRuntime(), "Container runtime to build the image with: auto, docker or podman.")
+	cobraCmd.Flags().StringVar(&cmd.dockerHost, "docker-host", "", "Docker API endpoint to build the image with. Overrides DOCKER_HOST.")
+	cobraCmd.Flags().BoolVar(&cmd.noOverride, "no-override", false, "Only build the image, don't record it as an override.")
+
+	return cobraCmd
+}
+
+type functionsBuildCmd struct {
+	name       string
+	tag        string
+	config     string
+	noOverride bool
+	runtime    string
+	dockerHost string
+
+	fs afero.Fs
+}
+
+func (c *functionsBuildCmd) run(_ *cobra.Command, args []string) error {
+	src, err := filepath.Abs(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "cannot resolve function source %q", args[0])
+	}
+	if c.name == "" {
+		c.name = filepath.Base(src)
+	}
+	image := c.name + ":" + c.tag
+
+	if err := setDockerHost(c.dockerHost); err != nil {
+		return err
+	}
+	if err := setContainerRuntime(c.runtime); err != nil {
+		return err
+	}
+	buildArgs := []string{"buildx", "build", "--load", "--tag", image}
+	if containerCLI == ContainerRuntimePodman {
+		// Podman builds straight into its local image store.
+		buildArgs = []string{"build", "--tag", image}
+	}
+	if ok, _ := afero.Exists(c.fs, filepath.Join(src, "Dockerfile")); !ok {
+		if ok, _ := afero.Exists(c.fs, filepath.Join(src, "go.mod")); !ok {
+			return errors.Errorf("function source %q has neither a Dockerfile nor a go.mod", src)
+		}
+		dockerfile, err := afero.TempFile(c.fs, "", "crossbench-Dockerfile-")
+		if err != nil {
+			return errors.Wrap(err, "cannot create Dockerfile")
+		}
+		defer c.fs.Remove(dockerfile.Name()) //nolint:errcheck // Best effort cleanup.
+		if _, err := dockerfile.WriteString(goFunctionDockerfile); err != nil {
+			return errors.Wrap(err, "cannot write Dockerfile")
+		}
+		if err := dockerfile.Close(); err != nil {
+			return errors.Wrap(err, "cannot write Dockerfile")
+		}
+		buildArgs = append(buildArgs, "--file", dockerfile.Name())
+	}
+	buildArgs = append(buildArgs, src)
+
+	infof("Building function %q as image %q", c.name, image)
+	build := exec.Command(containerCLI, buildArgs...)
+	build.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	build.Stdout = os.Stderr
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return errors.Wrapf(err, "cannot build function image %q", image)
+	}
+
+	if c.noOverride {
+		return nil
+	}
+	if err := setFunctionImageOverride(c.fs, c.config, c.name, image); err != nil {
+		return err
+	}
+	infof("Function %q will run image %q (override recorded in %s)", c.name, image, c.config)
+	return nil
+}
+
+// setFunctionImageOverride records the image to run for the named function in
+// the configuration file, creating the file if it doesn't exist. Only
+// functions.<name>.image is changed: the file is edited as a YAML node tree,
+// so comments, key order and settings crossbench doesn't know about are
+// preserved.
+func setFunctionImageOverride(fs afero.Fs, path, name, image string) error {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "cannot read config file %q", path)
+	}
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return errors.Wrapf(err, "cannot parse config file %q", path)
+	}
+
+	// An empty file, or one holding only comments, has no document. Append
+	// one so the comments are kept.
+	prefix := []byte{}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		prefix = data
+		if len(prefix) > 0 && !bytes.HasSuffix(prefix, []byte("\n")) {
+			prefix = append(prefix, '\n')
+		}
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!null"}}}
+	}
+
+	fn := yamlMapping(yamlMapping(yamlMappingNode(&doc.Content[0]), "functions"), name)
+	if v := yamlValue(fn, "image"); v != nil && v.Kind == yaml.ScalarNode {
+		v.Tag, v.Value = "!!str", image
+	} else {
+		setYAMLValue(fn, "image", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: image})
+	}
+
+	buf := bytes.NewBuffer(prefix)
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return errors.Wrapf(err, "cannot marshal config file %q", path)
+	}
+	if err := enc.Close(); err != nil {
+		return errors.Wrapf(err, "cannot marshal config file %q", path)
+	}
+	return errors.Wrapf(afero.WriteFile(fs, path, buf.Bytes(), 0644), "cannot write config file %q", path)
+}
+
+// yamlMappingNode returns the mapping node *n points to, replacing it with an
+// empty mapping if it isn't one, e.g. because it's null.
+func yamlMappingNode(n **yaml.Node) *yaml.Node {
+	if *n == nil || (*n).Kind != yaml.MappingNode {
+		*n = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	return *n
+}
+
+// yamlMapping returns the mapping value of the supplied key of a mapping
+// node, adding the key or replacing its value with an empty mapping if its
+// value isn't a mapping.
+func yamlMapping(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return yamlMappingNode(&n.Content[i+1])
+		}
+	}
+	v := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	setYAMLValue(n, key, v)
+	return v
+}
+
+// setYAMLValue sets the value of the supplied key of a mapping node, adding
+// the key after the existing ones if it isn't set.
+func setYAMLValue(n *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = v
+			return
+		}
+	}
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v)
+}
+
+// applyFunctionOverrides makes Functions with an image override in the
+// configuration run that image. Overridden images are built locally, so
+// they're never pulled.
+func applyFunctionOverrides(fns []pkgv1.Function, overrides map[string]FunctionConfig) {
+	for i := range fns {
+		o, ok := overrides[fns[i].GetName()]
+		if !ok || o.Image == "" || o.Source != "" {
+			continue
+		}
+		meta.AddAnnotations(&fns[i], map[string]string{
+			render.AnnotationKeyRuntimeDockerImage:      o.Image,
+			render.AnnotationKeyRuntimeDockerPullPolicy: string(render.AnnotationValueRuntimeDockerPullPolicyNever),
+		})
+		infof("Using locally built image %q for function %q", o.Image, fns[i].GetName())
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+)
+
+func TestSetFunctionImageOverride(t *testing.T) {
+	cases := map[string]struct {
+		reason string
+		config string
+		want   string
+	}{
+		"NoFile": {
+			reason: "The configuration file should be created if it doesn't exist.",
+			want: `functions:
+  function-patch-and-transform:
+    image: function-patch-and-transform:dev
+`,
+		},
+		"OnlyComments": {
+			reason: "Comments in a configuration file without settings should be kept.",
+			config: "# Settings for crossbench.\n",
+			want: `# Settings for crossbench.
+functions:
+  function-patch-and-transform:
+    image: function-patch-and-transform:dev
+`,
+		},
+		"AddFunction": {
+			reason: "Comments, key order and other settings should be kept when a function is added.",
+			config: `# Settings for crossbench.
+runtime: podman # for CI
+functions:
+  # Pinned for the demo.
+  function-auto-ready:
+    version: v0.5.0
+offline: true
+`,
+			want: `# Settings for crossbench.
+runtime: podman # for CI
+functions:
+  # Pinned for the demo.
+  function-auto-ready:
+    version: v0.5.0
+  function-patch-and-transform:
+    image: function-patch-and-transform:dev
+offline: true
+`,
+		},
+		"ReplaceImage": {
+			reason: "Only the image of the function should change.",
+			config: `functions:
+  function-patch-and-transform:
+    source: ../function-patch-and-transform # built by functions build
+    image: "function-patch-and-transform:old"
+    version: v0.8.0
+`,
+			want: `functions:
+  function-patch-and-transform:
+    source: ../function-patch-and-transform # built by functions build
+    image: "function-patch-and-transform:dev"
+    version: v0.8.0
+`,
+		},
+		"NullFunctions": {
+			reason: "A functions key without a value should be given one.",
+			config: "functions:\nruntime: docker\n",
+			want: `functions:
+  function-patch-and-transform:
+    image: function-patch-and-transform:dev
+runtime: docker
+`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			if tc.config != "" {
+				if err := afero.WriteFile(fs, ".crossbench.yaml", []byte(tc.config), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := setFunctionImageOverride(fs, ".crossbench.yaml", "function-patch-and-transform", "function-patch-and-transform:dev"); err != nil {
+				t.Fatalf("\n%s\nsetFunctionImageOverride(...): %v", tc.reason, err)
+			}
+			got, err := afero.ReadFile(fs, ".crossbench.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, string(got)); diff != "" {
+				t.Errorf("\n%s\nsetFunctionImageOverride(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/beta/validate"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/crd"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// Files written to a --bundle directory.
+const (
+	BundleFileXRD         = "xrd.yaml"
+	BundleFileComposition = "composition.yaml"
+	BundleFileFunctions   = "functions.yaml"
+	BundleFileExampleXR   = "example-xr.yaml"
+)
+
+// loadXRD loads the CompositeResourceDefinition in the supplied file.
+func loadXRD(fs afero.Fs, file string) (*unstructured.Unstructured, error) {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read XRD %q", file)
+	}
+	xrd := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(data, &xrd.Object); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse XRD %q", file)
+	}
+	if xrd.GetKind() != apiextensionsv1.CompositeResourceDefinitionKind {
+		return nil, errors.Errorf("%q holds a %s, not a %s", file, xrd.GetKind(), apiextensionsv1.CompositeResourceDefinitionKind)
+	}
+	return xrd, nil
+}
+
+// bundleXRD returns the XRD of the supplied Composition's XR type: the one
+// passed with --include-xrd, or the one of the Configuration package the
+// Composition came from.
+func (c *renderCmd) bundleXRD(comp *apiextensionsv1.Composition) (*unstructured.Unstructured, error) {
+	if c.xrd != nil {
+		return c.xrd, nil
+	}
+	gvk := schema.FromAPIVersionAndKind(comp.Spec.CompositeTypeRef.APIVersion, comp.Spec.CompositeTypeRef.Kind)
+	for _, xrd := range c.packageXRDs {
+		p := fieldpath.Pave(xrd.Object)
+		group, _ := p.GetString("spec.group")
+		kind, _ := p.GetString("spec.names.kind")
+		if group == gvk.Group && kind == gvk.Kind {
+			return xrd, nil
+		}
+	}
+	return nil, errors.New("--bundle needs the XRD of the composite resource, pass it with --include-xrd")
+}
+
+// writeXRD writes the supplied XRD as a YAML document.
+func writeXRD(w io.Writer, xrd *unstructured.Unstructured) error {
+	y, err := yaml.Marshal(xrd.Object)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal XRD %q to YAML", xrd.GetName())
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", y)
+	return err
+}
+
+// checkBundle returns the inconsistencies between the XRD, Composition,
+// Functions and example XR of a bundle.
+func checkBundle(xrd *unstructured.Unstructured, in render.Inputs) []string {
+	problems := []string{}
+	comp := in.Composition
+	ref := comp.Spec.CompositeTypeRef
+	gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+
+	p := fieldpath.Pave(xrd.Object)
+	group, _ := p.GetString("spec.group")
+	kind, _ := p.GetString("spec.names.kind")
+	if group != gvk.Group || kind != gvk.Kind {
+		problems = append(problems, fmt.Sprintf("XRD %q defines %s.%s, but Composition %q composes %s.%s", xrd.GetName(), kind, group, comp.GetName(), gvk.Kind, gvk.Group))
+	}
+	served := false
+	versions, _ := p.GetValue("spec.versions")
+	vs, _ := versions.([]any)
+	for _, v := range vs {
+		if m, ok := v.(map[string]any); ok && m["name"] == gvk.Version && m["served"] == true {
+			served = true
+		}
+	}
+	if !served {
+		problems = append(problems, fmt.Sprintf("XRD %q doesn't serve version %s the Composition composes", xrd.GetName(), gvk.Version))
+	}
+
+	fns := map[string]pkgv1.Function{}
+	for _, fn := range in.Functions {
+		fns[fn.GetName()] = fn
+	}
+	used := map[string]bool{}
+	for _, s := range comp.Spec.Pipeline {
+		used[s.FunctionRef.Name] = true
+		if _, ok := fns[s.FunctionRef.Name]; !ok {
+			problems = append(problems, fmt.Sprintf("pipeline step %q uses Function %q, which isn't in the bundle", s.Step, s.FunctionRef.Name))
+		}
+	}
+	for _, fn := range in.Functions {
+		if !used[fn.GetName()] {
+			problems = append(problems, fmt.Sprintf("Function %q isn't used by any pipeline step", fn.GetName()))
+		}
+		if !pinnedPackage(fn.Spec.Package) {
+			problems = append(problems, fmt.Sprintf("Function %q package %q isn't pinned to a version or digest", fn.GetName(), fn.Spec.Package))
+		}
+	}
+	return problems
+}
+
+// pinnedPackage returns true if the supplied package names a digest, or a tag
+// other than latest.
+func pinnedPackage(pkg string) bool {
+	if strings.Contains(pkg, "@sha256:") {
+		return true
+	}
+	_, tag, ok := strings.Cut(path.Base(pkg), ":")
+	return ok && tag != "" && tag != "latest"
+}
+
+// writeBundle checks the XRD, Composition, Functions and XR of a render are
+// consistent, then writes them to dir as an installable bundle. The XR is
+// written as it was read from xrFile.
+func writeBundle(fs afero.Fs, dir string, xrd *unstructured.Unstructured, in render.Inputs, xrFile string) error {
+	if problems := checkBundle(xrd, in); len(problems) > 0 {
+		return errors.Errorf("bundle is inconsistent:\n  %s", strings.Join(problems, "\n  "))
+	}
+	crds, err := crd.ConvertToCRDs([]*unstructured.Unstructured{xrd})
+	if err != nil {
+		return errors.Wrapf(err, "cannot convert XRD %q to a CRD", xrd.GetName())
+	}
+	xr := in.CompositeResource.Unstructured.DeepCopy()
+	out := &strings.Builder{}
+	if err := validate.SchemaValidation(context.Background(), []*unstructured.Unstructured{xr}, crds, true, true, out); err != nil {
+		return errors.Errorf("example composite resource %q doesn't match the schema of XRD %q:\n%s", xrFile, xrd.GetName(), strings.TrimSpace(out.String()))
+	}
+
+	example, err := afero.ReadFile(fs, xrFile)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read composite resource %q", xrFile)
+	}
+	comp := in.Composition.DeepCopy()
+	comp.SetGroupVersionKind(apiextensionsv1.CompositionGroupVersionKind)
+	fns := make([]any, len(in.Functions))
+	for i := range in.Functions {
+		fn := in.Functions[i].DeepCopy()
+		fn.SetGroupVersionKind(pkgv1.FunctionGroupVersionKind)
+		// Annotations telling crossbench how to run the Function locally
+		// don't belong in an installable manifest.
+		annotations := fn.GetAnnotations()
+		for k := range annotations {
+			if strings.HasPrefix(k, "render.crossplane.io/") {
+				delete(annotations, k)
+			}
+		}
+		fn.SetAnnotations(annotations)
+		fns[i] = fn
+	}
+
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "cannot create bundle directory %q", dir)
+	}
+	for _, f := range []struct {
+		name string
+		objs []any
+	}{
+		{BundleFileXRD, []any{xrd.Object}},
+		{BundleFileComposition, []any{comp}},
+		{BundleFileFunctions, fns},
+	} {
+		b := &strings.Builder{}
+		for _, o := range f.objs {
+			y, err := yaml.Marshal(o)
+			if err != nil {
+				return errors.Wrapf(err, "cannot marshal %s", f.name)
+			}
+			_, _ = fmt.Fprintf(b, "---\n%s", y)
+		}
+		if err := afero.WriteFile(fs, filepath.Join(dir, f.name), []byte(b.String()), 0644); err != nil {
+			return errors.Wrapf(err, "cannot write %q", filepath.Join(dir, f.name))
+		}
+	}
+	if err := afero.WriteFile(fs, filepath.Join(dir, BundleFileExampleXR), example, 0644); err != nil {
+		return errors.Wrapf(err, "cannot write %q", filepath.Join(dir, BundleFileExampleXR))
+	}
+	infof("Wrote a bundle of XRD %q, Composition %q, %d Function(s) and an example XR to %s", xrd.GetName(), comp.GetName(), len(fns), dir)
+	return nil
+}
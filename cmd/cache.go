@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// NewCacheCommand creates a new cache command.
+func NewCacheCommand() *cobra.Command {
+	fs := afero.NewOsFs()
+	cobraCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cache of function versions",
+		Long: `Functions extracted from a Composition use their latest version, which is
+cached so GitHub and registries aren't queried on every render. These commands
+inspect and clean up that cache.`,
+	}
+
+	cobraCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List cached function versions with their age",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cache, err := loadCache(fs)
+			if err != nil {
+				return err
+			}
+			return printCache(os.Stdout, cache, time.Now())
+		},
+	})
+
+	cobraCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove every cached function version",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			path, err := getCachePath(fs)
+			if err != nil {
+				return err
+			}
+			if err := fs.Remove(path); err != nil && !os.IsNotExist(err) {
+				return errors.Wrapf(err, "cannot remove cache %q", path)
+			}
+			infof("Cleared cache %s", path)
+			return nil
+		},
+	})
+
+	cobraCmd.AddCommand(&cobra.Command{
+		Use:   "prune",
+		Short: "Remove cached function versions that have expired",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cache, err := loadCache(fs)
+			if err != nil {
+				return err
+			}
+			n := pruneCache(cache, time.Now())
+			if n > 0 {
+				if err := saveCache(fs, cache); err != nil {
+					return err
+				}
+			}
+			infof("Pruned %d expired cache entries, %d left", n, len(cache.Versions))
+			return nil
+		},
+	})
+
+	cobraCmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Show the cache file and expiration in effect",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			path, err := getCachePath(fs)
+			if err != nil {
+				return err
+			}
+			cache, err := loadCache(fs)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(os.Stdout, "path: %s\n", path)
+			_, _ = fmt.Fprintf(os.Stdout, "expiration: %s\n", getCacheExpiration())
+			_, _ = fmt.Fprintf(os.Stdout, "entries: %d\n", len(cache.Versions))
+			return nil
+		},
+	})
+
+	return cobraCmd
+}
+
+// pruneCache removes the entries of the cache that have expired by now, and
+// returns how many were removed.
+func pruneCache(cache *FunctionVersionCache, now time.Time) int {
+	n := 0
+	for k, e := range cache.Versions {
+		if now.Sub(e.FetchedAt) > getCacheExpiration() {
+			delete(cache.Versions, k)
+			n++
+		}
+	}
+	return n
+}
+
+// printCache writes the entries of the cache as a table, sorted by
+// repository.
+func printCache(w io.Writer, cache *FunctionVersionCache, now time.Time) error {
+	keys := make([]string, 0, len(cache.Versions))
+	for k := range cache.Versions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "REPOSITORY\tVERSION\tAGE\tSTATUS")
+	for _, k := range keys {
+		e := cache.Versions[k]
+		age := now.Sub(e.FetchedAt)
+		status := "fresh"
+		if age > getCacheExpiration() {
+			status = "expired"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k, e.Version, cacheAge(age), status)
+	}
+	return tw.Flush()
+}
+
+// cacheAge formats the age of a cache entry, in days once it's over two days
+// old.
+func cacheAge(d time.Duration) string {
+	if d > 48*time.Hour {
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+	return d.Round(time.Minute).String()
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+)
+
+// capabilitiesSchemaVersion is bumped whenever fields of Capabilities are
+// changed or removed, so wrappers can tell what to expect.
+const capabilitiesSchemaVersion = 1
+
+// Capabilities describe what the installed crossbench supports, for wrapper
+// tools that adapt to its version.
+type Capabilities struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+
+	// Crossplane is the version of Crossplane whose render engine is built
+	// in.
+	Crossplane string `json:"crossplane"`
+
+	// Features are identifiers of the optional features supported.
+	Features []string `json:"features"`
+
+	// Runtimes functions can be run with.
+	Runtimes []string `json:"runtimes"`
+
+	// VersionResolvers look up the latest version of functions.
+	VersionResolvers []string `json:"versionResolvers"`
+
+	// OutputFormats are the formats of each kind of output.
+	OutputFormats map[string][]string `json:"outputFormats"`
+
+	// APIVersions accepted for each kind of input. Deprecated versions are
+	// converted.
+	APIVersions map[string]APIVersionSupport `json:"apiVersions"`
+
+	// Commands are every command, with their flags.
+	Commands []CommandCapability `json:"commands"`
+}
+
+// APIVersionSupport lists the API versions accepted for a kind.
+type APIVersionSupport struct {
+	Supported  []string `json:"supported"`
+	Deprecated []string `json:"deprecated,omitempty"`
+}
+
+// A CommandCapability is a command and its flags.
+type CommandCapability struct {
+	// Name is the command's path below crossbench, e.g. functions pull.
+	Name  string           `json:"name"`
+	Short string           `json:"short"`
+	Flags []FlagCapability `json:"flags,omitempty"`
+}
+
+// A FlagCapability is a flag of a command.
+type FlagCapability struct {
+	Name      string `json:"name"`
+	Shorthand string `json:"shorthand,omitempty"`
+	Type      string `json:"type"`
+	Default   string `json:"default,omitempty"`
+	Usage     string `json:"usage"`
+}
+
+// features are the identifiers reported in Capabilities.Features. Wrappers
+// check for them instead of parsing versions.
+var features = []string{
+	"api-upgrades",
+	"apply",
+	"assertions",
+	"bench",
+	"bundle",
+	"changed-since",
+	"changelog",
+	"chaos",
+	"claims",
+	"compositions-dir",
+	"configuration-packages",
+	"context-schemas",
+	"crossplane-lock",
+	"crossplane-version-check",
+	"daemon",
+	"debugger",
+	"deletion-simulation",
+	"dependency-order",
+	"diff",
+	"dry-run-validate",
+	"dump-io",
+	"explain-selection",
+	"extra-from-cluster",
+	"fail-on",
+	"findings-baseline",
+	"footprint",
+	"function-extraction",
+	"function-lock",
+	"functions-map",
+	"grpc-api",
+	"import-crank",
+	"interactive",
+	"inventory",
+	"junit-report",
+	"kubectl-plugin",
+	"kyverno",
+	"legacy-api-conversion",
+	"meta-context",
+	"metadata-checks",
+	"metrics",
+	"missing-inputs",
+	"no-network",
+	"observe",
+	"observed-from-cluster",
+	"offline",
+	"opentelemetry",
+	"output-dir-manifest",
+	"output-layout-gitops",
+	"output-layouts",
+	"parallel-steps",
+	"parity",
+	"partial-timeout-output",
+	"pin-digests",
+	"presets",
+	"remote-storage",
+	"runtime-overrides",
+	"sarif-report",
+	"sbom",
+	"scanner",
+	"sibling-discovery",
+	"snapshots",
+	"step-filters",
+	"step-inputs",
+	"stop-after-step",
+	"strict-decode",
+	"structured-logging",
+	"summary-file",
+	"telemetry",
+	"tests",
+	"timings",
+	"version-constraints",
+	"versions-manifest",
+}
+
+// NewCapabilitiesCommand creates a new capabilities command.
+func NewCapabilitiesCommand() *cobra.Command {
+	var output string
+	cobraCmd := &cobra.Command{
+		Use:   "capabilities",
+		Short: "Print the features, flags, runtimes and API versions supported",
+		Long: `Capabilities prints a machine readable description of what this crossbench
+supports - features, commands and their flags, output formats, function
+runtimes and the API versions of inputs - so wrapper tools can adapt to the
+installed version. Fields are only added within a schemaVersion.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return writeCapabilities(os.Stdout, output, NewCapabilities(cmd.Root()))
+		},
+	}
+	cobraCmd.Flags().StringVarP(&output, "output", "o", "json", "Output format: json or yaml.")
+	return cobraCmd
+}
+
+// NewCapabilities describes the capabilities of crossbench, including every
+// command below the supplied root command.
+func NewCapabilities(root *cobra.Command) Capabilities {
+	c := Capabilities{
+		SchemaVersion:    capabilitiesSchemaVersion,
+		Version:          version,
+		Commit:           commit,
+		Crossplane:       "unknown",
+		Features:         features,
+		Runtimes:         []string{ContainerRuntimeDocker, ContainerRuntimePodman, ContainerRuntimeKubernetes},
+		VersionResolvers: []string{VersionResolverAuto, VersionResolverGitHub, VersionResolverRegistry, VersionResolverMarketplace},
+		OutputFormats: map[string][]string{
+			"render":  {"yaml"},
+			"report":  reportFormats,
+			"sbom":    {SBOMFormatCycloneDX, SBOMFormatSPDX},
+			"summary": {"json"},
+		},
+		APIVersions: map[string]APIVersionSupport{
+			apiextensionsv1.CompositionKind:                 {Supported: []string{apiextensionsv1.SchemeGroupVersion.String()}},
+			apiextensionsv1.CompositionRevisionKind:         {Supported: []string{apiextensionsv1.SchemeGroupVersion.String()}},
+			apiextensionsv1.CompositeResourceDefinitionKind: {Supported: []string{apiextensionsv1.SchemeGroupVersion.String()}},
+			pkgv1.FunctionKind:                              {Supported: []string{pkgv1.SchemeGroupVersion.String()}},
+		},
+	}
+	for _, kind := range []string{apiextensionsv1.CompositionKind, apiextensionsv1.CompositionRevisionKind, apiextensionsv1.CompositeResourceDefinitionKind} {
+		s := c.APIVersions[kind]
+		for v := range legacyAPIVersions {
+			s.Deprecated = append(s.Deprecated, apiextensionsv1.Group+"/"+v)
+		}
+		sort.Strings(s.Deprecated)
+		c.APIVersions[kind] = s
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, d := range bi.Deps {
+			if d.Path == "github.com/crossplane/crossplane/v2" {
+				c.Crossplane = d.Version
+			}
+		}
+	}
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		for _, sub := range cmd.Commands() {
+			if sub.Hidden || sub.Name() == "help" || sub.Name() == "completion" {
+				continue
+			}
+			cc := CommandCapability{
+				Name:  strings.TrimPrefix(sub.CommandPath(), root.Name()+" "),
+				Short: sub.Short,
+			}
+			sub.Flags().VisitAll(func(f *pflag.Flag) {
+				cc.Flags = append(cc.Flags, FlagCapability{
+					Name:      f.Name,
+					Shorthand: f.Shorthand,
+					Type:      f.Value.Type(),
+					Default:   f.DefValue,
+					Usage:     f.Usage,
+				})
+			})
+			c.Commands = append(c.Commands, cc)
+			walk(sub)
+		}
+	}
+	walk(root)
+	return c
+}
+
+// writeCapabilities writes the capabilities as json or yaml.
+func writeCapabilities(w io.Writer, format string, c Capabilities) error {
+	var data []byte
+	var err error
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(c, "", "  ")
+		data = append(data, '\n')
+	case "yaml":
+		data, err = yaml.Marshal(c)
+	default:
+		return errors.Errorf("unknown output format %q, must be json or yaml", format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal capabilities")
+	}
+	_, err = w.Write(data)
+	return err
+}
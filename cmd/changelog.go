@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// Formats of the changelog.
+const (
+	ChangelogFormatMarkdown = "markdown"
+	ChangelogFormatJSON     = "json"
+)
+
+// A Changelog describes how the resources rendered for a set of XRs changed
+// between two revisions of their Composition.
+type Changelog struct {
+	Since string `json:"since"`
+	Until string `json:"until"`
+
+	// XRs are the changes of each XR, in the order they were rendered.
+	XRs []XRChangelog `json:"xrs"`
+}
+
+// An XRChangelog lists the composed resources of an XR that were added,
+// removed or changed. Unchanged resources are left out.
+type XRChangelog struct {
+	XR      string           `json:"xr"`
+	Changes []ResourceChange `json:"changes"`
+}
+
+// NewChangelogCommand creates a new changelog command.
+func NewChangelogCommand() *cobra.Command {
+	cmd := &changelogCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "changelog <composite-resource(s)> <composition> [functions] --since <ref>",
+		Short: "Describe how rendered resources changed between two git refs",
+		Long: `Changelog renders a representative set of XRs against the Composition (and
+functions file, if given) as they were at the git ref --since, and as they are
+at --until or in the working tree, then describes how the composed resources
+changed - added, removed and changed fields - as release notes.
+
+The composite resource argument may be a directory or a glob of XR files,
+which are read from the working tree, along with the observed and extra
+resources, so only changes to the Composition and Functions are reported.`,
+		Args: cobra.RangeArgs(2, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	// Flags
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().StringVar(&cmd.since, "since", "", "Git ref of the previous release, e.g. v1.2.0.")
+	cobraCmd.Flags().StringVar(&cmd.until, "until", "", "Git ref of the new release. Defaults to the working tree.")
+	cobraCmd.Flags().StringVar(&cmd.format, "format", ChangelogFormatMarkdown, "Format of the changelog: markdown or json.")
+	_ = cobraCmd.MarkFlagRequired("since")
+
+	return cobraCmd
+}
+
+type changelogCmd struct {
+	renderCmd
+
+	// Flags
+	since  string
+	until  string
+	format string
+}
+
+func (c *changelogCmd) run(cmd *cobra.Command, args []string) error {
+	if c.format != ChangelogFormatMarkdown && c.format != ChangelogFormatJSON {
+		return errors.Errorf("unknown --format %q, must be %s or %s", c.format, ChangelogFormatMarkdown, ChangelogFormatJSON)
+	}
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+
+	xrs, err := expandCompositeResourcePaths(c.fs, args[0])
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "crossbench-changelog-")
+	if err != nil {
+		return errors.Wrap(err, "cannot create directory for previous revisions")
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	before, err := checkoutFiles(filepath.Join(dir, "since"), c.since, args[1:])
+	if err != nil {
+		return err
+	}
+	after := args[1:]
+	if c.until != "" {
+		if after, err = checkoutFiles(filepath.Join(dir, "until"), c.until, args[1:]); err != nil {
+			return err
+		}
+	}
+
+	until := c.until
+	if until == "" {
+		until = "working tree"
+	}
+	cl := Changelog{Since: c.since, Until: until, XRs: make([]XRChangelog, 0, len(xrs))}
+	for _, xr := range xrs {
+		infof("Rendering composite resource %q at %s and %s", xr, c.since, until)
+		old, err := c.renderComposed(append([]string{xr}, before...))
+		if err != nil {
+			return errors.Wrapf(err, "cannot render %q at %s", xr, c.since)
+		}
+		cur, err := c.renderComposed(append([]string{xr}, after...))
+		if err != nil {
+			return errors.Wrapf(err, "cannot render %q at %s", xr, until)
+		}
+		xc := XRChangelog{XR: xr, Changes: []ResourceChange{}}
+		for _, ch := range DiffResources(cur, old, DiffOptions{}) {
+			if ch.Type != ChangeUnchanged {
+				xc.Changes = append(xc.Changes, ch)
+			}
+		}
+		cl.XRs = append(cl.XRs, xc)
+	}
+
+	if c.format == ChangelogFormatJSON {
+		data, err := json.MarshalIndent(cl, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal changelog")
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return cl.WriteMarkdown(os.Stdout)
+}
+
+// renderComposed renders the supplied arguments, and returns the composed
+// resources.
+func (c *changelogCmd) renderComposed(args []string) ([]unstructured.Unstructured, error) {
+	in, err := c.loadInputs(args)
+	if err != nil {
+		return nil, err
+	}
+	out, err := c.render(in)
+	if err != nil {
+		return nil, err
+	}
+	us := make([]unstructured.Unstructured, len(out.ComposedResources))
+	for i := range out.ComposedResources {
+		us[i] = out.ComposedResources[i].Unstructured
+	}
+	return us, nil
+}
+
+// checkoutFiles writes the supplied files, as they were at the supplied git
+// ref, to dir, and returns their paths there.
+func checkoutFiles(dir, ref string, files []string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create directory %q", dir)
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		rel := f
+		if filepath.IsAbs(f) {
+			wd, err := os.Getwd()
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot get working directory")
+			}
+			if rel, err = filepath.Rel(wd, f); err != nil {
+				return nil, errors.Wrapf(err, "cannot make %q relative to the working directory", f)
+			}
+		}
+		// A ./ prefix makes git resolve the path relative to the working
+		// directory instead of the root of the repository.
+		data, err := exec.Command("git", "show", ref+":./"+filepath.ToSlash(rel)).Output()
+		if err != nil {
+			if ee := (&exec.ExitError{}); errors.As(err, &ee) {
+				return nil, errors.Errorf("cannot read %q at git ref %q: %s", f, ref, strings.TrimSpace(string(ee.Stderr)))
+			}
+			return nil, errors.Wrapf(err, "cannot read %q at git ref %q", f, ref)
+		}
+		paths[i] = filepath.Join(dir, fmt.Sprintf("%d-%s", i, filepath.Base(f)))
+		if err := os.WriteFile(paths[i], data, 0644); err != nil {
+			return nil, errors.Wrapf(err, "cannot write %q", paths[i])
+		}
+	}
+	return paths, nil
+}
+
+// WriteMarkdown writes the changelog as Markdown release notes.
+func (cl Changelog) WriteMarkdown(w io.Writer) error {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "# Changes since %s\n", cl.Since)
+	for _, xc := range cl.XRs {
+		_, _ = fmt.Fprintf(b, "\n## %s\n\n", xc.XR)
+		if len(xc.Changes) == 0 {
+			b.WriteString("No changes.\n")
+			continue
+		}
+		for _, section := range []struct {
+			title string
+			t     ChangeType
+		}{
+			{"Added", ChangeAdded},
+			{"Removed", ChangeRemoved},
+			{"Changed", ChangeChanged},
+		} {
+			listed := false
+			for _, ch := range xc.Changes {
+				if ch.Type != section.t {
+					continue
+				}
+				if !listed {
+					_, _ = fmt.Fprintf(b, "%s:\n", section.title)
+					listed = true
+				}
+				_, _ = fmt.Fprintf(b, "- %s\n", ch.Resource)
+				for _, f := range ch.Fields {
+					switch {
+					case f.From == nil:
+						_, _ = fmt.Fprintf(b, "  - `%s` set to `%s`\n", f.Path, diffValue(f.To))
+					case f.To == nil:
+						_, _ = fmt.Fprintf(b, "  - `%s` removed (was `%s`)\n", f.Path, diffValue(f.From))
+					default:
+						_, _ = fmt.Fprintf(b, "  - `%s` changed from `%s` to `%s`\n", f.Path, diffValue(f.From), diffValue(f.To))
+					}
+				}
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
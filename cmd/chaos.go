@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+// Faults crossbench test --chaos injects into renders.
+const (
+	// ChaosFunctionTimeout fails every Function call with a deadline
+	// exceeded error, like a Function that timed out.
+	ChaosFunctionTimeout = "function-timeout"
+
+	// ChaosEmptyResponse answers every Function call with an empty
+	// response, so no desired state or context is returned.
+	ChaosEmptyResponse = "empty-response"
+
+	// ChaosMalformedObserved replaces the spec and status of every observed
+	// composed resource with a string. Only tests with observed resources
+	// are run with it.
+	ChaosMalformedObserved = "malformed-observed"
+)
+
+// chaosFaults are every fault crossbench test --chaos can inject.
+var chaosFaults = []string{ChaosFunctionTimeout, ChaosEmptyResponse, ChaosMalformedObserved}
+
+// A ChaosExpectation is how a test expects its composition to handle a fault.
+// Faults a test has no expectation for must make the render fail.
+type ChaosExpectation struct {
+	Fault string `json:"fault"`
+
+	// Error is text the error the render fails with must contain.
+	Error string `json:"error,omitempty"`
+
+	// Expect are the expectations the rendered output must meet, for
+	// compositions expected to render despite the fault.
+	Expect *TestExpectations `json:"expect,omitempty"`
+}
+
+// parseChaosFaults returns the faults named by the --chaos flag. all names
+// every fault.
+func parseChaosFaults(faults []string) ([]string, error) {
+	if slices.Contains(faults, "all") {
+		return chaosFaults, nil
+	}
+	for _, f := range faults {
+		if !slices.Contains(chaosFaults, f) {
+			return nil, errors.Errorf("unknown chaos fault %q, must be all or one of %s", f, strings.Join(chaosFaults, ", "))
+		}
+	}
+	return faults, nil
+}
+
+// chaosExpectation returns what the supplied test expects of a render with
+// the supplied fault injected.
+func (tc TestCase) chaosExpectation(fault string) ChaosExpectation {
+	for _, e := range tc.Chaos {
+		if e.Fault == fault {
+			return e
+		}
+	}
+	return ChaosExpectation{Fault: fault}
+}
+
+// checkChaos compares the result of a render with a fault injected with the
+// expectation of the fault, and returns a description of each way it isn't
+// met.
+func checkChaos(e ChaosExpectation, expected []map[string]any, out render.Outputs, err error) []string {
+	if e.Expect != nil {
+		if err != nil {
+			return []string{fmt.Sprintf("expected the render to succeed despite %s, got: %v", e.Fault, err)}
+		}
+		return CheckExpectations(*e.Expect, expected, out)
+	}
+	if err == nil {
+		return []string{fmt.Sprintf("expected %s to fail the render, but it rendered %d composed resources", e.Fault, len(out.ComposedResources))}
+	}
+	if !strings.Contains(err.Error(), e.Error) {
+		return []string{fmt.Sprintf("expected %s to fail the render with an error containing %q, got: %v", e.Fault, e.Error, err)}
+	}
+	return nil
+}
+
+// malformObserved replaces the spec and status of the supplied observed
+// composed resources with a string.
+func malformObserved(ors []composed.Unstructured) {
+	for i := range ors {
+		ors[i].Object["spec"] = "malformed"
+		ors[i].Object["status"] = "malformed"
+	}
+}
+
+// A chaosFunctionRunner injects a fault into every Function call.
+type chaosFunctionRunner struct {
+	wrapped FunctionRunner
+	fault   string
+}
+
+// RunFunction runs the named Function, unless the fault replaces the call.
+func (r *chaosFunctionRunner) RunFunction(ctx context.Context, name string, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	switch r.fault {
+	case ChaosFunctionTimeout:
+		return nil, status.Errorf(codes.DeadlineExceeded, "chaos: function %q timed out", name)
+	case ChaosEmptyResponse:
+		return &fnv1.RunFunctionResponse{}, nil
+	}
+	return r.wrapped.RunFunction(ctx, name, req)
+}
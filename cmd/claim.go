@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// Labels Crossplane adds to the XR of a Claim.
+const (
+	LabelKeyClaimName      = "crossplane.io/claim-name"
+	LabelKeyClaimNamespace = "crossplane.io/claim-namespace"
+)
+
+// claimOnlySpecFields are the Claim spec fields Crossplane doesn't propagate
+// to its XR.
+var claimOnlySpecFields = []string{"compositeDeletePolicy", "resourceRef", "writeConnectionSecretToRef"}
+
+// compositeForClaim returns the XR type of the supplied resource if it's a
+// Claim for the Composition's XR type: a namespaced resource in the same API
+// group, but of a different kind.
+func compositeForClaim(u *composite.Unstructured, comp *apiextensionsv1.Composition) (schema.GroupVersionKind, bool) {
+	xrGVK := schema.FromAPIVersionAndKind(comp.Spec.CompositeTypeRef.APIVersion, comp.Spec.CompositeTypeRef.Kind)
+	gvk := u.GroupVersionKind()
+	if u.GetNamespace() == "" || gvk.Group != xrGVK.Group || gvk.Kind == xrGVK.Kind {
+		return schema.GroupVersionKind{}, false
+	}
+	return xrGVK, true
+}
+
+// xrdCompositeForClaim returns the XR type of the supplied resource if one of
+// the XRDs offers it as a Claim.
+func xrdCompositeForClaim(u *composite.Unstructured, xrds []*unstructured.Unstructured) (schema.GroupVersionKind, bool) {
+	gvk := u.GroupVersionKind()
+	for _, xrd := range xrds {
+		p := fieldpath.Pave(xrd.Object)
+		group, _ := p.GetString("spec.group")
+		claimKind, _ := p.GetString("spec.claimNames.kind")
+		kind, _ := p.GetString("spec.names.kind")
+		if group != gvk.Group || claimKind == "" || claimKind != gvk.Kind {
+			continue
+		}
+
+		// Claims and their XRs share versions; use the Claim's.
+		return schema.GroupVersionKind{Group: group, Version: gvk.Version, Kind: kind}, true
+	}
+	return schema.GroupVersionKind{}, false
+}
+
+// claimToXR synthesizes the XR Crossplane creates for the supplied Claim. The
+// XR is named after the Claim with a suffix derived from the Claim's
+// namespace and name, so rendering is repeatable.
+func claimToXR(claim *composite.Unstructured, xrGVK schema.GroupVersionKind) (*composite.Unstructured, error) {
+	xr := composite.New(composite.WithSchema(composite.SchemaLegacy))
+	xr.SetGroupVersionKind(xrGVK)
+
+	sum := sha256.Sum256([]byte(claim.GetNamespace() + "/" + claim.GetName()))
+	xr.SetName(claim.GetName() + "-" + hex.EncodeToString(sum[:])[:5])
+
+	xr.SetLabels(claim.GetLabels())
+	meta.AddLabels(xr, map[string]string{
+		LabelKeyClaimName:      claim.GetName(),
+		LabelKeyClaimNamespace: claim.GetNamespace(),
+	})
+	annotations := claim.GetAnnotations()
+	delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+	xr.SetAnnotations(annotations)
+
+	p := fieldpath.Pave(xr.Object)
+	if spec, ok := claim.Object["spec"].(map[string]any); ok {
+		spec = runtime.DeepCopyJSON(spec)
+		for _, f := range claimOnlySpecFields {
+			delete(spec, f)
+		}
+		if err := p.SetValue("spec", spec); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.SetValue("spec.claimRef", map[string]any{
+		"apiVersion": claim.GetAPIVersion(),
+		"kind":       claim.GetKind(),
+		"namespace":  claim.GetNamespace(),
+		"name":       claim.GetName(),
+	}); err != nil {
+		return nil, err
+	}
+
+	return xr, nil
+}
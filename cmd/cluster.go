@@ -0,0 +1,362 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/structpb"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+// clusterFlags are the flags used to connect to a Kubernetes cluster.
+type clusterFlags struct {
+	kubeconfig  string
+	kubecontext string
+}
+
+// addClusterFlags registers the flags used to connect to a cluster.
+func (f *clusterFlags) addClusterFlags(cobraCmd *cobra.Command) {
+	cobraCmd.Flags().StringVar(&f.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use. Defaults to the KUBECONFIG environment variable or ~/.kube/config.")
+	cobraCmd.Flags().StringVar(&f.kubecontext, "kube-context", "", "The kubeconfig context to use. Defaults to the current context.")
+}
+
+// clusterClient reads and writes arbitrary resources in a cluster.
+type clusterClient struct {
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+}
+
+// clientConfig returns the kubeconfig selected by the flags, and by the
+// kubectl flags when running as a kubectl plugin.
+func (f *clusterFlags) clientConfig() clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = f.kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: f.kubecontext}
+	if overrides.CurrentContext == "" {
+		overrides.CurrentContext = kubectlContext()
+	}
+	overrides.Context.Namespace = kubectlNamespace()
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+}
+
+// namespace returns the namespace of the kubeconfig context selected by the
+// flags, or the one set by --namespace when running as a kubectl plugin.
+func (f *clusterFlags) namespace() (string, error) {
+	ns, _, err := f.clientConfig().Namespace()
+	return ns, errors.Wrap(err, "cannot load kubeconfig")
+}
+
+// newClusterClient returns a client for the cluster selected by the flags.
+func (f *clusterFlags) newClusterClient() (*clusterClient, error) {
+	cfg, err := f.clientConfig().ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load kubeconfig")
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create discovery client")
+	}
+
+	d, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create dynamic client")
+	}
+
+	return &clusterClient{
+		dynamic: d,
+		mapper:  restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc)),
+	}, nil
+}
+
+// resourceFor returns a dynamic client for resources of the supplied kind in
+// the supplied namespace. The namespace is ignored for cluster scoped kinds.
+func (c *clusterClient) resourceFor(gvk schema.GroupVersionKind, namespace string) (dynamic.ResourceInterface, error) {
+	m, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot find resource for %s", gvk)
+	}
+	if m.Scope.Name() == meta.RESTScopeNameNamespace {
+		return c.dynamic.Resource(m.Resource).Namespace(namespace), nil
+	}
+	return c.dynamic.Resource(m.Resource), nil
+}
+
+// get returns the named resource, or nil if it doesn't exist.
+func (c *clusterClient) get(ctx context.Context, gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	ri, err := c.resourceFor(gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+	u, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return u, errors.Wrapf(err, "cannot get %s %q", gvk.Kind, name)
+}
+
+// composedResources returns the resources composed by the supplied XR, as
+// recorded in the resource references of the XR in the cluster. It returns
+// the XR as found in the cluster, which is nil if it doesn't exist.
+func (c *clusterClient) composedResources(ctx context.Context, xr *unstructured.Unstructured) (*unstructured.Unstructured, []unstructured.Unstructured, error) {
+	live, err := c.get(ctx, xr.GroupVersionKind(), xr.GetNamespace(), xr.GetName())
+	if err != nil || live == nil {
+		return nil, nil, err
+	}
+
+	refs := []any{}
+	// Crossplane v2 XRs keep resource references under spec.crossplane,
+	// legacy XRs directly under spec.
+	for _, path := range []string{"spec.crossplane.resourceRefs", "spec.resourceRefs"} {
+		if v, err := fieldpath.Pave(live.Object).GetValue(path); err == nil {
+			if l, ok := v.([]any); ok {
+				refs = l
+				break
+			}
+		}
+	}
+
+	cds := []unstructured.Unstructured{}
+	for _, r := range refs {
+		ref, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		apiVersion, _ := ref["apiVersion"].(string)
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+		namespace, _ := ref["namespace"].(string)
+		if namespace == "" {
+			namespace = xr.GetNamespace()
+		}
+
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot parse resource reference apiVersion %q", apiVersion)
+		}
+		cd, err := c.get(ctx, gv.WithKind(kind), namespace, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if cd != nil {
+			cds = append(cds, *cd)
+		}
+	}
+
+	return live, cds, nil
+}
+
+// list returns the resources of the supplied kind in the supplied namespace,
+// or in every namespace if it's empty, that match the supplied labels.
+func (c *clusterClient) list(ctx context.Context, gvk schema.GroupVersionKind, namespace string, matchLabels map[string]string) ([]unstructured.Unstructured, error) {
+	ri, err := c.resourceFor(gvk, namespace)
+	if err != nil {
+		return nil, err
+	}
+	l, err := ri.List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(matchLabels).String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list %s", gvk.Kind)
+	}
+	return l.Items, nil
+}
+
+// kindFor returns the kind of the supplied resource type, e.g.
+// xbuckets.example.crossplane.io, xbucket or xbuckets.v1.example.crossplane.io.
+func (c *clusterClient) kindFor(resource string) (schema.GroupVersionKind, error) {
+	gvr, gr := schema.ParseResourceArg(strings.ToLower(resource))
+	if gvr != nil {
+		if gvk, err := c.mapper.KindFor(*gvr); err == nil {
+			return gvk, nil
+		}
+	}
+	gvk, err := c.mapper.KindFor(gr.WithVersion(""))
+	return gvk, errors.Wrapf(err, "cannot find resource type %q", resource)
+}
+
+// apply server-side applies the supplied resource, or creates it if it only
+// has a generateName. With dryRun the API server runs admission but doesn't
+// persist the resource.
+func (c *clusterClient) apply(ctx context.Context, u *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	ri, err := c.resourceFor(u.GroupVersionKind(), u.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	var opts []string
+	if dryRun {
+		opts = []string{metav1.DryRunAll}
+	}
+	if u.GetName() == "" {
+		return ri.Create(ctx, u, metav1.CreateOptions{DryRun: opts, FieldManager: applyFieldManager})
+	}
+	return ri.Apply(ctx, u.GetName(), u, metav1.ApplyOptions{DryRun: opts, FieldManager: applyFieldManager, Force: true})
+}
+
+// A clusterFetcher fetches the resources Functions require from a cluster,
+// when the resources supplied locally hold none matching. Each selector is
+// only fetched once per render.
+type clusterFetcher struct {
+	local   resourceFetcher
+	cluster *clusterClient
+
+	mu      sync.Mutex
+	fetched map[string]*fnv1.Resources
+}
+
+// newClusterFetcher returns a fetcher falling back to the supplied cluster.
+func newClusterFetcher(local resourceFetcher, cluster *clusterClient) *clusterFetcher {
+	return &clusterFetcher{local: local, cluster: cluster, fetched: map[string]*fnv1.Resources{}}
+}
+
+// Fetch returns the local resources matching the supplied selector, or the
+// matching resources in the cluster if there are none.
+func (f *clusterFetcher) Fetch(ctx context.Context, rs *fnv1.ResourceSelector) (*fnv1.Resources, error) {
+	out, err := f.local.Fetch(ctx, rs)
+	if err != nil || rs == nil || len(out.GetItems()) > 0 {
+		return out, err
+	}
+
+	key := fmt.Sprint(rs.GetApiVersion(), rs.GetKind(), rs.GetNamespace(), rs.GetMatchName(), rs.GetMatchLabels().GetLabels())
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if out, ok := f.fetched[key]; ok {
+		return out, nil
+	}
+
+	gvk := schema.FromAPIVersionAndKind(rs.GetApiVersion(), rs.GetKind())
+	var us []unstructured.Unstructured
+	if name := rs.GetMatchName(); name != "" {
+		u, err := f.cluster.get(ctx, gvk, rs.GetNamespace(), name)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil {
+			us = append(us, *u)
+		}
+	} else if us, err = f.cluster.list(ctx, gvk, rs.GetNamespace(), rs.GetMatchLabels().GetLabels()); err != nil {
+		return nil, err
+	}
+
+	out = &fnv1.Resources{}
+	for i := range us {
+		s, err := structpb.NewStruct(us[i].Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot convert %s %q", gvk.Kind, us[i].GetName())
+		}
+		out.Items = append(out.Items, &fnv1.Resource{Resource: s})
+	}
+	infof("Fetched %d %s resource(s) required by a function from the cluster", len(out.Items), gvk.Kind)
+	f.fetched[key] = out
+	return out, nil
+}
+
+// clusterObjectRef returns the type and name of the resource in the cluster
+// the supplied argument names as <type>/<name>, e.g.
+// xbuckets.example.crossplane.io/my-bucket or composition/my-composition,
+// rather than a file. Arguments that exist, or have a YAML or package
+// extension, are files.
+func clusterObjectRef(fs afero.Fs, arg string) (resource, name string, ok bool) {
+	switch strings.ToLower(filepath.Ext(arg)) {
+	case ".yaml", ".yml", ".json", ".xpkg":
+		return "", "", false
+	}
+	if ok, _ := afero.Exists(fs, arg); ok {
+		return "", "", false
+	}
+	resource, name, ok = strings.Cut(arg, "/")
+	if !ok || resource == "" || name == "" || strings.ContainsAny(arg, "*?[") || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return resource, name, true
+}
+
+// fetchObject returns the named resource of the supplied type from the
+// cluster selected by the cluster flags, without its managed fields.
+// Namespaced resources are read from the namespace of the kubeconfig context.
+func (c *renderCmd) fetchObject(ctx context.Context, resource, name string) (*unstructured.Unstructured, error) {
+	if c.networkDisabled() {
+		return nil, errors.Errorf("%s/%s can't be read from the cluster with --no-network or --offline", resource, name)
+	}
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	ns, err := c.namespace()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	gvk, err := cc.kindFor(resource)
+	if err != nil {
+		return nil, err
+	}
+	u, err := cc.get(ctx, gvk, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errors.Errorf("%s %q doesn't exist in the cluster", gvk.Kind, name)
+	}
+	u.SetManagedFields(nil)
+	return u, nil
+}
+
+// fetchObservedResources returns the resources the supplied XR composed in the
+// cluster selected by the cluster flags, to be passed to the pipeline as its
+// observed resources. Resources without a composition resource name can't be
+// matched to the resources the pipeline desires, so they're left out.
+func (c *renderCmd) fetchObservedResources(ctx context.Context, xr *composite.Unstructured) ([]composed.Unstructured, error) {
+	if c.networkDisabled() {
+		return nil, errors.New("--observed-from-cluster can't be used with --no-network or --offline")
+	}
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	live, cds, err := cc.composedResources(ctx, &xr.Unstructured)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get composed resources from cluster")
+	}
+	if live == nil {
+		infof("Composite resource %q doesn't exist in the cluster, rendering without observed resources", xr.GetName())
+		return []composed.Unstructured{}, nil
+	}
+
+	ors := make([]composed.Unstructured, 0, len(cds))
+	for i := range cds {
+		if cds[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName] == "" {
+			warnf("%s %q composed by %q has no %s annotation, leaving it out of the observed resources", cds[i].GetKind(), cds[i].GetName(), xr.GetName(), render.AnnotationKeyCompositionResourceName)
+			continue
+		}
+		ors = append(ors, composed.Unstructured{Unstructured: cds[i]})
+	}
+	infof("Observed %d resource(s) composed by %q in the cluster", len(ors), xr.GetName())
+	return ors, nil
+}
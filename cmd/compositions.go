@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/load"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// loadComposition loads a Composition, or a CompositionRevision converted to
+// the Composition it's a revision of, from the supplied file. The file may be
+// a YAML stream holding other resources too, such as the Composition's XRD,
+// but only one Composition.
+func loadComposition(fs afero.Fs, file string) (*apiextensionsv1.Composition, error) {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read composition file")
+	}
+	comps, others, err := compositionsFromYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(comps) == 1:
+		return comps[0], nil
+	case len(comps) > 1:
+		return nil, errors.Errorf("found %d Compositions, expected one: use --compositions-dir to select one of several", len(comps))
+	case len(others) > 0:
+		return nil, errors.Errorf("not a composition: %s", strings.Join(others, ", "))
+	}
+	return nil, errors.New("no Composition found")
+}
+
+// loadCompositeResource loads the composite resource (or Claim) in the
+// supplied file. Like crossplane render, only the first resource is used, but
+// empty documents - e.g. a leading --- or comments - are skipped.
+func loadCompositeResource(fs afero.Fs, file string) (*composite.Unstructured, error) {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read composite resource file")
+	}
+	docs, err := load.YamlStream(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot split YAML stream")
+	}
+	for _, doc := range docs {
+		xr := composite.New()
+		if err := yaml.Unmarshal(doc, xr); err != nil {
+			return nil, errors.Wrap(err, "cannot unmarshal composite resource YAML")
+		}
+		if len(xr.Object) > 0 {
+			return xr, nil
+		}
+	}
+	return render.LoadCompositeResource(fs, file)
+}
+
+// compositionsFromYAML returns the Compositions in the supplied YAML stream,
+// converting CompositionRevisions, in the order they appear. It also returns
+// the kind and name of the other resources in the stream. Anchors, aliases and
+// merge keys are resolved within each document.
+func compositionsFromYAML(data []byte) (comps []*apiextensionsv1.Composition, others []string, err error) {
+	docs, err := load.YamlStream(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot split YAML stream")
+	}
+	for _, doc := range docs {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return nil, nil, errors.Wrap(err, "cannot unmarshal composition YAML")
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		if doc, err = convertLegacyDocument(doc, u); err != nil {
+			return nil, nil, err
+		}
+		switch u.GroupVersionKind() {
+		case apiextensionsv1.CompositionGroupVersionKind:
+			comp := &apiextensionsv1.Composition{}
+			if err := yaml.Unmarshal(doc, comp); err != nil {
+				return nil, nil, errors.Wrap(err, "cannot unmarshal Composition YAML")
+			}
+			comps = append(comps, comp)
+		case apiextensionsv1.CompositionRevisionGroupVersionKind:
+			comp, err := compositionFromRevision(doc)
+			if err != nil {
+				return nil, nil, err
+			}
+			comps = append(comps, comp)
+		default:
+			others = append(others, u.GetKind()+"/"+u.GetName())
+		}
+	}
+	return comps, others, nil
+}
+
+// compositionFromRevision converts a CompositionRevision manifest to the
+// Composition it's a revision of. The Composition is named after the
+// revision's crossplane.io/composition-name label, if set, and keeps the
+// revision's labels so selectors still match.
+func compositionFromRevision(data []byte) (*apiextensionsv1.Composition, error) {
+	rev := &apiextensionsv1.CompositionRevision{}
+	if err := yaml.Unmarshal(data, rev); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal CompositionRevision YAML")
+	}
+
+	// A CompositionRevision's spec is a Composition's spec plus its revision
+	// number, so it converts field for field.
+	spec, err := json.Marshal(rev.Spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal CompositionRevision spec")
+	}
+	comp := &apiextensionsv1.Composition{}
+	if err := json.Unmarshal(spec, &comp.Spec); err != nil {
+		return nil, errors.Wrap(err, "cannot convert CompositionRevision spec")
+	}
+
+	comp.SetGroupVersionKind(apiextensionsv1.CompositionGroupVersionKind)
+	comp.SetName(rev.GetName())
+	if name := rev.GetLabels()[apiextensionsv1.LabelCompositionName]; name != "" {
+		comp.SetName(name)
+	}
+	comp.SetLabels(rev.GetLabels())
+	comp.SetAnnotations(rev.GetAnnotations())
+	return comp, nil
+}
+
+// loadCompositions loads every Composition in the YAML files of the supplied
+// directory, converting CompositionRevisions. Other resources in the files are
+// ignored.
+func loadCompositions(fs afero.Fs, dir string) ([]*apiextensionsv1.Composition, error) {
+	files, err := compositionFiles(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	comps := []*apiextensionsv1.Composition{}
+	for _, f := range files {
+		data, err := afero.ReadFile(fs, f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %q", f)
+		}
+		cs, _, err := compositionsFromYAML(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %q", f)
+		}
+		comps = append(comps, cs...)
+	}
+	return comps, nil
+}
+
+// compositionFiles returns the YAML files of the supplied directory of
+// Compositions, sorted.
+func compositionFiles(fs afero.Fs, dir string) ([]string, error) {
+	files := []string{}
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := afero.Glob(fs, filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot list compositions in %q", dir)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// compositionFile returns the file of the supplied directory of Compositions
+// the named Composition is in, or "" if it isn't in any.
+func compositionFile(fs afero.Fs, dir, name string) string {
+	files, _ := compositionFiles(fs, dir)
+	for _, f := range files {
+		data, err := afero.ReadFile(fs, f)
+		if err != nil {
+			continue
+		}
+		cs, _, _ := compositionsFromYAML(data)
+		for _, c := range cs {
+			if c.GetName() == name {
+				return f
+			}
+		}
+	}
+	return ""
+}
+
+// loadCompositionPaths loads the Compositions in the supplied files and
+// directories.
+func loadCompositionPaths(fs afero.Fs, paths []string) ([]*apiextensionsv1.Composition, error) {
+	comps := []*apiextensionsv1.Composition{}
+	for _, p := range paths {
+		if ok, _ := afero.IsDir(fs, p); ok {
+			cs, err := loadCompositions(fs, p)
+			if err != nil {
+				return nil, err
+			}
+			comps = append(comps, cs...)
+			continue
+		}
+		comp, err := loadComposition(fs, p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot load Composition from %q", p)
+		}
+		comps = append(comps, comp)
+	}
+	return comps, nil
+}
+
+// compositionsFor returns the Compositions whose compositeTypeRef is the
+// supplied XR's kind.
+func compositionsFor(xr *composite.Unstructured, comps []*apiextensionsv1.Composition) []*apiextensionsv1.Composition {
+	gvk := xr.GroupVersionKind()
+	candidates := []*apiextensionsv1.Composition{}
+	for _, comp := range comps {
+		if comp.Spec.CompositeTypeRef.APIVersion == gvk.GroupVersion().String() && comp.Spec.CompositeTypeRef.Kind == gvk.Kind {
+			candidates = append(candidates, comp)
+		}
+	}
+	return candidates
+}
+
+// selectComposition selects the Composition for the supplied XR the way
+// Crossplane does. The XR's composition reference is honored first, then its
+// composition selector merged with the supplied selector. Otherwise exactly
+// one Composition must be for the XR's type. The source describes where the
+// Compositions came from in errors.
+func selectComposition(xr *composite.Unstructured, comps []*apiextensionsv1.Composition, selector map[string]string, source string) (*apiextensionsv1.Composition, error) {
+	gvk := xr.GroupVersionKind()
+	candidates := compositionsFor(xr, comps)
+
+	if ref := xr.GetCompositionReference(); ref != nil {
+		for _, comp := range candidates {
+			if comp.GetName() == ref.Name {
+				return comp, nil
+			}
+		}
+		return nil, errors.Errorf("%s has no Composition %q for %s", source, ref.Name, gvk.Kind)
+	}
+
+	matchLabels := map[string]string{}
+	if sel := xr.GetCompositionSelector(); sel != nil {
+		for k, v := range sel.MatchLabels {
+			matchLabels[k] = v
+		}
+	}
+	for k, v := range selector {
+		matchLabels[k] = v
+	}
+	if len(matchLabels) > 0 {
+		selected := []*apiextensionsv1.Composition{}
+		for _, comp := range candidates {
+			if labels.SelectorFromSet(matchLabels).Matches(labels.Set(comp.GetLabels())) {
+				selected = append(selected, comp)
+			}
+		}
+		candidates = selected
+	}
+
+	switch len(candidates) {
+	case 0:
+		if len(matchLabels) > 0 {
+			return nil, errors.Errorf("%s has no Composition for %s matching labels %s", source, gvk.Kind, labels.Set(matchLabels))
+		}
+		return nil, errors.Errorf("%s has no Composition for %s", source, gvk.Kind)
+	case 1:
+		return candidates[0], nil
+	default:
+		names := make([]string, len(candidates))
+		for i, comp := range candidates {
+			names[i] = comp.GetName()
+		}
+		return nil, errors.Errorf("%s has %d Compositions for %s (%s): set the XR's compositionRef or compositionSelector, or use --composition-selector", source, len(candidates), gvk.Kind, strings.Join(names, ", "))
+	}
+}
+
+// compositeForClaimIn returns the XR type of the supplied resource if it's a
+// Claim for the XR type of any of the Compositions.
+func compositeForClaimIn(u *composite.Unstructured, comps []*apiextensionsv1.Composition) (schema.GroupVersionKind, bool) {
+	for _, comp := range comps {
+		if gvk, ok := compositeForClaim(u, comp); ok {
+			return gvk, true
+		}
+	}
+	return schema.GroupVersionKind{}, false
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompositionsFromYAML(t *testing.T) {
+	expanded := `apiVersion: apiextensions.crossplane.io/v1
+kind: CompositeResourceDefinition
+metadata:
+  name: xbuckets.example.crossplane.io
+---
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: bucket-composition
+  labels:
+    provider: aws
+spec:
+  compositeTypeRef:
+    apiVersion: example.crossplane.io/v1
+    kind: XBucket
+  mode: Pipeline
+  pipeline:
+  - step: configure-bucket
+    functionRef:
+      name: function-patch-and-transform
+    input:
+      apiVersion: pt.fn.crossplane.io/v1beta1
+      kind: Resources
+      resources:
+      - name: primary
+        base:
+          apiVersion: s3.aws.upbound.io/v1beta1
+          kind: Bucket
+          spec:
+            forProvider:
+              region: us-east-2
+              forceDestroy: false
+      - name: replica
+        base:
+          apiVersion: s3.aws.upbound.io/v1beta1
+          kind: Bucket
+          spec:
+            forProvider:
+              region: us-west-2
+              forceDestroy: false
+  - step: ready
+    functionRef:
+      name: function-auto-ready
+`
+
+	// The same stream, with the repeated parts written once.
+	anchored := `apiVersion: apiextensions.crossplane.io/v1
+kind: CompositeResourceDefinition
+metadata:
+  name: xbuckets.example.crossplane.io
+---
+apiVersion: apiextensions.crossplane.io/v1
+kind: Composition
+metadata:
+  name: bucket-composition
+  labels:
+    provider: aws
+spec:
+  compositeTypeRef:
+    apiVersion: example.crossplane.io/v1
+    kind: XBucket
+  mode: Pipeline
+  pipeline:
+  - step: configure-bucket
+    functionRef:
+      name: function-patch-and-transform
+    input:
+      apiVersion: pt.fn.crossplane.io/v1beta1
+      kind: Resources
+      resources:
+      - name: primary
+        base:
+          apiVersion: &apiVersion s3.aws.upbound.io/v1beta1
+          kind: &kind Bucket
+          spec:
+            forProvider: &forProvider
+              region: us-east-2
+              forceDestroy: false
+      - name: replica
+        base:
+          apiVersion: *apiVersion
+          kind: *kind
+          spec:
+            forProvider:
+              <<: *forProvider
+              region: us-west-2
+  - step: ready
+    functionRef:
+      name: function-auto-ready
+`
+
+	bomb := "a: &a [lol, lol, lol, lol, lol, lol, lol, lol, lol]\n"
+	prev := "a"
+	for _, n := range []string{"b", "c", "d", "e", "f", "g", "h", "i"} {
+		bomb += n + ": &" + n + " [" + strings.TrimSuffix(strings.Repeat("*"+prev+", ", 9), ", ") + "]\n"
+		prev = n
+	}
+
+	type want struct {
+		yaml   string
+		others []string
+		err    string
+	}
+
+	cases := map[string]struct {
+		reason string
+		yaml   string
+		want   want
+	}{
+		"AnchorsAliasesAndMergeKeys": {
+			reason: "Anchors, aliases and merge keys should be resolved as if the values were written out.",
+			yaml:   anchored,
+			want: want{
+				yaml:   expanded,
+				others: []string{"CompositeResourceDefinition/xbuckets.example.crossplane.io"},
+			},
+		},
+		"AnchorInOtherDocument": {
+			reason: "Anchors shouldn't be shared between the documents of a stream.",
+			yaml:   "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: &name c}\n---\n" + anchored + "---\napiVersion: v1\nkind: ConfigMap\nmetadata: {name: *name}\n",
+			want: want{
+				err: "unknown anchor 'name'",
+			},
+		},
+		"AliasCycle": {
+			reason: "An alias to the node it's in should be rejected.",
+			yaml:   "a: &a\n  b: *a\n",
+			want: want{
+				err: "anchor 'a' value contains itself",
+			},
+		},
+		"AliasBomb": {
+			reason: "Aliases expanding to an excessively large document should be rejected.",
+			yaml:   bomb,
+			want: want{
+				err: "excessive aliasing",
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			comps, others, err := compositionsFromYAML([]byte(tc.yaml))
+			if tc.want.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.want.err) {
+					t.Errorf("\n%s\ncompositionsFromYAML(...): want error containing %q, got %v", tc.reason, tc.want.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("\n%s\ncompositionsFromYAML(...): %v", tc.reason, err)
+			}
+			want, _, err := compositionsFromYAML([]byte(tc.want.yaml))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(want, comps); diff != "" {
+				t.Errorf("\n%s\ncompositionsFromYAML(...): -want, +got:\n%s", tc.reason, diff)
+			}
+			if diff := cmp.Diff(tc.want.others, others); diff != "" {
+				t.Errorf("\n%s\ncompositionsFromYAML(...): -want others, +got others:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
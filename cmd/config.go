@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// Config is the optional crossbench configuration file. It holds settings
+// that are too structured to be passed as flags or environment variables.
+type Config struct {
+	// Naming rules evaluated against the names of rendered resources.
+	Naming []NamingRule `json:"naming,omitempty"`
+
+	// EnvironmentLiterals rules evaluated against the inputs of the
+	// Composition's Function pipeline.
+	EnvironmentLiterals []EnvironmentLiteralRule `json:"environmentLiterals,omitempty"`
+
+	// Rules are custom checks written as CEL expressions.
+	Rules []LintRule `json:"rules,omitempty"`
+
+	// Functions overrides how individual Functions are run, keyed by Function
+	// name.
+	Functions map[string]FunctionConfig `json:"functions,omitempty"`
+
+	// GitHub configures access to the GitHub API, used to resolve Function
+	// versions.
+	GitHub GitHubConfig `json:"github,omitempty"`
+
+	// GRPC tunes the gRPC connections to Functions.
+	GRPC GRPCConfig `json:"grpc,omitempty"`
+
+	// Presets are named renders, run with crossbench render --preset.
+	Presets map[string]RenderPreset `json:"presets,omitempty"`
+
+	// OutputTargets configure how files are laid out in output directories,
+	// keyed by the --output-dir they apply to.
+	OutputTargets map[string]OutputTarget `json:"outputTargets,omitempty"`
+
+	// Siblings configure how the inputs of an XR passed on its own are found
+	// next to it.
+	Siblings SiblingsConfig `json:"siblings,omitempty"`
+
+	// ContextSchemas declare the context keys that may be passed to the
+	// Function pipeline, with the OpenAPI v3 schema of their values. Keys
+	// aren't checked unless some are declared, here or by a Composition.
+	ContextSchemas map[string]extv1.JSONSchemaProps `json:"contextSchemas,omitempty"`
+}
+
+// GitHubConfig configures access to the GitHub API.
+type GitHubConfig struct {
+	// Token used when the auth mode allows tokens from the configuration
+	// file. Environment variables and --github-token take precedence.
+	Token string `json:"token,omitempty"`
+
+	// Hosts are GitHub hosts, such as GitHub Enterprise Server, that serve
+	// some function repositories instead of the default GitHub API. The first
+	// host matching a repository is used.
+	Hosts []GitHubHost `json:"hosts,omitempty"`
+}
+
+// A GitHubHost serves the function repositories matching one of its
+// patterns.
+type GitHubHost struct {
+	// Repositories are owner/repository glob patterns, e.g. acme/function-*,
+	// or owners, e.g. acme, whose repositories are all served by the host.
+	Repositories []string `json:"repositories"`
+
+	// APIURL is the base URL of the host's API, e.g.
+	// https://github.internal.corp/api/v3.
+	APIURL string `json:"apiURL"`
+
+	// TokenEnv names the environment variable holding the host's token.
+	TokenEnv string `json:"tokenEnv,omitempty"`
+
+	// Token for the host, used if TokenEnv isn't set.
+	Token string `json:"token,omitempty"`
+}
+
+// FunctionConfig overrides how a Function is run.
+type FunctionConfig struct {
+	// Image is a locally built image to run instead of the Function's
+	// package. It's never pulled.
+	Image string `json:"image,omitempty"`
+
+	// Source is the path of a local Go module implementing the Function,
+	// relative to the configuration file. It's built and run as a subprocess
+	// whenever the Function is needed, and takes precedence over Image.
+	Source string `json:"source,omitempty"`
+
+	// Resolver overrides how the Function's latest version is resolved. One
+	// of auto, github, registry or marketplace.
+	Resolver string `json:"resolver,omitempty"`
+
+	// Version is a semver constraint, e.g. ">=0.8 <0.10". The latest
+	// version satisfying it is used instead of the latest version.
+	Version string `json:"version,omitempty"`
+}
+
+// getConfigPath returns the path of the crossbench configuration file
+// Default: .crossbench.yaml, configurable via CROSSBENCH_CONFIG env var
+func getConfigPath() string {
+	if path := os.Getenv("CROSSBENCH_CONFIG"); path != "" {
+		return path
+	}
+	return ".crossbench.yaml"
+}
+
+// loadConfig loads the configuration file at the supplied path. A missing
+// file is only an error if the path was set explicitly; otherwise an empty
+// configuration is returned.
+func loadConfig(fs afero.Fs, path string, explicit bool) (*Config, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return &Config{}, nil
+		}
+		return nil, errors.Wrapf(err, "cannot read config file %q", path)
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse config file %q", path)
+	}
+	for name, fc := range cfg.Functions {
+		if fc.Source != "" && !filepath.IsAbs(fc.Source) {
+			fc.Source = filepath.Join(filepath.Dir(path), fc.Source)
+			cfg.Functions[name] = fc
+		}
+	}
+	for name, p := range cfg.Presets {
+		p.resolvePaths(filepath.Dir(path))
+		cfg.Presets[name] = p
+	}
+	targets := make(map[string]OutputTarget, len(cfg.OutputTargets))
+	for dir, t := range cfg.OutputTargets {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(filepath.Dir(path), dir)
+		}
+		targets[dir] = t
+	}
+	cfg.OutputTargets = targets
+	return cfg, nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// AnnotationKeyContextSchemas declares the context keys a Composition's
+// pipeline expects, as a YAML or JSON map of each key to the OpenAPI v3 schema
+// of its value, e.g. {"apiextensions.crossplane.io/environment": {"type":
+// "object"}}. An empty schema accepts any value.
+const AnnotationKeyContextSchemas = "crossbench.io/context-schemas"
+
+// contextSchemas returns the context keys declared by the supplied
+// configuration and the Composition's context schemas annotation, with the
+// schemas of their values. The annotation takes precedence over the
+// configuration for keys both declare.
+func contextSchemas(cfg map[string]extv1.JSONSchemaProps, comp *apiextensionsv1.Composition) (map[string]extv1.JSONSchemaProps, error) {
+	schemas := make(map[string]extv1.JSONSchemaProps, len(cfg))
+	for k, s := range cfg {
+		schemas[k] = s
+	}
+	v, ok := comp.GetAnnotations()[AnnotationKeyContextSchemas]
+	if !ok {
+		return schemas, nil
+	}
+	declared := map[string]extv1.JSONSchemaProps{}
+	if err := yaml.Unmarshal([]byte(v), &declared); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse the %s annotation of Composition %q", AnnotationKeyContextSchemas, comp.GetName())
+	}
+	for k, s := range declared {
+		schemas[k] = s
+	}
+	return schemas, nil
+}
+
+// checkContext returns an error listing every supplied context value whose
+// key isn't declared by the supplied schemas, that isn't JSON, or that doesn't
+// match the schema of its key. Nothing is checked if no schemas are declared.
+func checkContext(schemas map[string]extv1.JSONSchemaProps, fctx map[string][]byte) error {
+	if len(schemas) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(fctx))
+	for k := range fctx {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	problems := []string{}
+	for _, k := range keys {
+		s, ok := schemas[k]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown context key, must be one of %s", k, strings.Join(declaredContextKeys(schemas), ", ")))
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(fctx[k], &v); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: value isn't JSON: %v", k, err))
+			continue
+		}
+		errs, err := validateContextValue(k, s, v)
+		if err != nil {
+			return err
+		}
+		for _, e := range errs {
+			problems = append(problems, e.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("invalid context:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validateContextValue validates the supplied value of the supplied context
+// key against the supplied schema.
+func validateContextValue(key string, s extv1.JSONSchemaProps, v any) (field.ErrorList, error) {
+	internal := &apiextensions.JSONSchemaProps{}
+	if err := extv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&s, internal, nil); err != nil {
+		return nil, errors.Wrapf(err, "cannot convert schema of context key %q", key)
+	}
+	validator, _, err := validation.NewSchemaValidator(internal)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schema of context key %q", key)
+	}
+	return validation.ValidateCustomResource(field.NewPath(key), v, validator), nil
+}
+
+// declaredContextKeys returns the keys of the supplied context schemas,
+// sorted.
+func declaredContextKeys(schemas map[string]extv1.JSONSchemaProps) []string {
+	keys := make([]string, 0, len(schemas))
+	for k := range schemas {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
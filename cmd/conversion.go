@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// legacyAPIVersions are the versions Compositions, CompositionRevisions and
+// XRDs were served at before v1. Their schemas are the same as v1's, so they
+// convert by changing the apiVersion.
+var legacyAPIVersions = map[string]bool{
+	"v1alpha1": true,
+	"v1beta1":  true,
+}
+
+// convertLegacyAPIVersion converts a Composition, CompositionRevision or XRD
+// at a pre-v1 API version to v1, with a warning, so older repositories can be
+// rendered during their migration. It returns whether it converted u.
+func convertLegacyAPIVersion(u *unstructured.Unstructured) bool {
+	gvk := u.GroupVersionKind()
+	if gvk.Group != apiextensionsv1.Group || !legacyAPIVersions[gvk.Version] {
+		return false
+	}
+	switch gvk.Kind {
+	case apiextensionsv1.CompositionKind, apiextensionsv1.CompositionRevisionKind, apiextensionsv1.CompositeResourceDefinitionKind:
+	default:
+		return false
+	}
+	u.SetAPIVersion(apiextensionsv1.SchemeGroupVersion.String())
+	warnf("Converted %s %q from %s to %s; update its apiVersion, %s is no longer served", gvk.Kind, u.GetName(), gvk.GroupVersion(), u.GetAPIVersion(), gvk.Version)
+	return true
+}
+
+// convertLegacyDocument converts the supplied YAML document with
+// convertLegacyAPIVersion, returning it as JSON if it was converted.
+func convertLegacyDocument(doc []byte, u *unstructured.Unstructured) ([]byte, error) {
+	if !convertLegacyAPIVersion(u) {
+		return doc, nil
+	}
+	j, err := json.Marshal(u.Object)
+	return j, errors.Wrap(err, "cannot marshal converted resource")
+}
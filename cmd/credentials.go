@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+)
+
+// AnnotationKeyRequiredCredentials declares, comma-separated, the names of
+// the credentials a Function expects in its RunFunctionRequest. It's read from
+// the Function manifests, since function packages have no standard way to
+// declare credential requirements.
+const AnnotationKeyRequiredCredentials = "crossbench.io/required-credentials"
+
+// getProfile returns the environment profile used to select credentials
+// Default: none, configurable via CROSSBENCH_PROFILE env var
+func getProfile() string {
+	return os.Getenv("CROSSBENCH_PROFILE")
+}
+
+// profileCredentialsPath returns where to load credentials from for the
+// supplied profile. Credentials for a profile live in a subdirectory of the
+// credentials directory named after the profile, e.g. credentials/prod.
+func profileCredentialsPath(fs afero.Fs, path, profile string) (string, error) {
+	if profile == "" {
+		return path, nil
+	}
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot stat credentials %q", path)
+	}
+	if !info.IsDir() {
+		return "", errors.Errorf("credentials %q must be a directory to select profile %q", path, profile)
+	}
+
+	p := filepath.Join(path, profile)
+	if ok, _ := afero.DirExists(fs, p); !ok {
+		return "", errors.Errorf("credentials directory %q has no profile %q", path, profile)
+	}
+	return p, nil
+}
+
+// MissingCredentials returns the secrets referenced by the credentials of the
+// Composition's pipeline steps that aren't among the supplied secrets, as
+// namespace/name followed by the steps that need them.
+func MissingCredentials(comp *apiextensionsv1.Composition, secrets []corev1.Secret) []string {
+	have := map[string]bool{}
+	for _, s := range secrets {
+		have[s.GetNamespace()+"/"+s.GetName()] = true
+	}
+
+	missing := []string{}
+	steps := map[string][]string{}
+	for _, step := range comp.Spec.Pipeline {
+		for _, cs := range step.Credentials {
+			if cs.Source != apiextensionsv1.FunctionCredentialsSourceSecret || cs.SecretRef == nil {
+				continue
+			}
+			key := cs.SecretRef.Namespace + "/" + cs.SecretRef.Name
+			if have[key] {
+				continue
+			}
+			if _, ok := steps[key]; !ok {
+				missing = append(missing, key)
+			}
+			steps[key] = append(steps[key], step.Step)
+		}
+	}
+
+	for i, key := range missing {
+		missing[i] = fmt.Sprintf("%s (needed by step %s)", key, strings.Join(steps[key], ", "))
+	}
+	return missing
+}
+
+// UnsatisfiedCredentialRequirements returns the credentials Functions declare
+// they require that the pipeline steps using them don't supply.
+func UnsatisfiedCredentialRequirements(comp *apiextensionsv1.Composition, fns []pkgv1.Function) []string {
+	required := map[string][]string{}
+	for _, fn := range fns {
+		for _, name := range strings.Split(fn.GetAnnotations()[AnnotationKeyRequiredCredentials], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				required[fn.GetName()] = append(required[fn.GetName()], name)
+			}
+		}
+	}
+
+	unsatisfied := []string{}
+	for _, step := range comp.Spec.Pipeline {
+		supplied := map[string]bool{}
+		for _, cs := range step.Credentials {
+			supplied[cs.Name] = true
+		}
+		for _, name := range required[step.FunctionRef.Name] {
+			if !supplied[name] {
+				unsatisfied = append(unsatisfied, fmt.Sprintf("credential %q (required by function %s, not supplied by step %s)", name, step.FunctionRef.Name, step.Step))
+			}
+		}
+	}
+	return unsatisfied
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// getDaemonSocket returns the path of the daemon's socket
+// Default: ~/.crossbench/daemon.sock, configurable via CROSSBENCH_DAEMON_SOCKET env var
+func getDaemonSocket() string {
+	if path := os.Getenv("CROSSBENCH_DAEMON_SOCKET"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".crossbench-daemon.sock"
+	}
+	return filepath.Join(home, ".crossbench", "daemon.sock")
+}
+
+// getUseDaemon returns whether functions are run by the daemon
+// Default: false, configurable via CROSSBENCH_DAEMON env var
+func getUseDaemon() bool {
+	return os.Getenv("CROSSBENCH_DAEMON") == "true"
+}
+
+// NewDaemonCommand creates a new daemon command.
+func NewDaemonCommand() *cobra.Command {
+	cmd := &daemonCmd{}
+
+	cobraCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Keep function containers running across renders",
+		Long: `Daemon keeps a warm pool of function containers, so renders don't pay the
+container cold start of every function they run. Renders and tests run with
+--daemon (or CROSSBENCH_DAEMON=true) ask the daemon for their functions over a
+local socket. The daemon starts a function's container the first time it's
+asked for, and reuses it afterwards.
+
+The containers are removed when the daemon stops.
+
+With --metrics-address, Prometheus metrics, such as the containers started and
+running, are served on GET /metrics. They're served on the socket too.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.socket, "socket", getDaemonSocket(), "Path of the socket to serve on.")
+	cobraCmd.Flags().StringVar(&cmd.runtime, "runtime", getContainerRuntime(), "Container runtime functions are run with: auto, docker or podman.")
+	cobraCmd.Flags().StringVar(&cmd.dockerHost, "docker-host", "", "Docker API endpoint functions are run with. Overrides DOCKER_HOST.")
+	cobraCmd.Flags().StringVar(&cmd.metricsAddress, "metrics-address", "", "Address to serve Prometheus metrics on, e.g. 127.0.0.1:9091. Not served if unset.")
+
+	return cobraCmd
+}
+
+type daemonCmd struct {
+	socket         string
+	runtime        string
+	dockerHost     string
+	metricsAddress string
+}
+
+// A functionPool runs each distinct function once, and hands out its target
+// to every render that needs it.
+type functionPool struct {
+	mu      sync.Mutex
+	running map[string]render.RuntimeContext
+}
+
+// newFunctionPool returns an empty pool.
+func newFunctionPool() *functionPool {
+	return &functionPool{running: map[string]render.RuntimeContext{}}
+}
+
+// DaemonRequest asks the daemon for the targets of the supplied Functions.
+type DaemonRequest struct {
+	Functions []pkgv1.Function `json:"functions"`
+}
+
+// DaemonResponse holds the gRPC target of each requested Function, by name.
+type DaemonResponse struct {
+	Targets map[string]string `json:"targets,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+func (c *daemonCmd) run(_ *cobra.Command, _ []string) error {
+	if err := setDockerHost(c.dockerHost); err != nil {
+		return err
+	}
+	if err := setContainerRuntime(c.runtime); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.socket), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create directory of socket %q", c.socket)
+	}
+	// A socket left behind by a daemon that didn't stop cleanly.
+	if conn, err := net.Dial("unix", c.socket); err == nil {
+		_ = conn.Close()
+		return errors.Errorf("a daemon is already serving on %q", c.socket)
+	}
+	_ = os.Remove(c.socket)
+	l, err := net.Listen("unix", c.socket)
+	if err != nil {
+		return errors.Wrapf(err, "cannot listen on %q", c.socket)
+	}
+
+	pool := newFunctionPool()
+	mux := http.NewServeMux()
+	mux.Handle("/", pool)
+	mux.Handle("GET /metrics", metricsHandler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	var msrv *http.Server
+	if c.metricsAddress != "" {
+		ml, err := net.Listen("tcp", c.metricsAddress)
+		if err != nil {
+			return errors.Wrapf(err, "cannot listen on %q", c.metricsAddress)
+		}
+		mmux := http.NewServeMux()
+		mmux.Handle("GET /metrics", metricsHandler())
+		msrv = &http.Server{Handler: mmux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := msrv.Serve(ml); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errorf("Cannot serve metrics: %v", err)
+			}
+		}()
+		infof("Serving metrics on http://%s/metrics", c.metricsAddress)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		if msrv != nil {
+			_ = msrv.Shutdown(context.Background())
+		}
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	infof("Serving function containers on %s", c.socket)
+	if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "cannot serve")
+	}
+	pool.stopAll()
+	return nil
+}
+
+// ServeHTTP starts or reuses the requested Functions and returns their
+// targets.
+func (p *functionPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &DaemonRequest{}
+	rsp := &DaemonResponse{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		rsp.Error = fmt.Sprintf("cannot decode request: %v", err)
+	} else if rsp.Targets, err = p.targets(r.Context(), req.Functions); err != nil {
+		rsp.Error = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rsp)
+}
+
+// targets returns the target of each of the supplied Functions, starting
+// those that aren't running yet.
+func (p *functionPool) targets(ctx context.Context, fns []pkgv1.Function) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	targets := make(map[string]string, len(fns))
+	for _, fn := range fns {
+		// Functions with the same package and runtime annotations share a
+		// container, whatever they're named.
+		key := fn.Spec.Package + " " + fmt.Sprint(fn.GetAnnotations())
+		rc, ok := p.running[key]
+		if ok {
+			// Replace containers that have died since they were started.
+			if conn, err := net.DialTimeout("tcp", rc.Target, time.Second); err == nil {
+				_ = conn.Close()
+			} else {
+				_ = rc.Stop(ctx)
+				functionsRunning.Dec()
+				ok = false
+			}
+		}
+		if !ok {
+			rt, err := render.GetRuntime(fn, newCrossplaneLogger())
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot get runtime for function %q", fn.GetName())
+			}
+			infof("Starting function %q (%s)", fn.GetName(), fn.Spec.Package)
+			if rc, err = rt.Start(ctx); err != nil {
+				return nil, errors.Wrapf(err, "cannot start function %q", fn.GetName())
+			}
+			p.running[key] = rc
+			functionStartsTotal.Inc()
+			functionsRunning.Inc()
+		}
+		targets[fn.GetName()] = rc.Target
+	}
+	return targets, nil
+}
+
+// stopAll stops every running Function.
+func (p *functionPool) stopAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for key, rc := range p.running {
+		if err := rc.Stop(ctx); err != nil {
+			warnf("Cannot stop function container %s: %v", rc.Target, err)
+		}
+		delete(p.running, key)
+		functionsRunning.Dec()
+	}
+	infof("Stopped function containers")
+}
+
+// useFunctions points the Functions that would be run in Docker at the
+// pool's containers.
+func (p *functionPool) useFunctions(ctx context.Context, fns []pkgv1.Function) error {
+	targets, err := p.targets(ctx, dockerFunctions(fns))
+	if err != nil {
+		return err
+	}
+	setFunctionTargets(fns, targets)
+	return nil
+}
+
+// dockerFunctions returns the Functions that would be run in Docker.
+func dockerFunctions(fns []pkgv1.Function) []pkgv1.Function {
+	out := []pkgv1.Function{}
+	for _, fn := range fns {
+		if fn.GetAnnotations()[render.AnnotationKeyRuntime] != string(render.AnnotationValueRuntimeDevelopment) {
+			out = append(out, fn)
+		}
+	}
+	return out
+}
+
+// setFunctionTargets points the supplied Functions at the gRPC targets in
+// targets, by Function name.
+func setFunctionTargets(fns []pkgv1.Function, targets map[string]string) {
+	for i := range fns {
+		if t, ok := targets[fns[i].GetName()]; ok {
+			meta.AddAnnotations(&fns[i], map[string]string{
+				render.AnnotationKeyRuntime:                  string(render.AnnotationValueRuntimeDevelopment),
+				render.AnnotationKeyRuntimeDevelopmentTarget: t,
+			})
+		}
+	}
+}
+
+// useDaemonFunctions points the Functions that would be run in Docker at the
+// containers of the daemon serving on the supplied socket.
+func useDaemonFunctions(ctx context.Context, socket string, fns []pkgv1.Function) error {
+	req := &DaemonRequest{Functions: dockerFunctions(fns)}
+	if len(req.Functions) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal daemon request")
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://daemon/functions", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "cannot create daemon request")
+	}
+	resp, err := client.Do(hr)
+	if err != nil {
+		return errors.Wrapf(err, "cannot reach the daemon at %q; start it with crossbench daemon", socket)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Only read from.
+
+	rsp := &DaemonResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(rsp); err != nil {
+		return errors.Wrap(err, "cannot decode daemon response")
+	}
+	if rsp.Error != "" {
+		return errors.New(rsp.Error)
+	}
+	setFunctionTargets(fns, rsp.Targets)
+	return nil
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+// A pipelineDebugger pauses the Function pipeline after steps return.
+type pipelineDebugger interface {
+	// StepsReturned is called after the supplied steps, which run together,
+	// returned the supplied responses or errors. It returns whether to re-run
+	// the steps, or an error to abort the render.
+	StepsReturned(steps []apiextensionsv1.PipelineStep, rsps []*fnv1.RunFunctionResponse, errs []error) (bool, error)
+}
+
+// A stepDebugger pauses after the pipeline steps matching its breakpoints,
+// shows what they returned and asks the user how to go on, with --debug.
+type stepDebugger struct {
+	p           *prompter
+	breakpoints []string
+}
+
+// newStepDebugger returns a debugger pausing after the steps whose names
+// match one of the supplied globs.
+func newStepDebugger(p *prompter, breakpoints []string) (*stepDebugger, error) {
+	for _, b := range breakpoints {
+		if _, err := path.Match(b, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid --debug breakpoint %q", b)
+		}
+	}
+	return &stepDebugger{p: p, breakpoints: breakpoints}, nil
+}
+
+// breaksAt returns whether the debugger pauses after the supplied step.
+func (d *stepDebugger) breaksAt(step string) bool {
+	for _, b := range d.breakpoints {
+		if ok, _ := path.Match(b, step); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// StepsReturned shows what the steps returned, if the debugger breaks at one
+// of them, and prompts to continue, re-run the steps or abort.
+func (d *stepDebugger) StepsReturned(steps []apiextensionsv1.PipelineStep, rsps []*fnv1.RunFunctionResponse, errs []error) (bool, error) {
+	names := make([]string, 0, len(steps))
+	pause := false
+	for i, s := range steps {
+		names = append(names, fmt.Sprintf("%q", s.Step))
+		// Always pause at errors, so the step can be fixed and re-run.
+		pause = pause || d.breaksAt(s.Step) || errs[i] != nil
+	}
+	if !pause {
+		return false, nil
+	}
+
+	d.show(steps, rsps, errs)
+	for {
+		answer, err := d.p.ask("[c]ontinue, [r]e-run, show [d]esired state, show conte[x]t, show r[e]sults, [a]bort")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "c", "continue", "":
+			return false, nil
+		case "r", "re-run", "rerun":
+			return true, nil
+		case "d", "desired":
+			d.showMessages(steps, rsps, func(rsp *fnv1.RunFunctionResponse) proto.Message { return rsp.GetDesired() })
+		case "x", "context":
+			d.showMessages(steps, rsps, func(rsp *fnv1.RunFunctionResponse) proto.Message { return rsp.GetContext() })
+		case "e", "results":
+			d.showResults(steps, rsps)
+		case "a", "abort":
+			return false, errors.Errorf("aborted after pipeline step(s) %s", strings.Join(names, ", "))
+		}
+	}
+}
+
+// show describes what each of the supplied steps returned, followed by its
+// desired state.
+func (d *stepDebugger) show(steps []apiextensionsv1.PipelineStep, rsps []*fnv1.RunFunctionResponse, errs []error) {
+	for i, s := range steps {
+		if errs[i] != nil {
+			_, _ = fmt.Fprintf(d.p.out, "Pipeline step %q (%s) failed: %v\n", s.Step, s.FunctionRef.Name, errs[i])
+			continue
+		}
+		rsp := rsps[i]
+		_, _ = fmt.Fprintf(d.p.out, "Pipeline step %q (%s) returned %d desired composed resource(s), %d result(s) and %d condition(s).\n",
+			s.Step, s.FunctionRef.Name, len(rsp.GetDesired().GetResources()), len(rsp.GetResults()), len(rsp.GetConditions()))
+	}
+	d.showMessages(steps, rsps, func(rsp *fnv1.RunFunctionResponse) proto.Message { return rsp.GetDesired() })
+}
+
+// showMessages writes the part of each response returned by the supplied
+// function as YAML.
+func (d *stepDebugger) showMessages(steps []apiextensionsv1.PipelineStep, rsps []*fnv1.RunFunctionResponse, part func(*fnv1.RunFunctionResponse) proto.Message) {
+	for i, s := range steps {
+		if rsps[i] == nil {
+			continue
+		}
+		b, err := protojson.Marshal(part(rsps[i]))
+		if err == nil {
+			b, err = yaml.JSONToYAML(b)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(d.p.out, "Cannot show the response of pipeline step %q: %v\n", s.Step, err)
+			continue
+		}
+		_, _ = fmt.Fprintf(d.p.out, "--- # %s\n%s", s.Step, b)
+	}
+}
+
+// showResults writes the results each step returned.
+func (d *stepDebugger) showResults(steps []apiextensionsv1.PipelineStep, rsps []*fnv1.RunFunctionResponse) {
+	for i, s := range steps {
+		if rsps[i] == nil {
+			continue
+		}
+		if len(rsps[i].GetResults()) == 0 {
+			_, _ = fmt.Fprintf(d.p.out, "Pipeline step %q returned no results.\n", s.Step)
+		}
+		for _, r := range rsps[i].GetResults() {
+			_, _ = fmt.Fprintf(d.p.out, "%s: %s: %s\n", s.Step, r.GetSeverity(), r.GetMessage())
+		}
+	}
+}
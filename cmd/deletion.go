@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"path"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// simulateDeletion marks the observed composed resources whose composition
+// resource names match a pattern in deleting as being deleted, by setting
+// their deletionTimestamp, and drops those matching a pattern in absent, as
+// if they were deleted. Every pattern must match an observed resource.
+func simulateDeletion(ors []composed.Unstructured, deleting, absent []string) ([]composed.Unstructured, error) {
+	if len(deleting) == 0 && len(absent) == 0 {
+		return ors, nil
+	}
+
+	matched := map[string]bool{}
+	matches := func(patterns []string, name string) bool {
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok {
+				matched[p] = true
+				return true
+			}
+		}
+		return false
+	}
+
+	out := make([]composed.Unstructured, 0, len(ors))
+	for _, or := range ors {
+		name := or.GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+		if matches(absent, name) {
+			infof("Simulating observed resource %q is absent", name)
+			continue
+		}
+		if matches(deleting, name) {
+			infof("Simulating observed resource %q is being deleted", name)
+			t := conditionTime()
+			or.SetDeletionTimestamp(&t)
+		}
+		out = append(out, or)
+	}
+
+	for _, p := range append(append([]string{}, deleting...), absent...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errors.Errorf("invalid composition resource name pattern %q", p)
+		}
+		if !matched[p] {
+			return nil, errors.Errorf("no observed resource has a composition resource name matching %q", p)
+		}
+	}
+	return out, nil
+}
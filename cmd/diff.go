@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// ChangeType describes how a resource differs between two sets.
+type ChangeType string
+
+// Change types.
+const (
+	ChangeAdded     ChangeType = "added"
+	ChangeRemoved   ChangeType = "removed"
+	ChangeChanged   ChangeType = "changed"
+	ChangeUnchanged ChangeType = "unchanged"
+)
+
+// A FieldChange is a difference in a single field. From is nil for added
+// fields, and To is nil for removed fields.
+type FieldChange struct {
+	Path string `json:"path"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// A ResourceChange describes how a resource differs between two sets.
+type ResourceChange struct {
+	Type     ChangeType    `json:"type"`
+	Resource string        `json:"resource"`
+	Fields   []FieldChange `json:"fields,omitempty"`
+}
+
+// DiffOptions configure how resources are compared.
+type DiffOptions struct {
+	// Subset only compares the fields present in the desired resources. This
+	// is useful when comparing against live resources, which have fields set
+	// by the API server and controllers.
+	Subset bool
+
+	// IgnorePaths are field paths that are never compared.
+	IgnorePaths []string
+}
+
+// liveIgnorePaths are fields that differ between rendered and live resources
+// for reasons that have nothing to do with the composition.
+var liveIgnorePaths = []string{
+	"status",
+	"metadata.ownerReferences",
+	"metadata.generateName",
+	"metadata.uid",
+	"metadata.resourceVersion",
+	"metadata.generation",
+	"metadata.creationTimestamp",
+	"metadata.managedFields",
+}
+
+// resourceKey identifies the same resource across two sets. Composed
+// resources are matched by their composition resource name, everything else
+// by kind, namespace and name.
+func resourceKey(u *unstructured.Unstructured) string {
+	if name := u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", u.GetAPIVersion(), u.GetKind(), u.GetNamespace(), u.GetName())
+}
+
+// DiffResources compares the desired resources with the actual ones. Changes
+// are returned in the order of the desired resources, followed by removed
+// resources.
+func DiffResources(desired, actual []unstructured.Unstructured, o DiffOptions) []ResourceChange {
+	byKey := map[string]*unstructured.Unstructured{}
+	for i := range actual {
+		byKey[resourceKey(&actual[i])] = &actual[i]
+	}
+
+	changes := []ResourceChange{}
+	seen := map[string]bool{}
+	for i := range desired {
+		k := resourceKey(&desired[i])
+		seen[k] = true
+
+		a, ok := byKey[k]
+		if !ok {
+			changes = append(changes, ResourceChange{Type: ChangeAdded, Resource: resourceID(&desired[i])})
+			continue
+		}
+
+		fields := diffFields("", desired[i].Object, a.Object, o)
+		rc := ResourceChange{Type: ChangeUnchanged, Resource: resourceID(&desired[i])}
+		if len(fields) > 0 {
+			rc.Type = ChangeChanged
+			rc.Fields = fields
+		}
+		changes = append(changes, rc)
+	}
+
+	for i := range actual {
+		if !seen[resourceKey(&actual[i])] {
+			changes = append(changes, ResourceChange{Type: ChangeRemoved, Resource: resourceID(&actual[i])})
+		}
+	}
+
+	return changes
+}
+
+func diffFields(path string, desired, actual any, o DiffOptions) []FieldChange {
+	for _, p := range o.IgnorePaths {
+		if path == p {
+			return nil
+		}
+	}
+
+	switch d := desired.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(d)+len(a))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		if !o.Subset {
+			for k := range a {
+				if _, ok := d[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+
+		changes := []FieldChange{}
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			dv, dok := d[k]
+			av, aok := a[k]
+			switch {
+			case !aok:
+				if !ignored(p, o) {
+					changes = append(changes, FieldChange{Path: p, To: dv})
+				}
+			case !dok:
+				if !ignored(p, o) {
+					changes = append(changes, FieldChange{Path: p, From: av})
+				}
+			default:
+				changes = append(changes, diffFields(p, dv, av, o)...)
+			}
+		}
+		return changes
+
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || (!o.Subset && len(a) != len(d)) || len(a) < len(d) {
+			break
+		}
+		changes := []FieldChange{}
+		for i := range d {
+			changes = append(changes, diffFields(fmt.Sprintf("%s[%d]", path, i), d[i], a[i], o)...)
+		}
+		return changes
+	}
+
+	if equalValues(desired, actual) {
+		return nil
+	}
+	return []FieldChange{{Path: path, From: actual, To: desired}}
+}
+
+func ignored(path string, o DiffOptions) bool {
+	for _, p := range o.IgnorePaths {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// equalValues compares two values decoded from JSON or YAML, treating numbers
+// of different types as equal if they have the same value.
+func equalValues(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch t := v.(type) {
+	case int64:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case float64:
+		return t, true
+	}
+	return 0, false
+}
+
+// printChanges writes a human readable diff and returns whether anything
+// changed.
+func printChanges(w io.Writer, changes []ResourceChange) bool {
+	counts := map[ChangeType]int{}
+	for _, rc := range changes {
+		counts[rc.Type]++
+		switch rc.Type {
+		case ChangeAdded:
+			_, _ = fmt.Fprintf(w, "+ %s\n", rc.Resource)
+		case ChangeRemoved:
+			_, _ = fmt.Fprintf(w, "- %s\n", rc.Resource)
+		case ChangeChanged:
+			_, _ = fmt.Fprintf(w, "~ %s\n", rc.Resource)
+			for _, f := range rc.Fields {
+				switch {
+				case f.From == nil:
+					_, _ = fmt.Fprintf(w, "    + %s: %s\n", f.Path, diffValue(f.To))
+				case f.To == nil:
+					_, _ = fmt.Fprintf(w, "    - %s: %s\n", f.Path, diffValue(f.From))
+				default:
+					_, _ = fmt.Fprintf(w, "    ~ %s: %s -> %s\n", f.Path, diffValue(f.From), diffValue(f.To))
+				}
+			}
+		case ChangeUnchanged:
+		}
+	}
+	_, _ = fmt.Fprintf(w, "%d to add, %d to change, %d to remove, %d unchanged\n", counts[ChangeAdded], counts[ChangeChanged], counts[ChangeRemoved], counts[ChangeUnchanged])
+	return counts[ChangeAdded]+counts[ChangeChanged]+counts[ChangeRemoved] > 0
+}
+
+func diffValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// NewDiffCommand creates a new diff command.
+func NewDiffCommand() *cobra.Command {
+	cmd := &diffCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "diff <composite-resource> [composition] [functions]",
+		Short: "Compare a rendered composition with the resources in a live cluster",
+		Long: `Diff renders the XR exactly like the render command does, then compares the
+rendered composed resources with the resources the XR composes in a live
+cluster, as recorded in the resource references of the XR in that cluster.
+
+Rendered resources are matched to live ones by their composition resource name.
+Only fields set by the composition are compared, so fields defaulted by the API
+server or written by controllers don't show up as changes. Resources that are
+composed in the cluster but not rendered are reported as removed.
+
+The output lists each resource as added (+), changed (~) or removed (-), with
+the field level changes for changed resources.`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	// Flags
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().BoolVar(&cmd.exitCode, "exit-code", false, "Exit with a non-zero status if there are differences.")
+	cobraCmd.Flags().StringVar(&cmd.summaryFile, "summary-file", "", "Write a JSON summary of the run - diff stats, duration and function versions - to this file for CI jobs.")
+
+	return cobraCmd
+}
+
+type diffCmd struct {
+	renderCmd
+
+	// Flags
+	exitCode bool
+}
+
+func (c *diffCmd) run(cmd *cobra.Command, args []string) error {
+	started := time.Now()
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+	if c.networkDisabled() {
+		return errors.New("diff needs a cluster, so it can't be used with --no-network or --offline")
+	}
+
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return err
+	}
+
+	in, err := c.loadInputs(args)
+	if err != nil {
+		return err
+	}
+
+	out, err := c.render(in)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	xr, live, err := cc.composedResources(ctx, &in.CompositeResource.Unstructured)
+	if err != nil {
+		return errors.Wrap(err, "cannot get composed resources from cluster")
+	}
+	if xr == nil {
+		infof("Composite resource %q doesn't exist in the cluster, all resources will be added", in.CompositeResource.GetName())
+	}
+
+	desired := make([]unstructured.Unstructured, len(out.ComposedResources))
+	for i := range out.ComposedResources {
+		desired[i] = out.ComposedResources[i].Unstructured
+	}
+
+	changes := DiffResources(desired, live, DiffOptions{Subset: true, IgnorePaths: liveIgnorePaths})
+	changed := printChanges(os.Stdout, changes)
+
+	r := RunResult{Name: args[0], Duration: time.Since(started), Diff: map[ChangeType]int{}}
+	r.recordRender(in, out)
+	for _, ch := range changes {
+		r.Diff[ch.Type]++
+	}
+	if err := writeSummaryFile(c.fs, c.summaryFile, NewRunSummary("diff", started, []RunResult{r})); err != nil {
+		return err
+	}
+
+	if changed && c.exitCode {
+		return errors.New("rendered resources differ from the cluster")
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+// pipelineStepKey is the context key of the pipeline step a Function is
+// called for.
+type pipelineStepKey struct{}
+
+// A pipelineStepCall identifies the pipeline step a Function is called for.
+type pipelineStepCall struct {
+	// Index of the step in the pipeline.
+	Index int
+	Step  string
+}
+
+// withPipelineStep returns a context recording that Functions called with it
+// run the supplied step.
+func withPipelineStep(ctx context.Context, index int, step string) context.Context {
+	return context.WithValue(ctx, pipelineStepKey{}, pipelineStepCall{Index: index, Step: step})
+}
+
+// A dumpingFunctionRunner writes the request and response of every Function
+// call to a directory as JSON, named after the pipeline step, e.g.
+// 01-patch-and-transform.request.json. Steps that call their Function more
+// than once, to fetch the resources it requires, number each call. Calls that
+// fail write the error instead of the response. Credential data is redacted,
+// and the files are only readable by the user.
+type dumpingFunctionRunner struct {
+	wrapped FunctionRunner
+	dir     string
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newDumpingFunctionRunner(wrapped FunctionRunner, dir string) (*dumpingFunctionRunner, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "cannot create function I/O directory %q", dir)
+	}
+	return &dumpingFunctionRunner{wrapped: wrapped, dir: dir, calls: map[string]int{}}, nil
+}
+
+// RunFunction runs the named Function, writing its request and response.
+func (r *dumpingFunctionRunner) RunFunction(ctx context.Context, name string, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	prefix := r.prefix(ctx, name)
+	if err := r.write(prefix+".request.json", redactCredentials(req)); err != nil {
+		return nil, err
+	}
+	rsp, err := r.wrapped.RunFunction(ctx, name, req)
+	if err != nil {
+		file := filepath.Join(r.dir, prefix+".error.txt")
+		if werr := os.WriteFile(file, []byte(err.Error()+"\n"), 0600); werr != nil {
+			warnf("Cannot write function error to %s: %v", file, werr)
+		}
+		return nil, err
+	}
+	return rsp, r.write(prefix+".response.json", rsp)
+}
+
+// prefix returns the name of the files of a call, without their extension.
+func (r *dumpingFunctionRunner) prefix(ctx context.Context, name string) string {
+	prefix := name
+	if c, ok := ctx.Value(pipelineStepKey{}).(pipelineStepCall); ok {
+		prefix = fmt.Sprintf("%02d-%s", c.Index+1, c.Step)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[prefix]++
+	if n := r.calls[prefix]; n > 1 {
+		prefix = fmt.Sprintf("%s-%d", prefix, n)
+	}
+	return prefix
+}
+
+func (r *dumpingFunctionRunner) write(file string, m proto.Message) error {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %s", file)
+	}
+	// protojson's whitespace is deliberately unstable, so it's indented
+	// separately.
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, data, "", "  "); err != nil {
+		return errors.Wrapf(err, "cannot indent %s", file)
+	}
+	buf.WriteByte('\n')
+	path := filepath.Join(r.dir, file)
+	return errors.Wrapf(os.WriteFile(path, buf.Bytes(), 0600), "cannot write function I/O to %q", path)
+}
+
+// redactedCredential replaces the value of every credential data key in
+// dumped requests.
+const redactedCredential = "REDACTED"
+
+// redactCredentials returns a copy of the supplied request with the value of
+// every credential data key redacted, so dumps don't spill the Secrets
+// passed with --function-credentials. The keys are kept, to show which
+// credentials the Function was sent.
+func redactCredentials(req *fnv1.RunFunctionRequest) *fnv1.RunFunctionRequest {
+	if len(req.GetCredentials()) == 0 {
+		return req
+	}
+	redacted := proto.Clone(req).(*fnv1.RunFunctionRequest) //nolint:forcetypeassert // Clone returns the same type.
+	for _, c := range redacted.GetCredentials() {
+		data := c.GetCredentialData().GetData()
+		for k := range data {
+			data[k] = []byte(redactedCredential)
+		}
+	}
+	return redacted
+}
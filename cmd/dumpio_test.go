@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+type echoFunctionRunner struct{}
+
+func (echoFunctionRunner) RunFunction(_ context.Context, _ string, _ *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	return &fnv1.RunFunctionResponse{}, nil
+}
+
+func TestDumpingFunctionRunnerRedactsCredentials(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "io")
+	r, err := newDumpingFunctionRunner(echoFunctionRunner{}, dir)
+	if err != nil {
+		t.Fatalf("newDumpingFunctionRunner(...): %v", err)
+	}
+
+	req := &fnv1.RunFunctionRequest{
+		Credentials: map[string]*fnv1.Credentials{
+			"aws": {Source: &fnv1.Credentials_CredentialData{CredentialData: &fnv1.CredentialData{
+				Data: map[string][]byte{"credentials": []byte("aws_secret_access_key=hunter2")},
+			}}},
+		},
+	}
+	ctx := withPipelineStep(context.Background(), 0, "patch-and-transform")
+	if _, err := r.RunFunction(ctx, "function-patch-and-transform", req); err != nil {
+		t.Fatalf("RunFunction(...): %v", err)
+	}
+
+	if got := string(req.GetCredentials()["aws"].GetCredentialData().GetData()["credentials"]); got != "aws_secret_access_key=hunter2" {
+		t.Errorf("\nThe request sent to the Function shouldn't be redacted.\nRunFunction(...): got credentials %q", got)
+	}
+
+	file := filepath.Join(dir, "01-patch-and-transform.request.json")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "aws_secret_access_key") {
+		t.Errorf("\nDumped requests shouldn't contain credential data.\nRunFunction(...): got\n%s", data)
+	}
+
+	want := map[string]os.FileMode{dir: 0o700, file: 0o600}
+	got := map[string]os.FileMode{}
+	for p := range want {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[p] = fi.Mode().Perm()
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("\nDumps should only be readable by the user.\nRunFunction(...): -want, +got:\n%s", diff)
+	}
+}
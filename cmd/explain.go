@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+)
+
+// ExplainStderr is the --explain-selection value writing the explanation to
+// stderr.
+const ExplainStderr = "-"
+
+// Outcomes of an ExplainedCheck.
+const (
+	CheckOutcomePassed = "passed"
+	CheckOutcomeFailed = "failed"
+)
+
+// A SelectionExplanation narrates every decision made before an XR is
+// rendered: the inputs loaded and where they came from, how the Composition
+// was selected, the checks run against the inputs and how the Function of
+// each pipeline step was resolved. It's written by --explain-selection.
+type SelectionExplanation struct {
+	// XR is the file the XR was loaded from.
+	XR string `json:"xr"`
+
+	// Claim the XR was synthesized from, if any, e.g. Bucket/default/my-bucket.
+	Claim string `json:"claim,omitempty"`
+
+	Inputs      []ExplainedInput      `json:"inputs"`
+	Composition *ExplainedComposition `json:"composition,omitempty"`
+	Checks      []ExplainedCheck      `json:"checks"`
+	Steps       []ExplainedStep       `json:"steps,omitempty"`
+
+	// Error that stopped the XR from being rendered, if any.
+	Error string `json:"error,omitempty"`
+
+	// fnSources describe where each Function came from, by name.
+	fnSources map[string]string
+}
+
+// An ExplainedInput is an input loaded for the render.
+type ExplainedInput struct {
+	// Role of the input, e.g. composition or observed-resources.
+	Role string `json:"role"`
+
+	Path string `json:"path"`
+
+	// Source describes why the input was used, e.g. it was passed as an
+	// argument or found next to the XR.
+	Source string `json:"source"`
+}
+
+// An ExplainedComposition describes how the Composition was selected.
+type ExplainedComposition struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+
+	// MatchLabels are the labels the Composition had to match, from the XR's
+	// compositionSelector and --composition-selector.
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// Candidates are the Compositions considered, with why each was or wasn't
+	// selected.
+	Candidates []ExplainedCandidate `json:"candidates,omitempty"`
+}
+
+// An ExplainedCandidate is a Composition considered for the XR.
+type ExplainedCandidate struct {
+	Name     string `json:"name"`
+	Selected bool   `json:"selected"`
+	Reason   string `json:"reason"`
+}
+
+// An ExplainedCheck is a check run against the inputs before rendering.
+type ExplainedCheck struct {
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Message string `json:"message,omitempty"`
+}
+
+// An ExplainedStep describes the Function a pipeline step runs.
+type ExplainedStep struct {
+	Step     string `json:"step"`
+	Function string `json:"function"`
+	Package  string `json:"package,omitempty"`
+
+	// Source describes where the Function's package came from, e.g. the lock
+	// file or the latest release.
+	Source string `json:"source,omitempty"`
+
+	// Overrides applied to the Function, e.g. a pinned digest or runtime
+	// annotations.
+	Overrides []string `json:"overrides,omitempty"`
+
+	// Skipped steps aren't run, because of --skip-step or --only-step.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// newSelectionExplanation returns an empty explanation of the render of the
+// supplied XR file.
+func newSelectionExplanation(xr string) *SelectionExplanation {
+	return &SelectionExplanation{XR: xr, Inputs: []ExplainedInput{}, Checks: []ExplainedCheck{}}
+}
+
+// input records an input loaded for the render. It does nothing if the
+// explanation is nil, as it is unless --explain-selection is set, and so do
+// the other methods recording decisions.
+func (e *SelectionExplanation) input(role, path, source string) {
+	if e == nil || path == "" {
+		return
+	}
+	e.Inputs = append(e.Inputs, ExplainedInput{Role: role, Path: path, Source: source})
+}
+
+// inputFiles records the supplied files, keyed by what they're for, such as
+// a context key, as inputs passed by the supplied flag.
+func (e *SelectionExplanation) inputFiles(role string, files map[string]string, flag string) {
+	if e == nil {
+		return
+	}
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		e.input(role, files[k], fmt.Sprintf("%s %q", flag, k))
+	}
+}
+
+// check records the outcome of the named check, and returns its error.
+func (e *SelectionExplanation) check(name string, err error) error {
+	if e == nil {
+		return err
+	}
+	c := ExplainedCheck{Name: name, Outcome: CheckOutcomePassed}
+	if err != nil {
+		c.Outcome = CheckOutcomeFailed
+		c.Message = err.Error()
+	}
+	e.Checks = append(e.Checks, c)
+	return err
+}
+
+// functionSource records where the named Function came from.
+func (e *SelectionExplanation) functionSource(name, source string) {
+	if e == nil {
+		return
+	}
+	if e.fnSources == nil {
+		e.fnSources = map[string]string{}
+	}
+	e.fnSources[name] = source
+}
+
+// compositionSelected records that the supplied Composition was used from
+// the supplied source for the supplied reason, without any other candidates.
+func (e *SelectionExplanation) compositionSelected(comp *apiextensionsv1.Composition, source, reason string) {
+	if e == nil {
+		return
+	}
+	e.Composition = &ExplainedComposition{
+		Name:       comp.GetName(),
+		Source:     source,
+		Candidates: []ExplainedCandidate{{Name: comp.GetName(), Selected: true, Reason: reason}},
+	}
+}
+
+// compositionSelection records how a Composition was selected for the
+// supplied XR from the supplied candidates, the way selectComposition does.
+// The selected Composition is nil if none could be selected.
+func (e *SelectionExplanation) compositionSelection(xr *composite.Unstructured, comps []*apiextensionsv1.Composition, selector map[string]string, source string, selected *apiextensionsv1.Composition) {
+	if e == nil {
+		return
+	}
+	ec := &ExplainedComposition{Source: source}
+	if selected != nil {
+		ec.Name = selected.GetName()
+	}
+
+	gvk := xr.GroupVersionKind()
+	ref := xr.GetCompositionReference()
+	matchLabels := map[string]string{}
+	if sel := xr.GetCompositionSelector(); sel != nil {
+		for k, v := range sel.MatchLabels {
+			matchLabels[k] = v
+		}
+	}
+	for k, v := range selector {
+		matchLabels[k] = v
+	}
+	if ref == nil && len(matchLabels) > 0 {
+		ec.MatchLabels = matchLabels
+	}
+
+	for _, comp := range comps {
+		c := ExplainedCandidate{Name: comp.GetName(), Selected: comp == selected}
+		tr := comp.Spec.CompositeTypeRef
+		switch {
+		case tr.APIVersion != gvk.GroupVersion().String() || tr.Kind != gvk.Kind:
+			c.Reason = fmt.Sprintf("composes %s, %s, not the XR's %s, %s", tr.Kind, tr.APIVersion, gvk.Kind, gvk.GroupVersion())
+		case ref != nil && comp.GetName() == ref.Name:
+			c.Reason = "named by the XR's compositionRef"
+		case ref != nil:
+			c.Reason = fmt.Sprintf("not named by the XR's compositionRef %q", ref.Name)
+		case len(matchLabels) > 0 && labels.SelectorFromSet(matchLabels).Matches(labels.Set(comp.GetLabels())):
+			c.Reason = fmt.Sprintf("labels match %s", labels.Set(matchLabels))
+		case len(matchLabels) > 0:
+			c.Reason = fmt.Sprintf("labels don't match %s", labels.Set(matchLabels))
+		default:
+			c.Reason = fmt.Sprintf("composes %s", gvk.Kind)
+		}
+		ec.Candidates = append(ec.Candidates, c)
+	}
+	e.Composition = ec
+}
+
+// functions records the Function each step of the supplied Composition's
+// pipeline runs. The supplied resolved Functions are as they were resolved,
+// and the supplied Functions as they'll be run, after any overrides. The
+// original Composition is the one before steps were skipped.
+func (e *SelectionExplanation) functions(original, comp *apiextensionsv1.Composition, resolved, fns []pkgv1.Function) {
+	if e == nil {
+		return
+	}
+	run := map[string]bool{}
+	for _, s := range comp.Spec.Pipeline {
+		run[s.Step] = true
+	}
+	byName := func(fns []pkgv1.Function) map[string]pkgv1.Function {
+		m := make(map[string]pkgv1.Function, len(fns))
+		for _, fn := range fns {
+			m[fn.GetName()] = fn
+		}
+		return m
+	}
+	before, after := byName(resolved), byName(fns)
+
+	for _, s := range original.Spec.Pipeline {
+		es := ExplainedStep{Step: s.Step, Function: s.FunctionRef.Name, Skipped: !run[s.Step]}
+		fn, ok := after[s.FunctionRef.Name]
+		if !es.Skipped && ok {
+			es.Package = fn.Spec.Package
+			es.Source = e.fnSources[fn.GetName()]
+			es.Overrides = functionOverrides(before[fn.GetName()], fn)
+		}
+		e.Steps = append(e.Steps, es)
+	}
+}
+
+// functionOverrides describes how the supplied Function was changed from how
+// it was resolved.
+func functionOverrides(resolved, fn pkgv1.Function) []string {
+	o := []string{}
+	if fn.Spec.Package != resolved.Spec.Package {
+		o = append(o, fmt.Sprintf("package %s changed to %s", resolved.Spec.Package, fn.Spec.Package))
+	}
+	keys := []string{}
+	for k, v := range fn.GetAnnotations() {
+		if resolved.GetAnnotations()[k] != v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		o = append(o, fmt.Sprintf("annotation %s: %s", k, fn.GetAnnotations()[k]))
+	}
+	if len(o) == 0 {
+		return nil
+	}
+	return o
+}
+
+// writeExplanations writes the supplied explanations to the supplied file,
+// as JSON if it has a .json extension and as a YAML stream otherwise, or as
+// YAML to stderr if the file is ExplainStderr. It does nothing if file is
+// empty.
+func writeExplanations(fs afero.Fs, file string, es []SelectionExplanation) error {
+	if file == "" {
+		return nil
+	}
+	if file == ExplainStderr {
+		return writeExplanationsYAML(os.Stderr, es)
+	}
+	b := &strings.Builder{}
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		data, err := json.MarshalIndent(es, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal selection explanation")
+		}
+		b.Write(append(data, '\n'))
+	} else if err := writeExplanationsYAML(b, es); err != nil {
+		return err
+	}
+	return errors.Wrapf(afero.WriteFile(fs, file, []byte(b.String()), 0644), "cannot write selection explanation %q", file)
+}
+
+// writeExplanationsYAML writes the supplied explanations as a YAML stream.
+func writeExplanationsYAML(w io.Writer, es []SelectionExplanation) error {
+	for _, e := range es {
+		data, err := yaml.Marshal(e)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal selection explanation")
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", data); err != nil {
+			return errors.Wrap(err, "cannot write selection explanation")
+		}
+	}
+	return nil
+}
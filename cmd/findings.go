@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// Severity of a Finding.
+type Severity string
+
+// Finding severities.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// A Finding is a problem a check detected in an input or rendered resource.
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Resource string   `json:"resource,omitempty"`
+	Message  string   `json:"message"`
+
+	// File is the input file the finding is located in, if known, and Path
+	// the field within it, e.g. spec.pipeline[0].input.region. They locate
+	// findings in reports, but don't identify them.
+	File string `json:"file,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// String returns a one line, human readable representation of the finding.
+func (f Finding) String() string {
+	if f.Resource == "" {
+		return fmt.Sprintf("%s: [%s] %s", strings.ToUpper(string(f.Severity)), f.Check, f.Message)
+	}
+	return fmt.Sprintf("%s: [%s] %s: %s", strings.ToUpper(string(f.Severity)), f.Check, f.Resource, f.Message)
+}
+
+// printFindings writes one line per finding.
+func printFindings(w io.Writer, findings []Finding) {
+	for _, f := range findings {
+		_, _ = fmt.Fprintln(w, f.String())
+	}
+}
+
+// findingsError returns an error if any of the supplied findings is of error
+// severity.
+func findingsError(findings []Finding) error {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return errors.Errorf("%d check(s) failed", n)
+}
+
+// resourceID identifies a resource in findings, e.g. "Bucket/my-bucket
+// (s3bucket)". The composition resource name is included when present, since
+// composed resources often don't have a name until they're created.
+func resourceID(u *unstructured.Unstructured) string {
+	id := fmt.Sprintf("%s/%s", u.GetKind(), u.GetName())
+	if u.GetName() == "" {
+		id = u.GetKind()
+	}
+	if name := u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]; name != "" {
+		id = fmt.Sprintf("%s (%s)", id, name)
+	}
+	return id
+}
+
+// A Baseline records known findings, so they're grandfathered and only new
+// findings fail.
+type Baseline struct {
+	Findings []Finding `json:"findings"`
+}
+
+// getBaselinePath returns the path of the findings baseline file of render
+// Default: .crossbench-baseline.yaml, configurable via CROSSBENCH_BASELINE env var
+func getBaselinePath() string {
+	if path := os.Getenv("CROSSBENCH_BASELINE"); path != "" {
+		return path
+	}
+	return ".crossbench-baseline.yaml"
+}
+
+// getLintBaselinePath returns the path of the findings baseline file of lint.
+// It's separate from render's, so writing one doesn't drop the other's
+// findings.
+// Default: .crossbench-lint-baseline.yaml, configurable via CROSSBENCH_LINT_BASELINE env var
+func getLintBaselinePath() string {
+	if path := os.Getenv("CROSSBENCH_LINT_BASELINE"); path != "" {
+		return path
+	}
+	return ".crossbench-lint-baseline.yaml"
+}
+
+// loadBaseline loads the baseline at the supplied path, which may be a
+// remote location. It returns an empty baseline if the file doesn't exist.
+func loadBaseline(fs afero.Fs, path string) (*Baseline, error) {
+	data, err := readStoredFile(fs, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Baseline{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read baseline %q", path)
+	}
+	b := &Baseline{}
+	if err := yaml.Unmarshal(data, b); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse baseline %q", path)
+	}
+	return b, nil
+}
+
+// writeBaseline writes the supplied findings as the baseline at the supplied
+// path, which may be a remote location.
+func writeBaseline(fs afero.Fs, path string, findings []Finding) error {
+	data, err := yaml.Marshal(&Baseline{Findings: findings})
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal baseline")
+	}
+	return errors.Wrapf(writeStoredFile(fs, path, data), "cannot write baseline %q", path)
+}
+
+// Filter returns the findings that aren't in the baseline, and how many were
+// suppressed. Findings match if their check, resource and message are equal.
+// Each baseline entry suppresses one finding and is used up doing so, so new
+// duplicates still fail when the findings of several XRs are filtered in
+// turn.
+func (b *Baseline) Filter(findings []Finding) ([]Finding, int) {
+	known := map[string]int{}
+	for _, f := range b.Findings {
+		known[f.fingerprint()]++
+	}
+
+	remaining := []Finding{}
+	suppressed := 0
+	for _, f := range findings {
+		if fp := f.fingerprint(); known[fp] > 0 {
+			known[fp]--
+			suppressed++
+			continue
+		}
+		remaining = append(remaining, f)
+	}
+
+	unused := []Finding{}
+	for _, f := range b.Findings {
+		if fp := f.fingerprint(); known[fp] > 0 {
+			known[fp]--
+			unused = append(unused, f)
+		}
+	}
+	b.Findings = unused
+	return remaining, suppressed
+}
+
+func (f Finding) fingerprint() string {
+	return f.Check + "\x00" + f.Resource + "\x00" + f.Message
+}
+
+// applyBaseline writes the findings as the baseline if write is true, and
+// otherwise filters out the findings that are in the baseline. Either way it
+// returns the findings that should be reported.
+func applyBaseline(fs afero.Fs, path string, write bool, findings []Finding) ([]Finding, error) {
+	if write {
+		if err := writeBaseline(fs, path, findings); err != nil {
+			return nil, err
+		}
+		infof("Wrote %d finding(s) to baseline %s", len(findings), path)
+		return nil, nil
+	}
+
+	b, err := loadBaseline(fs, path)
+	if err != nil {
+		return nil, err
+	}
+	remaining, suppressed := b.Filter(findings)
+	if suppressed > 0 {
+		infof("%d finding(s) suppressed by baseline %s", suppressed, path)
+	}
+	return remaining, nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBaselineFilter(t *testing.T) {
+	hi := Finding{Check: checkFunctionResults, Severity: SeverityError, Resource: "step configure-bucket", Message: "normal result: hi"}
+	other := Finding{Check: checkFunctionResults, Severity: SeverityError, Resource: "step configure-bucket", Message: "normal result: other"}
+
+	type want struct {
+		remaining  [][]Finding
+		suppressed []int
+	}
+
+	cases := map[string]struct {
+		reason   string
+		baseline []Finding
+		xrs      [][]Finding
+		want     want
+	}{
+		"Suppressed": {
+			reason:   "Findings in the baseline should be suppressed.",
+			baseline: []Finding{hi},
+			xrs:      [][]Finding{{hi, other}},
+			want: want{
+				remaining:  [][]Finding{{other}},
+				suppressed: []int{1},
+			},
+		},
+		"IgnoresLocation": {
+			reason:   "Findings should match regardless of the file and path they're located at.",
+			baseline: []Finding{hi},
+			xrs:      [][]Finding{{{Check: hi.Check, Severity: hi.Severity, Resource: hi.Resource, Message: hi.Message, File: "composition.yaml", Path: "spec"}}},
+			want: want{
+				remaining:  [][]Finding{{}},
+				suppressed: []int{1},
+			},
+		},
+		"NewDuplicate": {
+			reason:   "Each baseline entry should only suppress one finding, so new duplicates are reported.",
+			baseline: []Finding{hi},
+			xrs:      [][]Finding{{hi, hi}},
+			want: want{
+				remaining:  [][]Finding{{hi}},
+				suppressed: []int{1},
+			},
+		},
+		"UsedUpAcrossXRs": {
+			reason:   "A baseline entry used up by one XR shouldn't suppress the same finding of the next XR.",
+			baseline: []Finding{hi},
+			xrs:      [][]Finding{{hi}, {hi}},
+			want: want{
+				remaining:  [][]Finding{{}, {hi}},
+				suppressed: []int{1, 0},
+			},
+		},
+		"BatchBaseline": {
+			reason:   "A baseline written from a batch of XRs should suppress the findings of every XR.",
+			baseline: []Finding{hi, hi},
+			xrs:      [][]Finding{{hi}, {hi}},
+			want: want{
+				remaining:  [][]Finding{{}, {}},
+				suppressed: []int{1, 1},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			b := &Baseline{Findings: tc.baseline}
+			got := want{}
+			for _, findings := range tc.xrs {
+				remaining, suppressed := b.Filter(findings)
+				got.remaining = append(got.remaining, remaining)
+				got.suppressed = append(got.suppressed, suppressed)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nFilter(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+)
+
+// Field names (lowercased) that commonly describe an instance size or tier
+// in provider managed resources, e.g. instanceType, instanceClass, vmSize.
+var footprintSizeFields = map[string]bool{
+	"instancetype":  true,
+	"instancetypes": true,
+	"instanceclass": true,
+	"machinetype":   true,
+	"vmsize":        true,
+	"nodetype":      true,
+	"cachenodetype": true,
+	"skuname":       true,
+	"tier":          true,
+}
+
+// Field names (lowercased) that commonly describe a number of nodes.
+var footprintNodeFields = map[string]bool{
+	"nodecount":        true,
+	"desiredsize":      true,
+	"initialnodecount": true,
+	"numnodes":         true,
+	"numcachenodes":    true,
+	"numberofnodes":    true,
+	"instancecount":    true,
+}
+
+// Field names (lowercased) that commonly describe a disk size. Plain numbers
+// are assumed to be in GB, quantities like "100Gi" are converted.
+var footprintDiskFields = map[string]bool{
+	"allocatedstorage": true,
+	"disksizegb":       true,
+	"disksize":         true,
+	"volumesize":       true,
+	"storagegb":        true,
+	"storagesize":      true,
+}
+
+// kindFootprint is the aggregated footprint of all composed resources of a
+// single kind.
+type kindFootprint struct {
+	Count  int
+	Sizes  map[string]int
+	Nodes  int64
+	DiskGB float64
+}
+
+// Footprint summarizes the infrastructure requested by a set of rendered
+// composed resources, keyed by kind.group.
+type Footprint map[string]*kindFootprint
+
+// ComputeFootprint walks the spec of each composed resource and aggregates
+// well-known size, node count and disk fields. This is a best-effort
+// heuristic - providers name these fields inconsistently.
+func ComputeFootprint(cds []composed.Unstructured) Footprint {
+	fp := Footprint{}
+	for i := range cds {
+		gvk := cds[i].GetObjectKind().GroupVersionKind()
+		key := gvk.Kind
+		if gvk.Group != "" {
+			key = fmt.Sprintf("%s.%s", gvk.Kind, gvk.Group)
+		}
+
+		kf, ok := fp[key]
+		if !ok {
+			kf = &kindFootprint{Sizes: map[string]int{}}
+			fp[key] = kf
+		}
+		kf.Count++
+
+		spec, ok := cds[i].Object["spec"].(map[string]any)
+		if !ok {
+			continue
+		}
+		// Managed resources carry their configuration in forProvider.
+		if forProvider, ok := spec["forProvider"].(map[string]any); ok {
+			spec = forProvider
+		}
+		kf.walk(spec)
+	}
+	return fp
+}
+
+func (kf *kindFootprint) walk(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, fv := range t {
+			key := strings.ToLower(k)
+			switch {
+			case footprintSizeFields[key]:
+				kf.addSize(fv)
+			case footprintNodeFields[key]:
+				if n, ok := footprintNumber(fv); ok {
+					kf.Nodes += int64(n)
+				}
+			case footprintDiskFields[key]:
+				if gb, ok := footprintDiskGB(fv); ok {
+					kf.DiskGB += gb
+				}
+			default:
+				kf.walk(fv)
+			}
+		}
+	case []any:
+		for _, e := range t {
+			kf.walk(e)
+		}
+	}
+}
+
+func (kf *kindFootprint) addSize(v any) {
+	switch t := v.(type) {
+	case string:
+		if t != "" {
+			kf.Sizes[t]++
+		}
+	case []any:
+		for _, e := range t {
+			kf.addSize(e)
+		}
+	}
+}
+
+func footprintNumber(v any) (float64, bool) {
+	switch t := v.(type) {
+	case int64:
+		return float64(t), true
+	case float64:
+		return t, true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func footprintDiskGB(v any) (float64, bool) {
+	if n, ok := footprintNumber(v); ok {
+		return n, true
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil {
+		return 0, false
+	}
+	return float64(q.Value()) / 1e9, true
+}
+
+// Print writes the footprint as a table, one row per kind.
+func (fp Footprint) Print(w io.Writer) error {
+	keys := make([]string, 0, len(fp))
+	for k := range fp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KIND\tCOUNT\tSIZES\tNODES\tDISK (GB)")
+
+	total := 0
+	for _, k := range keys {
+		kf := fp[k]
+		total += kf.Count
+
+		sizes := make([]string, 0, len(kf.Sizes))
+		for s, n := range kf.Sizes {
+			sizes = append(sizes, fmt.Sprintf("%s x%d", s, n))
+		}
+		sort.Strings(sizes)
+
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", k, kf.Count, footprintCell(strings.Join(sizes, ", ")), footprintCell(kf.Nodes), footprintCell(kf.DiskGB))
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t%d\t\t\t\n", total)
+
+	return tw.Flush()
+}
+
+func footprintCell(v any) string {
+	switch t := v.(type) {
+	case string:
+		if t == "" {
+			return "-"
+		}
+		return t
+	case int64:
+		if t == 0 {
+			return "-"
+		}
+		return strconv.FormatInt(t, 10)
+	case float64:
+		if t == 0 {
+			return "-"
+		}
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
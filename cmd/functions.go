@@ -7,7 +7,6 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -222,14 +221,26 @@ func inferPackageFromFunctionName(ctx context.Context, name string, fs afero.Fs,
 		return name, nil
 	}
 
+	// Functions in the functions map use their explicit package or repository
+	fnMap, err := loadFunctionsMap(fs, getFunctionsMapPath())
+	if err != nil {
+		return "", err
+	}
+	if m, ok := fnMap.Functions[name]; ok && m.Package != "" {
+		return m.Package, nil
+	}
+
 	// Map function name to GitHub repository
-	owner, repo, err := mapFunctionNameToGitHubRepo(name)
+	registry, owner, repo, err := mapFunction(fnMap, name)
 	if err != nil {
 		return "", fmt.Errorf("cannot map function name to GitHub repository: %w", err)
 	}
 
-	// Create cache key from owner/repo
+	// Create cache key from owner/repo, and the version constraint if any
 	cacheKey := fmt.Sprintf("%s/%s", owner, repo)
+	if c := functionConstraints[name]; c != "" {
+		cacheKey += " " + c
+	}
 
 	// Load cache
 	cache, err := loadCache(fs)
@@ -245,10 +256,21 @@ func inferPackageFromFunctionName(ctx context.Context, name string, fs afero.Fs,
 	version, found := getCachedVersion(cache, cacheKey)
 	if found && !forceRefresh {
 		// Cache hit - use cached version immediately
-		_, _ = fmt.Fprintf(os.Stderr, "INFO: Using cached function version %s:%s from %s\n", cacheKey, version, cachePath)
+		versionCacheLookups.WithLabelValues("hit").Inc()
+		infof("Using cached function version %s:%s from %s", cacheKey, version, cachePath)
+	} else if offline {
+		// Offline - any cached version will do, even if it has expired
+		entry, ok := cache.Versions[cacheKey]
+		if !ok {
+			return "", fmt.Errorf("running offline, but no version of %s is cached in %s: run once online, pin it with crossbench lock, or pass a functions file", cacheKey, cachePath)
+		}
+		version = entry.Version
+		versionCacheLookups.WithLabelValues("stale").Inc()
+		infof("Running offline, using cached function version %s:%s from %s", cacheKey, version, cachePath)
 	} else {
-		// Cache miss or expired - fetch latest release version from GitHub
-		version, err = fetchLatestReleaseVersion(ctx, owner, repo)
+		// Cache miss or expired - fetch latest version from GitHub or the registry
+		versionCacheLookups.WithLabelValues("miss").Inc()
+		version, err = fetchLatestVersion(ctx, name, registry, owner, repo)
 		if err != nil {
 			// If rate limited, try to use stale cache if available
 			if rateLimitErr, ok := err.(*RateLimitError); ok {
@@ -256,7 +278,8 @@ func inferPackageFromFunctionName(ctx context.Context, name string, fs afero.Fs,
 				if staleEntry, hasStale := cache.Versions[cacheKey]; hasStale {
 					// Use stale cache as fallback when rate limited
 					version = staleEntry.Version
-					_, _ = fmt.Fprintf(os.Stderr, "WARN: Received rate limit from GitHub for %s, falling back to cached version %s from %s\n", cacheKey, version, cachePath)
+					versionCacheLookups.WithLabelValues("stale").Inc()
+					warnf("Received rate limit from GitHub for %s, falling back to cached version %s from %s", cacheKey, version, cachePath)
 					// Don't update cache timestamp, keep it as stale
 				} else {
 					return "", fmt.Errorf("cannot fetch latest version for %s/%s: %w (no cached version available)", owner, repo, rateLimitErr)
@@ -269,14 +292,11 @@ func inferPackageFromFunctionName(ctx context.Context, name string, fs afero.Fs,
 			setCachedVersion(cache, cacheKey, version)
 			if err := saveCache(fs, cache); err != nil {
 				// Log but don't fail if cache save fails
-				_, _ = fmt.Fprintf(os.Stderr, "WARN: Failed to save cache to %s: %v\n", cachePath, err)
+				warnf("Failed to save cache to %s: %v", cachePath, err)
 			}
 		}
 	}
 
-	// Determine the package registry based on function name
-	registry := getPackageRegistry(name)
-
 	// Construct the package reference
 	packageName := fmt.Sprintf("%s/%s/%s:%s", registry, owner, repo, version)
 	return packageName, nil
@@ -344,6 +364,10 @@ func getUpboundFunctionNames() []string {
 // getPackageRegistry returns the appropriate package registry for a given function name.
 // Most functions use the default registry, but some use Upbound registry
 func getPackageRegistry(functionName string) string {
+	// Marketplace functions are published to the Upbound registry
+	if resolverFor(functionName) == VersionResolverMarketplace {
+		return getUpboundPackageRegistry()
+	}
 	// Check if this function should use Upbound registry
 	upboundFunctions := getUpboundFunctionNames()
 	for _, fn := range upboundFunctions {
@@ -390,35 +414,9 @@ func getGitHubAPIURL() string {
 	return "https://api.github.com"
 }
 
-// getGitHubToken retrieves a GitHub token from environment or gh CLI
-func getGitHubToken() string {
-	// Check CROSSBENCH_GITHUB_TOKEN first (project-specific)
-	if token := os.Getenv("CROSSBENCH_GITHUB_TOKEN"); token != "" {
-		return token
-	}
-	
-	// Check standard GITHUB_TOKEN environment variable
-	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
-		return token
-	}
-	
-	// Try to get token from gh CLI
-	cmd := exec.Command("gh", "auth", "token")
-	output, err := cmd.Output()
-	if err == nil && len(output) > 0 {
-		// Remove trailing newline
-		token := strings.TrimSpace(string(output))
-		if token != "" {
-			return token
-		}
-	}
-	
-	return ""
-}
-
 // fetchLatestReleaseVersion fetches the latest release version from GitHub API.
 func fetchLatestReleaseVersion(ctx context.Context, owner, repo string) (string, error) {
-	baseURL := getGitHubAPIURL()
+	baseURL, token := gitHubCredentials.Endpoint(owner, repo)
 	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest", baseURL, owner, repo)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -430,7 +428,7 @@ func fetchLatestReleaseVersion(ctx context.Context, owner, repo string) (string,
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	
 	// Add authentication token if available
-	if token := getGitHubToken(); token != "" {
+	if token != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 
@@ -443,11 +441,13 @@ func fetchLatestReleaseVersion(ctx context.Context, owner, repo string) (string,
 		return "", fmt.Errorf("failed to fetch release: %w", err)
 	}
 	defer resp.Body.Close()
+	recordGitHubRateLimit(resp)
 
 	if resp.StatusCode == http.StatusForbidden {
 		// Check if this is a rate limit error
 		body, _ := io.ReadAll(resp.Body)
 		if strings.Contains(string(body), "rate limit") || strings.Contains(string(body), "API rate limit") {
+			gitHubRateLimitedTotal.Inc()
 			return "", &RateLimitError{Message: fmt.Sprintf("GitHub API rate limit exceeded: %s", string(body))}
 		}
 		return "", fmt.Errorf("GitHub API returned status %d: %s", resp.StatusCode, string(body))
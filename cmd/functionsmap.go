@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// A FunctionsMap maps Function names to their packages, for Functions whose
+// names don't follow the crossplane-contrib naming pattern. It's consulted
+// before a Function's package is inferred from its name.
+type FunctionsMap struct {
+	// Functions are keyed by Function name.
+	Functions map[string]FunctionMapping `json:"functions"`
+}
+
+// A FunctionMapping is where a Function's package comes from.
+type FunctionMapping struct {
+	// Package is an explicit package reference, e.g.
+	// ghcr.io/acme/function-naming:v1.2.0. It's used as-is.
+	Package string `json:"package,omitempty"`
+
+	// Repository is the owner/name of the Function's repository. Its latest
+	// version is resolved like any other Function's.
+	Repository string `json:"repository,omitempty"`
+
+	// Registry the Repository's packages are pushed to. Defaults to the
+	// registry the Function would otherwise use.
+	Registry string `json:"registry,omitempty"`
+}
+
+// getFunctionsMapPath returns the path of the functions map
+// Default: functions-map.yaml, configurable via CROSSBENCH_FUNCTIONS_MAP env var
+func getFunctionsMapPath() string {
+	if path := os.Getenv("CROSSBENCH_FUNCTIONS_MAP"); path != "" {
+		return path
+	}
+	return "functions-map.yaml"
+}
+
+// loadFunctionsMap loads the functions map at the supplied path. A missing
+// file is an empty map.
+func loadFunctionsMap(fs afero.Fs, path string) (*FunctionsMap, error) {
+	m := &FunctionsMap{Functions: map[string]FunctionMapping{}}
+	data, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read functions map %q", path)
+	}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse functions map %q", path)
+	}
+	for name, fm := range m.Functions {
+		if fm.Package == "" && fm.Repository == "" {
+			return nil, errors.Errorf("function %q in functions map %q needs a package or a repository", name, path)
+		}
+		if fm.Package == "" {
+			if owner, repo, ok := strings.Cut(fm.Repository, "/"); !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+				return nil, errors.Errorf("repository %q of function %q in functions map %q must be owner/name", fm.Repository, name, path)
+			}
+		}
+	}
+	return m, nil
+}
+
+// mapFunction returns the package repository of the named Function: the
+// package registry, and the owner and name of its repository. Functions in the
+// functions map use their mapping; others are mapped by name.
+func mapFunction(m *FunctionsMap, name string) (registry, owner, repo string, err error) {
+	fm, ok := m.Functions[name]
+	if !ok || fm.Repository == "" {
+		owner, repo, err = mapFunctionNameToGitHubRepo(name)
+		return getPackageRegistry(name), owner, repo, err
+	}
+	owner, repo, _ = strings.Cut(fm.Repository, "/")
+	registry = fm.Registry
+	if registry == "" {
+		registry = getPackageRegistry(name)
+	}
+	return registry, owner, repo, nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// GitHub authentication modes. Auto tries every source in order: the
+// --github-token flag, environment variables, the configuration file, the gh
+// CLI and finally anonymous access. The other modes only use one source.
+const (
+	GitHubAuthModeAuto      = "auto"
+	GitHubAuthModeNoGH      = "no-gh"
+	GitHubAuthModeFlag      = "flag"
+	GitHubAuthModeEnv       = "env"
+	GitHubAuthModeConfig    = "config"
+	GitHubAuthModeGH        = "gh"
+	GitHubAuthModeAnonymous = "anonymous"
+)
+
+var gitHubAuthModes = []string{
+	GitHubAuthModeAuto, GitHubAuthModeNoGH, GitHubAuthModeFlag, GitHubAuthModeEnv,
+	GitHubAuthModeConfig, GitHubAuthModeGH, GitHubAuthModeAnonymous,
+}
+
+// gitHubCredentials is the credential chain used for GitHub API requests.
+// Commands replace it once their flags and configuration are loaded.
+var gitHubCredentials = &gitHubCredentialChain{mode: getGitHubAuthMode()}
+
+// getGitHubAuthMode returns how crossbench authenticates to the GitHub API
+// Default: auto, configurable via CROSSBENCH_GITHUB_AUTH_MODE env var
+func getGitHubAuthMode() string {
+	if mode := os.Getenv("CROSSBENCH_GITHUB_AUTH_MODE"); mode != "" {
+		return mode
+	}
+	return GitHubAuthModeAuto
+}
+
+// A gitHubCredentialChain resolves the GitHub token to use from the sources
+// allowed by its mode. The token is resolved once, and the source it came
+// from is logged.
+type gitHubCredentialChain struct {
+	mode        string
+	flagToken   string
+	configToken string
+	hosts       []GitHubHost
+
+	once  sync.Once
+	token string
+}
+
+// newGitHubCredentialChain returns a credential chain for the supplied mode,
+// token flag and GitHub configuration.
+func newGitHubCredentialChain(mode, flagToken string, cfg GitHubConfig) (*gitHubCredentialChain, error) {
+	for _, h := range cfg.Hosts {
+		if h.APIURL == "" {
+			return nil, errors.Errorf("GitHub host for %s has no apiURL", strings.Join(h.Repositories, ", "))
+		}
+		for _, r := range h.Repositories {
+			if _, err := path.Match(r, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid GitHub host repository pattern %q", r)
+			}
+		}
+	}
+	for _, m := range gitHubAuthModes {
+		if m == mode {
+			return &gitHubCredentialChain{mode: mode, flagToken: flagToken, configToken: cfg.Token, hosts: cfg.Hosts}, nil
+		}
+	}
+	return nil, errors.Errorf("unknown GitHub auth mode %q: must be one of %s", mode, strings.Join(gitHubAuthModes, ", "))
+}
+
+// Endpoint returns the API URL and token to use for the supplied repository.
+// Repositories served by a configured host use its API and token; all others
+// use the default API and the token from the chain. Host patterns are
+// owner/repository globs, or a bare owner for all of its repositories.
+func (c *gitHubCredentialChain) Endpoint(owner, repo string) (apiURL, token string) {
+	for _, h := range c.hosts {
+		for _, r := range h.Repositories {
+			// A bare owner matches all of its repositories.
+			if !strings.Contains(r, "/") {
+				r += "/*"
+			}
+			if ok, _ := path.Match(r, owner+"/"+repo); !ok {
+				continue
+			}
+			token := h.Token
+			if h.TokenEnv != "" {
+				token = os.Getenv(h.TokenEnv)
+			}
+			return strings.TrimSuffix(h.APIURL, "/"), token
+		}
+	}
+	return getGitHubAPIURL(), c.Token()
+}
+
+// Token returns the GitHub token, or an empty string for anonymous access.
+func (c *gitHubCredentialChain) Token() string {
+	c.once.Do(func() {
+		token, source := c.resolve()
+		if token == "" {
+			infof("Using anonymous GitHub API access (auth mode %q)", c.mode)
+			return
+		}
+		infof("Using GitHub token from %s", source)
+		c.token = token
+	})
+	return c.token
+}
+
+// resolve returns the first token found in the sources allowed by the mode,
+// and a description of its source.
+func (c *gitHubCredentialChain) resolve() (token, source string) {
+	use := func(mode string) bool {
+		switch c.mode {
+		case GitHubAuthModeAuto:
+			return true
+		case GitHubAuthModeNoGH:
+			return mode != GitHubAuthModeGH
+		default:
+			return c.mode == mode
+		}
+	}
+
+	if use(GitHubAuthModeFlag) && c.flagToken != "" {
+		return c.flagToken, "the --github-token flag"
+	}
+	if use(GitHubAuthModeEnv) {
+		for _, env := range []string{"CROSSBENCH_GITHUB_TOKEN", "GITHUB_TOKEN"} {
+			if token := os.Getenv(env); token != "" {
+				return token, env
+			}
+		}
+	}
+	if use(GitHubAuthModeConfig) && c.configToken != "" {
+		return c.configToken, "the configuration file"
+	}
+	if use(GitHubAuthModeGH) {
+		if out, err := exec.Command("gh", "auth", "token").Output(); err == nil {
+			if token := strings.TrimSpace(string(out)); token != "" {
+				return token, "the gh CLI"
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// GRPCConfig tunes the gRPC connections to Functions.
+type GRPCConfig struct {
+	// MaxMessageSize is the largest request or response, e.g. 64Mi. Defaults
+	// to gRPC's 4Mi limit on responses. Functions enforce their own limit on
+	// requests.
+	MaxMessageSize string `json:"maxMessageSize,omitempty"`
+
+	// Keepalive is how often connections are pinged while idle. Functions
+	// may close connections that ping more often than they allow.
+	Keepalive *metav1.Duration `json:"keepalive,omitempty"`
+
+	// KeepaliveTimeout is how long to wait for a ping to be acknowledged
+	// before closing the connection. Defaults to 20s.
+	KeepaliveTimeout *metav1.Duration `json:"keepaliveTimeout,omitempty"`
+
+	// Compression of requests. Only gzip is supported, and the Function must
+	// support it too. Message size limits apply to decompressed messages, so
+	// compression doesn't let larger requests through.
+	Compression string `json:"compression,omitempty"`
+}
+
+// pipelineOptions returns the options that apply the tuning to the Function
+// pipeline.
+func (g GRPCConfig) pipelineOptions() (pipelineOptions, error) {
+	o := pipelineOptions{}
+	if g.MaxMessageSize != "" {
+		q, err := kresource.ParseQuantity(g.MaxMessageSize)
+		if err != nil {
+			return o, errors.Wrapf(err, "invalid gRPC max message size %q", g.MaxMessageSize)
+		}
+		n, ok := q.AsInt64()
+		if !ok || n <= 0 || n > int64(^uint32(0)>>1) {
+			return o, errors.Errorf("invalid gRPC max message size %q, must be between 1 and 2Gi", g.MaxMessageSize)
+		}
+		o.CallOptions = append(o.CallOptions, grpc.MaxCallRecvMsgSize(int(n)), grpc.MaxCallSendMsgSize(int(n)))
+	}
+	if g.Keepalive != nil && g.Keepalive.Duration > 0 {
+		p := keepalive.ClientParameters{Time: g.Keepalive.Duration, Timeout: 20 * time.Second, PermitWithoutStream: true}
+		if g.KeepaliveTimeout != nil && g.KeepaliveTimeout.Duration > 0 {
+			p.Timeout = g.KeepaliveTimeout.Duration
+		}
+		o.DialOptions = append(o.DialOptions, grpc.WithKeepaliveParams(p))
+	}
+	switch g.Compression {
+	case "", "none":
+	case gzip.Name:
+		o.CallOptions = append(o.CallOptions, grpc.UseCompressor(gzip.Name))
+	default:
+		return o, errors.Errorf("unsupported gRPC compression %q, must be gzip or none", g.Compression)
+	}
+	return o, nil
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/beta/validate"
+
+	crossbenchv1 "github.com/gjbravi/crossbench/proto/crossbench/v1"
+)
+
+// renderServer serves the crossbench gRPC API with the inputs and flags of a
+// serve command.
+type renderServer struct {
+	crossbenchv1.UnimplementedRenderServiceServer
+
+	serve *serveCmd
+}
+
+// Render renders a composite resource, and streams each rendered document.
+func (s *renderServer) Render(req *crossbenchv1.RenderRequest, stream grpc.ServerStreamingServer[crossbenchv1.RenderResponse]) error {
+	dir, err := os.MkdirTemp("", "crossbench-serve-")
+	if err != nil {
+		return status.Errorf(codes.Internal, "cannot create request directory: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	rc, args, err := s.requestCmd(stream.Context(), dir, req.GetInputs())
+	if err != nil {
+		return err
+	}
+	in, err := s.serve.loadRequestInputs(rc, args)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	out, err := rc.render(in)
+	if err == nil && req.GetIncludeFullXr() {
+		err = includeFullXR(in, out)
+	}
+	if err != nil {
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	type document struct {
+		t   crossbenchv1.DocumentType
+		obj kruntime.Object
+	}
+	docs := []document{{crossbenchv1.DocumentType_DOCUMENT_TYPE_COMPOSITE_RESOURCE, out.CompositeResource}}
+	for i := range out.ComposedResources {
+		docs = append(docs, document{crossbenchv1.DocumentType_DOCUMENT_TYPE_COMPOSED_RESOURCE, &out.ComposedResources[i]})
+	}
+	if req.GetIncludeFunctionResults() {
+		for i := range out.Results {
+			docs = append(docs, document{crossbenchv1.DocumentType_DOCUMENT_TYPE_RESULT, &out.Results[i]})
+		}
+	}
+	if req.GetIncludeContext() {
+		docs = append(docs, document{crossbenchv1.DocumentType_DOCUMENT_TYPE_CONTEXT, out.Context})
+	}
+
+	ser := json.NewSerializerWithOptions(json.DefaultMetaFactory, nil, nil, json.SerializerOptions{Yaml: true})
+	for _, d := range docs {
+		buf := &bytes.Buffer{}
+		if err := ser.Encode(d.obj, buf); err != nil {
+			return status.Errorf(codes.Internal, "cannot marshal rendered document to YAML: %v", err)
+		}
+		if err := stream.Send(&crossbenchv1.RenderResponse{Type: d.t, Yaml: buf.String()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate renders a composite resource, then validates it and its composed
+// resources against the supplied schemas.
+func (s *renderServer) Validate(ctx context.Context, req *crossbenchv1.ValidateRequest) (*crossbenchv1.ValidateResponse, error) {
+	if req.GetSchemas() == "" {
+		return nil, status.Error(codes.InvalidArgument, "schemas are required")
+	}
+	dir, err := os.MkdirTemp("", "crossbench-serve-")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot create request directory: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	rc, args, err := s.requestCmd(ctx, dir, req.GetInputs())
+	if err != nil {
+		return nil, err
+	}
+	schemas := filepath.Join(dir, "schemas.yaml")
+	if err := os.WriteFile(schemas, []byte(req.GetSchemas()), 0644); err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot write schemas: %v", err)
+	}
+	crds, err := loadSchemas(schemas)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	in, err := s.serve.loadRequestInputs(rc, args)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	out, err := rc.render(in)
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	resources := make([]*unstructured.Unstructured, 0, len(out.ComposedResources)+1)
+	resources = append(resources, &in.CompositeResource.Unstructured)
+	for i := range out.ComposedResources {
+		resources = append(resources, &out.ComposedResources[i].Unstructured)
+	}
+	report := &bytes.Buffer{}
+	err = validate.SchemaValidation(ctx, resources, crds, req.GetErrorOnMissingSchemas(), false, report)
+	return &crossbenchv1.ValidateResponse{Valid: err == nil, Report: report.String()}, nil
+}
+
+// ResolveFunctions returns the Functions the pipeline of a Composition runs.
+func (s *renderServer) ResolveFunctions(ctx context.Context, req *crossbenchv1.ResolveFunctionsRequest) (*crossbenchv1.ResolveFunctionsResponse, error) {
+	if req.GetComposition() == "" {
+		return nil, status.Error(codes.InvalidArgument, "composition is required")
+	}
+	dir, err := os.MkdirTemp("", "crossbench-serve-")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot create request directory: %v", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	file := filepath.Join(dir, "composition.yaml")
+	if err := os.WriteFile(file, []byte(req.GetComposition()), 0644); err != nil {
+		return nil, status.Errorf(codes.Internal, "cannot write composition: %v", err)
+	}
+	rc := s.serve.renderCmd
+	rc.ctx = ctx
+	comp, err := loadComposition(rc.fs, file)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot load Composition: %v", err)
+	}
+
+	s.serve.resolve.Lock()
+	fns, err := rc.resolveFunctions(rc.context(), comp, nil)
+	if err == nil {
+		err = rc.overrideFunctions(fns)
+	}
+	s.serve.resolve.Unlock()
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+
+	rsp := &crossbenchv1.ResolveFunctionsResponse{}
+	for _, fn := range fns {
+		rsp.Functions = append(rsp.Functions, &crossbenchv1.Function{
+			Name:        fn.GetName(),
+			Package:     fn.Spec.Package,
+			Annotations: fn.GetAnnotations(),
+		})
+	}
+	return rsp, nil
+}
+
+// requestCmd writes the supplied inputs to dir, and returns the command and
+// arguments that load them.
+func (s *renderServer) requestCmd(ctx context.Context, dir string, in *crossbenchv1.RenderInputs) (*renderCmd, []string, error) {
+	if in.GetCompositeResource() == "" || in.GetComposition() == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "composite resource and composition are required")
+	}
+	rc, args, err := s.serve.requestCmd(ctx, dir, serveInputs{
+		XR:                in.GetCompositeResource(),
+		Composition:       in.GetComposition(),
+		Functions:         in.GetFunctions(),
+		ObservedResources: in.GetObservedResources(),
+		ExtraResources:    in.GetExtraResources(),
+		Context:           in.GetContext(),
+	})
+	if err != nil {
+		return nil, nil, status.Error(codes.Internal, err.Error())
+	}
+	return rc, args, nil
+}
+
+// traceUnary runs each unary call in a span named after its method.
+func traceUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, span := tracer.Start(ctx, info.FullMethod)
+	defer span.End()
+	rsp, err := handler(ctx, req)
+	recordSpanError(span, err)
+	return rsp, err
+}
+
+// traceStream runs each streaming call in a span named after its method.
+func traceStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := tracer.Start(ss.Context(), info.FullMethod)
+	defer span.End()
+	err := handler(srv, &tracedStream{ServerStream: ss, ctx: ctx})
+	recordSpanError(span, err)
+	return err
+}
+
+// A tracedStream is a server stream whose context carries its span.
+type tracedStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,488 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// NewImportCrankCommand creates a new import-crank command.
+func NewImportCrankCommand() *cobra.Command {
+	cmd := &importCrankCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "import-crank <script-or-makefile>...",
+		Short: "Convert crossplane render invocations into presets and tests",
+		Long: `Import-crank reads shell scripts and Makefiles that run crossplane render (or
+crossplane beta render), and adds an equivalent preset to the configuration
+file for each invocation, so it can be run with crossbench render --preset. A
+test scaffold per preset is written to --tests-dir, with a snapshot to record
+with crossbench test --update-snapshots.
+
+Variables assigned in the file, e.g. XR=xr.yaml or XR := xr.yaml, are
+expanded. Invocations using other variables, or flags crossbench has no
+equivalent for, are reported and skipped. Relative paths are taken to be
+relative to the file they're read from.
+
+Presets are named after the file the output is redirected to, or otherwise the
+XR file. Existing presets and test files are kept unless --force is set.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Configuration file to add the presets to. It's created if it doesn't exist.")
+	cobraCmd.Flags().StringVar(&cmd.testsDir, "tests-dir", "tests", "Directory to write a test scaffold per preset to. Set to an empty string to skip them.")
+	cobraCmd.Flags().BoolVar(&cmd.force, "force", false, "Replace presets and test files that already exist.")
+
+	return cobraCmd
+}
+
+type importCrankCmd struct {
+	config   string
+	testsDir string
+	force    bool
+
+	fs afero.Fs
+}
+
+// A crankInvocation is a crossplane render command found in a file.
+type crankInvocation struct {
+	// Name of the preset, before it's made unique.
+	Name   string
+	Preset RenderPreset
+}
+
+func (c *importCrankCmd) run(_ *cobra.Command, args []string) error {
+	invocations := []crankInvocation{}
+	for _, file := range args {
+		data, err := afero.ReadFile(c.fs, file)
+		if err != nil {
+			return errors.Wrapf(err, "cannot read %q", file)
+		}
+		found := parseCrankInvocations(string(data), filepath.Dir(file), file)
+		if len(found) == 0 {
+			warnf("No crossplane render invocations found in %s", file)
+		}
+		invocations = append(invocations, found...)
+	}
+	if len(invocations) == 0 {
+		return errors.New("no crossplane render invocations to import")
+	}
+
+	// Keep every other setting of an existing configuration file.
+	cfg := map[string]any{}
+	if data, err := afero.ReadFile(c.fs, c.config); err == nil {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return errors.Wrapf(err, "cannot parse config file %q", c.config)
+		}
+		if cfg == nil {
+			cfg = map[string]any{}
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "cannot read config file %q", c.config)
+	}
+	presets, _ := cfg["presets"].(map[string]any)
+	if presets == nil {
+		presets = map[string]any{}
+	}
+
+	configDir := filepath.Dir(c.config)
+	used := map[string]bool{}
+	imported := 0
+	for _, inv := range invocations {
+		name := inv.Name
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s-%d", inv.Name, i)
+		}
+		used[name] = true
+
+		if _, ok := presets[name]; ok && !c.force {
+			warnf("Preset %q already exists in %s, keeping it", name, c.config)
+			continue
+		}
+		p, err := relativePreset(inv.Preset, configDir)
+		if err != nil {
+			return err
+		}
+		presets[name] = p
+		imported++
+		infof("Imported preset %q", name)
+
+		if c.testsDir == "" {
+			continue
+		}
+		if err := c.writeTestScaffold(name, inv.Preset); err != nil {
+			return err
+		}
+	}
+
+	cfg["presets"] = presets
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal config file")
+	}
+	if err := afero.WriteFile(c.fs, c.config, data, 0644); err != nil {
+		return errors.Wrapf(err, "cannot write config file %q", c.config)
+	}
+	infof("Wrote %d presets to %s", imported, c.config)
+	if c.testsDir != "" && imported > 0 {
+		infof("Record the test snapshots with crossbench test %s --update-snapshots", c.testsDir)
+	}
+	return nil
+}
+
+// writeTestScaffold writes a test of the supplied preset, whose output is
+// compared with a snapshot.
+func (c *importCrankCmd) writeTestScaffold(name string, p RenderPreset) error {
+	file := filepath.Join(c.testsDir, name+".test.yaml")
+	if _, err := c.fs.Stat(file); err == nil && !c.force {
+		warnf("Test file %s already exists, keeping it", file)
+		return nil
+	}
+	rp, err := relativePreset(p, c.testsDir)
+	if err != nil {
+		return err
+	}
+	suite := TestSuite{Tests: []TestCase{{
+		Name:                name,
+		XR:                  rp.XR,
+		Composition:         rp.Composition,
+		Functions:           rp.Functions,
+		ObservedResources:   rp.ObservedResources,
+		ExtraResources:      rp.ExtraResources,
+		FunctionCredentials: rp.FunctionCredentials,
+		ContextFiles:        rp.ContextFiles,
+		ContextValues:       rp.ContextValues,
+		Snapshot:            filepath.Join("snapshots", name+".yaml"),
+	}}}
+	data, err := yaml.Marshal(suite)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal test file")
+	}
+	if err := c.fs.MkdirAll(c.testsDir, 0755); err != nil {
+		return errors.Wrapf(err, "cannot create tests directory %q", c.testsDir)
+	}
+	return errors.Wrapf(afero.WriteFile(c.fs, file, data, 0644), "cannot write test file %q", file)
+}
+
+// relativePreset returns a copy of the supplied preset, whose paths are
+// relative to dir.
+func relativePreset(p RenderPreset, dir string) (RenderPreset, error) {
+	var err error
+	rel := func(path string) string {
+		if path == "" || err != nil {
+			return path
+		}
+		abs, aerr := filepath.Abs(path)
+		if aerr != nil {
+			err = aerr
+			return path
+		}
+		base, aerr := filepath.Abs(dir)
+		if aerr != nil {
+			err = aerr
+			return path
+		}
+		r, rerr := filepath.Rel(base, abs)
+		if rerr != nil {
+			return abs
+		}
+		return r
+	}
+	out := p
+	out.XR = rel(p.XR)
+	out.Composition = rel(p.Composition)
+	out.Functions = rel(p.Functions)
+	out.ObservedResources = rel(p.ObservedResources)
+	out.ExtraResources = rel(p.ExtraResources)
+	out.FunctionCredentials = rel(p.FunctionCredentials)
+	if len(p.ContextFiles) > 0 {
+		out.ContextFiles = make(map[string]string, len(p.ContextFiles))
+		for k, v := range p.ContextFiles {
+			out.ContextFiles[k] = rel(v)
+		}
+	}
+	return out, errors.Wrap(err, "cannot resolve preset paths")
+}
+
+var (
+	// crankAssignment matches variable assignments of Makefiles and shell
+	// scripts.
+	crankAssignment = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*(?:\?=|:=|::=|=)\s*(.*)$`)
+
+	// crankVariable matches references to variables: $(VAR), ${VAR} or $VAR.
+	crankVariable = regexp.MustCompile(`\$\(([A-Za-z_][A-Za-z0-9_]*)\)|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+	// presetNameInvalid matches the characters preset names can't contain.
+	presetNameInvalid = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// parseCrankInvocations returns the crossplane render invocations of the
+// supplied shell script or Makefile. Relative paths are resolved against dir.
+// Invocations that can't be converted are reported and skipped.
+func parseCrankInvocations(script, dir, file string) []crankInvocation {
+	// Join continued lines.
+	script = strings.ReplaceAll(script, "\\\r\n", " ")
+	script = strings.ReplaceAll(script, "\\\n", " ")
+
+	vars := map[string]string{}
+	out := []crankInvocation{}
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(strings.ReplaceAll(line, "$$", "$"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := crankAssignment.FindStringSubmatch(line); m != nil && !strings.Contains(line, "crossplane") {
+			vars[m[1]] = strings.Trim(expandCrankVariables(strings.TrimSpace(m[2]), vars), `"'`)
+			continue
+		}
+		line = strings.TrimLeft(line, "@-+")
+
+		for _, words := range splitShellCommands(expandCrankVariables(line, vars)) {
+			inv, ok, err := parseCrankRender(words, dir)
+			if err != nil {
+				warnf("Skipping crossplane render at %s:%d: %v", file, i+1, err)
+				continue
+			}
+			if ok {
+				out = append(out, inv)
+			}
+		}
+	}
+	return out
+}
+
+// expandCrankVariables expands the references to known variables in s.
+// Unknown variables are left as they are.
+func expandCrankVariables(s string, vars map[string]string) string {
+	return crankVariable.ReplaceAllStringFunc(s, func(ref string) string {
+		m := crankVariable.FindStringSubmatch(ref)
+		name := m[1] + m[2] + m[3]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+// splitShellCommands splits a line of shell into the words of each command it
+// runs, honoring quotes and backslash escapes. Redirections are kept as words
+// of their own, e.g. > and out.yaml.
+func splitShellCommands(line string) [][]string {
+	cmds := [][]string{}
+	words := []string{}
+	word := &strings.Builder{}
+	inWord := false
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			cmds = append(cmds, words)
+		}
+		words = []string{}
+	}
+
+	var quote rune
+	rs := []rune(line)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			if r == '\\' && quote == '"' && i+1 < len(rs) {
+				i++
+				r = rs[i]
+			}
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\' && i+1 < len(rs):
+			i++
+			word.WriteRune(rs[i])
+			inWord = true
+		case r == '$' && i+1 < len(rs) && (rs[i+1] == '(' || rs[i+1] == '{'):
+			// Keep unexpanded variables whole.
+			closing := ')'
+			if rs[i+1] == '{' {
+				closing = '}'
+			}
+			j := i + 1
+			for j < len(rs)-1 && rs[j] != closing {
+				j++
+			}
+			word.WriteString(string(rs[i : j+1]))
+			i = j
+			inWord = true
+		case r == ' ' || r == '\t':
+			endWord()
+		case r == ';' || r == '|' || r == '&' || r == '(' || r == ')':
+			endCommand()
+		case r == '>':
+			endWord()
+			if i+1 < len(rs) && rs[i+1] == '>' {
+				i++
+			}
+			words = append(words, ">")
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand()
+	return cmds
+}
+
+// parseCrankRender converts the supplied command to a preset if it runs
+// crossplane render. It returns false if it doesn't.
+func parseCrankRender(words []string, dir string) (crankInvocation, bool, error) {
+	start := -1
+	for i := 0; i+1 < len(words); i++ {
+		if filepath.Base(words[i]) != "crossplane" {
+			continue
+		}
+		switch {
+		case words[i+1] == "render":
+			start = i + 2
+		case words[i+1] == "beta" && i+2 < len(words) && words[i+2] == "render":
+			start = i + 3
+		}
+		break
+	}
+	if start < 0 {
+		return crankInvocation{}, false, nil
+	}
+
+	// Split off redirections of the output.
+	args := []string{}
+	output := ""
+	for i := start; i < len(words); i++ {
+		if words[i] == ">" {
+			if i+1 < len(words) {
+				output = words[i+1]
+			}
+			i++
+			continue
+		}
+		args = append(args, words[i])
+	}
+	for _, a := range args {
+		if strings.Contains(a, "$") {
+			return crankInvocation{}, true, errors.Errorf("%q uses a variable that isn't assigned in the file", a)
+		}
+	}
+
+	fs := pflag.NewFlagSet("crossplane render", pflag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	contextFiles := fs.StringArray("context-files", nil, "")
+	contextValues := fs.StringArray("context-values", nil, "")
+	includeFunctionResults := fs.BoolP("include-function-results", "r", false, "")
+	includeFullXR := fs.BoolP("include-full-xr", "x", false, "")
+	includeContext := fs.BoolP("include-context", "c", false, "")
+	observed := fs.StringP("observed-resources", "o", "", "")
+	required := fs.StringP("required-resources", "e", "", "")
+	extra := fs.String("extra-resources", "", "")
+	credentials := fs.String("function-credentials", "", "")
+	annotations := fs.StringArrayP("function-annotations", "a", nil, "")
+	timeout := fs.Duration("timeout", 0, "")
+	xrd := fs.String("xrd", "", "")
+	if err := fs.Parse(args); err != nil {
+		return crankInvocation{}, true, err
+	}
+	if len(*annotations) > 0 {
+		return crankInvocation{}, true, errors.New("--function-annotations has no crossbench equivalent, use --runtime-overrides")
+	}
+	if *xrd != "" {
+		return crankInvocation{}, true, errors.New("--xrd has no crossbench equivalent")
+	}
+	if fs.NArg() < 2 || fs.NArg() > 3 {
+		return crankInvocation{}, true, errors.Errorf("expected a composite resource, composition and functions, got %d arguments", fs.NArg())
+	}
+
+	path := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	keyValues := func(kvs []string, paths bool) (map[string]string, error) {
+		if len(kvs) == 0 {
+			return nil, nil
+		}
+		m := make(map[string]string, len(kvs))
+		for _, kv := range kvs {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, errors.Errorf("context %q must be KEY=VALUE", kv)
+			}
+			if paths {
+				v = path(v)
+			}
+			m[k] = v
+		}
+		return m, nil
+	}
+
+	p := RenderPreset{
+		XR:                     path(fs.Arg(0)),
+		Composition:            path(fs.Arg(1)),
+		Functions:              path(fs.Arg(2)),
+		ObservedResources:      path(*observed),
+		ExtraResources:         path(*required),
+		FunctionCredentials:    path(*credentials),
+		IncludeFunctionResults: *includeFunctionResults,
+		IncludeFullXR:          *includeFullXR,
+		IncludeContext:         *includeContext,
+	}
+	if p.ExtraResources == "" {
+		p.ExtraResources = path(*extra)
+	}
+	if *timeout > 0 {
+		p.Timeout = &metav1.Duration{Duration: *timeout}
+	}
+	var err error
+	if p.ContextFiles, err = keyValues(*contextFiles, true); err != nil {
+		return crankInvocation{}, true, err
+	}
+	if p.ContextValues, err = keyValues(*contextValues, false); err != nil {
+		return crankInvocation{}, true, err
+	}
+
+	name := output
+	if name == "" {
+		name = fs.Arg(0)
+	}
+	return crankInvocation{Name: presetName(name), Preset: p}, true, nil
+}
+
+// presetName derives a preset name from the supplied file name.
+func presetName(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	name = strings.Trim(presetNameInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if name == "" {
+		return "render"
+	}
+	return name
+}
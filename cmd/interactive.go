@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// contextKeyEnvironment is the context key the environment is passed to
+// functions under.
+const contextKeyEnvironment = "apiextensions.crossplane.io/environment"
+
+// A prompter asks the user for inputs that are missing, with --interactive.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newPrompter returns a prompter reading from stdin and writing to stderr.
+// It fails unless both are terminals, so the supplied flag never hangs a CI
+// job.
+func newPrompter(flag string) (*prompter, error) {
+	for _, f := range []*os.File{os.Stdin, os.Stderr} {
+		fi, err := f.Stat()
+		if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+			return nil, errors.Errorf("%s needs a terminal", flag)
+		}
+	}
+	return &prompter{in: bufio.NewReader(os.Stdin), out: os.Stderr}, nil
+}
+
+// choose asks the user to pick one of the supplied options, and returns its
+// index.
+func (p *prompter) choose(question string, options []string) (int, error) {
+	_, _ = fmt.Fprintln(p.out, question)
+	for i, o := range options {
+		_, _ = fmt.Fprintf(p.out, "  %d) %s\n", i+1, o)
+	}
+	for {
+		answer, err := p.ask(fmt.Sprintf("Enter a number (1-%d)", len(options)))
+		if err != nil {
+			return 0, err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+	}
+}
+
+// ask asks the user a question and returns the answer, which may be empty.
+func (p *prompter) ask(question string) (string, error) {
+	_, _ = fmt.Fprintf(p.out, "%s: ", question)
+	answer, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", errors.Wrap(err, "cannot read answer")
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// askJSON asks the user for a JSON value until it's valid. An empty answer
+// returns an empty string.
+func (p *prompter) askJSON(question string) (string, error) {
+	for {
+		answer, err := p.ask(question)
+		if err != nil || answer == "" {
+			return answer, err
+		}
+		if json.Valid([]byte(answer)) {
+			return answer, nil
+		}
+		_, _ = fmt.Fprintln(p.out, "That isn't valid JSON.")
+	}
+}
+
+// chooseComposition asks the user to pick one of the supplied Compositions.
+func (p *prompter) chooseComposition(reason error, comps []*apiextensionsv1.Composition) (*apiextensionsv1.Composition, error) {
+	names := make([]string, len(comps))
+	for i, comp := range comps {
+		names[i] = comp.GetName()
+		if l := comp.GetLabels(); len(l) > 0 {
+			names[i] += " (" + labels.Set(l).String() + ")"
+		}
+	}
+	i, err := p.choose(fmt.Sprintf("%v. Which Composition should be used?", reason), names)
+	if err != nil {
+		return nil, err
+	}
+	return comps[i], nil
+}
+
+// readsEnvironment returns whether the supplied Composition's steps patch
+// from the environment without a step that loads it, such as
+// function-environment-configs. Such Compositions need the environment to be
+// passed as a context value.
+func readsEnvironment(comp *apiextensionsv1.Composition) bool {
+	reads := false
+	for _, step := range comp.Spec.Pipeline {
+		if strings.Contains(step.FunctionRef.Name, "environment-configs") {
+			return false
+		}
+		if step.Input == nil {
+			continue
+		}
+		var in any
+		if err := json.Unmarshal(step.Input.Raw, &in); err != nil {
+			continue
+		}
+		reads = reads || hasEnvironmentPatch(in)
+	}
+	return reads
+}
+
+// hasEnvironmentPatch returns whether v contains a patch from the
+// environment.
+func hasEnvironmentPatch(v any) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		if t, _ := v["type"].(string); t == "FromEnvironmentFieldPath" || t == "CombineFromEnvironment" {
+			return true
+		}
+		for _, e := range v {
+			if hasEnvironmentPatch(e) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if hasEnvironmentPatch(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// Formats of the inventory.
+const (
+	InventoryFormatMarkdown = "markdown"
+	InventoryFormatJSON     = "json"
+)
+
+// An Inventory describes what consumers of an XR type get when they create
+// one: the resources it composes and the parameters they can set.
+type Inventory struct {
+	APIVersion  string `json:"apiVersion"`
+	Kind        string `json:"kind"`
+	Composition string `json:"composition"`
+
+	// Example is the file of the XR rendered, if one was passed. The XR is
+	// generated from the XRD otherwise.
+	Example string `json:"example,omitempty"`
+
+	Resources  []InventoryResource  `json:"resources"`
+	Parameters []InventoryParameter `json:"parameters"`
+}
+
+// An InventoryResource is a kind of resource composed by the XR.
+type InventoryResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Count      int    `json:"count"`
+
+	// Names are the composition resource names of the resources.
+	Names []string `json:"names,omitempty"`
+}
+
+// An InventoryParameter is a field of the XR's spec consumers can set.
+type InventoryParameter struct {
+	// Path of the field below spec, e.g. parameters.region.
+	Path        string `json:"path"`
+	Type        string `json:"type,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Allowed values of the field, if it's an enum.
+	Allowed []string `json:"allowed,omitempty"`
+}
+
+// NewInventoryCommand creates a new inventory command.
+func NewInventoryCommand() *cobra.Command {
+	cmd := &inventoryCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "inventory <xrd> <composition> [functions]",
+		Short: "Describe the resources an XR type composes, for its consumers",
+		Long: `Inventory renders an example XR of the XRD's type with the Composition, and
+describes what creating one gives consumers - how many resources of each kind
+it composes - along with the parameters of the XR they can set, taken from
+the XRD's schema, ready to paste into platform documentation.
+
+The example XR sets every required field and every field with a default,
+using the default, the first allowed value or a placeholder. Pass --xr to
+render a hand-written example instead.`,
+		Args: cobra.RangeArgs(2, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	// Flags
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().StringVar(&cmd.example, "xr", "", "Render this XR instead of one generated from the XRD's schema.")
+	cobraCmd.Flags().StringVar(&cmd.format, "format", InventoryFormatMarkdown, "Format of the inventory: markdown or json.")
+
+	return cobraCmd
+}
+
+type inventoryCmd struct {
+	renderCmd
+
+	// Flags
+	example string
+	format  string
+}
+
+func (c *inventoryCmd) run(cmd *cobra.Command, args []string) error {
+	if c.format != InventoryFormatMarkdown && c.format != InventoryFormatJSON {
+		return errors.Errorf("unknown --format %q, must be %s or %s", c.format, InventoryFormatMarkdown, InventoryFormatJSON)
+	}
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+
+	xrd, err := loadXRD(c.fs, args[0])
+	if err != nil {
+		return err
+	}
+	apiVersion, spec, err := xrdSchema(xrd)
+	if err != nil {
+		return err
+	}
+	p := fieldpath.Pave(xrd.Object)
+	kind, _ := p.GetString("spec.names.kind")
+
+	xr := c.example
+	if xr == "" {
+		f, err := afero.TempFile(c.fs, "", "crossbench-inventory-*.yaml")
+		if err != nil {
+			return errors.Wrap(err, "cannot create example XR file")
+		}
+		defer c.fs.Remove(f.Name()) //nolint:errcheck // Best effort cleanup.
+		y, err := yaml.Marshal(exampleXR(xrd, apiVersion, spec).Object)
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal example XR")
+		}
+		if _, err := f.Write(y); err != nil {
+			return errors.Wrapf(err, "cannot write example XR %q", f.Name())
+		}
+		if err := f.Close(); err != nil {
+			return errors.Wrapf(err, "cannot write example XR %q", f.Name())
+		}
+		debugf("Rendering example XR:\n%s", y)
+		xr = f.Name()
+	}
+
+	in, err := c.loadInputs(append([]string{xr}, args[1:]...))
+	if err != nil {
+		return err
+	}
+	if ref := in.Composition.Spec.CompositeTypeRef; ref.APIVersion != in.CompositeResource.GetAPIVersion() || ref.Kind != kind {
+		return errors.Errorf("Composition %q composes %s, %s, not the XRD's %s, %s", in.Composition.GetName(), ref.Kind, ref.APIVersion, kind, apiVersion)
+	}
+	out, err := c.render(in)
+	if err != nil {
+		return err
+	}
+
+	inv := NewInventory(apiVersion, kind, in.Composition.GetName(), out, spec)
+	inv.Example = c.example
+	if c.format == InventoryFormatJSON {
+		data, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal inventory")
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	return inv.WriteMarkdown(os.Stdout)
+}
+
+// xrdSchema returns the API version of the supplied XRD's XRs - its
+// referenceable version, or its first served version - and the schema of
+// their spec in that version.
+func xrdSchema(xrd *unstructured.Unstructured) (string, *extv1.JSONSchemaProps, error) {
+	p := fieldpath.Pave(xrd.Object)
+	group, _ := p.GetString("spec.group")
+	versions := []map[string]any{}
+	if err := p.GetValueInto("spec.versions", &versions); err != nil || len(versions) == 0 {
+		return "", nil, errors.Errorf("XRD %q has no versions", xrd.GetName())
+	}
+	v := versions[0]
+	for _, candidate := range versions {
+		if candidate["referenceable"] == true {
+			v = candidate
+			break
+		}
+	}
+	name, _ := v["name"].(string)
+	apiVersion := group + "/" + name
+
+	s := &extv1.JSONSchemaProps{}
+	vp := fieldpath.Pave(v)
+	if err := vp.GetValueInto("schema.openAPIV3Schema", s); err != nil {
+		return apiVersion, &extv1.JSONSchemaProps{}, nil //nolint:nilerr // An XR without a schema has no parameters.
+	}
+	spec := s.Properties["spec"]
+	return apiVersion, &spec, nil
+}
+
+// exampleXR returns an XR of the supplied XRD's type in the supplied API
+// version, with a spec generated from the supplied schema.
+func exampleXR(xrd *unstructured.Unstructured, apiVersion string, spec *extv1.JSONSchemaProps) *unstructured.Unstructured {
+	p := fieldpath.Pave(xrd.Object)
+	kind, _ := p.GetString("spec.names.kind")
+	scope, _ := p.GetString("spec.scope")
+
+	xr := &unstructured.Unstructured{Object: map[string]any{}}
+	xr.SetAPIVersion(apiVersion)
+	xr.SetKind(kind)
+	xr.SetName("example")
+	// XRDs are namespaced by default from apiextensions.crossplane.io/v2.
+	if scope == "Namespaced" || (scope == "" && strings.HasSuffix(xrd.GetAPIVersion(), "/v2")) {
+		xr.SetNamespace("default")
+	}
+	if v, ok := exampleValue(spec).(map[string]any); ok {
+		xr.Object["spec"] = v
+	} else {
+		xr.Object["spec"] = map[string]any{}
+	}
+	return xr
+}
+
+// exampleValue returns an example value matching the supplied schema: its
+// default, its first allowed value or a placeholder of its type. Objects get
+// their required properties and those with a default.
+func exampleValue(s *extv1.JSONSchemaProps) any {
+	if s.Default != nil {
+		var v any
+		if err := json.Unmarshal(s.Default.Raw, &v); err == nil {
+			return v
+		}
+	}
+	if len(s.Enum) > 0 {
+		var v any
+		if err := json.Unmarshal(s.Enum[0].Raw, &v); err == nil {
+			return v
+		}
+	}
+	switch s.Type {
+	case "string":
+		return "example"
+	case "integer", "number":
+		if s.Minimum != nil {
+			return *s.Minimum
+		}
+		return 1
+	case "boolean":
+		return false
+	case "array":
+		items := []any{}
+		if s.Items != nil && s.Items.Schema != nil && s.MinItems != nil {
+			for i := int64(0); i < *s.MinItems; i++ {
+				items = append(items, exampleValue(s.Items.Schema))
+			}
+		}
+		return items
+	}
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	obj := map[string]any{}
+	for name, prop := range s.Properties {
+		if required[name] || prop.Default != nil {
+			obj[name] = exampleValue(&prop)
+		}
+	}
+	return obj
+}
+
+// NewInventory describes the supplied rendered output of an XR of the
+// supplied type, and the parameters of its spec's schema.
+func NewInventory(apiVersion, kind, composition string, out render.Outputs, spec *extv1.JSONSchemaProps) Inventory {
+	inv := Inventory{
+		APIVersion:  apiVersion,
+		Kind:        kind,
+		Composition: composition,
+		Resources:   []InventoryResource{},
+		Parameters:  inventoryParameters("", spec, true),
+	}
+	byKind := map[string]*InventoryResource{}
+	for i := range out.ComposedResources {
+		cd := &out.ComposedResources[i]
+		key := cd.GetAPIVersion() + "/" + cd.GetKind()
+		r, ok := byKind[key]
+		if !ok {
+			r = &InventoryResource{APIVersion: cd.GetAPIVersion(), Kind: cd.GetKind()}
+			byKind[key] = r
+		}
+		r.Count++
+		if name := cd.GetAnnotations()[render.AnnotationKeyCompositionResourceName]; name != "" {
+			r.Names = append(r.Names, name)
+		}
+	}
+	for _, r := range byKind {
+		sort.Strings(r.Names)
+		inv.Resources = append(inv.Resources, *r)
+	}
+	sort.Slice(inv.Resources, func(i, j int) bool {
+		a, b := inv.Resources[i], inv.Resources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.APIVersion < b.APIVersion
+	})
+	return inv
+}
+
+// inventoryParameters returns the leaf fields of the supplied schema, below
+// the supplied path. Fields are only required if all their parents are.
+func inventoryParameters(path string, s *extv1.JSONSchemaProps, required bool) []InventoryParameter {
+	params := []InventoryParameter{}
+	if s == nil {
+		return params
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	req := map[string]bool{}
+	for _, r := range s.Required {
+		req[r] = true
+	}
+	for _, name := range names {
+		prop := s.Properties[name]
+		p := name
+		if path != "" {
+			p = path + "." + name
+		}
+		if prop.Type == "object" && len(prop.Properties) > 0 {
+			params = append(params, inventoryParameters(p, &prop, required && req[name])...)
+			continue
+		}
+		if prop.Type == "array" && prop.Items != nil && prop.Items.Schema != nil && len(prop.Items.Schema.Properties) > 0 {
+			params = append(params, inventoryParameters(p+"[]", prop.Items.Schema, required && req[name])...)
+			continue
+		}
+		ip := InventoryParameter{Path: p, Type: prop.Type, Required: required && req[name], Description: strings.TrimSpace(prop.Description)}
+		if prop.Default != nil {
+			ip.Default = schemaValue(prop.Default.Raw)
+		}
+		for _, e := range prop.Enum {
+			ip.Allowed = append(ip.Allowed, schemaValue(e.Raw))
+		}
+		if ip.Type == "" && prop.XPreserveUnknownFields != nil && *prop.XPreserveUnknownFields {
+			ip.Type = "object"
+		}
+		params = append(params, ip)
+	}
+	return params
+}
+
+// schemaValue returns the supplied JSON value of a schema, such as a
+// default, as text: strings as they are, and other values as JSON.
+func schemaValue(raw []byte) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// WriteMarkdown writes the inventory as Markdown documentation.
+func (inv Inventory) WriteMarkdown(w io.Writer) error {
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "# %s\n\n", inv.Kind)
+	_, _ = fmt.Fprintf(b, "Creating a %s (`%s`) with the Composition `%s` gives you:\n\n", inv.Kind, inv.APIVersion, inv.Composition)
+	if len(inv.Resources) == 0 {
+		b.WriteString("- No composed resources\n")
+	}
+	for _, r := range inv.Resources {
+		_, _ = fmt.Fprintf(b, "- %d %s\n", r.Count, pluralKind(r.Kind, r.Count))
+	}
+
+	if len(inv.Resources) > 0 {
+		b.WriteString("\n## Resources\n\n")
+		b.WriteString("| Kind | API version | Count | Composition resources |\n")
+		b.WriteString("|------|-------------|-------|-----------------------|\n")
+		for _, r := range inv.Resources {
+			names := "-"
+			if len(r.Names) > 0 {
+				names = "`" + strings.Join(r.Names, "`, `") + "`"
+			}
+			_, _ = fmt.Fprintf(b, "| %s | `%s` | %d | %s |\n", r.Kind, r.APIVersion, r.Count, names)
+		}
+	}
+
+	if len(inv.Parameters) > 0 {
+		b.WriteString("\n## Parameters\n\n")
+		b.WriteString("| Parameter | Type | Required | Default | Description |\n")
+		b.WriteString("|-----------|------|----------|---------|-------------|\n")
+		for _, p := range inv.Parameters {
+			required, def := "no", "-"
+			if p.Required {
+				required = "yes"
+			}
+			if p.Default != "" {
+				def = "`" + p.Default + "`"
+			}
+			desc := p.Description
+			if len(p.Allowed) > 0 {
+				desc = strings.TrimSpace(fmt.Sprintf("%s One of `%s`.", desc, strings.Join(p.Allowed, "`, `")))
+			}
+			desc = strings.ReplaceAll(strings.ReplaceAll(desc, "\n", " "), "|", "\\|")
+			_, _ = fmt.Fprintf(b, "| `spec.%s` | %s | %s | %s | %s |\n", p.Path, p.Type, required, def, desc)
+		}
+	}
+
+	if inv.Example != "" {
+		_, _ = fmt.Fprintf(b, "\nResources rendered from the example in `%s`.\n", inv.Example)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// pluralKind returns the supplied kind, pluralized if count isn't 1.
+func pluralKind(kind string, count int) string {
+	if count == 1 {
+		return kind
+	}
+	switch {
+	case strings.HasSuffix(kind, "s"), strings.HasSuffix(kind, "x"), strings.HasSuffix(kind, "ch"), strings.HasSuffix(kind, "sh"):
+		return kind + "es"
+	case strings.HasSuffix(kind, "y") && len(kind) > 1 && !strings.ContainsAny(kind[len(kind)-2:len(kind)-1], "aeiouAEIOU"):
+		return kind[:len(kind)-1] + "ies"
+	}
+	return kind + "s"
+}
@@ -0,0 +1,967 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+const checkKyverno = "kyverno"
+
+// Results of a Kyverno rule for a resource.
+const (
+	KyvernoPass = "PASS"
+	KyvernoFail = "FAIL"
+	KyvernoSkip = "SKIP"
+)
+
+// A kyvernoPolicy is a Kyverno ClusterPolicy or Policy. Only the fields used
+// to evaluate validate rules are decoded.
+type kyvernoPolicy struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace,omitempty"`
+	} `json:"metadata"`
+	Spec struct {
+		ValidationFailureAction string        `json:"validationFailureAction,omitempty"`
+		Rules                   []kyvernoRule `json:"rules"`
+	} `json:"spec"`
+}
+
+type kyvernoRule struct {
+	Name          string           `json:"name"`
+	Match         kyvernoMatch     `json:"match"`
+	Exclude       *kyvernoMatch    `json:"exclude,omitempty"`
+	Context       []any            `json:"context,omitempty"`
+	Preconditions any              `json:"preconditions,omitempty"`
+	Validate      *kyvernoValidate `json:"validate,omitempty"`
+}
+
+// A kyvernoMatch selects resources: any of the Any filters, all of the All
+// filters, or the legacy Resources filter.
+type kyvernoMatch struct {
+	Any       []kyvernoFilter   `json:"any,omitempty"`
+	All       []kyvernoFilter   `json:"all,omitempty"`
+	Resources *kyvernoResources `json:"resources,omitempty"`
+}
+
+type kyvernoFilter struct {
+	Resources kyvernoResources `json:"resources"`
+}
+
+type kyvernoResources struct {
+	Kinds       []string              `json:"kinds,omitempty"`
+	Name        string                `json:"name,omitempty"`
+	Names       []string              `json:"names,omitempty"`
+	Namespaces  []string              `json:"namespaces,omitempty"`
+	Annotations map[string]string     `json:"annotations,omitempty"`
+	Selector    *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+type kyvernoValidate struct {
+	FailureAction string      `json:"failureAction,omitempty"`
+	Message       string      `json:"message,omitempty"`
+	Pattern       any         `json:"pattern,omitempty"`
+	AnyPattern    []any       `json:"anyPattern,omitempty"`
+	CEL           *kyvernoCEL `json:"cel,omitempty"`
+	Deny          any         `json:"deny,omitempty"`
+	ForEach       any         `json:"foreach,omitempty"`
+	PodSecurity   any         `json:"podSecurity,omitempty"`
+	Manifests     any         `json:"manifests,omitempty"`
+}
+
+type kyvernoCEL struct {
+	Expressions []struct {
+		Expression string `json:"expression"`
+		Message    string `json:"message,omitempty"`
+	} `json:"expressions"`
+}
+
+// A KyvernoResult is the result of a Kyverno validate rule for a resource.
+type KyvernoResult struct {
+	Policy   string
+	Rule     string
+	Resource string
+	Status   string
+
+	// Severity of a failure: error for Enforce rules, warning for Audit
+	// rules.
+	Severity Severity
+
+	// Message explains why the rule failed or was skipped.
+	Message string
+}
+
+// loadKyvernoPolicies loads the Kyverno ClusterPolicies and Policies in the
+// supplied files and directories.
+func loadKyvernoPolicies(fs afero.Fs, paths []string) ([]kyvernoPolicy, error) {
+	docs, _, err := loadDocuments(fs, paths)
+	if err != nil {
+		return nil, err
+	}
+	policies := []kyvernoPolicy{}
+	for _, d := range docs {
+		gvk := d.GroupVersionKind()
+		if gvk.Group != "kyverno.io" || (gvk.Kind != "ClusterPolicy" && gvk.Kind != "Policy") {
+			continue
+		}
+		data, err := json.Marshal(d.Object)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot marshal Kyverno %s %q", gvk.Kind, d.GetName())
+		}
+		p := kyvernoPolicy{}
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse Kyverno %s %q", gvk.Kind, d.GetName())
+		}
+		policies = append(policies, p)
+	}
+	if len(policies) == 0 {
+		return nil, errors.Errorf("no Kyverno ClusterPolicies or Policies in %s", strings.Join(paths, ", "))
+	}
+	return policies, nil
+}
+
+// EvaluateKyvernoPolicies evaluates the validate rules of the supplied
+// policies against every resource they match, returning a result per rule
+// and resource. Rules that need data crossbench doesn't have, such as
+// preconditions, context entries and deny conditions, which refer to
+// admission requests or the cluster, are skipped.
+func EvaluateKyvernoPolicies(policies []kyvernoPolicy, resources []*unstructured.Unstructured) ([]KyvernoResult, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+		cel.Variable("request", cel.DynType),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CEL environment")
+	}
+
+	results := []KyvernoResult{}
+	for _, p := range policies {
+		for _, r := range p.Spec.Rules {
+			if r.Validate == nil {
+				continue
+			}
+			var prgs []cel.Program
+			if r.Validate.CEL != nil {
+				for _, e := range r.Validate.CEL.Expressions {
+					ast, iss := env.Compile(e.Expression)
+					if iss.Err() != nil {
+						return nil, errors.Wrapf(iss.Err(), "Kyverno policy %q rule %q has an invalid CEL expression", p.Metadata.Name, r.Name)
+					}
+					prg, err := env.Program(ast)
+					if err != nil {
+						return nil, errors.Wrapf(err, "Kyverno policy %q rule %q has an invalid CEL expression", p.Metadata.Name, r.Name)
+					}
+					prgs = append(prgs, prg)
+				}
+			}
+
+			severity := SeverityWarning
+			action := r.Validate.FailureAction
+			if action == "" {
+				action = p.Spec.ValidationFailureAction
+			}
+			if strings.EqualFold(action, "Enforce") {
+				severity = SeverityError
+			}
+
+			for _, u := range resources {
+				if p.Kind == "Policy" && u.GetNamespace() != p.Metadata.Namespace {
+					continue
+				}
+				if !r.Match.matches(u) || (r.Exclude != nil && r.Exclude.matches(u)) {
+					continue
+				}
+				res := KyvernoResult{Policy: p.Metadata.Name, Rule: r.Name, Resource: resourceID(u), Severity: severity}
+				res.Status, res.Message = r.evaluate(u, prgs)
+				results = append(results, res)
+			}
+		}
+	}
+	return results, nil
+}
+
+// evaluate returns the result of the rule for the supplied resource, with
+// the reason it failed or was skipped, using the supplied programs of its CEL
+// expressions.
+func (r kyvernoRule) evaluate(u *unstructured.Unstructured, prgs []cel.Program) (string, string) {
+	v := r.Validate
+	switch {
+	case r.Preconditions != nil:
+		return KyvernoSkip, "preconditions aren't supported"
+	case len(r.Context) > 0:
+		return KyvernoSkip, "context entries aren't supported"
+	case v.Deny != nil:
+		return KyvernoSkip, "deny conditions aren't supported"
+	case v.ForEach != nil:
+		return KyvernoSkip, "foreach validations aren't supported"
+	case v.PodSecurity != nil:
+		return KyvernoSkip, "podSecurity validations aren't supported"
+	case v.Manifests != nil:
+		return KyvernoSkip, "manifest validations aren't supported"
+	}
+
+	message := v.Message
+	if message == "" {
+		message = "validation failed"
+	}
+
+	if v.Pattern != nil {
+		err := matchKyvernoPattern(u.Object, v.Pattern, "")
+		switch {
+		case err == nil:
+		case err.anchor:
+			return KyvernoSkip, err.Error()
+		default:
+			return KyvernoFail, fmt.Sprintf("%s (%s)", message, err)
+		}
+	}
+
+	if len(v.AnyPattern) > 0 {
+		reasons := []string{}
+		skipped := true
+		for _, p := range v.AnyPattern {
+			err := matchKyvernoPattern(u.Object, p, "")
+			if err == nil {
+				return KyvernoPass, ""
+			}
+			skipped = skipped && err.anchor
+			reasons = append(reasons, err.Error())
+		}
+		if skipped {
+			return KyvernoSkip, strings.Join(reasons, "; ")
+		}
+		return KyvernoFail, fmt.Sprintf("%s (%s)", message, strings.Join(reasons, "; "))
+	}
+
+	vars := map[string]any{"object": u.Object, "oldObject": nil, "request": map[string]any{"operation": "CREATE"}}
+	for i, prg := range prgs {
+		e := v.CEL.Expressions[i]
+		out, _, err := prg.Eval(vars)
+		switch {
+		case err != nil:
+			return KyvernoFail, fmt.Sprintf("cannot evaluate %s: %v", e.Expression, err)
+		case out.Value() != true:
+			if e.Message != "" {
+				return KyvernoFail, e.Message
+			}
+			return KyvernoFail, fmt.Sprintf("failed expression: %s", e.Expression)
+		}
+	}
+
+	if v.Pattern == nil && len(prgs) == 0 {
+		return KyvernoSkip, "only pattern, anyPattern and cel validations are supported"
+	}
+	return KyvernoPass, ""
+}
+
+// matches returns whether the supplied resource is selected.
+func (m kyvernoMatch) matches(u *unstructured.Unstructured) bool {
+	if len(m.Any) == 0 && len(m.All) == 0 && m.Resources == nil {
+		return false
+	}
+	if m.Resources != nil && !m.Resources.matches(u) {
+		return false
+	}
+	if len(m.Any) > 0 {
+		matched := false
+		for _, f := range m.Any {
+			matched = matched || f.Resources.matches(u)
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, f := range m.All {
+		if !f.Resources.matches(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches returns whether the supplied resource is described. Names,
+// namespaces, kinds and annotation values may contain * and ? wildcards.
+func (r kyvernoResources) matches(u *unstructured.Unstructured) bool {
+	if len(r.Kinds) > 0 && !anyMatch(r.Kinds, func(k string) bool { return kyvernoKindMatches(k, u) }) {
+		return false
+	}
+	names := r.Names
+	if r.Name != "" {
+		names = append(names, r.Name)
+	}
+	if len(names) > 0 && !anyMatch(names, func(n string) bool { return kyvernoWildcard(n, u.GetName()) }) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && !anyMatch(r.Namespaces, func(n string) bool { return kyvernoWildcard(n, u.GetNamespace()) }) {
+		return false
+	}
+	for k, v := range r.Annotations {
+		a, ok := u.GetAnnotations()[k]
+		if !ok || !kyvernoWildcard(v, a) {
+			return false
+		}
+	}
+	if r.Selector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(r.Selector)
+		if err != nil || !sel.Matches(labels.Set(u.GetLabels())) {
+			return false
+		}
+	}
+	return true
+}
+
+// anyMatch returns whether fn returns true for any of the supplied values.
+func anyMatch(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// kyvernoKindMatches returns whether the supplied resource is of the
+// supplied kind, given as Kind, Version/Kind or Group/Version/Kind.
+func kyvernoKindMatches(kind string, u *unstructured.Unstructured) bool {
+	gvk := u.GroupVersionKind()
+	parts := strings.Split(kind, "/")
+	switch len(parts) {
+	case 1:
+		return kyvernoWildcard(parts[0], gvk.Kind)
+	case 2:
+		return kyvernoWildcard(parts[0], gvk.Version) && kyvernoWildcard(parts[1], gvk.Kind)
+	case 3:
+		return kyvernoWildcard(parts[0], gvk.Group) && kyvernoWildcard(parts[1], gvk.Version) && kyvernoWildcard(parts[2], gvk.Kind)
+	}
+	return false
+}
+
+// kyvernoWildcard returns whether s matches the supplied pattern, in which *
+// matches any characters and ? any one character.
+func kyvernoWildcard(pattern, s string) bool {
+	re := "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern)) + "$"
+	ok, _ := regexp.MatchString(re, s)
+	return ok
+}
+
+// A kyvernoPatternError explains why a resource doesn't match a pattern.
+type kyvernoPatternError struct {
+	path   string
+	reason string
+
+	// anchor is set when a conditional or global anchor didn't match, so
+	// the pattern doesn't apply rather than fail.
+	anchor bool
+}
+
+func (e *kyvernoPatternError) Error() string {
+	if e.path == "" {
+		return e.reason
+	}
+	return e.path + ": " + e.reason
+}
+
+// matchKyvernoPattern returns why the supplied resource value at the
+// supplied field path doesn't match the supplied Kyverno pattern, or nil if
+// it does. It follows Kyverno's own engine: objects are matched key by key,
+// lists element by element and scalars by the conditions of the pattern.
+func matchKyvernoPattern(value, pattern any, path string) *kyvernoPatternError {
+	switch p := pattern.(type) {
+	case map[string]any:
+		v, ok := value.(map[string]any)
+		switch {
+		case value == nil:
+			return &kyvernoPatternError{path: path, reason: "is required"}
+		case !ok:
+			return &kyvernoPatternError{path: path, reason: "must be an object"}
+		}
+		return matchKyvernoMap(v, p, path)
+	case []any:
+		v, ok := value.([]any)
+		switch {
+		case value == nil:
+			return &kyvernoPatternError{path: path, reason: "is required"}
+		case !ok:
+			return &kyvernoPatternError{path: path, reason: "must be a list"}
+		}
+		return matchKyvernoList(v, p, path)
+	}
+
+	// Every element of a list must match a scalar pattern.
+	if v, ok := value.([]any); ok {
+		for i, e := range v {
+			if !matchKyvernoScalar(e, pattern) {
+				return &kyvernoPatternError{path: fmt.Sprintf("%s[%d]", path, i), reason: fmt.Sprintf("%s doesn't match %s", kyvernoString(e), kyvernoString(pattern))}
+			}
+		}
+		return nil
+	}
+	if !matchKyvernoScalar(value, pattern) {
+		return &kyvernoPatternError{path: path, reason: fmt.Sprintf("%s doesn't match %s", kyvernoString(value), kyvernoString(pattern))}
+	}
+	return nil
+}
+
+// Kyverno anchors of pattern keys.
+const (
+	kyvernoConditional = "("
+	kyvernoGlobal      = "<("
+	kyvernoExistence   = "^("
+	kyvernoNegation    = "X("
+	kyvernoEquality    = "=("
+	kyvernoAddIfAbsent = "+("
+)
+
+// kyvernoAnchor splits an anchored key of a pattern, e.g. =(name), into its
+// anchor and key.
+func kyvernoAnchor(key string) (string, string) {
+	for _, a := range []string{kyvernoGlobal, kyvernoExistence, kyvernoNegation, kyvernoEquality, kyvernoAddIfAbsent, kyvernoConditional} {
+		if strings.HasPrefix(key, a) && strings.HasSuffix(key, ")") && len(key) > len(a)+1 {
+			return a, strings.TrimSuffix(strings.TrimPrefix(key, a), ")")
+		}
+	}
+	return "", key
+}
+
+// matchKyvernoMap matches an object against a pattern object. Conditional,
+// global, negation and existence anchors are matched first, since they
+// decide whether the rest of the pattern applies. Of the remaining keys,
+// those whose patterns hold anchors are matched first, so an anchor that
+// doesn't match skips the rule before another key fails it.
+func matchKyvernoMap(value, pattern map[string]any, path string) *kyvernoPatternError {
+	pattern = expandKyvernoMetadata(pattern, value)
+
+	anchors, keys := []string{}, []string{}
+	for k := range pattern {
+		switch a, _ := kyvernoAnchor(k); a {
+		case kyvernoConditional, kyvernoGlobal, kyvernoNegation, kyvernoExistence:
+			anchors = append(anchors, k)
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(anchors)
+	sort.Slice(keys, func(i, j int) bool {
+		ai, aj := kyvernoHasAnchors(pattern[keys[i]]), kyvernoHasAnchors(pattern[keys[j]])
+		if ai != aj {
+			return ai
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range anchors {
+		anchor, key := kyvernoAnchor(k)
+		v, ok := value[key]
+		path := kyvernoPath(path, key)
+		switch anchor {
+		case kyvernoConditional, kyvernoGlobal:
+			if !ok {
+				return &kyvernoPatternError{path: path, reason: "anchor isn't set, so the pattern doesn't apply", anchor: true}
+			}
+			if err := matchKyvernoPattern(v, pattern[k], path); err != nil {
+				return &kyvernoPatternError{path: err.path, reason: "anchor doesn't match, so the pattern doesn't apply (" + err.reason + ")", anchor: true}
+			}
+		case kyvernoNegation:
+			if ok {
+				return &kyvernoPatternError{path: path, reason: "must not be set"}
+			}
+		case kyvernoExistence:
+			if !ok {
+				continue
+			}
+			if err := matchKyvernoExistence(v, pattern[k], path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, k := range keys {
+		anchor, key := kyvernoAnchor(k)
+		v, ok := value[key]
+		path := kyvernoPath(path, key)
+		switch {
+		case anchor == kyvernoEquality && !ok:
+			continue
+		case anchor == kyvernoAddIfAbsent:
+			return &kyvernoPatternError{path: path, reason: "+() anchors are only supported by mutate rules"}
+		case anchor == "" && pattern[k] == "*":
+			// * requires the field to be set, to anything.
+			if v == nil {
+				return &kyvernoPatternError{path: path, reason: "is required"}
+			}
+			continue
+		}
+		// A missing field is matched as null, so e.g. a null or !value
+		// pattern doesn't require it.
+		if err := matchKyvernoPattern(v, pattern[k], path); err != nil {
+			if !ok && !err.anchor && err.path == path {
+				err.reason = "is required"
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// matchKyvernoExistence matches a list against the pattern of an existence
+// anchor: every element of the pattern must match at least one element of
+// the list.
+func matchKyvernoExistence(value, pattern any, path string) *kyvernoPatternError {
+	elems, ok := value.([]any)
+	if !ok {
+		return &kyvernoPatternError{path: path, reason: "must be a list, since ^() anchors only apply to lists"}
+	}
+	patterns, ok := pattern.([]any)
+	if !ok {
+		return &kyvernoPatternError{path: path, reason: "the pattern of a ^() anchor must be a list"}
+	}
+	for _, p := range patterns {
+		if _, ok := p.(map[string]any); !ok {
+			return &kyvernoPatternError{path: path, reason: "the pattern of a ^() anchor must be a list of objects"}
+		}
+		found := false
+		for _, e := range elems {
+			if matchKyvernoPattern(e, p, path) == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &kyvernoPatternError{path: path, reason: "must have an element matching the pattern"}
+		}
+	}
+	return nil
+}
+
+// matchKyvernoList matches a list against a pattern list. Like Kyverno, only
+// the first element of a pattern of objects or scalars is used, and every
+// element of the list must match it. Elements of a list of objects that an
+// anchor doesn't apply to are skipped, and if it applies to none, neither
+// does the pattern. Lists of lists are matched element by element.
+func matchKyvernoList(value, pattern []any, path string) *kyvernoPatternError {
+	if len(pattern) == 0 {
+		return &kyvernoPatternError{path: path, reason: "the pattern is an empty list"}
+	}
+	switch p := pattern[0].(type) {
+	case map[string]any:
+		applied := 0
+		var skipped *kyvernoPatternError
+		for i, e := range value {
+			err := matchKyvernoPattern(e, p, fmt.Sprintf("%s[%d]", path, i))
+			switch {
+			case err == nil:
+				applied++
+			case err.anchor:
+				if skipped == nil {
+					skipped = err
+				}
+			default:
+				return err
+			}
+		}
+		if applied == 0 && skipped != nil {
+			return skipped
+		}
+		return nil
+	case []any:
+		if len(value) < len(pattern) {
+			return &kyvernoPatternError{path: path, reason: fmt.Sprintf("must have at least %d elements", len(pattern))}
+		}
+		for i, p := range pattern {
+			if err := matchKyvernoPattern(value[i], p, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return matchKyvernoPattern(value, pattern[0], path)
+}
+
+// kyvernoHasAnchors returns whether the supplied pattern has an anchored key
+// at any depth.
+func kyvernoHasAnchors(pattern any) bool {
+	switch p := pattern.(type) {
+	case map[string]any:
+		for k, v := range p {
+			if a, _ := kyvernoAnchor(k); a != "" || kyvernoHasAnchors(v) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range p {
+			if kyvernoHasAnchors(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandKyvernoMetadata returns the supplied pattern with the wildcard keys
+// of its metadata.labels and metadata.annotations replaced by the keys of the
+// resource's they match, e.g. app.kubernetes.io/* by app.kubernetes.io/name.
+// Of several matching keys, one whose value matches is used.
+func expandKyvernoMetadata(pattern, value map[string]any) map[string]any {
+	pk, pm := kyvernoField(pattern, "metadata")
+	rm, _ := value["metadata"].(map[string]any)
+	if pm == nil || rm == nil {
+		return pattern
+	}
+
+	meta := maps.Clone(pm)
+	for _, tag := range []string{"labels", "annotations"} {
+		tk, tp := kyvernoField(meta, tag)
+		rt, _ := rm[tag].(map[string]any)
+		if tp == nil || rt == nil {
+			continue
+		}
+		keys := slices.Sorted(maps.Keys(rt))
+		expanded := make(map[string]any, len(tp))
+		for k, v := range tp {
+			anchor, key := kyvernoAnchor(k)
+			if !strings.ContainsAny(key, "*?") {
+				expanded[k] = v
+				continue
+			}
+			match := ""
+			for _, rk := range keys {
+				if !kyvernoWildcard(key, rk) {
+					continue
+				}
+				matches := matchKyvernoPattern(rt[rk], v, "") == nil
+				if match == "" || matches {
+					match = rk
+				}
+				if matches {
+					break
+				}
+			}
+			switch {
+			case match == "":
+				expanded[k] = v
+			case anchor == "":
+				expanded[match] = v
+			default:
+				expanded[anchor+match+")"] = v
+			}
+		}
+		meta[tk] = expanded
+	}
+
+	out := maps.Clone(pattern)
+	out[pk] = meta
+	return out
+}
+
+// kyvernoField returns the key and object value of the supplied field of a
+// pattern object, which may be anchored.
+func kyvernoField(pattern map[string]any, field string) (string, map[string]any) {
+	for k, v := range pattern {
+		if _, key := kyvernoAnchor(k); key == field {
+			m, _ := v.(map[string]any)
+			return k, m
+		}
+	}
+	return "", nil
+}
+
+// kyvernoPath appends key to the supplied field path.
+func kyvernoPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Operators of the conditions of string patterns.
+const (
+	kyvernoEqual        = ""
+	kyvernoNotEqual     = "!"
+	kyvernoMore         = ">"
+	kyvernoMoreEqual    = ">="
+	kyvernoLess         = "<"
+	kyvernoLessEqual    = "<="
+	kyvernoInRange      = "-"
+	kyvernoNotInRange   = "!-"
+	kyvernoRangeOperand = `(\d+(?:\.\d+)?[^!-]*)`
+)
+
+var (
+	// kyvernoRange matches range conditions, e.g. 1-10 or 1Gi!-4Gi.
+	kyvernoRange = regexp.MustCompile(`^` + kyvernoRangeOperand + `(!?-)` + kyvernoRangeOperand + `$`)
+
+	// kyvernoNumeric matches operands that are compared as numbers,
+	// quantities or durations rather than strings.
+	kyvernoNumeric = regexp.MustCompile(`^(\d+|\.\d)`)
+)
+
+// matchKyvernoScalar returns whether a scalar value matches a scalar
+// pattern. A null pattern matches empty values; numbers match numbers and
+// numeric strings. String patterns are conditions combined with | (or) and &
+// (and): values with * and ? wildcards, negated with !, comparisons with >,
+// >=, < and <=, and ranges like 1-10 or 1!-10, comparing numbers, quantities
+// and durations.
+func matchKyvernoScalar(value, pattern any) bool {
+	switch p := pattern.(type) {
+	case nil:
+		return kyvernoNull(value)
+	case bool:
+		b, ok := value.(bool)
+		return ok && b == p
+	case float64:
+		return kyvernoNumberEquals(value, p)
+	case int64:
+		return kyvernoNumberEquals(value, float64(p))
+	case int:
+		return kyvernoNumberEquals(value, float64(p))
+	case string:
+		for _, alt := range strings.Split(p, "|") {
+			all := true
+			for _, c := range strings.Split(alt, "&") {
+				all = all && matchKyvernoCondition(value, strings.TrimSpace(c))
+			}
+			if all {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchKyvernoCondition returns whether the supplied value matches a
+// condition of a string pattern.
+func matchKyvernoCondition(value any, c string) bool {
+	op := kyvernoOperator(c)
+	switch op {
+	case kyvernoInRange:
+		m := kyvernoRange.FindStringSubmatch(c)
+		return matchKyvernoCondition(value, kyvernoMoreEqual+m[1]) && matchKyvernoCondition(value, kyvernoLessEqual+m[3])
+	case kyvernoNotInRange:
+		m := kyvernoRange.FindStringSubmatch(c)
+		return matchKyvernoCondition(value, kyvernoLess+m[1]) || matchKyvernoCondition(value, kyvernoMore+m[3])
+	}
+	operand := strings.TrimSpace(strings.TrimPrefix(c, op))
+	if kyvernoNumeric.MatchString(operand) {
+		return compareKyvernoNumber(value, operand, op)
+	}
+	return compareKyvernoString(value, operand, op)
+}
+
+// kyvernoOperator returns the operator of a condition of a string pattern.
+func kyvernoOperator(c string) string {
+	if len(c) < 2 {
+		return kyvernoEqual
+	}
+	for _, op := range []string{kyvernoMoreEqual, kyvernoLessEqual, kyvernoMore, kyvernoLess, kyvernoNotEqual} {
+		if strings.HasPrefix(c, op) {
+			return op
+		}
+	}
+	if m := kyvernoRange.FindStringSubmatch(c); m != nil {
+		return m[2]
+	}
+	return kyvernoEqual
+}
+
+// compareKyvernoString returns whether the supplied value matches, or with
+// the ! operator doesn't match, a wildcard pattern. Strings can't be compared
+// with the other operators.
+func compareKyvernoString(value any, pattern, op string) bool {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'E', -1, 64)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case int:
+		s = strconv.Itoa(v)
+	case bool:
+		s = strconv.FormatBool(v)
+	default:
+		return false
+	}
+	switch op {
+	case kyvernoEqual:
+		return kyvernoWildcard(pattern, s)
+	case kyvernoNotEqual:
+		return !kyvernoWildcard(pattern, s)
+	}
+	return false
+}
+
+// compareKyvernoNumber compares the supplied value with a numeric operand:
+// as durations if both are, as quantities if the operand is one, and
+// otherwise as strings. A missing value counts as 0.
+func compareKyvernoNumber(value any, operand, op string) bool {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		s = "0"
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', 6, 64)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case int:
+		s = strconv.Itoa(v)
+	default:
+		return false
+	}
+
+	if p, err := time.ParseDuration(operand); err == nil {
+		if v, err := time.ParseDuration(s); err == nil {
+			return kyvernoCompare(compareFloats(float64(v), float64(p)), op)
+		}
+	}
+	if p, err := resource.ParseQuantity(operand); err == nil {
+		v, err := resource.ParseQuantity(s)
+		return err == nil && kyvernoCompare(v.Cmp(p), op)
+	}
+	return compareKyvernoString(value, operand, op)
+}
+
+// kyvernoCompare returns whether the result of a comparison satisfies the
+// supplied operator.
+func kyvernoCompare(cmp int, op string) bool {
+	switch op {
+	case kyvernoEqual:
+		return cmp == 0
+	case kyvernoNotEqual:
+		return cmp != 0
+	case kyvernoMore:
+		return cmp > 0
+	case kyvernoMoreEqual:
+		return cmp >= 0
+	case kyvernoLess:
+		return cmp < 0
+	case kyvernoLessEqual:
+		return cmp <= 0
+	}
+	return false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// kyvernoNumberEquals returns whether the supplied value is a number, or a
+// numeric string, equal to n.
+func kyvernoNumberEquals(value any, n float64) bool {
+	switch v := value.(type) {
+	case int64:
+		return n == math.Trunc(n) && int64(n) == v
+	case int:
+		return n == math.Trunc(n) && int(n) == v
+	case float64:
+		return v == n
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return err == nil && f == n
+	}
+	return false
+}
+
+// kyvernoString returns the supplied scalar value as a string.
+func kyvernoString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// kyvernoNull returns whether the supplied value is null or the zero value
+// of a scalar, which a null pattern matches. Objects and lists never match.
+func kyvernoNull(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case int64:
+		return v == 0
+	case int:
+		return v == 0
+	}
+	return false
+}
+
+// CheckKyverno returns a finding for every failed Kyverno rule.
+func CheckKyverno(results []KyvernoResult) []Finding {
+	findings := []Finding{}
+	for _, r := range results {
+		if r.Status != KyvernoFail {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:    checkKyverno,
+			Severity: r.Severity,
+			Resource: r.Resource,
+			Message:  fmt.Sprintf("%s/%s: %s", r.Policy, r.Rule, r.Message),
+		})
+	}
+	return findings
+}
+
+// PrintKyvernoResults writes the result of every Kyverno rule for every
+// resource as a table, followed by the totals. Why rules failed is reported
+// by their findings.
+func PrintKyvernoResults(w io.Writer, results []KyvernoResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tPOLICY\tRULE\tRESOURCE\tREASON")
+	counts := map[string]int{}
+	for _, r := range results {
+		reason := "-"
+		if r.Status == KyvernoSkip {
+			reason = r.Message
+		}
+		counts[r.Status]++
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Status, r.Policy, r.Rule, r.Resource, reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d Kyverno rule results, %d passed, %d failed, %d skipped\n", len(results), counts[KyvernoPass], counts[KyvernoFail], counts[KyvernoSkip])
+	return err
+}
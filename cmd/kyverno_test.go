@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/yaml"
+)
+
+// kyvernoPattern parses a pattern the way policies are loaded, so numbers are
+// float64.
+func kyvernoPattern(t *testing.T, s string) any {
+	t.Helper()
+	var p any
+	if err := yaml.Unmarshal([]byte(s), &p); err != nil {
+		t.Fatalf("cannot parse pattern: %v", err)
+	}
+	return p
+}
+
+// kyvernoResource parses a resource the way rendered resources are decoded,
+// so integers are int64.
+func kyvernoResource(t *testing.T, s string) map[string]any {
+	t.Helper()
+	data, err := yaml.YAMLToJSON([]byte(s))
+	if err != nil {
+		t.Fatalf("cannot parse resource: %v", err)
+	}
+	r := map[string]any{}
+	if err := utiljson.Unmarshal(data, &r); err != nil {
+		t.Fatalf("cannot parse resource: %v", err)
+	}
+	return r
+}
+
+// kyvernoStatus returns the result of the rule a pattern error is for.
+func kyvernoStatus(err *kyvernoPatternError) string {
+	switch {
+	case err == nil:
+		return KyvernoPass
+	case err.anchor:
+		return KyvernoSkip
+	}
+	return KyvernoFail
+}
+
+func TestMatchKyvernoPattern(t *testing.T) {
+	// Patterns and resources are taken from the examples of Kyverno's
+	// documentation of validate rules.
+	latestPullPolicy := `
+spec:
+  containers:
+  - (image): "*:latest"
+    imagePullPolicy: "!IfNotPresent"
+`
+	imagePullSecrets := `
+spec:
+  containers:
+  - name: "*"
+    <(image): "someimagename"
+  imagePullSecrets:
+  - name: my-registry-secret
+`
+	noHostPath := `
+spec:
+  =(volumes):
+  - X(hostPath): "null"
+`
+	nginxContainer := `
+spec:
+  ^(containers):
+  - image: nginx:latest
+`
+
+	cases := map[string]struct {
+		reason   string
+		pattern  string
+		resource string
+		want     string
+	}{
+		"ConditionalAnchorFails": {
+			reason:   "Elements a conditional anchor applies to must match the rest of the pattern.",
+			pattern:  latestPullPolicy,
+			resource: "spec: {containers: [{name: nginx, image: 'nginx:latest', imagePullPolicy: IfNotPresent}]}",
+			want:     KyvernoFail,
+		},
+		"ConditionalAnchorPasses": {
+			reason:   "Elements a conditional anchor applies to pass when they match the rest of the pattern.",
+			pattern:  latestPullPolicy,
+			resource: "spec: {containers: [{name: nginx, image: 'nginx:latest', imagePullPolicy: Always}]}",
+			want:     KyvernoPass,
+		},
+		"ConditionalAnchorSkipsElements": {
+			reason:   "Elements a conditional anchor doesn't apply to are skipped.",
+			pattern:  latestPullPolicy,
+			resource: "spec: {containers: [{name: a, image: 'nginx:1.2', imagePullPolicy: IfNotPresent}, {name: b, image: 'nginx:latest', imagePullPolicy: Always}]}",
+			want:     KyvernoPass,
+		},
+		"ConditionalAnchorAppliesToNoElement": {
+			reason:   "A pattern whose conditional anchor applies to no element of a list doesn't apply.",
+			pattern:  latestPullPolicy,
+			resource: "spec: {containers: [{name: nginx, image: 'nginx:1.2', imagePullPolicy: IfNotPresent}]}",
+			want:     KyvernoSkip,
+		},
+		"ConditionalAnchorOnMap": {
+			reason:   "A conditional anchor that doesn't match in an object skips the rule.",
+			pattern:  "metadata: {labels: {(app): nginx}}\nspec: {replicas: '<=3'}",
+			resource: "metadata: {labels: {app: other}}\nspec: {replicas: 5}",
+			want:     KyvernoSkip,
+		},
+		"ConditionalAnchorOnMapApplies": {
+			reason:   "A conditional anchor that matches in an object applies the rest of the pattern.",
+			pattern:  "metadata: {labels: {(app): nginx}}\nspec: {replicas: '<=3'}",
+			resource: "metadata: {labels: {app: nginx}}\nspec: {replicas: 5}",
+			want:     KyvernoFail,
+		},
+		"ConditionalAnchorParentMissing": {
+			reason:   "An object holding a conditional anchor is still required, unless it's anchored too.",
+			pattern:  "metadata: {labels: {(app): nginx}}\nspec: {replicas: '<=3'}",
+			resource: "metadata: {name: x}\nspec: {replicas: 5}",
+			want:     KyvernoFail,
+		},
+		"GlobalAnchorApplies": {
+			reason:   "A global anchor that matches applies the whole pattern.",
+			pattern:  imagePullSecrets,
+			resource: "spec: {containers: [{name: a, image: someimagename}]}",
+			want:     KyvernoFail,
+		},
+		"GlobalAnchorPasses": {
+			reason:   "A global anchor that matches passes when the whole pattern matches.",
+			pattern:  imagePullSecrets,
+			resource: "spec: {containers: [{name: a, image: someimagename}], imagePullSecrets: [{name: my-registry-secret}]}",
+			want:     KyvernoPass,
+		},
+		"GlobalAnchorSkips": {
+			reason:   "A global anchor that doesn't match skips the rule.",
+			pattern:  imagePullSecrets,
+			resource: "spec: {containers: [{name: a, image: other}]}",
+			want:     KyvernoSkip,
+		},
+		"NegationAnchorFails": {
+			reason:   "A negation anchor fails if its key is set.",
+			pattern:  noHostPath,
+			resource: "spec: {volumes: [{name: a, hostPath: {path: /var}}]}",
+			want:     KyvernoFail,
+		},
+		"NegationAnchorPasses": {
+			reason:   "A negation anchor passes if its key isn't set.",
+			pattern:  noHostPath,
+			resource: "spec: {volumes: [{name: a, emptyDir: {}}]}",
+			want:     KyvernoPass,
+		},
+		"EqualityAnchorAbsent": {
+			reason:   "An equality anchor passes if its key isn't set.",
+			pattern:  noHostPath,
+			resource: "spec: {containers: []}",
+			want:     KyvernoPass,
+		},
+		"EqualityAnchorMismatch": {
+			reason:   "An equality anchor fails if its key is set to a value that doesn't match.",
+			pattern:  "spec: {=(hostNetwork): false}",
+			resource: "spec: {hostNetwork: true}",
+			want:     KyvernoFail,
+		},
+		"ExistenceAnchorPasses": {
+			reason:   "An existence anchor passes if any element of the list matches.",
+			pattern:  nginxContainer,
+			resource: "spec: {containers: [{image: busybox}, {image: 'nginx:latest'}]}",
+			want:     KyvernoPass,
+		},
+		"ExistenceAnchorFails": {
+			reason:   "An existence anchor fails if no element of the list matches.",
+			pattern:  nginxContainer,
+			resource: "spec: {containers: [{image: busybox}]}",
+			want:     KyvernoFail,
+		},
+		"ExistenceAnchorAbsent": {
+			reason:   "An existence anchor passes if its key isn't set.",
+			pattern:  nginxContainer,
+			resource: "spec: {initContainers: [{image: busybox}]}",
+			want:     KyvernoPass,
+		},
+		"MultiElementListUsesFirst": {
+			reason:   "Only the first element of a list pattern of objects is used, and every element must match it.",
+			pattern:  "spec: {containers: [{name: 'app-*'}, {name: sidecar}]}",
+			resource: "spec: {containers: [{name: app-1}, {name: app-2}]}",
+			want:     KyvernoPass,
+		},
+		"MultiElementListEveryElement": {
+			reason:   "Every element of the list must match the first element of a list pattern of objects.",
+			pattern:  "spec: {containers: [{name: 'app-*'}, {name: sidecar}]}",
+			resource: "spec: {containers: [{name: app-1}, {name: sidecar}]}",
+			want:     KyvernoFail,
+		},
+		"ScalarListPattern": {
+			reason:   "Every element of a list must match a list pattern of a scalar.",
+			pattern:  "spec: {command: ['!*sh']}",
+			resource: "spec: {command: [ls, bash]}",
+			want:     KyvernoFail,
+		},
+		"ScalarPatternOnList": {
+			reason:   "Every element of a list must match a scalar pattern.",
+			pattern:  "spec: {args: '!--insecure*'}",
+			resource: "spec: {args: [--port=1, --insecure-skip-verify]}",
+			want:     KyvernoFail,
+		},
+		"QuantityRangeIn": {
+			reason:   "Quantities in a range match.",
+			pattern:  "resources: {limits: {memory: 1Gi-4Gi}}",
+			resource: "resources: {limits: {memory: 4096Mi}}",
+			want:     KyvernoPass,
+		},
+		"QuantityRangeOut": {
+			reason:   "Quantities out of a range don't match.",
+			pattern:  "resources: {limits: {memory: 1Gi-4Gi}}",
+			resource: "resources: {limits: {memory: 512Mi}}",
+			want:     KyvernoFail,
+		},
+		"QuantityNotInRange": {
+			reason:   "Quantities out of a negated range match.",
+			pattern:  "resources: {limits: {memory: 1Gi!-4Gi}}",
+			resource: "resources: {limits: {memory: 512Mi}}",
+			want:     KyvernoPass,
+		},
+		"QuantityNotInRangeIn": {
+			reason:   "Quantities in a negated range don't match.",
+			pattern:  "resources: {limits: {memory: 1Gi!-4Gi}}",
+			resource: "resources: {limits: {memory: 2Gi}}",
+			want:     KyvernoFail,
+		},
+		"QuantityComparison": {
+			reason:   "Quantities are compared in any unit.",
+			pattern:  "resources: {requests: {cpu: '>=100m'}}",
+			resource: "resources: {requests: {cpu: '0.5'}}",
+			want:     KyvernoPass,
+		},
+		"NumberComparison": {
+			reason:   "Integers are compared with numeric conditions.",
+			pattern:  "spec: {replicas: '>=2'}",
+			resource: "spec: {replicas: 1}",
+			want:     KyvernoFail,
+		},
+		"NumberRange": {
+			reason:   "Integers in a range match.",
+			pattern:  "spec: {replicas: 1-5}",
+			resource: "spec: {replicas: 3}",
+			want:     KyvernoPass,
+		},
+		"MissingNumberIsZero": {
+			reason:   "A missing field is compared as 0 by numeric conditions.",
+			pattern:  "spec: {replicas: '<=1'}",
+			resource: "spec: {}",
+			want:     KyvernoPass,
+		},
+		"DurationComparison": {
+			reason:   "Durations are compared as durations.",
+			pattern:  "spec: {timeout: '<=1m'}",
+			resource: "spec: {timeout: 2m}",
+			want:     KyvernoFail,
+		},
+		"DurationRange": {
+			reason:   "Durations in a range match.",
+			pattern:  "spec: {timeout: 1m-1h}",
+			resource: "spec: {timeout: 30m}",
+			want:     KyvernoPass,
+		},
+		"NumberPatternMatchesString": {
+			reason:   "Number patterns match numeric strings.",
+			pattern:  "spec: {port: 80}",
+			resource: "spec: {port: '80'}",
+			want:     KyvernoPass,
+		},
+		"WildcardNegation": {
+			reason:   "Negated wildcards don't match values they'd match.",
+			pattern:  "spec: {image: '!*:latest'}",
+			resource: "spec: {image: 'nginx:latest'}",
+			want:     KyvernoFail,
+		},
+		"Or": {
+			reason:   "Values matching any condition of an | pattern match.",
+			pattern:  "spec: {image: 'registry.io/* | docker.io/*'}",
+			resource: "spec: {image: docker.io/nginx}",
+			want:     KyvernoPass,
+		},
+		"And": {
+			reason:   "Values must match every condition of an & pattern.",
+			pattern:  "spec: {name: 'a* & *z'}",
+			resource: "spec: {name: abc}",
+			want:     KyvernoFail,
+		},
+		"NonEmpty": {
+			reason:   "?* doesn't match an empty string.",
+			pattern:  "metadata: {labels: {team: '?*'}}",
+			resource: "metadata: {labels: {team: ''}}",
+			want:     KyvernoFail,
+		},
+		"AnyValueRequired": {
+			reason:   "* requires the field to be set.",
+			pattern:  "spec: {template: '*'}",
+			resource: "spec: {}",
+			want:     KyvernoFail,
+		},
+		"AnyValueObject": {
+			reason:   "* matches any value, including an object.",
+			pattern:  "spec: {template: '*'}",
+			resource: "spec: {template: {metadata: {}}}",
+			want:     KyvernoPass,
+		},
+		"NullMissing": {
+			reason:   "A null pattern matches a missing field.",
+			pattern:  "spec: {hostPID: null}",
+			resource: "spec: {}",
+			want:     KyvernoPass,
+		},
+		"NullZero": {
+			reason:   "A null pattern matches the zero value of a scalar.",
+			pattern:  "spec: {hostPID: null}",
+			resource: "spec: {hostPID: false}",
+			want:     KyvernoPass,
+		},
+		"NullObject": {
+			reason:   "A null pattern doesn't match an object, even an empty one.",
+			pattern:  "spec: {securityContext: null}",
+			resource: "spec: {securityContext: {}}",
+			want:     KyvernoFail,
+		},
+		"MissingObject": {
+			reason:   "An object pattern requires the field.",
+			pattern:  "spec: {securityContext: {runAsNonRoot: true}}",
+			resource: "spec: {}",
+			want:     KyvernoFail,
+		},
+		"MetadataWildcardKey": {
+			reason:   "Wildcard label keys match the labels they expand to.",
+			pattern:  "metadata: {labels: {app.kubernetes.io/*: '?*'}}",
+			resource: "metadata: {labels: {team: a, app.kubernetes.io/name: web}}",
+			want:     KyvernoPass,
+		},
+		"MetadataWildcardKeyMissing": {
+			reason:   "Wildcard label keys that match no label are required.",
+			pattern:  "metadata: {labels: {app.kubernetes.io/*: '?*'}}",
+			resource: "metadata: {labels: {team: a}}",
+			want:     KyvernoFail,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := matchKyvernoPattern(kyvernoResource(t, tc.resource), kyvernoPattern(t, tc.pattern), "")
+			if got := kyvernoStatus(err); got != tc.want {
+				t.Errorf("\n%s\nmatchKyvernoPattern(...): want %s, got %s (%v)", tc.reason, tc.want, got, err)
+			}
+		})
+	}
+}
+
+func TestKyvernoKindMatches(t *testing.T) {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("s3.aws.upbound.io/v1beta1")
+	u.SetKind("Bucket")
+
+	cases := map[string]struct {
+		reason string
+		kind   string
+		want   bool
+	}{
+		"Kind":              {reason: "A kind matches resources of any group and version.", kind: "Bucket", want: true},
+		"KindWildcard":      {reason: "Kinds may have wildcards.", kind: "Buck*", want: true},
+		"Any":               {reason: "* matches every kind.", kind: "*", want: true},
+		"VersionKind":       {reason: "A version and kind match resources of any group.", kind: "v1beta1/Bucket", want: true},
+		"OtherVersion":      {reason: "A version and kind don't match other versions.", kind: "v1/Bucket", want: false},
+		"GroupAnyVersion":   {reason: "A group, wildcard version and kind match any version.", kind: "s3.aws.upbound.io/*/Bucket", want: true},
+		"OtherGroup":        {reason: "A group, version and kind don't match other groups.", kind: "ec2.aws.upbound.io/*/Bucket", want: false},
+		"GroupVersionKind":  {reason: "A group, version and kind match exactly.", kind: "s3.aws.upbound.io/v1beta1/Bucket", want: true},
+		"Subresource":       {reason: "Subresources never match rendered resources.", kind: "Bucket/status", want: false},
+		"OtherKind":         {reason: "Other kinds don't match.", kind: "Pod", want: false},
+		"SubresourceOfKind": {reason: "A group, version, kind and subresource never match rendered resources.", kind: "s3.aws.upbound.io/v1beta1/Bucket/status", want: false},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := kyvernoKindMatches(tc.kind, u); got != tc.want {
+				t.Errorf("\n%s\nkyvernoKindMatches(%q): want %t, got %t", tc.reason, tc.kind, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateKyvernoPolicies(t *testing.T) {
+	policy := kyvernoPolicy{Kind: "ClusterPolicy"}
+	policy.Metadata.Name = "buckets"
+	policy.Spec.ValidationFailureAction = "Audit"
+	policy.Spec.Rules = []kyvernoRule{
+		{
+			Name:     "require-region",
+			Match:    kyvernoMatch{Any: []kyvernoFilter{{Resources: kyvernoResources{Kinds: []string{"s3.aws.upbound.io/*/Bucket"}}}}},
+			Validate: &kyvernoValidate{FailureAction: "Enforce", Message: "a region is required", Pattern: kyvernoPattern(t, "spec: {forProvider: {region: '?*'}}")},
+		},
+		{
+			Name:     "private",
+			Match:    kyvernoMatch{Any: []kyvernoFilter{{Resources: kyvernoResources{Kinds: []string{"Bucket"}}}}},
+			Validate: &kyvernoValidate{Message: "buckets must be private", Pattern: kyvernoPattern(t, "spec: {forProvider: {=(acl): private}}")},
+		},
+		{
+			Name:     "deny",
+			Match:    kyvernoMatch{Any: []kyvernoFilter{{Resources: kyvernoResources{Kinds: []string{"Bucket"}}}}},
+			Validate: &kyvernoValidate{Deny: map[string]any{}},
+		},
+	}
+
+	u := &unstructured.Unstructured{Object: kyvernoResource(t, "apiVersion: s3.aws.upbound.io/v1beta1\nkind: Bucket\nmetadata: {name: b}\nspec: {forProvider: {acl: public-read}}")}
+	cm := &unstructured.Unstructured{Object: kyvernoResource(t, "apiVersion: v1\nkind: ConfigMap\nmetadata: {name: c}")}
+
+	got, err := EvaluateKyvernoPolicies([]kyvernoPolicy{policy}, []*unstructured.Unstructured{u, cm})
+	if err != nil {
+		t.Fatalf("EvaluateKyvernoPolicies(...): %v", err)
+	}
+	want := []KyvernoResult{
+		{Policy: "buckets", Rule: "require-region", Resource: "Bucket/b", Status: KyvernoFail, Severity: SeverityError, Message: "a region is required (spec.forProvider.region: is required)"},
+		{Policy: "buckets", Rule: "private", Resource: "Bucket/b", Status: KyvernoFail, Severity: SeverityWarning, Message: "buckets must be private (spec.forProvider.acl: \"public-read\" doesn't match \"private\")"},
+		{Policy: "buckets", Rule: "deny", Resource: "Bucket/b", Status: KyvernoSkip, Severity: SeverityWarning, Message: "deny conditions aren't supported"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("\nRules should be evaluated against the resources they match, failing with the severity of their failure action.\nEvaluateKyvernoPolicies(...): -want, +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// NewLintCommand creates a new lint command.
+func NewLintCommand() *cobra.Command {
+	cmd := &lintCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "lint <file-or-dir>...",
+		Short: "Check Compositions and XRDs against the configured rules",
+		Long: `Lint checks the Compositions and XRDs in the supplied files and directories
+against the rules in the crossbench configuration file, without rendering
+anything: custom rules targeting compositions or XRDs, and environment
+literal rules.
+
+Rules targeting the XR or composed resources are evaluated by render, validate
+and diff instead, since they need a rendered XR.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Path to the crossbench configuration file. It's optional unless set explicitly.")
+	cobraCmd.Flags().StringVar(&cmd.baseline, "baseline", getLintBaselinePath(), "Findings baseline: a file, or an s3://, gs:// or oci:// location shared with CI. Findings in it are grandfathered, so only new findings are reported and fail.")
+	cobraCmd.Flags().BoolVar(&cmd.writeBaseline, "write-baseline", false, "Write the current findings to the --baseline file instead of reporting them.")
+	cobraCmd.Flags().StringToStringVar(&cmd.reports, "report", nil, "Write a report of the findings as <format>=<path>. Repeatable; formats: junit, sarif.")
+
+	return cobraCmd
+}
+
+type lintCmd struct {
+	config        string
+	baseline      string
+	writeBaseline bool
+	reports       map[string]string
+
+	fs afero.Fs
+}
+
+func (c *lintCmd) run(cmd *cobra.Command, args []string) error {
+	started := time.Now()
+	if err := validReports(c.reports); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(c.fs, c.config, cmd.Flags().Changed("config"))
+	if err != nil {
+		return err
+	}
+
+	docs, sources, err := loadDocuments(c.fs, args)
+	if err != nil {
+		return err
+	}
+
+	findings, err := lint(cfg, docs)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]string, len(docs))
+	for _, d := range docs {
+		files[resourceID(d)] = sources[d]
+	}
+	for i := range findings {
+		findings[i].File = files[findings[i].Resource]
+	}
+	findings, err = applyBaseline(c.fs, c.baseline, c.writeBaseline, findings)
+	if err != nil {
+		return err
+	}
+	printFindings(os.Stderr, findings)
+
+	err = findingsError(findings)
+	result := RunResult{Name: "lint", Duration: time.Since(started), Findings: findings}
+	if err != nil {
+		result.Failures = []string{err.Error()}
+	}
+	if rerr := writeReports(c.fs, c.reports, NewRunSummary("lint", started, []RunResult{result})); rerr != nil {
+		return rerr
+	}
+	return err
+}
+
+// loadDocuments loads the Kubernetes resources in the supplied YAML files and
+// directories, walked recursively. Documents that aren't Kubernetes
+// resources, such as test files, are skipped. The file each document was
+// loaded from is returned too.
+func loadDocuments(fs afero.Fs, paths []string) ([]*unstructured.Unstructured, map[*unstructured.Unstructured]string, error) {
+	files := []string{}
+	for _, p := range paths {
+		err := afero.Walk(fs, p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); !info.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot walk %q", p)
+		}
+	}
+
+	docs := []*unstructured.Unstructured{}
+	sources := map[*unstructured.Unstructured]string{}
+	for _, f := range files {
+		data, err := afero.ReadFile(fs, f)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot read %q", f)
+		}
+		us, err := parseYAMLStream(data)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "cannot parse %q", f)
+		}
+		for i := range us {
+			if us[i].GetAPIVersion() != "" && us[i].GetKind() != "" {
+				convertLegacyAPIVersion(&us[i])
+				docs = append(docs, &us[i])
+				sources[&us[i]] = f
+			}
+		}
+	}
+	return docs, sources, nil
+}
+
+// lint runs the static checks - those that don't need a rendered XR - against
+// the Compositions and XRDs among the supplied documents.
+func lint(cfg *Config, docs []*unstructured.Unstructured) ([]Finding, error) {
+	rules, err := compileRules(cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	comps := []*unstructured.Unstructured{}
+	xrds := []*unstructured.Unstructured{}
+	for _, d := range docs {
+		gvk := d.GroupVersionKind()
+		switch {
+		case gvk == apiextensionsv1.CompositionGroupVersionKind:
+			comps = append(comps, d)
+		case gvk.Group == apiextensionsv1.Group && gvk.Kind == apiextensionsv1.CompositeResourceDefinitionKind:
+			xrds = append(xrds, d)
+		}
+	}
+
+	findings := EvaluateRules(rules, RuleTargetComposition, comps, nil)
+	findings = append(findings, EvaluateRules(rules, RuleTargetXRD, xrds, nil)...)
+
+	if len(cfg.EnvironmentLiterals) > 0 {
+		for _, u := range comps {
+			comp := &apiextensionsv1.Composition{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, comp); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse Composition %q", u.GetName())
+			}
+			lf, err := CheckEnvironmentLiterals(cfg.EnvironmentLiterals, comp)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot check environment literals")
+			}
+			findings = append(findings, lf...)
+		}
+	}
+	return findings, nil
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+const checkEnvironmentLiterals = "environment-literals"
+
+// An EnvironmentLiteralRule detects hardcoded, environment-specific values,
+// such as account IDs, IP ranges or regions, in the inputs of a Composition's
+// Function pipeline. Such values belong in an EnvironmentConfig.
+type EnvironmentLiteralRule struct {
+	// Name of the rule, used in findings. Defaults to the preset.
+	Name string `json:"name,omitempty"`
+
+	// Pattern is a regular expression matching the literal values.
+	Pattern string `json:"pattern,omitempty"`
+
+	// Preset applies a built-in pattern. One of aws-account-id, aws-region,
+	// gcp-region or ipv4.
+	Preset string `json:"preset,omitempty"`
+
+	// Allow lists literal values that are fine to hardcode, e.g. 0.0.0.0/0.
+	Allow []string `json:"allow,omitempty"`
+
+	// Severity of violations. Defaults to warning.
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// environmentLiteralPresets are built-in patterns for common
+// environment-specific values.
+var environmentLiteralPresets = map[string]string{
+	"aws-account-id": `\b\d{12}\b`,
+	"aws-region":     `\b(us|eu|ap|sa|ca|me|af|il|mx)-(gov-)?(north|south|east|west|central|northeast|southeast|northwest|southwest)-\d\b`,
+	"gcp-region":     `\b(us|europe|asia|australia|northamerica|southamerica|me|africa)-(north|south|east|west|central|northeast|southeast|northwest|southwest)\d\b`,
+	"ipv4":           `\b\d{1,3}(\.\d{1,3}){3}(/\d{1,2})?\b`,
+}
+
+// CheckEnvironmentLiterals reports string values in the inputs of the
+// Composition's pipeline steps that match any of the rules.
+func CheckEnvironmentLiterals(rules []EnvironmentLiteralRule, comp *apiextensionsv1.Composition) ([]Finding, error) {
+	type compiled struct {
+		name     string
+		re       *regexp.Regexp
+		allow    map[string]bool
+		severity Severity
+	}
+
+	crs := make([]compiled, 0, len(rules))
+	for i, r := range rules {
+		c := compiled{name: r.Name, allow: map[string]bool{}, severity: r.Severity}
+		pattern := r.Pattern
+		if r.Preset != "" {
+			p, ok := environmentLiteralPresets[r.Preset]
+			if !ok {
+				return nil, errors.Errorf("environment literal rule %d: unknown preset %q", i, r.Preset)
+			}
+			pattern = p
+			if c.name == "" {
+				c.name = r.Preset
+			}
+		}
+		if pattern == "" {
+			return nil, errors.Errorf("environment literal rule %d: a pattern or preset is required", i)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "environment literal rule %d: invalid pattern", i)
+		}
+		c.re = re
+		if c.name == "" {
+			c.name = pattern
+		}
+		if c.severity == "" {
+			c.severity = SeverityWarning
+		}
+		for _, v := range r.Allow {
+			c.allow[v] = true
+		}
+		crs = append(crs, c)
+	}
+
+	findings := []Finding{}
+	for i, step := range comp.Spec.Pipeline {
+		if step.Input == nil || len(step.Input.Raw) == 0 {
+			continue
+		}
+		var input any
+		if err := json.Unmarshal(step.Input.Raw, &input); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse input of step %q", step.Step)
+		}
+
+		walkStrings(input, "input", func(path, value string) {
+			for _, c := range crs {
+				m := c.re.FindString(value)
+				if m == "" || c.allow[m] || c.allow[value] {
+					continue
+				}
+				findings = append(findings, Finding{
+					Check:    checkEnvironmentLiterals,
+					Severity: c.severity,
+					Resource: fmt.Sprintf("Composition/%s", comp.GetName()),
+					Message:  fmt.Sprintf("step %s: %s hardcodes %q (%s); source it from an EnvironmentConfig instead", step.Step, path, m, c.name),
+					Path:     fmt.Sprintf("spec.pipeline[%d].%s", i, path),
+				})
+			}
+		})
+	}
+	return findings, nil
+}
+
+// walkStrings calls fn with the field path and value of every string in the
+// supplied JSON value.
+func walkStrings(v any, path string, fn func(path, value string)) {
+	switch v := v.(type) {
+	case string:
+		fn(path, v)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkStrings(v[k], path+"."+k, fn)
+		}
+	case []any:
+		for i, e := range v {
+			walkStrings(e, path+"["+strconv.Itoa(i)+"]", fn)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+)
+
+// A Lock records the resolved package and digest of each Function, so
+// rendering is repeatable and doesn't need to look up versions.
+type Lock struct {
+	// Functions are keyed by Function name.
+	Functions map[string]LockedFunction `json:"functions"`
+}
+
+// A LockedFunction is a Function package resolved to a digest.
+type LockedFunction struct {
+	// Package is the resolved package, including its tag.
+	Package string `json:"package"`
+
+	// Digest of the package's OCI image.
+	Digest string `json:"digest"`
+}
+
+// Image returns the reference of the locked image, pinned to its digest.
+func (f LockedFunction) Image() string {
+	if f.Digest == "" {
+		return f.Package
+	}
+	ref, err := name.ParseReference(f.Package)
+	if err != nil {
+		return f.Package + "@" + f.Digest
+	}
+	return ref.Context().Name() + "@" + f.Digest
+}
+
+// getLockPath returns the path of the function lock file
+// Default: crossbench.lock, configurable via CROSSBENCH_LOCK_FILE env var
+func getLockPath() string {
+	if path := os.Getenv("CROSSBENCH_LOCK_FILE"); path != "" {
+		return path
+	}
+	return "crossbench.lock"
+}
+
+// loadLock loads the lock file at the supplied path. It returns nil if the
+// file doesn't exist.
+func loadLock(fs afero.Fs, path string) (*Lock, error) {
+	data, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read lock file %q", path)
+	}
+	l := &Lock{}
+	if err := yaml.Unmarshal(data, l); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse lock file %q", path)
+	}
+	if l.Functions == nil {
+		l.Functions = map[string]LockedFunction{}
+	}
+	return l, nil
+}
+
+// saveLock writes the lock file to the supplied path.
+func saveLock(fs afero.Fs, path string, l *Lock) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal lock file")
+	}
+	header := "# Generated by crossbench lock. Do not edit; run crossbench lock --update instead.\n"
+	return errors.Wrapf(afero.WriteFile(fs, path, append([]byte(header), data...), 0644), "cannot write lock file %q", path)
+}
+
+// getPinDigests returns whether function package tags are resolved to digests
+// Default: false, configurable via CROSSBENCH_PIN_DIGESTS env var
+func getPinDigests() bool {
+	return os.Getenv("CROSSBENCH_PIN_DIGESTS") == "true"
+}
+
+// pinFunctionDigests resolves the package of each Function to the digest its
+// tag currently points to, and pins the Function to it. Packages already
+// pinned to a digest, and Functions overridden in the configuration, are left
+// alone.
+func pinFunctionDigests(fns []pkgv1.Function, overrides map[string]FunctionConfig) error {
+	for i := range fns {
+		if o, ok := overrides[fns[i].GetName()]; ok && (o.Image != "" || o.Source != "") {
+			continue
+		}
+		ref, err := name.ParseReference(fns[i].Spec.Package)
+		if err != nil {
+			return errors.Wrapf(err, "cannot parse function %q package %q", fns[i].GetName(), fns[i].Spec.Package)
+		}
+		if _, ok := ref.(name.Digest); ok {
+			continue
+		}
+		digest, err := crane.Digest(fns[i].Spec.Package)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve digest of function %q package %q", fns[i].GetName(), fns[i].Spec.Package)
+		}
+		fns[i].Spec.Package = ref.Context().Name() + "@" + digest
+		infof("Pinned function %q to %s", fns[i].GetName(), fns[i].Spec.Package)
+	}
+	return nil
+}
+
+// lockedFunctions returns the Functions the Composition's pipeline uses,
+// taking each locked Function from the lock and extracting the others. It
+// also returns the names of the Functions that weren't locked.
+func lockedFunctions(comp *apiextensionsv1.Composition, l *Lock, fs afero.Fs, forceRefresh bool) ([]pkgv1.Function, []string, error) {
+	fns := []pkgv1.Function{}
+	unlocked := comp.DeepCopy()
+	unlocked.Spec.Pipeline = nil
+	seen := map[string]bool{}
+	for _, step := range comp.Spec.Pipeline {
+		n := step.FunctionRef.Name
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		lf, ok := l.Functions[n]
+		if !ok {
+			unlocked.Spec.Pipeline = append(unlocked.Spec.Pipeline, step)
+			continue
+		}
+		fn := pkgv1.Function{}
+		fn.SetName(n)
+		fn.Spec.Package = lf.Image()
+		fns = append(fns, fn)
+	}
+
+	if len(unlocked.Spec.Pipeline) == 0 {
+		return fns, nil, nil
+	}
+
+	names := make([]string, len(unlocked.Spec.Pipeline))
+	for i, step := range unlocked.Spec.Pipeline {
+		names[i] = step.FunctionRef.Name
+	}
+	extracted, err := ExtractFunctionsFromComposition(unlocked, fs, forceRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(fns, extracted...), names, nil
+}
+
+// NewLockCommand creates a new lock command.
+func NewLockCommand() *cobra.Command {
+	cmd := &lockCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "lock <composition-file-or-dir>...",
+		Short: "Pin the functions used by compositions in a lock file",
+		Long: `Lock resolves the package and OCI digest of every function used by the
+supplied compositions and records them in a lock file. When the lock file
+exists, render, validate, diff and test use the locked, digest-pinned packages
+instead of looking up the latest versions on GitHub.
+
+Functions already in the lock file keep their pinned version unless --update
+is set, which resolves every function again.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Path to the crossbench configuration file, for per-function resolvers and version constraints. It's optional unless set explicitly.")
+	cobraCmd.Flags().StringVar(&cmd.lockFile, "lock-file", getLockPath(), "Path to the lock file.")
+	cobraCmd.Flags().BoolVar(&cmd.update, "update", false, "Resolve every function again, including those already locked.")
+
+	return cobraCmd
+}
+
+type lockCmd struct {
+	config   string
+	lockFile string
+	update   bool
+
+	fs afero.Fs
+}
+
+func (c *lockCmd) run(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(c.fs, c.config, cmd.Flags().Changed("config"))
+	if err != nil {
+		return err
+	}
+	if err := setFunctionResolution(cfg.Functions); err != nil {
+		return err
+	}
+	creds, err := newGitHubCredentialChain(getGitHubAuthMode(), "", cfg.GitHub)
+	if err != nil {
+		return err
+	}
+	gitHubCredentials = creds
+
+	comps, err := loadCompositionPaths(c.fs, args)
+	if err != nil {
+		return err
+	}
+
+	l, err := loadLock(c.fs, c.lockFile)
+	if err != nil {
+		return err
+	}
+	if l == nil || c.update {
+		l = &Lock{Functions: map[string]LockedFunction{}}
+	}
+
+	for _, comp := range comps {
+		if comp.Spec.Mode != apiextensionsv1.CompositionModePipeline {
+			continue
+		}
+		fns, _, err := lockedFunctions(comp, l, c.fs, c.update)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve functions of Composition %q", comp.GetName())
+		}
+		for _, fn := range fns {
+			if _, ok := l.Functions[fn.GetName()]; ok {
+				continue
+			}
+			digest, err := crane.Digest(fn.Spec.Package)
+			if err != nil {
+				return errors.Wrapf(err, "cannot resolve digest of function %q package %q", fn.GetName(), fn.Spec.Package)
+			}
+			l.Functions[fn.GetName()] = LockedFunction{Package: fn.Spec.Package, Digest: digest}
+			infof("Locked function %q to %s@%s", fn.GetName(), fn.Spec.Package, digest)
+		}
+	}
+
+	if err := saveLock(c.fs, c.lockFile, l); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(l.Functions))
+	for n := range l.Functions {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	infof("Wrote %d function(s) to %s: %s", len(names), c.lockFile, strings.Join(names, ", "))
+	return nil
+}
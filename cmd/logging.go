@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+)
+
+// Formats of --log-format.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logger is what every command logs with. It's configured by --log-level and
+// --log-format.
+var logger = slog.New(newTextLogHandler(os.Stderr, slog.LevelInfo))
+
+// getLogLevel returns the lowest level logged
+// Default: info, configurable via CROSSBENCH_LOG_LEVEL env var
+func getLogLevel() string {
+	if l := os.Getenv("CROSSBENCH_LOG_LEVEL"); l != "" {
+		return l
+	}
+	return "info"
+}
+
+// getLogFormat returns the format logs are written in
+// Default: text, configurable via CROSSBENCH_LOG_FORMAT env var
+func getLogFormat() string {
+	if f := os.Getenv("CROSSBENCH_LOG_FORMAT"); f != "" {
+		return f
+	}
+	return LogFormatText
+}
+
+// AddLoggingFlags registers the --log-level and --log-format flags, which
+// apply to every command below the supplied root command.
+func AddLoggingFlags(root *cobra.Command) {
+	level, format := getLogLevel(), getLogFormat()
+	root.PersistentFlags().StringVar(&level, "log-level", level, "Lowest level of the messages logged to stderr: debug, info, warn or error.")
+	root.PersistentFlags().StringVar(&format, "log-format", format, "Format of the messages logged to stderr: text or json.")
+	root.PersistentPreRunE = func(_ *cobra.Command, _ []string) error {
+		return setupLogging(os.Stderr, level, format)
+	}
+}
+
+// setupLogging configures the logger.
+func setupLogging(w io.Writer, level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return errors.Errorf("unknown --log-level %q, must be debug, info, warn or error", level)
+	}
+	switch format {
+	case LogFormatText:
+		logger = slog.New(newTextLogHandler(w, l))
+	case LogFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: l}))
+	default:
+		return errors.Errorf("unknown --log-format %q, must be %s or %s", format, LogFormatText, LogFormatJSON)
+	}
+	return nil
+}
+
+// debugf logs a debug message.
+func debugf(format string, args ...any) {
+	logf(slog.LevelDebug, format, args...)
+}
+
+// infof logs an informational message.
+func infof(format string, args ...any) {
+	logf(slog.LevelInfo, format, args...)
+}
+
+// warnf logs a warning.
+func warnf(format string, args ...any) {
+	logf(slog.LevelWarn, format, args...)
+}
+
+// errorf logs an error that doesn't stop the command.
+func errorf(format string, args ...any) {
+	logf(slog.LevelError, format, args...)
+}
+
+func logf(level slog.Level, format string, args ...any) {
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, fmt.Sprintf(format, args...))
+}
+
+// A textLogHandler writes each record on a line of its own, prefixed by its
+// level, e.g. INFO: Pulling function-example key=value.
+type textLogHandler struct {
+	mu    *sync.Mutex
+	w     io.Writer
+	level slog.Level
+	attrs []slog.Attr
+}
+
+func newTextLogHandler(w io.Writer, level slog.Level) *textLogHandler {
+	return &textLogHandler{mu: &sync.Mutex{}, w: w, level: level}
+}
+
+func (h *textLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textLogHandler) Handle(_ context.Context, r slog.Record) error {
+	b := &strings.Builder{}
+	b.WriteString(r.Level.String())
+	b.WriteString(": ")
+	b.WriteString(r.Message)
+	attr := func(a slog.Attr) bool {
+		if a.Equal(slog.Attr{}) {
+			return true
+		}
+		_, _ = fmt.Fprintf(b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		attr(a)
+	}
+	r.Attrs(attr)
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &c
+}
+
+// WithGroup isn't supported; attributes of groups are logged ungrouped.
+func (h *textLogHandler) WithGroup(_ string) slog.Handler {
+	return h
+}
+
+// crossplaneLogger adapts the logger to the logging.Logger of the Crossplane
+// render engine.
+type crossplaneLogger struct {
+	l *slog.Logger
+}
+
+// newCrossplaneLogger returns the logger as a logging.Logger.
+func newCrossplaneLogger() logging.Logger {
+	return crossplaneLogger{l: logger}
+}
+
+func (c crossplaneLogger) Info(msg string, keysAndValues ...any) {
+	c.l.Info(msg, keysAndValues...)
+}
+
+func (c crossplaneLogger) Debug(msg string, keysAndValues ...any) {
+	c.l.Debug(msg, keysAndValues...)
+}
+
+func (c crossplaneLogger) WithValues(keysAndValues ...any) logging.Logger {
+	return crossplaneLogger{l: c.l.With(keysAndValues...)}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// contextKeyMeta is the context key crossbench passes its metadata to
+// functions under, so they can tell they're rendered locally, e.g. to skip
+// cloud lookups.
+const contextKeyMeta = "crossbench.io/meta"
+
+// getMetaContext returns whether crossbench metadata is passed to functions
+// Default: true, configurable via CROSSBENCH_META_CONTEXT env var
+func getMetaContext() bool {
+	return os.Getenv("CROSSBENCH_META_CONTEXT") != "false"
+}
+
+// MetaContext is the crossbench metadata passed to functions.
+type MetaContext struct {
+	// Mode is the command rendering, e.g. render, test, diff or serve.
+	Mode string `json:"mode"`
+
+	// Profile is the environment profile, if any.
+	Profile string `json:"profile,omitempty"`
+
+	// Version of crossbench.
+	Version string `json:"version"`
+}
+
+// injectsMeta returns whether the metadata is passed to functions. It isn't
+// if the user sets the context key themselves.
+func (c *renderCmd) injectsMeta() bool {
+	if !c.metaContext {
+		return false
+	}
+	if _, ok := c.contextFiles[contextKeyMeta]; ok {
+		return false
+	}
+	_, ok := c.contextValues[contextKeyMeta]
+	return !ok
+}
+
+// metaContextValue returns the metadata as a context value.
+func (c *renderCmd) metaContextValue() ([]byte, error) {
+	b, err := json.Marshal(MetaContext{Mode: c.mode, Profile: c.profile, Version: version})
+	return b, errors.Wrap(err, "cannot marshal crossbench metadata")
+}
+
+// stripMetaContext removes the metadata from the context the pipeline
+// produced. It's only passed to functions, and isn't output.
+func stripMetaContext(out render.Outputs) {
+	if out.Context == nil {
+		return
+	}
+	if fields, ok := out.Context.Object["fields"].(map[string]*structpb.Value); ok {
+		delete(fields, contextKeyMeta)
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are recorded by every command, but only served by serve and daemon.
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	rendersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crossbench_renders_total",
+		Help: "Renders of composite resources, by result: success or error.",
+	}, []string{"result"})
+
+	renderDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "crossbench_render_duration_seconds",
+		Help:    "How long rendering a composite resource took, including starting its Functions.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	})
+
+	functionCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "crossbench_function_call_duration_seconds",
+		Help:    "How long calls to Functions took, by Function name.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"function"})
+
+	functionStartsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "crossbench_function_pool_starts_total",
+		Help: "Function containers started by the warm pool of serve or daemon.",
+	})
+
+	functionsRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "crossbench_function_pool_running",
+		Help: "Function containers running in the warm pool of serve or daemon.",
+	})
+
+	versionCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "crossbench_version_cache_lookups_total",
+		Help: "Lookups of function versions in the version cache, by result: hit, miss or stale (used as a fallback).",
+	}, []string{"result"})
+
+	gitHubRateLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "crossbench_github_rate_limit",
+		Help: "The GitHub API rate limit reported by the last response, by field: limit, remaining or reset (Unix time).",
+	}, []string{"field"})
+
+	gitHubRateLimitedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "crossbench_github_rate_limited_total",
+		Help: "GitHub API requests refused because the rate limit was exceeded.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		rendersTotal,
+		renderDuration,
+		functionCallDuration,
+		functionStartsTotal,
+		functionsRunning,
+		versionCacheLookups,
+		gitHubRateLimit,
+		gitHubRateLimitedTotal,
+	)
+}
+
+// metricsHandler serves the metrics in the Prometheus exposition format.
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// recordGitHubRateLimit records the rate limit headers of a GitHub API
+// response.
+func recordGitHubRateLimit(resp *http.Response) {
+	for field, header := range map[string]string{
+		"limit":     "X-RateLimit-Limit",
+		"remaining": "X-RateLimit-Remaining",
+		"reset":     "X-RateLimit-Reset",
+	} {
+		if v, err := strconv.ParseFloat(resp.Header.Get(header), 64); err == nil {
+			gitHubRateLimit.WithLabelValues(field).Set(v)
+		}
+	}
+}
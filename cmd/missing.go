@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+// getMissingInputs returns whether the optional inputs a render went without
+// are reported
+// Default: true, configurable via CROSSBENCH_MISSING_INPUTS env var
+func getMissingInputs() bool {
+	return os.Getenv("CROSSBENCH_MISSING_INPUTS") != "false"
+}
+
+// A MissingInput is an optional input a render went without. The render
+// succeeded, but providing the input would make it more realistic.
+type MissingInput struct {
+	// Input is how the input is provided, e.g. --extra-resources.
+	Input string `json:"input"`
+
+	// Step is the pipeline step that reported or needed the input, if any.
+	Step string `json:"step,omitempty"`
+
+	Reason string `json:"reason"`
+}
+
+// missingDataHints are phrases of function results that report data a
+// function was missing, e.g. function-patch-and-transform's "cannot find
+// required resource".
+var missingDataHints = []string{
+	"cannot find",
+	"can't find",
+	"could not find",
+	"couldn't find",
+	"not found",
+	"missing",
+	"does not exist",
+	"doesn't exist",
+	"no observed",
+	"not yet observed",
+}
+
+// MissingInputs returns the optional inputs the supplied render went
+// without: resources functions required but none of the extra or required
+// resources matched, the environment when steps patch from it, the observed
+// state of composed resources, and data functions reported missing in their
+// results. Requirements aren't checked when they were fetched from a
+// cluster.
+func MissingInputs(in render.Inputs, out render.Outputs, fromCluster bool) []MissingInput {
+	missing := []MissingInput{}
+
+	if !fromCluster {
+		fetcher := render.NewFilteringFetcher(append(in.ExtraResources, in.RequiredResources...)...)
+		steps := make([]string, 0, len(out.Requirements))
+		for s := range out.Requirements {
+			steps = append(steps, s)
+		}
+		sort.Strings(steps)
+		for _, s := range steps {
+			selectors := map[string]*fnv1.ResourceSelector{}
+			for n, sel := range out.Requirements[s].ExtraResources { //nolint:staticcheck // Functions may still use the deprecated field.
+				selectors[n] = sel
+			}
+			for n, sel := range out.Requirements[s].Resources {
+				selectors[n] = sel
+			}
+			names := make([]string, 0, len(selectors))
+			for n := range selectors {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			for _, n := range names {
+				rs, err := fetcher.Fetch(context.Background(), selectors[n])
+				if err != nil || len(rs.GetItems()) > 0 {
+					continue
+				}
+				missing = append(missing, MissingInput{
+					Input:  "--extra-resources",
+					Step:   s,
+					Reason: fmt.Sprintf("requires %s as %q, but none was supplied", describeSelector(selectors[n]), n),
+				})
+			}
+		}
+	}
+
+	if _, ok := in.Context[contextKeyEnvironment]; !ok && in.Composition != nil && readsEnvironment(in.Composition) {
+		missing = append(missing, MissingInput{
+			Input:  "--context-values",
+			Reason: fmt.Sprintf("the pipeline patches from the environment, but no %s context value was supplied", contextKeyEnvironment),
+		})
+	}
+
+	observed := map[string]bool{}
+	for _, o := range in.ObservedResources {
+		observed[o.GetAnnotations()[render.AnnotationKeyCompositionResourceName]] = true
+	}
+	unobserved := []string{}
+	for _, cd := range out.ComposedResources {
+		if name := cd.GetAnnotations()[render.AnnotationKeyCompositionResourceName]; !observed[name] {
+			unobserved = append(unobserved, name)
+		}
+	}
+	if len(unobserved) > 0 {
+		missing = append(missing, MissingInput{
+			Input:  "--observed-resources",
+			Reason: fmt.Sprintf("%s rendered as if never created, so readiness and patches from their status aren't exercised", strings.Join(unobserved, ", ")),
+		})
+	}
+
+	for _, r := range out.Results {
+		message, _ := r.Object["message"].(string)
+		lower := strings.ToLower(message)
+		for _, h := range missingDataHints {
+			if strings.Contains(lower, h) {
+				step, _ := r.Object["step"].(string)
+				missing = append(missing, MissingInput{Input: "function results", Step: step, Reason: fmt.Sprintf("reported %q", message)})
+				break
+			}
+		}
+	}
+	return missing
+}
+
+// describeSelector returns a readable description of the resources the
+// supplied selector matches, e.g. EnvironmentConfig "prod" or
+// EnvironmentConfig with labels env=prod.
+func describeSelector(rs *fnv1.ResourceSelector) string {
+	d := rs.GetKind()
+	if ns := rs.GetNamespace(); ns != "" {
+		d += " in namespace " + ns
+	}
+	if name := rs.GetMatchName(); name != "" {
+		return fmt.Sprintf("%s %q", d, name)
+	}
+	labels := rs.GetMatchLabels().GetLabels()
+	if len(labels) == 0 {
+		return "every " + d
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return fmt.Sprintf("%s with labels %s", d, strings.Join(pairs, ","))
+}
+
+// printMissingInputs writes the supplied missing inputs as a single report,
+// or nothing if there are none.
+func printMissingInputs(w io.Writer, missing []MissingInput) {
+	if len(missing) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(w, "Inputs you could provide for a more realistic render:")
+	for _, m := range missing {
+		if m.Step != "" {
+			_, _ = fmt.Fprintf(w, "  %s: step %s %s\n", m.Input, m.Step, m.Reason)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "  %s: %s\n", m.Input, m.Reason)
+	}
+}
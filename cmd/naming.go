@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+)
+
+const checkNaming = "naming"
+
+// A NamingRule constrains the names of rendered resources of a kind. The name
+// checked is the resource's external name annotation if it's set, since
+// that's the name the provider will use, and its metadata.name otherwise.
+type NamingRule struct {
+	// Kind of the resources the rule applies to. Empty matches all kinds.
+	Kind string `json:"kind,omitempty"`
+
+	// APIGroup of the resources the rule applies to. Empty matches all groups.
+	APIGroup string `json:"apiGroup,omitempty"`
+
+	// Pattern is a regular expression names must match.
+	Pattern string `json:"pattern,omitempty"`
+
+	// MinLength and MaxLength bound the length of names.
+	MinLength int `json:"minLength,omitempty"`
+	MaxLength int `json:"maxLength,omitempty"`
+
+	// AllowedCharacters is a regular expression character class body, e.g.
+	// "a-z0-9-", listing the only characters names may contain.
+	AllowedCharacters string `json:"allowedCharacters,omitempty"`
+
+	// Preset applies a built-in, provider-specific rule. One of s3-bucket,
+	// gcs-bucket, azure-storage-account or dns-1123-label.
+	Preset string `json:"preset,omitempty"`
+
+	// Severity of violations. Defaults to error.
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// namingPresets are built-in rules for names the cloud providers constrain.
+// They return a description of each violation.
+var namingPresets = map[string]func(name string) []string{
+	"s3-bucket":             s3BucketNameViolations,
+	"gcs-bucket":            gcsBucketNameViolations,
+	"azure-storage-account": azureStorageAccountNameViolations,
+	"dns-1123-label":        dns1123LabelViolations,
+}
+
+var (
+	s3BucketNameRE  = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*[a-z0-9]$`)
+	gcsBucketNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*[a-z0-9]$`)
+	azureStorageRE  = regexp.MustCompile(`^[a-z0-9]+$`)
+	dns1123LabelRE  = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/bucketnamingrules.html
+func s3BucketNameViolations(name string) []string {
+	v := lengthViolations(name, 3, 63)
+	if !s3BucketNameRE.MatchString(name) {
+		v = append(v, "must contain only lowercase letters, numbers, dots and hyphens, and begin and end with a letter or number")
+	}
+	if strings.Contains(name, "..") {
+		v = append(v, "must not contain two adjacent periods")
+	}
+	if net.ParseIP(name) != nil {
+		v = append(v, "must not be formatted as an IP address")
+	}
+	for _, p := range []string{"xn--", "sthree-", "amzn-s3-demo-"} {
+		if strings.HasPrefix(name, p) {
+			v = append(v, fmt.Sprintf("must not start with %q", p))
+		}
+	}
+	for _, s := range []string{"-s3alias", "--ol-s3", ".mrap", "--x-s3"} {
+		if strings.HasSuffix(name, s) {
+			v = append(v, fmt.Sprintf("must not end with %q", s))
+		}
+	}
+	return v
+}
+
+// https://cloud.google.com/storage/docs/buckets#naming
+func gcsBucketNameViolations(name string) []string {
+	max := 63
+	if strings.Contains(name, ".") {
+		max = 222
+	}
+	v := lengthViolations(name, 3, max)
+	if !gcsBucketNameRE.MatchString(name) {
+		v = append(v, "must contain only lowercase letters, numbers, dots, hyphens and underscores, and begin and end with a letter or number")
+	}
+	if strings.HasPrefix(name, "goog") {
+		v = append(v, `must not start with "goog"`)
+	}
+	if net.ParseIP(name) != nil {
+		v = append(v, "must not be formatted as an IP address")
+	}
+	return v
+}
+
+// https://learn.microsoft.com/azure/storage/common/storage-account-overview#storage-account-name
+func azureStorageAccountNameViolations(name string) []string {
+	v := lengthViolations(name, 3, 24)
+	if !azureStorageRE.MatchString(name) {
+		v = append(v, "must contain only lowercase letters and numbers")
+	}
+	return v
+}
+
+func dns1123LabelViolations(name string) []string {
+	v := lengthViolations(name, 1, 63)
+	if !dns1123LabelRE.MatchString(name) {
+		v = append(v, "must consist of lowercase alphanumeric characters or '-', and begin and end with an alphanumeric character")
+	}
+	return v
+}
+
+func lengthViolations(name string, min, max int) []string {
+	switch {
+	case min > 0 && len(name) < min:
+		return []string{fmt.Sprintf("must be at least %d characters, is %d", min, len(name))}
+	case max > 0 && len(name) > max:
+		return []string{fmt.Sprintf("must be at most %d characters, is %d", max, len(name))}
+	}
+	return nil
+}
+
+// CheckNaming evaluates the supplied naming rules against the composed
+// resources. Resources without a name yet are skipped.
+func CheckNaming(rules []NamingRule, cds []composed.Unstructured) ([]Finding, error) {
+	findings := []Finding{}
+	for _, r := range rules {
+		violations, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+
+		sev := r.Severity
+		if sev == "" {
+			sev = SeverityError
+		}
+
+		for i := range cds {
+			gvk := cds[i].GetObjectKind().GroupVersionKind()
+			if (r.Kind != "" && r.Kind != gvk.Kind) || (r.APIGroup != "" && r.APIGroup != gvk.Group) {
+				continue
+			}
+
+			name := meta.GetExternalName(&cds[i])
+			if name == "" {
+				name = cds[i].GetName()
+			}
+			if name == "" {
+				continue
+			}
+
+			for _, v := range violations(name) {
+				findings = append(findings, Finding{
+					Check:    checkNaming,
+					Severity: sev,
+					Resource: resourceID(&cds[i].Unstructured),
+					Message:  fmt.Sprintf("name %q %s", name, v),
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// compile returns a function that describes each way a name violates the rule.
+func (r NamingRule) compile() (func(name string) []string, error) {
+	var preset func(string) []string
+	if r.Preset != "" {
+		p, ok := namingPresets[r.Preset]
+		if !ok {
+			return nil, errors.Errorf("unknown naming preset %q", r.Preset)
+		}
+		preset = p
+	}
+
+	var pattern, allowed *regexp.Regexp
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid naming pattern %q", r.Pattern)
+		}
+		pattern = re
+	}
+	if r.AllowedCharacters != "" {
+		re, err := regexp.Compile(fmt.Sprintf("^[%s]*$", r.AllowedCharacters))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid allowed characters %q", r.AllowedCharacters)
+		}
+		allowed = re
+	}
+
+	return func(name string) []string {
+		var v []string
+		if preset != nil {
+			v = append(v, preset(name)...)
+		}
+		v = append(v, lengthViolations(name, r.MinLength, r.MaxLength)...)
+		if allowed != nil && !allowed.MatchString(name) {
+			v = append(v, fmt.Sprintf("must contain only characters in [%s]", r.AllowedCharacters))
+		}
+		if pattern != nil && !pattern.MatchString(name) {
+			v = append(v, fmt.Sprintf("must match %q", r.Pattern))
+		}
+		return v
+	}, nil
+}
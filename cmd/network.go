@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// getNoNetwork returns whether outbound network access is blocked
+// Default: false, configurable via CROSSBENCH_NO_NETWORK env var
+func getNoNetwork() bool {
+	return os.Getenv("CROSSBENCH_NO_NETWORK") == "true"
+}
+
+// A networkBlocker is an HTTP transport that fails and records every request.
+type networkBlocker struct {
+	mu       sync.Mutex
+	attempts []string
+}
+
+// RoundTrip records and refuses the supplied request.
+func (b *networkBlocker) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempt := fmt.Sprintf("%s %s", req.Method, req.URL.Redacted())
+	b.mu.Lock()
+	b.attempts = append(b.attempts, attempt)
+	b.mu.Unlock()
+	return nil, errors.Errorf("network access is disabled (--no-network or --offline): %s", attempt)
+}
+
+// Attempts returns the requests that were refused.
+func (b *networkBlocker) Attempts() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.attempts...)
+}
+
+// hermetic wraps a command's run function so that if noNetwork returns true
+// every outbound HTTP request - GitHub version lookups and registry pulls - is
+// refused, and the run fails listing the refused requests.
+func hermetic(noNetwork func() bool, run func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if !noNetwork() {
+			return run(cmd, args)
+		}
+
+		b := &networkBlocker{}
+		httpTransport, registryTransport := http.DefaultTransport, remote.DefaultTransport
+		http.DefaultTransport, remote.DefaultTransport = b, b
+		defer func() {
+			http.DefaultTransport, remote.DefaultTransport = httpTransport, registryTransport
+		}()
+
+		err := run(cmd, args)
+		if attempts := b.Attempts(); len(attempts) > 0 {
+			return errors.Errorf("run is not hermetic, %d network request(s) were refused:\n  %s", len(attempts), strings.Join(attempts, "\n  "))
+		}
+		return err
+	}
+}
+
+// getOffline returns whether crossbench runs offline
+// Default: false, configurable via CROSSBENCH_OFFLINE env var
+func getOffline() bool {
+	return os.Getenv("CROSSBENCH_OFFLINE") == "true"
+}
+
+// offline is true when crossbench must not use the network, taking function
+// versions from the cache even if it has expired.
+var offline bool
+
+// requireLocalImages returns an error naming the Functions whose images
+// aren't present in the local container runtime. Functions that don't run in
+// Docker are skipped.
+func requireLocalImages(fns []pkgv1.Function) error {
+	missing := []string{}
+	for _, fn := range fns {
+		if fn.GetAnnotations()[render.AnnotationKeyRuntime] == string(render.AnnotationValueRuntimeDevelopment) {
+			continue
+		}
+		image := fn.Spec.Package
+		if i := fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerImage]; i != "" {
+			image = i
+		}
+		if err := exec.Command(containerCLI, "image", "inspect", image).Run(); err != nil {
+			missing = append(missing, fmt.Sprintf("%s (%s)", fn.GetName(), image))
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("running offline, but these function images aren't present locally; run crossbench functions pull while online first:\n  %s", strings.Join(missing, "\n  "))
+	}
+	return nil
+}
+
+// neverPullFunctions stops the Docker runtime from pulling Function images,
+// so rendering only uses images that are already available locally.
+func neverPullFunctions(fns []pkgv1.Function) {
+	for i := range fns {
+		meta.AddAnnotations(&fns[i], map[string]string{
+			render.AnnotationKeyRuntimeDockerPullPolicy: string(render.AnnotationValueRuntimeDockerPullPolicyNever),
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+)
+
+const checkMetadata = "metadata"
+
+// validateMetadata returns the ways the metadata of the supplied resource
+// breaks the rules the API server enforces on create: names and generateName
+// prefixes must be DNS-1123 subdomains, namespaces DNS-1123 labels, label keys
+// qualified names and values at most 63 characters, annotations at most 256kB
+// in total and finalizers qualified names. Resources without a name yet are
+// fine, since Crossplane names them when they're created.
+func validateMetadata(u *unstructured.Unstructured) field.ErrorList {
+	p := field.NewPath("metadata")
+	errs := field.ErrorList{}
+	if gn := u.GetGenerateName(); gn != "" {
+		for _, msg := range apivalidation.NameIsDNSSubdomain(gn, true) {
+			errs = append(errs, field.Invalid(p.Child("generateName"), gn, msg))
+		}
+	}
+	if n := u.GetName(); n != "" {
+		for _, msg := range apivalidation.NameIsDNSSubdomain(n, false) {
+			errs = append(errs, field.Invalid(p.Child("name"), n, msg))
+		}
+	}
+	if ns := u.GetNamespace(); ns != "" {
+		for _, msg := range apivalidation.ValidateNamespaceName(ns, false) {
+			errs = append(errs, field.Invalid(p.Child("namespace"), ns, msg))
+		}
+	}
+	errs = append(errs, metav1validation.ValidateLabels(u.GetLabels(), p.Child("labels"))...)
+	errs = append(errs, apivalidation.ValidateAnnotations(u.GetAnnotations(), p.Child("annotations"))...)
+	errs = append(errs, apivalidation.ValidateFinalizers(u.GetFinalizers(), p.Child("finalizers"))...)
+	return errs
+}
+
+// CheckMetadata verifies that the metadata of the rendered XR and composed
+// resources would be accepted by the API server, so violations are caught
+// locally rather than when the resources are applied.
+func CheckMetadata(xr *unstructured.Unstructured, cds []composed.Unstructured) []Finding {
+	us := []*unstructured.Unstructured{xr}
+	for i := range cds {
+		us = append(us, &cds[i].Unstructured)
+	}
+	findings := []Finding{}
+	for _, u := range us {
+		for _, err := range validateMetadata(u) {
+			findings = append(findings, Finding{
+				Check:    checkMetadata,
+				Severity: SeverityError,
+				Resource: resourceID(u),
+				Message:  err.Error(),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// Files and directories written by crossbench observe.
+const (
+	ObserveFileXR      = "xr.yaml"
+	ObserveDirObserved = "observed"
+)
+
+// NewObserveCommand creates a new observe command.
+func NewObserveCommand() *cobra.Command {
+	cmd := &observeCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "observe <type>/<name>",
+		Short: "Export an XR and the resources it composes in a cluster to files",
+		Long: `Observe reads an XR from a live cluster, along with the resources it composes
+as recorded in its resource references, and writes them to --output-dir: the
+XR to xr.yaml, and each composed resource to its own file in the observed
+subdirectory, ready to be passed to render with --observed-resources.
+
+The type is the XR's resource, e.g. xbuckets.example.crossplane.io, or its
+kind, e.g. xbucket, optionally qualified by its group. Namespaced XRs are read
+from --namespace, or the namespace of the kubeconfig context.
+
+The exported state can be rendered again with:
+
+  crossbench render <output-dir>/xr.yaml <composition> -o <output-dir>/observed
+
+or, with the composition next to the output directory, just the XR, since
+render finds an observed directory next to an XR passed on its own.
+
+Server-side bookkeeping, such as managed fields, is left out of the files.
+Nothing is written to the cluster.`,
+		Args: cobra.ExactArgs(1),
+		RunE: cmd.run,
+	}
+
+	cmd.addClusterFlags(cobraCmd)
+	cobraCmd.Flags().StringVarP(&cmd.namespace, "namespace", "n", "", "Namespace of a namespaced XR. Defaults to the namespace of the kubeconfig context.")
+	cobraCmd.Flags().StringVar(&cmd.outputDir, "output-dir", "", "Directory to write the XR and its observed resources to. Defaults to a directory named after the XR.")
+	cobraCmd.Flags().DurationVar(&cmd.timeout, "timeout", 1*time.Minute, "How long to run before timing out.")
+
+	return cobraCmd
+}
+
+type observeCmd struct {
+	clusterFlags
+
+	namespace string
+	outputDir string
+	timeout   time.Duration
+
+	fs afero.Fs
+}
+
+func (c *observeCmd) run(_ *cobra.Command, args []string) error {
+	resource, name, ok := strings.Cut(args[0], "/")
+	if !ok || resource == "" || name == "" {
+		return errors.Errorf("invalid XR %q, must be <type>/<name>, e.g. xbuckets.example.crossplane.io/my-bucket", args[0])
+	}
+
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	gvk, err := cc.kindFor(resource)
+	if err != nil {
+		return err
+	}
+	ns := c.namespace
+	if ns == "" {
+		if ns, err = c.clusterFlags.namespace(); err != nil {
+			return err
+		}
+	}
+	xr := &unstructured.Unstructured{}
+	xr.SetGroupVersionKind(gvk)
+	xr.SetName(name)
+	xr.SetNamespace(ns)
+
+	live, cds, err := cc.composedResources(ctx, xr)
+	if err != nil {
+		return errors.Wrap(err, "cannot get composed resources from cluster")
+	}
+	if live == nil {
+		return errors.Errorf("%s %q doesn't exist in the cluster", gvk.Kind, name)
+	}
+
+	dir := c.outputDir
+	if dir == "" {
+		dir = safeFileName(strings.ToLower(name))
+	}
+	if err := c.fs.MkdirAll(filepath.Join(dir, ObserveDirObserved), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create output directory %q", dir)
+	}
+	if err := writeObserved(c.fs, filepath.Join(dir, ObserveFileXR), live); err != nil {
+		return err
+	}
+	for i := range cds {
+		if cds[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName] == "" {
+			warnf("%s %q has no %s annotation, render can't match it to a desired resource", cds[i].GetKind(), cds[i].GetName(), render.AnnotationKeyCompositionResourceName)
+		}
+		if err := writeObserved(c.fs, filepath.Join(dir, ObserveDirObserved, outputFileName(&cds[i])), &cds[i]); err != nil {
+			return err
+		}
+	}
+	infof("Wrote %s %q and %d composed resource(s) to %s", gvk.Kind, name, len(cds), dir)
+	return nil
+}
+
+// writeObserved writes the supplied resource, as read from a cluster, to the
+// supplied file, leaving out its managed fields.
+func writeObserved(fs afero.Fs, file string, u *unstructured.Unstructured) error {
+	u = u.DeepCopy()
+	u.SetManagedFields(nil)
+	y, err := encodeYAML(u)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %s %q to YAML", u.GetKind(), u.GetName())
+	}
+	return errors.Wrapf(afero.WriteFile(fs, file, y, 0644), "cannot write %q", file)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+)
+
+// AnnotationKeyArgoCDSyncWave is the annotation Argo CD uses to order the
+// resources it applies.
+const AnnotationKeyArgoCDSyncWave = "argocd.argoproj.io/sync-wave"
+
+// A DependencyOrder assigns each composed resource to a wave. Resources only
+// depend on resources in earlier waves, so waves can be applied in order and
+// deleted in reverse order.
+type DependencyOrder struct {
+	// Waves holds the indices of the composed resources in each wave.
+	Waves [][]int
+
+	// DependsOn holds the indices of the resources each resource references.
+	DependsOn map[int][]int
+
+	// Cyclic holds the indices of resources that are part of, or depend on, a
+	// reference cycle. They're placed in the last wave.
+	Cyclic []int
+}
+
+// ComputeDependencyOrder derives a best-effort dependency order from the
+// references and selectors between composed resources.
+func ComputeDependencyOrder(cds []composed.Unstructured) DependencyOrder {
+	o := DependencyOrder{DependsOn: map[int][]int{}}
+	for i := range cds {
+		seen := map[int]bool{}
+		for _, r := range findReferences(&cds[i]) {
+			for _, j := range resolveReference(r, cds, i) {
+				if !seen[j] {
+					seen[j] = true
+					o.DependsOn[i] = append(o.DependsOn[i], j)
+				}
+			}
+		}
+		sort.Ints(o.DependsOn[i])
+	}
+
+	placed := map[int]bool{}
+	for len(placed) < len(cds) {
+		wave := []int{}
+		for i := range cds {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for _, j := range o.DependsOn[i] {
+				if !placed[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				wave = append(wave, i)
+			}
+		}
+
+		if len(wave) == 0 {
+			// Everything left is part of or depends on a cycle.
+			for i := range cds {
+				if !placed[i] {
+					o.Cyclic = append(o.Cyclic, i)
+				}
+			}
+			wave = o.Cyclic
+		}
+
+		for _, i := range wave {
+			placed[i] = true
+		}
+		o.Waves = append(o.Waves, wave)
+	}
+
+	return o
+}
+
+// SetSyncWaves annotates each composed resource with the Argo CD sync wave
+// matching its dependency wave.
+func (o DependencyOrder) SetSyncWaves(cds []composed.Unstructured) {
+	for w, wave := range o.Waves {
+		for _, i := range wave {
+			meta.AddAnnotations(&cds[i], map[string]string{AnnotationKeyArgoCDSyncWave: strconv.Itoa(w)})
+		}
+	}
+}
+
+// Print writes the creation order, wave by wave, with each resource's
+// dependencies.
+func (o DependencyOrder) Print(w io.Writer, cds []composed.Unstructured) {
+	_, _ = fmt.Fprintln(w, "Dependency order (create top to bottom, delete bottom to top):")
+	for n, wave := range o.Waves {
+		_, _ = fmt.Fprintf(w, "  wave %d:\n", n)
+		for _, i := range wave {
+			_, _ = fmt.Fprintf(w, "    %s", resourceID(&cds[i].Unstructured))
+			if deps := o.DependsOn[i]; len(deps) > 0 {
+				names := make([]string, len(deps))
+				for k, j := range deps {
+					names[k] = resourceID(&cds[j].Unstructured)
+				}
+				_, _ = fmt.Fprintf(w, " <- %s", strings.Join(names, ", "))
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+	}
+	if len(o.Cyclic) > 0 {
+		_, _ = fmt.Fprintf(w, "WARN: %d resource(s) are part of or depend on a reference cycle and were placed in the last wave\n", len(o.Cyclic))
+	}
+}
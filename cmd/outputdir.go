@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bytes"
+	encjson "encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/afero"
+	kresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Layouts of the files written to an output directory.
+const (
+	// OutputLayoutResource writes each resource to its own file.
+	OutputLayoutResource = "resource"
+
+	// OutputLayoutKind writes the resources of each kind to one file.
+	OutputLayoutKind = "kind"
+
+	// OutputLayoutStep writes the composed resources to one file per
+	// pipeline step, the step that first desired them.
+	OutputLayoutStep = "step"
+
+	// OutputLayoutSingle writes every resource to one file.
+	OutputLayoutSingle = "single"
+
+	// OutputLayoutGitOps writes each resource to its own file in a directory
+	// per API group and kind, e.g. s3.aws.upbound.io/bucket/my-bucket.yaml.
+	OutputLayoutGitOps = "gitops"
+)
+
+// An OutputTarget configures how files are laid out in an output directory.
+type OutputTarget struct {
+	// Layout is resource, kind, step, single or gitops. Defaults to resource.
+	Layout string `json:"layout,omitempty"`
+
+	// MaxFileSize, e.g. 512Ki, splits files that would be larger into parts
+	// holding whole resources. Files aren't split by default.
+	MaxFileSize string `json:"maxFileSize,omitempty"`
+}
+
+// An outputLayout is how the files of an output directory are laid out.
+type outputLayout struct {
+	Layout      string
+	MaxFileSize int64
+
+	// Pipeline is the order of the pipeline steps, and ResourceSteps the step
+	// that first desired each composed resource, by composition resource
+	// name. They name the files of the step layout.
+	Pipeline      []string
+	ResourceSteps map[string]string
+}
+
+// newOutputLayout validates the supplied output target.
+func newOutputLayout(t OutputTarget) (outputLayout, error) {
+	l := outputLayout{Layout: t.Layout}
+	switch l.Layout {
+	case "":
+		l.Layout = OutputLayoutResource
+	case OutputLayoutResource, OutputLayoutKind, OutputLayoutStep, OutputLayoutSingle, OutputLayoutGitOps:
+	default:
+		return l, errors.Errorf("unknown output layout %q, must be %s, %s, %s, %s or %s", t.Layout, OutputLayoutResource, OutputLayoutKind, OutputLayoutStep, OutputLayoutSingle, OutputLayoutGitOps)
+	}
+	if t.MaxFileSize != "" {
+		q, err := kresource.ParseQuantity(t.MaxFileSize)
+		if err != nil {
+			return l, errors.Wrapf(err, "invalid max file size %q", t.MaxFileSize)
+		}
+		if l.MaxFileSize = q.Value(); l.MaxFileSize <= 0 {
+			return l, errors.Errorf("invalid max file size %q, must be positive", t.MaxFileSize)
+		}
+	}
+	return l, nil
+}
+
+// fileName returns the name of the file holding the supplied resource, which
+// is the XR if composed is false.
+func (l outputLayout) fileName(u *unstructured.Unstructured, composed bool) string {
+	switch l.Layout {
+	case OutputLayoutKind:
+		return safeFileName(strings.ToLower(u.GetKind())) + ".yaml"
+	case OutputLayoutStep:
+		if !composed {
+			return outputFileName(u)
+		}
+		step := l.ResourceSteps[u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]]
+		for i, s := range l.Pipeline {
+			if s == step {
+				return fmt.Sprintf("%02d-%s.yaml", i+1, safeFileName(strings.ToLower(step)))
+			}
+		}
+		return "resources.yaml"
+	case OutputLayoutSingle:
+		return "resources.yaml"
+	case OutputLayoutGitOps:
+		return gitOpsFileName(u)
+	}
+	return outputFileName(u)
+}
+
+// gitOpsFileName returns the path of the file for a rendered resource in the
+// gitops layout: <group>/<kind>/<name>.yaml, with core for the core group.
+// Resources without a name use their composition resource name instead.
+func gitOpsFileName(u *unstructured.Unstructured) string {
+	group := u.GroupVersionKind().Group
+	if group == "" {
+		group = "core"
+	}
+	name := u.GetName()
+	if name == "" {
+		name = u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+	}
+	if name = safeFileName(strings.ToLower(name)); name == "" {
+		name = "resource"
+	}
+	return filepath.Join(safeFileName(strings.ToLower(group)), safeFileName(strings.ToLower(u.GetKind())), name+".yaml")
+}
+
+// safeFileName replaces the characters of s that aren't safe in file names.
+func safeFileName(s string) string {
+	return strings.Trim(unsafeFileNameChars.ReplaceAllString(s, "-"), "-")
+}
+
+// encodeYAML serializes a resource to YAML.
+func encodeYAML(o runtime.Object) ([]byte, error) {
+	s := json.NewSerializerWithOptions(json.DefaultMetaFactory, nil, nil, json.SerializerOptions{Yaml: true})
+	b := &bytes.Buffer{}
+	if err := s.Encode(o, b); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// outputFileName returns the file name for a rendered resource, built from
+// its kind, namespace and name, e.g. bucket-my-bucket.yaml or
+// configmap-team-a-settings.yaml. Resources without a name use their
+// composition resource name instead.
+func outputFileName(u *unstructured.Unstructured) string {
+	name := u.GetName()
+	if name == "" {
+		name = u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+	}
+	base := strings.ToLower(u.GetKind())
+	if ns := u.GetNamespace(); ns != "" {
+		base += "-" + strings.ToLower(ns)
+	}
+	if name != "" {
+		base += "-" + strings.ToLower(name)
+	}
+	return safeFileName(base) + ".yaml"
+}
+
+// manifestFileName is the file in the root of an output directory that
+// indexes every file written to it.
+const manifestFileName = "manifest.json"
+
+// An OutputManifest indexes the files written to an output directory, so
+// humans and tools know which inputs produced each of them.
+type OutputManifest struct {
+	Files []OutputFile `json:"files"`
+}
+
+// An OutputFile is a file written to an output directory.
+type OutputFile struct {
+	// Path of the file, relative to the output directory. Files holding
+	// several resources are listed once for each of them.
+	Path string `json:"path"`
+
+	// APIVersion, Kind and Name of the resource in the file, if it holds one.
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty"`
+	Name       string `json:"name,omitempty"`
+
+	// Resource is the composition resource name of a composed resource.
+	Resource string `json:"resource,omitempty"`
+
+	// Inputs that produced the file.
+	Inputs OutputInputs `json:"inputs"`
+}
+
+// OutputInputs are the inputs of the render that produced an output file.
+type OutputInputs struct {
+	XR                string `json:"xr"`
+	Composition       string `json:"composition"`
+	CompositionFile   string `json:"compositionFile,omitempty"`
+	Functions         string `json:"functions,omitempty"`
+	Profile           string `json:"profile,omitempty"`
+	ObservedResources string `json:"observedResources,omitempty"`
+	ExtraResources    string `json:"extraResources,omitempty"`
+}
+
+// writeOutputManifest writes the manifest of the files written to the
+// supplied output directory.
+func writeOutputManifest(fs afero.Fs, dir string, files []OutputFile) error {
+	data, err := encjson.MarshalIndent(OutputManifest{Files: files}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal output manifest")
+	}
+	path := filepath.Join(dir, manifestFileName)
+	return errors.Wrapf(afero.WriteFile(fs, path, append(data, '\n'), 0644), "cannot write %q", path)
+}
+
+// writeOutputDir writes the rendered XR and composed resources to files in
+// the supplied directory, laid out as configured. Function results and
+// context, if included, are written to results.yaml and context.yaml. It
+// returns the files written, with paths relative to dir.
+func writeOutputDir(fs afero.Fs, dir string, out render.Outputs, includeResults, includeContext bool, l outputLayout) ([]OutputFile, error) {
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create output directory %q", dir)
+	}
+
+	files := []OutputFile{}
+	write := func(name string, data []byte) error {
+		files = append(files, OutputFile{Path: name})
+		path := filepath.Join(dir, name)
+		return errors.Wrapf(afero.WriteFile(fs, path, data, 0644), "cannot write %q", path)
+	}
+
+	// Group the resources by file, in the order the files are first used.
+	type document struct {
+		data []byte
+		u    *unstructured.Unstructured
+	}
+	names := []string{}
+	groups := map[string][]document{}
+	used := map[string]int{}
+	add := func(u *unstructured.Unstructured, composed bool, data []byte) {
+		name := l.fileName(u, composed)
+		if _, ok := groups[name]; ok && (l.Layout == OutputLayoutResource || l.Layout == OutputLayoutGitOps) {
+			// Disambiguate resources that map to the same file name, counting
+			// from the last suffix used for it until a name is unused.
+			ext := filepath.Ext(name)
+			base := strings.TrimSuffix(name, ext)
+			n := max(used[name], 1)
+			for {
+				n++
+				if _, ok := groups[fmt.Sprintf("%s-%d%s", base, n, ext)]; !ok {
+					break
+				}
+			}
+			used[name] = n
+			name = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], document{data: data, u: u})
+	}
+
+	b, err := encodeYAML(out.CompositeResource)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal composite resource %q to YAML", out.CompositeResource.GetName())
+	}
+	add(&out.CompositeResource.Unstructured, false, b)
+
+	for i := range out.ComposedResources {
+		b, err := encodeYAML(&out.ComposedResources[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot marshal composed resource %q to YAML", out.ComposedResources[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName])
+		}
+		add(&out.ComposedResources[i].Unstructured, true, b)
+	}
+
+	for _, name := range names {
+		// Split the file into parts no larger than the max file size, unless
+		// a single resource is larger.
+		parts := [][]document{{}}
+		size := int64(0)
+		for _, d := range groups[name] {
+			n := int64(len(d.data) + len("---\n"))
+			if l.MaxFileSize > 0 && size > 0 && size+n > l.MaxFileSize {
+				parts = append(parts, []document{})
+				size = 0
+			}
+			if l.MaxFileSize > 0 && n > l.MaxFileSize {
+				warnf("%s %q is %d bytes, larger than the max file size of %d bytes", d.u.GetKind(), d.u.GetName(), n, l.MaxFileSize)
+			}
+			parts[len(parts)-1] = append(parts[len(parts)-1], d)
+			size += n
+		}
+		for i, docs := range parts {
+			path := name
+			if len(parts) > 1 {
+				ext := filepath.Ext(name)
+				path = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i+1, ext)
+			}
+			buf := &bytes.Buffer{}
+			for _, d := range docs {
+				if len(docs) > 1 {
+					_, _ = fmt.Fprintln(buf, "---")
+				}
+				buf.Write(d.data)
+				files = append(files, OutputFile{Path: path})
+				f := &files[len(files)-1]
+				f.APIVersion, f.Kind, f.Name = d.u.GetAPIVersion(), d.u.GetKind(), d.u.GetName()
+				f.Resource = d.u.GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+			}
+			path = filepath.Join(dir, path)
+			if err := fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return nil, errors.Wrapf(err, "cannot create output directory %q", filepath.Dir(path))
+			}
+			if err := afero.WriteFile(fs, path, buf.Bytes(), 0644); err != nil {
+				return nil, errors.Wrapf(err, "cannot write %q", path)
+			}
+		}
+	}
+
+	if includeResults && len(out.Results) > 0 {
+		buf := &bytes.Buffer{}
+		for i := range out.Results {
+			b, err := encodeYAML(&out.Results[i])
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot marshal result to YAML")
+			}
+			_, _ = fmt.Fprintln(buf, "---")
+			buf.Write(b)
+		}
+		if err := write("results.yaml", buf.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	if includeContext && out.Context != nil {
+		b, err := encodeYAML(out.Context)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot marshal context to YAML")
+		}
+		if err := write("context.yaml", b); err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// outputTarget returns how the files of the --output-dir are laid out: the
+// --output-layout and --max-file-size flags, falling back to the outputTargets
+// entry of the configuration file for the directory.
+func (c *renderCmd) outputTarget() (outputLayout, error) {
+	t := OutputTarget{}
+	if dir, err := filepath.Abs(c.outputDir); err == nil {
+		for d, ot := range c.cfg.OutputTargets {
+			if abs, err := filepath.Abs(d); err == nil && abs == dir {
+				t = ot
+			}
+		}
+	}
+	if c.outputLayout != "" {
+		t.Layout = c.outputLayout
+	}
+	if c.maxFileSize != "" {
+		t.MaxFileSize = c.maxFileSize
+	}
+	return newOutputLayout(t)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+func TestWriteOutputDir(t *testing.T) {
+	resource := func(apiVersion, kind, namespace, name string) composed.Unstructured {
+		u := composed.New()
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		return *u
+	}
+	xr := composite.New()
+	xr.SetAPIVersion("example.org/v1")
+	xr.SetKind("XStorage")
+	xr.SetName("storage")
+
+	cases := map[string]struct {
+		reason   string
+		composed []composed.Unstructured
+		want     []string
+	}{
+		"Namespaces": {
+			reason: "Namespaced resources with the same kind and name should be written to files named after their namespace.",
+			composed: []composed.Unstructured{
+				resource("v1", "ConfigMap", "a", "cfg"),
+				resource("v1", "ConfigMap", "b", "cfg"),
+				resource("v1", "ConfigMap", "c", "cfg"),
+			},
+			want: []string{"xstorage-storage.yaml", "configmap-a-cfg.yaml", "configmap-b-cfg.yaml", "configmap-c-cfg.yaml"},
+		},
+		"Clashes": {
+			reason: "Every resource mapping to the same file name should get a file of its own.",
+			composed: []composed.Unstructured{
+				resource("s3.aws.upbound.io/v1beta1", "Bucket", "", "data"),
+				resource("storage.gcp.upbound.io/v1beta1", "Bucket", "", "data"),
+				resource("storage.azure.upbound.io/v1beta1", "Bucket", "", "data"),
+				resource("oss.alibaba.crossplane.io/v1alpha1", "Bucket", "", "data"),
+			},
+			want: []string{"xstorage-storage.yaml", "bucket-data.yaml", "bucket-data-2.yaml", "bucket-data-3.yaml", "bucket-data-4.yaml"},
+		},
+		"ClashWithSuffix": {
+			reason: "A suffixed name another resource maps to should be skipped.",
+			composed: []composed.Unstructured{
+				resource("s3.aws.upbound.io/v1beta1", "Bucket", "", "data"),
+				resource("s3.aws.upbound.io/v1beta1", "Bucket", "", "data-2"),
+				resource("storage.gcp.upbound.io/v1beta1", "Bucket", "", "data"),
+				resource("storage.gcp.upbound.io/v1beta1", "Bucket", "", "data-2"),
+			},
+			want: []string{"xstorage-storage.yaml", "bucket-data.yaml", "bucket-data-2.yaml", "bucket-data-3.yaml", "bucket-data-2-2.yaml"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			out := render.Outputs{CompositeResource: xr, ComposedResources: tc.composed}
+			files, err := writeOutputDir(fs, "out", out, false, false, outputLayout{Layout: OutputLayoutResource})
+			if err != nil {
+				t.Fatalf("writeOutputDir(...): %v", err)
+			}
+
+			got := []string{}
+			for _, f := range files {
+				got = append(got, f.Path)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nwriteOutputDir(...): -want, +got:\n%s", tc.reason, diff)
+			}
+
+			written, err := afero.ReadDir(fs, "out")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(written) != len(tc.want) {
+				t.Errorf("\n%s\nwriteOutputDir(...): want %d files, got %d", tc.reason, len(tc.want), len(written))
+			}
+		})
+	}
+}
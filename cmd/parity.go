@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// getCrossplaneBinary returns the crossplane CLI parity compares with
+// Default: crossplane, configurable via CROSSBENCH_CROSSPLANE_BINARY env var
+func getCrossplaneBinary() string {
+	if b := os.Getenv("CROSSBENCH_CROSSPLANE_BINARY"); b != "" {
+		return b
+	}
+	return "crossplane"
+}
+
+// NewParityCommand creates a new parity command.
+func NewParityCommand() *cobra.Command {
+	cmd := &parityCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "parity <composite-resource> [composition] [functions]",
+		Short: "Compare a render with the output of crossplane render",
+		Long: `Parity renders the XR exactly like the render command does, then renders it
+again with an external crossplane render and compares the two outputs, to check
+crossbench matches the upstream render engine of that crossplane version.
+
+Both renders get the same inputs: the XR, Composition and Functions crossbench
+loaded - including Functions extracted from the Composition, locked versions
+and runtime overrides - along with the observed resources, extra resources,
+credentials and context. crossplane render runs Functions with Docker.
+
+The XR and composed resources are compared field by field. The output lists
+resources only crossbench rendered (+), only crossplane rendered (-) and
+resources that differ (~), with crossplane's value of each field first. Known
+differences can be left out with --ignore-path.`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	// Flags
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().StringVar(&cmd.crossplaneBinary, "crossplane-binary", getCrossplaneBinary(), "The crossplane CLI to compare with.")
+	cobraCmd.Flags().StringSliceVar(&cmd.ignorePaths, "ignore-path", nil, "Comma-separated field paths not compared, e.g. status.conditions or metadata.annotations.")
+
+	return cobraCmd
+}
+
+type parityCmd struct {
+	renderCmd
+
+	// Flags
+	crossplaneBinary string
+	ignorePaths      []string
+}
+
+func (c *parityCmd) run(cmd *cobra.Command, args []string) error {
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+
+	in, err := c.loadInputs(args)
+	if err != nil {
+		return err
+	}
+
+	out, err := c.render(in)
+	if err != nil {
+		return errors.Wrap(err, "cannot render with crossbench")
+	}
+	b := &bytes.Buffer{}
+	if err := writeOutputs(b, out, false, false); err != nil {
+		return err
+	}
+	got, err := parseYAMLStream(b.Bytes())
+	if err != nil {
+		return errors.Wrap(err, "cannot parse crossbench output")
+	}
+
+	if v, err := exec.Command(c.crossplaneBinary, "version", "--client").Output(); err == nil { //nolint:gosec // The binary is chosen by the user.
+		infof("Comparing with crossplane render of %s", strings.TrimSpace(string(v)))
+	}
+	upstream, err := c.crossplaneRender(in)
+	if err != nil {
+		return err
+	}
+	want, err := parseYAMLStream(upstream)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse crossplane render output")
+	}
+
+	changes := DiffResources(got, want, DiffOptions{IgnorePaths: c.ignorePaths})
+	if printChanges(os.Stdout, changes) {
+		return errors.New("crossbench and crossplane render outputs differ")
+	}
+	return nil
+}
+
+// crossplaneRender runs crossplane render with the supplied inputs, and
+// returns its output.
+func (c *parityCmd) crossplaneRender(in render.Inputs) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "crossbench-parity-")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create directory for crossplane render inputs")
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	args, err := writeParityInputs(dir, in)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot write crossplane render inputs")
+	}
+	args = append(args, fmt.Sprintf("--timeout=%s", c.timeout))
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	r := exec.CommandContext(c.context(), c.crossplaneBinary, append([]string{"render"}, args...)...) //nolint:gosec // The binary is chosen by the user.
+	r.Stdout = stdout
+	r.Stderr = stderr
+	debugf("Running %s render %s", c.crossplaneBinary, strings.Join(args, " "))
+	if err := r.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.Errorf("crossplane render failed: %s", msg)
+		}
+		return nil, errors.Wrap(err, "crossplane render failed")
+	}
+	return stdout.Bytes(), nil
+}
+
+// writeParityInputs writes the supplied inputs to dir as YAML, and returns the
+// crossplane render arguments and flags that load them.
+func writeParityInputs(dir string, in render.Inputs) ([]string, error) {
+	write := func(name string, objs ...any) (string, error) {
+		b := &bytes.Buffer{}
+		for _, o := range objs {
+			y, err := yaml.Marshal(o)
+			if err != nil {
+				return "", err
+			}
+			_, _ = fmt.Fprintln(b, "---")
+			b.Write(y)
+		}
+		path := filepath.Join(dir, name)
+		return path, os.WriteFile(path, b.Bytes(), 0644)
+	}
+
+	comp := in.Composition.DeepCopy()
+	comp.SetGroupVersionKind(apiextensionsv1.CompositionGroupVersionKind)
+	fns := make([]any, len(in.Functions))
+	for i := range in.Functions {
+		fn := in.Functions[i].DeepCopy()
+		fn.SetGroupVersionKind(pkgv1.FunctionGroupVersionKind)
+		fns[i] = fn
+	}
+
+	args := make([]string, 0, 3)
+	for _, f := range []struct {
+		name string
+		objs []any
+	}{
+		{"xr.yaml", []any{in.CompositeResource.Object}},
+		{"composition.yaml", []any{comp}},
+		{"functions.yaml", fns},
+	} {
+		path, err := write(f.name, f.objs...)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, path)
+	}
+
+	if len(in.ObservedResources) > 0 {
+		ors := make([]any, len(in.ObservedResources))
+		for i := range in.ObservedResources {
+			ors[i] = in.ObservedResources[i].Object
+		}
+		path, err := write("observed-resources.yaml", ors...)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--observed-resources="+path)
+	}
+	if len(in.ExtraResources) > 0 {
+		ers := make([]any, len(in.ExtraResources))
+		for i := range in.ExtraResources {
+			ers[i] = in.ExtraResources[i].Object
+		}
+		path, err := write("extra-resources.yaml", ers...)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--extra-resources="+path)
+	}
+	if len(in.FunctionCredentials) > 0 {
+		creds := make([]any, len(in.FunctionCredentials))
+		for i := range in.FunctionCredentials {
+			s := in.FunctionCredentials[i].DeepCopy()
+			s.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+			creds[i] = s
+		}
+		path, err := write("function-credentials.yaml", creds...)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--function-credentials="+path)
+	}
+
+	// Context values are passed as files, since JSON values may hold the
+	// separators of --context-values.
+	keys := make([]string, 0, len(in.Context))
+	for k := range in.Context {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		path := filepath.Join(dir, fmt.Sprintf("context-%d.json", i))
+		if err := os.WriteFile(path, in.Context[k], 0644); err != nil {
+			return nil, err
+		}
+		args = append(args, fmt.Sprintf("--context-files=%s=%s", k, path))
+	}
+	return args, nil
+}
@@ -0,0 +1,848 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/v2/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+	fnv1beta1 "github.com/crossplane/crossplane/v2/proto/fn/v1beta1"
+)
+
+// This file is a port of crossplane render's Function pipeline. Crossplane's
+// implementation lives behind internal packages and can't be configured, so
+// crossbench runs the pipeline itself to control how Functions are called.
+
+// waitForReady makes RPCs wait for the Function to be ready, giving Docker
+// containers time to start. See https://grpc.io/docs/guides/wait-for-ready/
+const waitForReady = `{
+	"methodConfig":[{
+		"name": [{}],
+		"waitForReady": true
+	}]
+}`
+
+// largeRequestSize is the gRPC default max message size. Most Functions
+// reject larger requests.
+const largeRequestSize = 4 << 20
+
+// maxRequirementsIterations caps how many times a Function is called to
+// satisfy the resources it requires.
+const maxRequirementsIterations = 5
+
+// A FunctionRunner runs a Composition Function.
+type FunctionRunner interface {
+	RunFunction(ctx context.Context, name string, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error)
+}
+
+// pipelineOptions configure how the Function pipeline runs.
+type pipelineOptions struct {
+	// DialOptions are used to connect to every Function.
+	DialOptions []grpc.DialOption
+
+	// CallOptions are used for every call to a Function.
+	CallOptions []grpc.CallOption
+
+	// ParallelSteps runs consecutive pipeline steps the Composition marks as
+	// independent concurrently.
+	ParallelSteps bool
+
+	// Timeout the context of the pipeline was given, for reporting.
+	Timeout time.Duration
+
+	// Observer is told how long the phases of the render took, if set.
+	Observer pipelineObserver
+
+	// Chaos is a fault injected into every Function call, if set.
+	Chaos string
+
+	// DumpIO is a directory the request and response of every Function call
+	// are written to, if set.
+	DumpIO string
+
+	// Debugger pauses the pipeline after steps return, if set.
+	Debugger pipelineDebugger
+
+	// ResourceSteps, if set, is filled with the pipeline step that first
+	// desired each composed resource, by composition resource name.
+	ResourceSteps map[string]string
+
+	// Cluster, if set, is where the resources Functions require are fetched
+	// from when none of the supplied extra or required resources match.
+	Cluster *clusterClient
+}
+
+// A resourceFetcher fetches the resources matching a Function's requirement.
+type resourceFetcher interface {
+	Fetch(ctx context.Context, rs *fnv1.ResourceSelector) (*fnv1.Resources, error)
+}
+
+// A pipelineObserver is told how long the phases of a render took.
+type pipelineObserver interface {
+	// FunctionsStarted is called once every Function is started.
+	FunctionsStarted(d time.Duration)
+
+	// StepRan is called when a pipeline step returns, whether or not it
+	// succeeded. Steps that run concurrently call it concurrently.
+	StepRan(step apiextensionsv1.PipelineStep, d time.Duration)
+}
+
+// AnnotationKeyIndependentSteps lists, comma separated, the pipeline steps of
+// a Composition that don't consume each other's output. Consecutive listed
+// steps run concurrently with --parallel-steps.
+const AnnotationKeyIndependentSteps = "crossbench.io/independent-steps"
+
+// stopAfterStep returns a copy of the supplied Composition whose pipeline
+// ends with the supplied step, given by name or by its 1-based position.
+func stopAfterStep(comp *apiextensionsv1.Composition, step string) (*apiextensionsv1.Composition, error) {
+	steps := comp.Spec.Pipeline
+	n := slices.IndexFunc(steps, func(s apiextensionsv1.PipelineStep) bool { return s.Step == step }) + 1
+	if n == 0 {
+		i, err := strconv.Atoi(step)
+		if err != nil || i < 1 || i > len(steps) {
+			names := make([]string, len(steps))
+			for i, s := range steps {
+				names[i] = s.Step
+			}
+			return nil, errors.Errorf("composition %q has no pipeline step %q, must be one of %s or a number from 1 to %d", comp.GetName(), step, strings.Join(names, ", "), len(steps))
+		}
+		n = i
+	}
+	c := comp.DeepCopy()
+	c.Spec.Pipeline = c.Spec.Pipeline[:n]
+	return c, nil
+}
+
+// filterSteps returns a copy of the supplied Composition whose pipeline leaves
+// out the steps matching skip and, if only is set, the steps not matching only.
+// Steps are matched by name or glob. The desired state passes through the
+// steps left out unchanged.
+func filterSteps(comp *apiextensionsv1.Composition, skip, only []string) (*apiextensionsv1.Composition, error) {
+	names := make([]string, len(comp.Spec.Pipeline))
+	for i, s := range comp.Spec.Pipeline {
+		names[i] = s.Step
+	}
+	match := func(flag string, patterns []string) (map[string]bool, error) {
+		matched := map[string]bool{}
+		for _, p := range patterns {
+			found := false
+			for _, n := range names {
+				ok, err := path.Match(p, n)
+				if err != nil {
+					return nil, errors.Wrapf(err, "invalid %s pattern %q", flag, p)
+				}
+				if ok {
+					matched[n] = true
+					found = true
+				}
+			}
+			if !found {
+				return nil, errors.Errorf("%s %q matches no pipeline step of composition %q, must match one of %s", flag, p, comp.GetName(), strings.Join(names, ", "))
+			}
+		}
+		return matched, nil
+	}
+	skipped, err := match("--skip-step", skip)
+	if err != nil {
+		return nil, err
+	}
+	kept, err := match("--only-step", only)
+	if err != nil {
+		return nil, err
+	}
+
+	c := comp.DeepCopy()
+	c.Spec.Pipeline = nil
+	left := []string{}
+	for _, s := range comp.Spec.Pipeline {
+		if skipped[s.Step] || (len(only) > 0 && !kept[s.Step]) {
+			left = append(left, s.Step)
+			continue
+		}
+		c.Spec.Pipeline = append(c.Spec.Pipeline, *s.DeepCopy())
+	}
+	if len(c.Spec.Pipeline) == 0 {
+		return nil, errors.Errorf("every pipeline step of composition %q is skipped", comp.GetName())
+	}
+	if len(left) > 0 {
+		infof("Skipping pipeline step(s) %s, passing the desired state through", strings.Join(left, ", "))
+	}
+	return c, nil
+}
+
+// pipelineFunctions returns the supplied Functions that a pipeline step of the
+// supplied Composition references.
+func pipelineFunctions(comp *apiextensionsv1.Composition, fns []pkgv1.Function) []pkgv1.Function {
+	used := map[string]bool{}
+	for _, s := range comp.Spec.Pipeline {
+		used[s.FunctionRef.Name] = true
+	}
+	out := make([]pkgv1.Function, 0, len(fns))
+	for _, fn := range fns {
+		if used[fn.GetName()] {
+			out = append(out, fn)
+		}
+	}
+	return out
+}
+
+// independentSteps returns the pipeline steps the supplied Composition marks
+// as independent.
+func independentSteps(comp *apiextensionsv1.Composition) map[string]bool {
+	steps := map[string]bool{}
+	for _, s := range strings.Split(comp.GetAnnotations()[AnnotationKeyIndependentSteps], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			steps[s] = true
+		}
+	}
+	return steps
+}
+
+// A runtimeFunctionRunner runs Functions locally, using the runtime
+// configured in their annotations (e.g. Docker).
+type runtimeFunctionRunner struct {
+	contexts map[string]render.RuntimeContext
+	conns    map[string]*grpc.ClientConn
+	opts     []grpc.CallOption
+	mx       sync.Mutex
+}
+
+// newRuntimeFunctionRunner starts the supplied Functions and connects to them.
+func newRuntimeFunctionRunner(ctx context.Context, log logging.Logger, fns []pkgv1.Function, opts pipelineOptions) (*runtimeFunctionRunner, error) {
+	r := &runtimeFunctionRunner{
+		contexts: map[string]render.RuntimeContext{},
+		conns:    map[string]*grpc.ClientConn{},
+		opts:     opts.CallOptions,
+	}
+
+	dial := append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(waitForReady),
+	}, opts.DialOptions...)
+
+	for _, fn := range fns {
+		rt, err := render.GetRuntime(fn, log)
+		if err != nil {
+			return r, errors.Wrapf(err, "cannot get runtime for Function %q", fn.GetName())
+		}
+		// Starting a Function includes pulling its image, if needed.
+		sctx, span := tracer.Start(ctx, "start function", trace.WithAttributes(
+			attribute.String("crossbench.function", fn.GetName()),
+			attribute.String("crossbench.package", fn.Spec.Package),
+			attribute.String("crossbench.runtime", fn.GetAnnotations()[render.AnnotationKeyRuntime]),
+		))
+		rctx, err := rt.Start(sctx)
+		recordSpanError(span, err)
+		span.End()
+		if err != nil {
+			return r, errors.Wrapf(err, "cannot start Function %q", fn.GetName())
+		}
+		r.contexts[fn.GetName()] = rctx
+
+		conn, err := grpc.NewClient(rctx.Target, dial...)
+		if err != nil {
+			return r, errors.Wrapf(err, "cannot dial Function %q at address %q", fn.GetName(), rctx.Target)
+		}
+		r.conns[fn.GetName()] = conn
+	}
+	return r, nil
+}
+
+// RunFunction runs the named Function. It falls back to the v1beta1 API if
+// the Function doesn't implement v1.
+func (r *runtimeFunctionRunner) RunFunction(ctx context.Context, name string, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	// Only guard the lookup; parallel steps call Functions concurrently.
+	r.mx.Lock()
+	conn, ok := r.conns[name]
+	r.mx.Unlock()
+	if !ok {
+		return nil, errors.Errorf("unknown Function %q - does it exist in your Functions file?", name)
+	}
+
+	rsp, err := fnv1.NewFunctionRunnerServiceClient(conn).RunFunction(ctx, req, r.opts...)
+	if status.Code(err) != codes.Unimplemented {
+		return rsp, err
+	}
+
+	// The v1 and v1beta1 messages are identical on the wire.
+	breq := &fnv1beta1.RunFunctionRequest{}
+	if err := convertProto(req, breq); err != nil {
+		return nil, err
+	}
+	brsp, err := fnv1beta1.NewFunctionRunnerServiceClient(conn).RunFunction(ctx, breq, r.opts...)
+	if err != nil {
+		return nil, err
+	}
+	rsp = &fnv1.RunFunctionResponse{}
+	return rsp, convertProto(brsp, rsp)
+}
+
+// Stop the runner's runtimes, and close its connections.
+func (r *runtimeFunctionRunner) Stop(ctx context.Context) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	for name, conn := range r.conns {
+		_ = conn.Close()
+		delete(r.conns, name)
+	}
+	for name, rctx := range r.contexts {
+		if err := rctx.Stop(ctx); err != nil {
+			return errors.Wrapf(err, "cannot stop function %q runtime (target %q)", name, rctx.Target)
+		}
+		delete(r.contexts, name)
+	}
+	return nil
+}
+
+// convertProto converts between identical protobuf messages of different
+// API versions.
+func convertProto(from, to proto.Message) error {
+	b, err := proto.Marshal(from)
+	if err != nil {
+		return errors.Wrapf(err, "cannot marshal %T to protobuf bytes", from)
+	}
+	return errors.Wrapf(proto.Unmarshal(b, to), "cannot unmarshal %T protobuf bytes into %T", from, to)
+}
+
+// A fetchingFunctionRunner runs a Function repeatedly, fetching the resources
+// it requires, until its requirements stabilize.
+type fetchingFunctionRunner struct {
+	wrapped   FunctionRunner
+	resources resourceFetcher
+}
+
+// RunFunction runs the named Function, fetching any resources it requires.
+func (c *fetchingFunctionRunner) RunFunction(ctx context.Context, name string, req *fnv1.RunFunctionRequest) (*fnv1.RunFunctionResponse, error) {
+	var requirements *fnv1.Requirements
+	bootstrap := maps.Clone(req.GetRequiredResources())
+
+	for i := 0; i <= maxRequirementsIterations; i++ {
+		rsp, err := c.wrapped.RunFunction(ctx, name, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, rs := range rsp.GetResults() {
+			if rs.GetSeverity() == fnv1.Severity_SEVERITY_FATAL {
+				return rsp, nil
+			}
+		}
+
+		newRequirements := rsp.GetRequirements()
+		if proto.Equal(newRequirements, requirements) {
+			return rsp, nil
+		}
+		requirements = newRequirements
+
+		req.ExtraResources = map[string]*fnv1.Resources{} //nolint:staticcheck // Functions may still use the deprecated field.
+		req.RequiredResources = maps.Clone(bootstrap)
+		if req.RequiredResources == nil {
+			req.RequiredResources = map[string]*fnv1.Resources{}
+		}
+		for n, sel := range newRequirements.GetExtraResources() { //nolint:staticcheck // Functions may still use the deprecated field.
+			rs, err := c.resources.Fetch(ctx, sel)
+			if err != nil {
+				return nil, errors.Wrapf(err, "fetching resources for %s", n)
+			}
+			req.ExtraResources[n] = rs //nolint:staticcheck // Functions may still use the deprecated field.
+		}
+		for n, sel := range newRequirements.GetResources() {
+			rs, err := c.resources.Fetch(ctx, sel)
+			if err != nil {
+				return nil, errors.Wrapf(err, "fetching resources for %s", n)
+			}
+			req.RequiredResources[n] = rs
+		}
+		req.Context = rsp.GetContext()
+	}
+	return nil, errors.Errorf("requirements didn't stabilize after the maximum number of iterations (%d)", maxRequirementsIterations)
+}
+
+// renderPipeline renders the desired XR and composed resources, sorted by
+// resource name, by running the Composition's Function pipeline. It behaves
+// like crossplane render's Render.
+func renderPipeline(ctx context.Context, log logging.Logger, in render.Inputs, opts pipelineOptions) (render.Outputs, error) { //nolint:gocognit // Mirrors crossplane render.
+	started := time.Now()
+	runtimes, err := newRuntimeFunctionRunner(ctx, log, in.Functions, opts)
+	defer func() {
+		// Don't use the main context, it may have been cancelled by now.
+		stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := runtimes.Stop(stopCtx); err != nil {
+			log.Info("Error stopping function runtimes", "error", err)
+		}
+	}()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return render.Outputs{}, &pipelineTimeoutError{Report: newTimeoutReport(in.Composition, opts.Timeout, nil, nil)}
+		}
+		return render.Outputs{}, errors.Wrap(err, "cannot start function runtimes")
+	}
+	if opts.Observer != nil {
+		opts.Observer.FunctionsStarted(time.Since(started))
+	}
+
+	var fetcher, required resourceFetcher = render.NewFilteringFetcher(append(in.ExtraResources, in.RequiredResources...)...), render.NewFilteringFetcher(in.RequiredResources...)
+	if opts.Cluster != nil {
+		fetcher = newClusterFetcher(fetcher, opts.Cluster)
+		required = newClusterFetcher(required, opts.Cluster)
+	}
+	var functions FunctionRunner = runtimes
+	if opts.Chaos != "" {
+		functions = &chaosFunctionRunner{wrapped: runtimes, fault: opts.Chaos}
+	}
+	if opts.DumpIO != "" {
+		if functions, err = newDumpingFunctionRunner(functions, opts.DumpIO); err != nil {
+			return render.Outputs{}, err
+		}
+	}
+	runner := &fetchingFunctionRunner{wrapped: functions, resources: fetcher}
+
+	observed := map[string]*composed.Unstructured{}
+	observedState := &fnv1.State{Resources: map[string]*fnv1.Resource{}}
+	xrs, err := structpb.NewStruct(in.CompositeResource.Object)
+	if err != nil {
+		return render.Outputs{}, errors.Wrap(err, "cannot build observed composite and composed resources for RunFunctionRequest")
+	}
+	observedState.Composite = &fnv1.Resource{Resource: xrs}
+	for i, cd := range in.ObservedResources {
+		name := cd.GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+		observed[name] = &in.ObservedResources[i]
+		s, err := structpb.NewStruct(cd.Object)
+		if err != nil {
+			return render.Outputs{}, errors.Wrap(err, "cannot build observed composite and composed resources for RunFunctionRequest")
+		}
+		observedState.Resources[name] = &fnv1.Resource{Resource: s}
+	}
+
+	d := &fnv1.State{}
+	results := make([]unstructured.Unstructured, 0)
+	conditions := make([]xpv1.Condition, 0)
+	requirements := make(map[string]fnv1.Requirements)
+
+	fctx := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	for k, data := range in.Context {
+		var jv any
+		if err := json.Unmarshal(data, &jv); err != nil {
+			return render.Outputs{}, errors.Wrapf(err, "cannot unmarshal JSON value for context key %q", k)
+		}
+		v, err := structpb.NewValue(jv)
+		if err != nil {
+			return render.Outputs{}, errors.Wrapf(err, "cannot store JSON value for context key %q", k)
+		}
+		fctx.Fields[k] = v
+	}
+
+	// Group consecutive independent steps. Every step of a group gets the same
+	// desired state and context, and their responses are merged.
+	steps := in.Composition.Spec.Pipeline
+	stepIndex := make(map[string]int, len(steps))
+	for i, s := range steps {
+		stepIndex[s.Step] = i
+	}
+	independent := independentSteps(in.Composition)
+	groups := [][]apiextensionsv1.PipelineStep{}
+	for i := 0; i < len(steps); {
+		j := i + 1
+		if opts.ParallelSteps && independent[steps[i].Step] {
+			for j < len(steps) && independent[steps[j].Step] {
+				j++
+			}
+		}
+		groups = append(groups, steps[i:j])
+		i = j
+	}
+
+	// runGroup runs the steps of a group, given the desired state and context
+	// returned by the previous group.
+	runGroup := func(group []apiextensionsv1.PipelineStep) ([]*fnv1.RunFunctionResponse, []error, error) {
+		rsps := make([]*fnv1.RunFunctionResponse, len(group))
+		errs := make([]error, len(group))
+		var wg sync.WaitGroup
+		for i, fn := range group {
+			req, err := stepRequest(ctx, log, fn, in, required, observedState, d, fctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sctx, span := tracer.Start(withPipelineStep(ctx, stepIndex[fn.Step], fn.Step), "run function", trace.WithAttributes(
+					attribute.String("crossbench.step", fn.Step),
+					attribute.String("crossbench.function", fn.FunctionRef.Name),
+				))
+				log.Debug("Running pipeline step", "step", fn.Step, "function", fn.FunctionRef.Name)
+				start := time.Now()
+				rsps[i], errs[i] = runner.RunFunction(sctx, fn.FunctionRef.Name, req)
+				recordSpanError(span, errs[i])
+				span.End()
+				functionCallDuration.WithLabelValues(fn.FunctionRef.Name).Observe(time.Since(start).Seconds())
+				if opts.Observer != nil {
+					opts.Observer.StepRan(fn, time.Since(start))
+				}
+			}()
+		}
+		wg.Wait()
+		return rsps, errs, nil
+	}
+
+	completed := []string{}
+	for _, group := range groups {
+		rsps, errs, err := runGroup(group)
+		if err != nil {
+			return render.Outputs{}, err
+		}
+
+		if ctx.Err() == context.DeadlineExceeded {
+			// Return what the completed steps rendered, along with the
+			// steps in progress.
+			te := &pipelineTimeoutError{Report: newTimeoutReport(in.Composition, opts.Timeout, completed, group)}
+			if len(completed) > 0 {
+				if te.Outputs, err = pipelineOutputs(in, observed, d, fctx, results, conditions, requirements); err != nil {
+					return render.Outputs{}, err
+				}
+			}
+			return render.Outputs{}, te
+		}
+
+		for opts.Debugger != nil {
+			rerun, err := opts.Debugger.StepsReturned(group, rsps, errs)
+			if err != nil {
+				return render.Outputs{}, err
+			}
+			if !rerun {
+				break
+			}
+			if rsps, errs, err = runGroup(group); err != nil {
+				return render.Outputs{}, err
+			}
+		}
+
+		for i, fn := range group {
+			if errs[i] != nil {
+				return render.Outputs{}, errors.Wrapf(errs[i], "cannot run pipeline step %q", fn.Step)
+			}
+			rsp := rsps[i]
+			log.Debug("Pipeline step returned", "step", fn.Step, "desired-resources", len(rsp.GetDesired().GetResources()), "results", len(rsp.GetResults()), "conditions", len(rsp.GetConditions()))
+			if opts.ResourceSteps != nil {
+				for name := range rsp.GetDesired().GetResources() {
+					if _, ok := opts.ResourceSteps[name]; !ok {
+						opts.ResourceSteps[name] = fn.Step
+					}
+				}
+			}
+
+			for _, c := range rsp.GetConditions() {
+				var st corev1.ConditionStatus
+				switch c.GetStatus() {
+				case fnv1.Status_STATUS_CONDITION_TRUE:
+					st = corev1.ConditionTrue
+				case fnv1.Status_STATUS_CONDITION_FALSE:
+					st = corev1.ConditionFalse
+				case fnv1.Status_STATUS_CONDITION_UNKNOWN, fnv1.Status_STATUS_CONDITION_UNSPECIFIED:
+					st = corev1.ConditionUnknown
+				}
+				conditions = append(conditions, xpv1.Condition{
+					Type:               xpv1.ConditionType(c.GetType()),
+					Status:             st,
+					LastTransitionTime: conditionTime(),
+					Reason:             xpv1.ConditionReason(c.GetReason()),
+					Message:            c.GetMessage(),
+				})
+			}
+
+			if rsp.GetRequirements() != nil {
+				requirements[fn.Step] = *rsp.GetRequirements() //nolint:govet // Copied like crossplane render does.
+			}
+
+			for _, rs := range rsp.GetResults() {
+				if rs.GetSeverity() == fnv1.Severity_SEVERITY_FATAL {
+					return render.Outputs{Requirements: requirements}, &fatalResultError{Step: fn.Step, Message: rs.GetMessage()}
+				}
+				results = append(results, unstructured.Unstructured{Object: map[string]any{
+					"apiVersion": "render.crossplane.io/v1beta1",
+					"kind":       "Result",
+					"step":       fn.Step,
+					"severity":   rs.GetSeverity().String(),
+					"message":    rs.GetMessage(),
+				}})
+			}
+		}
+
+		for _, fn := range group {
+			completed = append(completed, fn.Step)
+		}
+		if len(group) == 1 {
+			d = rsps[0].GetDesired()
+			fctx = rsps[0].GetContext()
+			continue
+		}
+		if d, fctx, err = mergeResponses(group, d, fctx, rsps); err != nil {
+			return render.Outputs{}, err
+		}
+	}
+
+	return pipelineOutputs(in, observed, d, fctx, results, conditions, requirements)
+}
+
+// pipelineOutputs builds the outputs of a render from the desired state and
+// context returned by the last pipeline step, and the results, conditions
+// and requirements of every step.
+func pipelineOutputs(in render.Inputs, observed map[string]*composed.Unstructured, d *fnv1.State, fctx *structpb.Struct, results []unstructured.Unstructured, conditions []xpv1.Condition, requirements map[string]fnv1.Requirements) (render.Outputs, error) {
+	desired := make([]composed.Unstructured, 0, len(d.GetResources()))
+	var unready []string
+	for name, dr := range d.GetResources() {
+		if dr.GetReady() != fnv1.Ready_READY_TRUE {
+			unready = append(unready, name)
+		}
+
+		cd := composed.New()
+		cd.Object = dr.GetResource().AsMap()
+
+		// Keep the name and namespace of existing composed resources.
+		if or, ok := observed[name]; ok {
+			cd.SetNamespace(or.GetNamespace())
+			cd.SetName(or.GetName())
+			cd.SetGenerateName(or.GetGenerateName())
+		}
+
+		if err := render.SetComposedResourceMetadata(cd, in.CompositeResource, name); err != nil {
+			return render.Outputs{}, errors.Wrapf(err, "cannot render composed resource %q metadata", name)
+		}
+		desired = append(desired, *cd)
+	}
+	sort.Slice(desired, func(i, j int) bool {
+		return desired[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName] < desired[j].GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+	})
+
+	xr := composite.New()
+	xr.Object = d.GetComposite().GetResource().AsMap()
+	xr.SetAPIVersion(in.CompositeResource.GetAPIVersion())
+	xr.SetKind(in.CompositeResource.GetKind())
+	xr.SetName(in.CompositeResource.GetName())
+	xr.SetNamespace(in.CompositeResource.GetNamespace())
+
+	xrCond := xpv1.Available()
+	if d.GetComposite().GetReady() == fnv1.Ready_READY_FALSE {
+		xrCond = xpv1.Creating()
+	} else if d.GetComposite().GetReady() == fnv1.Ready_READY_UNSPECIFIED && len(unready) > 0 {
+		xrCond = xpv1.Creating().WithMessage(fmt.Sprintf("Unready resources: %s", resource.StableNAndSomeMore(resource.DefaultFirstN, unready)))
+	}
+	xrCond.LastTransitionTime = conditionTime()
+	xr.SetConditions(xrCond)
+	for _, c := range conditions {
+		if xpv1.IsSystemConditionType(c.Type) {
+			continue
+		}
+		xr.SetConditions(c)
+	}
+
+	out := render.Outputs{CompositeResource: xr, ComposedResources: desired, Results: results, Requirements: requirements}
+	if fctx != nil {
+		out.Context = &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "render.crossplane.io/v1beta1",
+			"kind":       "Context",
+			"fields":     fctx.GetFields(),
+		}}
+	}
+	return out, nil
+}
+
+// stepRequest builds the request for the supplied pipeline step, given the
+// desired state and context returned by the previous step. The resources the
+// step requires up front are fetched from the supplied fetcher.
+func stepRequest(ctx context.Context, log logging.Logger, fn apiextensionsv1.PipelineStep, in render.Inputs, required resourceFetcher, observed, d *fnv1.State, fctx *structpb.Struct) (*fnv1.RunFunctionRequest, error) {
+	req := &fnv1.RunFunctionRequest{Observed: observed, Desired: d, Context: fctx}
+
+	if fn.Input != nil {
+		input := &structpb.Struct{}
+		if err := input.UnmarshalJSON(fn.Input.Raw); err != nil {
+			return nil, errors.Wrapf(err, "cannot unmarshal input for Composition pipeline step %q", fn.Step)
+		}
+		req.Input = input
+	}
+
+	req.Credentials = map[string]*fnv1.Credentials{}
+	for _, cs := range fn.Credentials {
+		if cs.Source != apiextensionsv1.FunctionCredentialsSourceSecret || cs.SecretRef == nil {
+			continue
+		}
+		s, err := render.GetSecret(cs.SecretRef.Name, cs.SecretRef.Namespace, in.FunctionCredentials)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get credentials from secret %q", cs.SecretRef.Name)
+		}
+		req.Credentials[cs.Name] = &fnv1.Credentials{
+			Source: &fnv1.Credentials_CredentialData{CredentialData: &fnv1.CredentialData{Data: s.Data}},
+		}
+	}
+
+	if fn.Requirements != nil {
+		req.RequiredResources = map[string]*fnv1.Resources{}
+		for _, sel := range fn.Requirements.RequiredResources {
+			rs, err := required.Fetch(ctx, toResourceSelector(sel))
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot fetch bootstrap required resources for requirement %q", sel.RequirementName)
+			}
+			req.RequiredResources[sel.RequirementName] = rs
+		}
+	}
+
+	// The Function API only has a unary RPC, so state can't be streamed or
+	// chunked. Flag requests large enough to hit Functions' default limit.
+	if n := proto.Size(req); n > largeRequestSize {
+		log.Info("Large RunFunctionRequest", "step", fn.Step, "bytes", n)
+		warnf("Request to pipeline step %q is %.1fMiB; functions reject requests over their max receive message size (usually 4MiB), so the function's limit may need raising", fn.Step, float64(n)/(1<<20))
+	}
+
+	return req, nil
+}
+
+// mergeResponses merges the desired state and context returned by steps that
+// ran concurrently from the same desired state d and context fctx. Changes to
+// the composite resource and context are merged in step order, so later steps
+// win. Composed resources added, changed or deleted by a step are applied, but
+// two steps changing the same composed resource differently is an error: the
+// steps aren't independent.
+func mergeResponses(steps []apiextensionsv1.PipelineStep, d *fnv1.State, fctx *structpb.Struct, rsps []*fnv1.RunFunctionResponse) (*fnv1.State, *structpb.Struct, error) {
+	merged := proto.Clone(d).(*fnv1.State) //nolint:forcetypeassert // Clone returns the same type.
+	if merged.Resources == nil {
+		merged.Resources = map[string]*fnv1.Resource{}
+	}
+	mctx := proto.Clone(fctx).(*structpb.Struct) //nolint:forcetypeassert // Clone returns the same type.
+	if mctx == nil || mctx.Fields == nil {
+		mctx = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	}
+	changedBy := map[string]int{}
+
+	for i, rsp := range rsps {
+		rd := rsp.GetDesired()
+
+		if !proto.Equal(rd.GetComposite(), d.GetComposite()) {
+			if merged.GetComposite() == nil || rd.GetComposite() == nil {
+				merged.Composite = rd.GetComposite()
+			} else {
+				xrm := merged.GetComposite().GetResource().AsMap()
+				mergeChanges(xrm, d.GetComposite().GetResource().AsMap(), rd.GetComposite().GetResource().AsMap())
+				xr, err := structpb.NewStruct(xrm)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "cannot merge desired composite resource of pipeline step %q", steps[i].Step)
+				}
+				merged.Composite = &fnv1.Resource{Resource: xr, ConnectionDetails: maps.Clone(merged.GetComposite().GetConnectionDetails()), Ready: rd.GetComposite().GetReady()}
+				maps.Copy(merged.Composite.ConnectionDetails, rd.GetComposite().GetConnectionDetails())
+			}
+		}
+
+		names := map[string]bool{}
+		for name := range d.GetResources() {
+			names[name] = true
+		}
+		for name := range rd.GetResources() {
+			names[name] = true
+		}
+		for name := range names {
+			r := rd.GetResources()[name]
+			if proto.Equal(r, d.GetResources()[name]) {
+				continue
+			}
+			if j, ok := changedBy[name]; ok && !proto.Equal(r, merged.GetResources()[name]) {
+				return nil, nil, errors.Errorf("pipeline steps %q and %q both change desired composed resource %q, they can't run in parallel", steps[j].Step, steps[i].Step, name)
+			}
+			changedBy[name] = i
+			if r == nil {
+				delete(merged.Resources, name)
+				continue
+			}
+			merged.Resources[name] = r
+		}
+
+		for k, v := range rsp.GetContext().GetFields() {
+			if !proto.Equal(v, fctx.GetFields()[k]) {
+				mctx.Fields[k] = v
+			}
+		}
+		for k := range fctx.GetFields() {
+			if _, ok := rsp.GetContext().GetFields()[k]; !ok {
+				delete(mctx.Fields, k)
+			}
+		}
+	}
+	return merged, mctx, nil
+}
+
+// mergeChanges applies the fields of src that differ from base to dst, and
+// deletes the fields of base that src deleted. Nested objects are merged field
+// by field.
+func mergeChanges(dst, base, src map[string]any) {
+	for k := range base {
+		if _, ok := src[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range src {
+		if reflect.DeepEqual(v, base[k]) {
+			continue
+		}
+		sm, sok := v.(map[string]any)
+		bm, _ := base[k].(map[string]any)
+		dm, dok := dst[k].(map[string]any)
+		if sok && dok {
+			mergeChanges(dm, bm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// toResourceSelector converts a Composition's required resource selector to
+// its protobuf equivalent.
+func toResourceSelector(sel apiextensionsv1.RequiredResourceSelector) *fnv1.ResourceSelector {
+	rs := &fnv1.ResourceSelector{
+		ApiVersion: sel.APIVersion,
+		Kind:       sel.Kind,
+		Namespace:  sel.Namespace,
+	}
+	switch {
+	case sel.Name != nil:
+		rs.Match = &fnv1.ResourceSelector_MatchName{MatchName: *sel.Name}
+	case len(sel.MatchLabels) > 0:
+		rs.Match = &fnv1.ResourceSelector_MatchLabels{MatchLabels: &fnv1.MatchLabels{Labels: sel.MatchLabels}}
+	}
+	return rs
+}
+
+// conditionTime is the fixed transition time of rendered conditions, which
+// would otherwise be noise.
+func conditionTime() metav1.Time {
+	return metav1.NewTime(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+func TestMergeResponses(t *testing.T) {
+	state := func(t *testing.T, spec map[string]any) *fnv1.State {
+		t.Helper()
+		xr, err := structpb.NewStruct(map[string]any{"apiVersion": "example.org/v1", "kind": "XBucket", "spec": spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &fnv1.State{Composite: &fnv1.Resource{Resource: xr}}
+	}
+	steps := []apiextensionsv1.PipelineStep{{Step: "labels"}, {Step: "region"}}
+	base := map[string]any{
+		"region": "us-east-2",
+		"acl":    "private",
+		"tags":   map[string]any{"team": "a", "env": "dev"},
+	}
+
+	cases := map[string]struct {
+		reason string
+		specs  []map[string]any
+		want   map[string]any
+	}{
+		"Changes": {
+			reason: "Fields changed by each step should be merged.",
+			specs: []map[string]any{
+				{"region": "us-east-2", "acl": "private", "tags": map[string]any{"team": "b", "env": "dev"}},
+				{"region": "eu-west-1", "acl": "private", "tags": map[string]any{"team": "a", "env": "dev"}},
+			},
+			want: map[string]any{"region": "eu-west-1", "acl": "private", "tags": map[string]any{"team": "b", "env": "dev"}},
+		},
+		"Deletions": {
+			reason: "Fields deleted by a step should be deleted, including nested ones.",
+			specs: []map[string]any{
+				{"region": "us-east-2", "tags": map[string]any{"team": "a", "env": "dev"}},
+				{"region": "eu-west-1", "acl": "private", "tags": map[string]any{"team": "a"}},
+			},
+			want: map[string]any{"region": "eu-west-1", "tags": map[string]any{"team": "a"}},
+		},
+		"DeletedObject": {
+			reason: "An object deleted by a step should be deleted.",
+			specs: []map[string]any{
+				{"region": "us-east-2", "acl": "private"},
+				{"region": "us-east-2", "acl": "private", "tags": map[string]any{"team": "a", "env": "dev"}},
+			},
+			want: map[string]any{"region": "us-east-2", "acl": "private"},
+		},
+		"LaterStepWins": {
+			reason: "A field deleted by one step and changed by a later one should have the later step's value.",
+			specs: []map[string]any{
+				{"region": "us-east-2", "tags": map[string]any{"team": "a", "env": "dev"}},
+				{"region": "us-east-2", "acl": "public-read", "tags": map[string]any{"team": "a", "env": "dev"}},
+			},
+			want: map[string]any{"region": "us-east-2", "acl": "public-read", "tags": map[string]any{"team": "a", "env": "dev"}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			rsps := make([]*fnv1.RunFunctionResponse, len(tc.specs))
+			for i, spec := range tc.specs {
+				rsps[i] = &fnv1.RunFunctionResponse{Desired: state(t, spec)}
+			}
+			merged, _, err := mergeResponses(steps, state(t, base), nil, rsps)
+			if err != nil {
+				t.Fatalf("\n%s\nmergeResponses(...): %v", tc.reason, err)
+			}
+			if diff := cmp.Diff(tc.want, merged.GetComposite().GetResource().AsMap()["spec"]); diff != "" {
+				t.Errorf("\n%s\nmergeResponses(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+)
+
+// kubectlPluginBinary is the name of the binary kubectl runs for
+// kubectl crossbench.
+const kubectlPluginBinary = "kubectl-crossbench"
+
+// kubectlFlags are the kubectl flags honored when crossbench runs as a
+// kubectl plugin. They're nil otherwise.
+var kubectlFlags *genericclioptions.ConfigFlags
+
+// IsKubectlPlugin returns whether the supplied program name, i.e. os.Args[0],
+// is the kubectl plugin binary, meaning crossbench was invoked as
+// kubectl crossbench.
+func IsKubectlPlugin(arg0 string) bool {
+	name := strings.TrimSuffix(filepath.Base(arg0), ".exe")
+	return name == kubectlPluginBinary
+}
+
+// ConfigureKubectlPlugin makes the supplied root command behave as the
+// kubectl crossbench plugin. It's shown as kubectl crossbench, and every
+// command that connects to a cluster also accepts the --context and
+// --namespace flags of kubectl, which --kube-context and --namespace flags of
+// its own take precedence over.
+func ConfigureKubectlPlugin(root *cobra.Command) {
+	kubectlFlags = &genericclioptions.ConfigFlags{
+		Context:   new(string),
+		Namespace: new(string),
+	}
+	fs := pflag.NewFlagSet("kubectl", pflag.ContinueOnError)
+	kubectlFlags.AddFlags(fs)
+
+	if root.Annotations == nil {
+		root.Annotations = map[string]string{}
+	}
+	root.Annotations[cobra.CommandDisplayNameAnnotation] = "kubectl crossbench"
+
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		if c.Flags().Lookup("kube-context") != nil {
+			fs.VisitAll(func(f *pflag.Flag) {
+				if c.Flags().Lookup(f.Name) != nil {
+					return
+				}
+				if f.Shorthand != "" && c.Flags().ShorthandLookup(f.Shorthand) != nil {
+					// Keep the flag, but not a shorthand the command uses.
+					nf := *f
+					nf.Shorthand = ""
+					c.Flags().AddFlag(&nf)
+					return
+				}
+				c.Flags().AddFlag(f)
+			})
+		}
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
+
+// kubectlContext returns the kubeconfig context set by the --context flag of
+// the kubectl plugin, if any.
+func kubectlContext() string {
+	if kubectlFlags == nil || kubectlFlags.Context == nil {
+		return ""
+	}
+	return *kubectlFlags.Context
+}
+
+// kubectlNamespace returns the namespace set by the --namespace flag of the
+// kubectl plugin, if any.
+func kubectlNamespace() string {
+	if kubectlFlags == nil || kubectlFlags.Namespace == nil {
+		return ""
+	}
+	return *kubectlFlags.Namespace
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// ContainerRuntimeKubernetes runs functions as pods in a cluster, for
+// machines that can't run containers but can reach a cluster.
+const ContainerRuntimeKubernetes = "kubernetes"
+
+// functionPort is the port functions serve gRPC on.
+const functionPort = 9443
+
+// AnnotationKeyFunction annotates the pods functions are run in with the
+// function's name.
+const AnnotationKeyFunction = "crossbench.io/function"
+
+// podRuntime runs functions as pods in a cluster, and connects to them over a
+// port-forward.
+type podRuntime struct {
+	kubecontext      string
+	namespace        string
+	serviceAccount   string
+	imagePullSecrets []string
+}
+
+// addPodRuntimeFlags registers the flags of the kubernetes runtime.
+func (r *podRuntime) addPodRuntimeFlags(cobraCmd *cobra.Command) {
+	cobraCmd.Flags().StringVar(&r.kubecontext, "pod-kube-context", "", "With --runtime kubernetes, the kubeconfig context of the cluster to run function pods in. Defaults to the current context.")
+	cobraCmd.Flags().StringVar(&r.namespace, "pod-namespace", "default", "With --runtime kubernetes, the namespace to run function pods in.")
+	cobraCmd.Flags().StringVar(&r.serviceAccount, "pod-service-account", "", "With --runtime kubernetes, the service account function pods run as.")
+	cobraCmd.Flags().StringSliceVar(&r.imagePullSecrets, "pod-image-pull-secrets", nil, "With --runtime kubernetes, comma-separated secrets used to pull function images.")
+}
+
+// startFunctionPods runs the Functions that would be run in Docker as pods,
+// and points the Functions at port-forwards to them. The returned function
+// stops the port-forwards and deletes the pods.
+func (r *podRuntime) startFunctionPods(ctx context.Context, fns []pkgv1.Function) (func(), error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: r.kubecontext}).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load kubeconfig")
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create Kubernetes client")
+	}
+
+	pods := []string{}
+	forwards := []chan struct{}{}
+	stop := func() {
+		for _, f := range forwards {
+			close(f)
+		}
+		// The render context may have expired, so clean up with a new one.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, p := range pods {
+			if err := client.CoreV1().Pods(r.namespace).Delete(ctx, p, metav1.DeleteOptions{}); err != nil {
+				warnf("Cannot delete function pod %s/%s: %v", r.namespace, p, err)
+			}
+		}
+	}
+
+	for i := range fns {
+		if fns[i].GetAnnotations()[render.AnnotationKeyRuntime] == string(render.AnnotationValueRuntimeDevelopment) {
+			continue
+		}
+		name := fns[i].GetName()
+		pod, err := client.CoreV1().Pods(r.namespace).Create(ctx, r.functionPod(fns[i]), metav1.CreateOptions{})
+		if err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "cannot create pod for function %q", name)
+		}
+		pods = append(pods, pod.GetName())
+		infof("Running function %q in pod %s/%s", name, r.namespace, pod.GetName())
+
+		if err := waitForPod(ctx, client, r.namespace, pod.GetName()); err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "function %q didn't start", name)
+		}
+
+		addr, err := freeLocalAddress()
+		if err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "cannot find a free port for function %q", name)
+		}
+		done := make(chan struct{})
+		if err := forwardPort(ctx, cfg, client, r.namespace, pod.GetName(), addr, done); err != nil {
+			close(done)
+			stop()
+			return nil, errors.Wrapf(err, "cannot forward a port to function %q", name)
+		}
+		forwards = append(forwards, done)
+
+		meta.AddAnnotations(&fns[i], map[string]string{
+			render.AnnotationKeyRuntime:                  string(render.AnnotationValueRuntimeDevelopment),
+			render.AnnotationKeyRuntimeDevelopmentTarget: "dns:///" + addr,
+		})
+	}
+
+	return stop, nil
+}
+
+// functionPod returns the pod the supplied Function is run in.
+func (r *podRuntime) functionPod(fn pkgv1.Function) *corev1.Pod {
+	image := fn.Spec.Package
+	if i := fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerImage]; i != "" {
+		image = i
+	}
+	pullPolicy := corev1.PullIfNotPresent
+	switch fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerPullPolicy] {
+	case string(render.AnnotationValueRuntimeDockerPullPolicyAlways):
+		pullPolicy = corev1.PullAlways
+	case string(render.AnnotationValueRuntimeDockerPullPolicyNever):
+		pullPolicy = corev1.PullNever
+	}
+
+	// Leave room for the suffix generated names get.
+	prefix := fn.GetName()
+	if len(prefix) > 50 {
+		prefix = prefix[:50]
+	}
+
+	secrets := make([]corev1.LocalObjectReference, 0, len(r.imagePullSecrets))
+	for _, s := range r.imagePullSecrets {
+		secrets = append(secrets, corev1.LocalObjectReference{Name: s})
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: strings.TrimSuffix(prefix, "-") + "-",
+			Namespace:    r.namespace,
+			Labels:       map[string]string{"app.kubernetes.io/managed-by": "crossbench"},
+			Annotations:  map[string]string{AnnotationKeyFunction: fn.GetName()},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: r.serviceAccount,
+			ImagePullSecrets:   secrets,
+			Containers: []corev1.Container{{
+				Name:            "function",
+				Image:           image,
+				ImagePullPolicy: pullPolicy,
+				Args:            []string{"--insecure"},
+				Ports:           []corev1.ContainerPort{{Name: "grpc", ContainerPort: functionPort}},
+			}},
+		},
+	}
+}
+
+// waitForPod waits until the named pod is running, and fails early if its
+// image can't be pulled or it exits.
+func waitForPod(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+	for {
+		pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "cannot get pod %s/%s", namespace, name)
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return errors.Errorf("pod %s/%s exited: %s", namespace, name, pod.Status.Phase)
+		}
+		for _, s := range pod.Status.ContainerStatuses {
+			if w := s.State.Waiting; w != nil && (w.Reason == "ErrImagePull" || w.Reason == "ImagePullBackOff" || w.Reason == "InvalidImageName") {
+				return errors.Errorf("pod %s/%s can't pull image %q: %s", namespace, name, s.Image, w.Message)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "pod %s/%s isn't running", namespace, name)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// forwardPort forwards the supplied local address to the function port of
+// the named pod until done is closed.
+func forwardPort(ctx context.Context, cfg *rest.Config, client kubernetes.Interface, namespace, name, addr string, done chan struct{}) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot create port-forward transport")
+	}
+	url := client.CoreV1().RESTClient().Post().Resource("pods").Namespace(namespace).Name(name).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	ready := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(dialer, []string{host}, []string{fmt.Sprintf("%s:%d", port, functionPort)}, done, ready, io.Discard, os.Stderr)
+	if err != nil {
+		return errors.Wrap(err, "cannot create port-forward")
+	}
+	failed := make(chan error, 1)
+	go func() { failed <- fw.ForwardPorts() }()
+
+	select {
+	case <-ready:
+		return nil
+	case err := <-failed:
+		return errors.Wrap(err, "port-forward failed")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
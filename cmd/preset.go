@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// A RenderPreset names the inputs and output options of a render, so it can
+// be run with crossbench render --preset. Paths are relative to the
+// configuration file.
+type RenderPreset struct {
+	XR                     string            `json:"xr"`
+	Composition            string            `json:"composition"`
+	Functions              string            `json:"functions,omitempty"`
+	ObservedResources      string            `json:"observedResources,omitempty"`
+	ExtraResources         string            `json:"extraResources,omitempty"`
+	FunctionCredentials    string            `json:"functionCredentials,omitempty"`
+	ContextFiles           map[string]string `json:"contextFiles,omitempty"`
+	ContextValues          map[string]string `json:"contextValues,omitempty"`
+	IncludeFunctionResults bool              `json:"includeFunctionResults,omitempty"`
+	IncludeFullXR          bool              `json:"includeFullXR,omitempty"`
+	IncludeContext         bool              `json:"includeContext,omitempty"`
+	Timeout                *metav1.Duration  `json:"timeout,omitempty"`
+}
+
+// resolvePaths makes the relative paths of the preset relative to dir.
+func (p *RenderPreset) resolvePaths(dir string) {
+	rel := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	p.XR = rel(p.XR)
+	p.Composition = rel(p.Composition)
+	p.Functions = rel(p.Functions)
+	p.ObservedResources = rel(p.ObservedResources)
+	p.ExtraResources = rel(p.ExtraResources)
+	p.FunctionCredentials = rel(p.FunctionCredentials)
+	files := make(map[string]string, len(p.ContextFiles))
+	for k, v := range p.ContextFiles {
+		files[k] = rel(v)
+	}
+	p.ContextFiles = files
+}
+
+// applyPreset sets the flags of the --preset that weren't set on the command
+// line, and returns the arguments it renders.
+func (c *renderCmd) applyPreset(cmd *cobra.Command) ([]string, error) {
+	p, ok := c.cfg.Presets[c.preset]
+	if !ok {
+		return nil, errors.Errorf("preset %q isn't defined in config file %q", c.preset, c.config)
+	}
+	if p.XR == "" || p.Composition == "" {
+		return nil, errors.Errorf("preset %q must specify an xr and a composition", c.preset)
+	}
+
+	unset := func(flag string) bool { return !cmd.Flags().Changed(flag) }
+	if p.ObservedResources != "" && unset("observed-resources") {
+		c.observedResources = p.ObservedResources
+	}
+	if p.ExtraResources != "" && unset("extra-resources") {
+		c.extraResources = p.ExtraResources
+	}
+	if p.FunctionCredentials != "" && unset("function-credentials") {
+		c.functionCredentials = p.FunctionCredentials
+	}
+	if len(p.ContextFiles) > 0 && unset("context-files") {
+		c.contextFiles = p.ContextFiles
+	}
+	if len(p.ContextValues) > 0 && unset("context-values") {
+		c.contextValues = p.ContextValues
+	}
+	if p.Timeout != nil && unset("timeout") {
+		c.timeout = p.Timeout.Duration
+	}
+	c.includeFunctionResults = c.includeFunctionResults || p.IncludeFunctionResults
+	c.includeFullXR = c.includeFullXR || p.IncludeFullXR
+	c.includeContext = c.includeContext || p.IncludeContext
+
+	args := []string{p.XR, p.Composition}
+	if p.Functions != "" {
+		args = append(args, p.Functions)
+	}
+	return args, nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+func newFunctionsPullCommand() *cobra.Command {
+	cmd := &functionsPullCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "pull <composition-file-or-dir>...",
+		Short: "Pull the function images used by compositions ahead of rendering",
+		Long: `Pull resolves every function used by the supplied compositions, the same way
+render does, and pulls its image into the local Docker daemon, or Podman
+with --runtime podman. Run it as a CI
+warm-up step so rendering is fast and doesn't fail on registry hiccups.
+
+Functions pinned in the lock file are pulled by digest. Functions with a
+locally built image override or a source override in the crossbench
+configuration file aren't pulled.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Path to the crossbench configuration file. It's optional unless set explicitly.")
+	cobraCmd.Flags().StringVar(&cmd.lockFile, "lock-file", getLockPath(), "Path to the function lock file. Locked functions are pulled by digest.")
+	cobraCmd.Flags().BoolVar(&cmd.forceRefresh, "force-refresh", false, "Bypass the cache when looking up function versions.")
+	cobraCmd.Flags().StringVar(&cmd.runtime, "runtime", getContainerRuntime(), "Container runtime to pull images into: auto, docker or podman.")
+	cobraCmd.Flags().StringVar(&cmd.dockerHost, "docker-host", "", "Docker API endpoint to pull images into. Overrides DOCKER_HOST.")
+	cobraCmd.Flags().BoolVar(&cmd.failFast, "fail-fast", false, "Stop at the first image that fails to pull instead of pulling them all.")
+
+	return cobraCmd
+}
+
+type functionsPullCmd struct {
+	config       string
+	lockFile     string
+	forceRefresh bool
+	failFast     bool
+	runtime      string
+	dockerHost   string
+
+	fs afero.Fs
+}
+
+func (c *functionsPullCmd) run(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(c.fs, c.config, cmd.Flags().Changed("config"))
+	if err != nil {
+		return err
+	}
+	if err := setDockerHost(c.dockerHost); err != nil {
+		return err
+	}
+	if err := setContainerRuntime(c.runtime); err != nil {
+		return err
+	}
+	if err := setFunctionResolution(cfg.Functions); err != nil {
+		return err
+	}
+	creds, err := newGitHubCredentialChain(getGitHubAuthMode(), "", cfg.GitHub)
+	if err != nil {
+		return err
+	}
+	gitHubCredentials = creds
+
+	comps, err := loadCompositionPaths(c.fs, args)
+	if err != nil {
+		return err
+	}
+	l, err := loadLock(c.fs, c.lockFile)
+	if err != nil {
+		return err
+	}
+
+	images := map[string]string{}
+	for _, comp := range comps {
+		if comp.Spec.Mode != apiextensionsv1.CompositionModePipeline {
+			continue
+		}
+		var fns []pkgv1.Function
+		if l != nil {
+			fns, _, err = lockedFunctions(comp, l, c.fs, c.forceRefresh)
+		} else {
+			fns, err = ExtractFunctionsFromComposition(comp, c.fs, c.forceRefresh)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve functions of Composition %q", comp.GetName())
+		}
+		for _, fn := range fns {
+			if o, ok := cfg.Functions[fn.GetName()]; ok && (o.Image != "" || o.Source != "") {
+				infof("Skipping function %q, it's overridden in %s", fn.GetName(), c.config)
+				continue
+			}
+			image := fn.Spec.Package
+			if i := fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerImage]; i != "" {
+				image = i
+			}
+			images[image] = fn.GetName()
+		}
+	}
+
+	refs := make([]string, 0, len(images))
+	for ref := range images {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	results := make([]RunResult, len(refs))
+	failed := 0
+	for i, ref := range refs {
+		results[i].Name = ref
+		if c.failFast && failed > 0 {
+			results[i].Skipped = true
+			continue
+		}
+		infof("Pulling function %q image %q", images[ref], ref)
+		start := time.Now()
+		pull := exec.Command(containerCLI, "pull", ref)
+		pull.Stdout = os.Stderr
+		pull.Stderr = os.Stderr
+		if err := pull.Run(); err != nil {
+			errorf("Cannot pull function %q image %q: %v", images[ref], ref, err)
+			results[i].Failures = []string{err.Error()}
+			failed++
+		}
+		results[i].Duration = time.Since(start)
+	}
+	if failed > 0 {
+		_ = PrintRunSummary(os.Stderr, results)
+		return errors.Errorf("%d of %d function images failed to pull", failed, len(refs))
+	}
+	infof("Pulled %d function image(s)", len(refs))
+	return nil
+}
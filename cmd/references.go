@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+)
+
+const checkReferences = "references"
+
+// A reference from one composed resource to another, following the managed
+// resource conventions: fooRef and fooRefs name the referenced resources,
+// fooSelector selects them by label.
+type reference struct {
+	Path     string
+	Name     string
+	Selector map[string]string
+}
+
+// findReferences returns the references in the forProvider and initProvider
+// fields of a managed resource. Secret references are ignored, since secrets
+// are never composed alongside the resources that use them.
+func findReferences(cd *composed.Unstructured) []reference {
+	refs := []reference{}
+	spec, ok := cd.Object["spec"].(map[string]any)
+	if !ok {
+		return refs
+	}
+	for _, f := range []string{"forProvider", "initProvider"} {
+		refs = append(refs, walkReferences("spec."+f, spec[f])...)
+	}
+	return refs
+}
+
+func walkReferences(path string, v any) []reference {
+	refs := []reference{}
+	switch t := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := path + "." + k
+			switch {
+			case strings.HasSuffix(k, "SecretRef"):
+			case strings.HasSuffix(k, "Ref"):
+				if r, ok := t[k].(map[string]any); ok {
+					if name, ok := r["name"].(string); ok && name != "" {
+						refs = append(refs, reference{Path: p, Name: name})
+					}
+				}
+			case strings.HasSuffix(k, "Refs"):
+				l, _ := t[k].([]any)
+				for i, e := range l {
+					if r, ok := e.(map[string]any); ok {
+						if name, ok := r["name"].(string); ok && name != "" {
+							refs = append(refs, reference{Path: fmt.Sprintf("%s[%d]", p, i), Name: name})
+						}
+					}
+				}
+			case strings.HasSuffix(k, "Selector"):
+				s, _ := t[k].(map[string]any)
+				ml, _ := s["matchLabels"].(map[string]any)
+				if len(ml) == 0 {
+					continue
+				}
+				sel := map[string]string{}
+				for lk, lv := range ml {
+					sel[lk] = fmt.Sprint(lv)
+				}
+				refs = append(refs, reference{Path: p, Selector: sel})
+			default:
+				refs = append(refs, walkReferences(p, t[k])...)
+			}
+		}
+	case []any:
+		for i, e := range t {
+			refs = append(refs, walkReferences(fmt.Sprintf("%s[%d]", path, i), e)...)
+		}
+	}
+	return refs
+}
+
+// CheckReferences verifies that every reference and selector of a composed
+// resource resolves to another resource in the rendered set. References are
+// matched by name (or external name) regardless of kind, since the kind a
+// reference field points to isn't recorded in the resource itself.
+func CheckReferences(cds []composed.Unstructured) []Finding {
+	findings := []Finding{}
+	for i := range cds {
+		for _, r := range findReferences(&cds[i]) {
+			if len(resolveReference(r, cds, i)) > 0 {
+				continue
+			}
+			msg := fmt.Sprintf("%s references %q, which is not a rendered resource", r.Path, r.Name)
+			if r.Selector != nil {
+				msg = fmt.Sprintf("%s selects %s, which matches no rendered resource", r.Path, labels.SelectorFromSet(r.Selector))
+			}
+			findings = append(findings, Finding{
+				Check:    checkReferences,
+				Severity: SeverityError,
+				Resource: resourceID(&cds[i].Unstructured),
+				Message:  msg,
+			})
+		}
+	}
+	return findings
+}
+
+// resolveReference returns the indices of the resources the reference of
+// resource self resolves to.
+func resolveReference(r reference, cds []composed.Unstructured, self int) []int {
+	matches := []int{}
+	for j := range cds {
+		if j == self {
+			continue
+		}
+		if r.Selector != nil {
+			if labels.SelectorFromSet(r.Selector).Matches(labels.Set(cds[j].GetLabels())) {
+				matches = append(matches, j)
+			}
+			continue
+		}
+		if cds[j].GetName() == r.Name || meta.GetExternalName(&cds[j]) == r.Name {
+			matches = append(matches, j)
+		}
+	}
+	return matches
+}
@@ -3,22 +3,34 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/mod/semver"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/json"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
-	"github.com/crossplane/crossplane-runtime/v2/pkg/logging"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
 
 	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgmetav1 "github.com/crossplane/crossplane/v2/apis/pkg/meta/v1"
 	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/v2/apis/pkg/v1beta1"
 	"github.com/crossplane/crossplane/v2/cmd/crank/render"
 )
 
@@ -29,7 +41,7 @@ func NewRenderCommand() *cobra.Command {
 	}
 
 	cobraCmd := &cobra.Command{
-		Use:   "render <composite-resource> <composition> [functions]",
+		Use:   "render <composite-resource(s)> [composition] [functions]",
 		Short: "Render a Crossplane composition using composition functions",
 		Long: `Render shows you what composed resources Crossplane would create by
 printing them to stdout. It also prints any changes that would be made to the
@@ -37,10 +49,43 @@ status of the XR. It doesn't talk to Crossplane. Instead it runs the Composition
 Function pipeline specified by the Composition locally, and uses that to render
 the XR. It only supports Compositions in Pipeline mode.
 
+The composite resource argument may also be a directory or a glob of XR files,
+in which case each XR is rendered against the composition in turn. Each XR's
+output is preceded by a "# Source:" comment naming its file, or written to a
+subdirectory of --output-dir named after it. The output directory's
+manifest.json indexes every file written with the inputs that produced it.
+
+The composition argument may also be a Configuration package: a local .xpkg
+file or an OCI reference such as xpkg.crossplane.io/acme/platform:v1.2.0. The
+package's Composition for the XR is used, and Functions extracted from it are
+pinned to the versions the package depends on.
+
+The composite resource may also be a namespaced Claim. crossbench synthesizes
+the XR Crossplane would create for it - copying its spec, labels and
+annotations and setting spec.claimRef - and renders that.
+
+Instead of passing the composition argument, --compositions-dir selects the
+Composition for each XR from a directory, honoring the XR's compositionRef and
+compositionSelector plus any --composition-selector labels.
+
+Instead of passing arguments, --preset renders the inputs of a preset defined in
+the configuration file, such as one imported from a crossplane render
+invocation with crossbench import-crank.
+
+A CompositionRevision, e.g. exported from a cluster, can be used wherever a
+Composition is expected.
+
+The composite resource and composition arguments may also name resources in
+the cluster selected by --kubeconfig and --kube-context as <type>/<name>, e.g.
+xbuckets.example.crossplane.io/my-bucket or composition/my-composition, when
+no such file exists. Namespaced XRs are read from the namespace of the
+kubeconfig context.
+
 If the functions argument is not provided, crossbench will automatically extract
 function references from the composition's pipeline and use them.
 
-Composition Functions are pulled and run using Docker by default. You can add
+Composition Functions are pulled and run using Docker by default, Podman with
+--runtime podman, or as pods in a cluster with --runtime kubernetes. You can add
 the following annotations to each Function to change how they're run:
 
   render.crossplane.io/runtime: "Development"
@@ -70,21 +115,53 @@ the following annotations to each Function to change how they're run:
 Use the standard DOCKER_HOST, DOCKER_API_VERSION, DOCKER_CERT_PATH, and
 DOCKER_TLS_VERIFY environment variables to configure how this command connects
 to the Docker daemon.`,
-		Args: cobra.RangeArgs(2, 3),
-		RunE: cmd.run,
+		Args: cobra.RangeArgs(0, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
 	}
 
 	// Flags
-	cobraCmd.Flags().StringToStringVar(&cmd.contextFiles, "context-files", nil, "Comma-separated context key-value pairs to pass to the Function pipeline. Values must be files containing JSON.")
-	cobraCmd.Flags().StringToStringVar(&cmd.contextValues, "context-values", nil, "Comma-separated context key-value pairs to pass to the Function pipeline. Values must be JSON. Keys take precedence over --context-files.")
+	cmd.addInputFlags(cobraCmd)
 	cobraCmd.Flags().BoolVarP(&cmd.includeFunctionResults, "include-function-results", "r", false, "Include informational and warning messages from Functions in the rendered output as resources of kind: Result.")
 	cobraCmd.Flags().BoolVarP(&cmd.includeFullXR, "include-full-xr", "x", false, "Include a direct copy of the input XR's spec and metadata fields in the rendered output.")
-	cobraCmd.Flags().StringVarP(&cmd.observedResources, "observed-resources", "o", "", "A YAML file or directory of YAML files specifying the observed state of composed resources.")
-	cobraCmd.Flags().StringVarP(&cmd.extraResources, "extra-resources", "e", "", "A YAML file or directory of YAML files specifying extra resources to pass to the Function pipeline.")
 	cobraCmd.Flags().BoolVarP(&cmd.includeContext, "include-context", "c", false, "Include the context in the rendered output as a resource of kind: Context.")
-	cobraCmd.Flags().StringVar(&cmd.functionCredentials, "function-credentials", "", "A YAML file or directory of YAML files specifying credentials to use for Functions to render the XR.")
-	cobraCmd.Flags().DurationVar(&cmd.timeout, "timeout", 1*time.Minute, "How long to run before timing out.")
-	cobraCmd.Flags().BoolVar(&cmd.refreshCache, "refresh-cache", false, "Force refresh of cached function versions from GitHub")
+	cobraCmd.Flags().StringVar(&cmd.outputDir, "output-dir", "", "Write the XR and each composed resource to its own file, named by kind and name, in this directory instead of stdout.")
+	cobraCmd.Flags().StringVar(&cmd.outputLayout, "output-layout", "", "How resources are grouped into files in the --output-dir: resource (a file each), kind, step (a file per pipeline step), single or gitops (a file each, in <group>/<kind> directories). Overrides outputTargets in the configuration file; defaults to resource.")
+	cobraCmd.Flags().StringVar(&cmd.maxFileSize, "max-file-size", "", "Split files in the --output-dir larger than this, e.g. 512Ki, into numbered parts holding whole resources. Overrides outputTargets in the configuration file.")
+	cobraCmd.Flags().BoolVar(&cmd.footprint, "footprint", false, "Print a summary of the infrastructure requested by the composed resources (counts, sizes, nodes, disk) to stderr.")
+	cobraCmd.Flags().StringSliceVar(&cmd.requiredLabels, "required-labels", getRequiredLabels(), "Comma-separated XR labels that must be propagated to every composed resource.")
+	cobraCmd.Flags().BoolVar(&cmd.dependencyOrder, "dependency-order", false, "Print a best-effort creation and deletion order of the composed resources, derived from their references and selectors, to stderr.")
+	cobraCmd.Flags().BoolVar(&cmd.syncWaves, "sync-waves", false, "Annotate composed resources with argocd.argoproj.io/sync-wave according to their dependency order.")
+	cobraCmd.Flags().StringVar(&cmd.includeXRD, "include-xrd", "", "Include the XRD in this file in the rendered output, ahead of the XR.")
+	cobraCmd.Flags().StringVar(&cmd.bundle, "bundle", "", "Write an installable bundle - the XRD, the Composition, the Function manifests as pinned for the render and the XR as an example - to this directory, after checking they're consistent. The XRD comes from --include-xrd or the Configuration package.")
+	cobraCmd.Flags().StringVar(&cmd.snapshot, "snapshot", "", "Compare the rendered XR and composed resources with this snapshot file, or s3://, gs:// or oci:// location, and fail if they differ.")
+	cobraCmd.Flags().BoolVar(&cmd.updateSnapshots, "update-snapshots", false, "Write the rendered XR and composed resources to the --snapshot file instead of comparing them.")
+	cobraCmd.Flags().StringVar(&cmd.apiUpgrades, "api-upgrades", "", "A YAML file mapping provider API version changes (renamed and removed fields). Reports the composed resources that would need composition changes.")
+	cobraCmd.Flags().BoolVar(&cmd.checkReferences, "check-references", false, "Fail if a reference or selector of a composed resource doesn't resolve to another rendered resource.")
+	cobraCmd.Flags().BoolVar(&cmd.checkMetadata, "check-metadata", false, "Fail if the metadata of the XR or a composed resource would be rejected by the API server: invalid names, namespaces, label keys or values, oversized annotations or invalid finalizers.")
+	cobraCmd.Flags().StringSliceVar(&cmd.kyvernoPolicies, "kyverno-policies", nil, "Comma-separated files or directories of Kyverno ClusterPolicies and Policies. Their validate rules are evaluated against the XR and composed resources, printing each rule's result to stderr; failed Enforce rules fail the render, failed Audit rules are warnings.")
+	cobraCmd.Flags().StringArrayVar(&cmd.assertions, "assert", nil, "Fail unless this CEL expression is true of the rendered output, e.g. 'resources.all(r, has(r.metadata.labels))'. It can refer to xr, resources (the composed resources), results and context. Repeatable.")
+	cobraCmd.Flags().BoolVar(&cmd.dryRunValidate, "dry-run-validate", false, "Submit each composed resource to the cluster selected by --kubeconfig and --kube-context with a server-side dry-run, and fail if the API server or an admission webhook rejects it.")
+	cobraCmd.Flags().StringVar(&cmd.baseline, "baseline", getBaselinePath(), "Findings baseline: a file, or an s3://, gs:// or oci:// location shared with CI. Findings in it are grandfathered, so only new findings are reported and fail.")
+	cobraCmd.Flags().BoolVar(&cmd.writeBaseline, "write-baseline", false, "Write the current findings of every XR rendered to the --baseline file instead of reporting them.")
+	cobraCmd.Flags().StringVar(&cmd.sbom, "sbom", "", "Write an SBOM listing the function images used by the render, with their digests, to this file.")
+	cobraCmd.Flags().StringVar(&cmd.sbomFormat, "sbom-format", SBOMFormatCycloneDX, "Format of the --sbom file: cyclonedx or spdx.")
+	cobraCmd.Flags().BoolVar(&cmd.sbomMerge, "sbom-merge", false, "Merge the components of each function image's own CycloneDX SBOM, attached as a cosign sha256-<digest>.sbom tag, into the --sbom file.")
+	cobraCmd.Flags().StringSliceVar(&cmd.requiredAnnotations, "required-annotations", getRequiredAnnotations(), "Comma-separated XR annotations that must be propagated to every composed resource.")
+	cobraCmd.Flags().BoolVar(&cmd.failFast, "fail-fast", false, "When rendering a directory of XRs, stop at the first one that fails instead of rendering them all.")
+	cobraCmd.Flags().StringVar(&cmd.changedSince, "changed-since", "", "When rendering several XRs, skip those the changes to the Composition and functions file since this git ref can't affect. Best effort: XRs are only skipped when the changes are function-patch-and-transform patches reading XR fields they don't set; any other change renders them all.")
+	cobraCmd.Flags().StringVar(&cmd.failOn, "fail-on", getFailOn(), "Function results that fail the render: fatal fails only when a step returns a fatal result; warning also fails on warnings; results fails on any result; none never fails because of results, only logging a fatal one.")
+	cobraCmd.Flags().StringVar(&cmd.timings, "timings", "", "Report how long starting the functions and each pipeline step took: stderr prints a table, output adds a kind: Timing document to the rendered output. --timings alone means stderr.")
+	cobraCmd.Flag("timings").NoOptDefVal = TimingsStderr
+	cobraCmd.Flags().StringVar(&cmd.timeoutBehavior, "timeout-behavior", getTimeoutBehavior(), "What to do when the --timeout is hit: fail discards the output; partial writes what the completed pipeline steps rendered and a report of the step in progress, then fails.")
+	cobraCmd.Flags().StringVar(&cmd.summaryFile, "summary-file", "", "Write a JSON summary of the run - per-XR outcome, duration, function versions and findings - to this file for CI jobs.")
+	cobraCmd.Flags().StringToStringVar(&cmd.reports, "report", nil, "Write a report of the run, with a test case per rendered XR, as <format>=<path>. Repeatable; formats: junit, sarif.")
+	cobraCmd.Flags().StringVar(&cmd.dumpIO, "dump-io", "", "Write the request and response of every function call - observed and desired state, context and results - to this directory as JSON, named after the pipeline step. With several XRs, each gets a subdirectory, named like those of --output-dir.")
+	cobraCmd.Flags().StringVar(&cmd.stopAfterStep, "stop-after-step", "", "Only run the pipeline up to this step, given by name or number, e.g. 2 for the first two steps, and print the desired state it returned.")
+	cobraCmd.Flags().StringSliceVar(&cmd.debug, "debug", nil, "Pause after the pipeline steps matching these names or globs - every step if none are given - show the desired state they returned, and prompt to continue, re-run the step or abort. Needs a terminal; --timeout doesn't apply.")
+	cobraCmd.Flag("debug").NoOptDefVal = "*"
+	cobraCmd.Flags().StringVar(&cmd.preset, "preset", "", "Render the inputs of this preset of the configuration file. Flags set on the command line take precedence over the preset.")
+	cobraCmd.Flags().StringVar(&cmd.explainSelection, "explain-selection", "", "Write an account of every decision made before rendering - the inputs loaded, how the Composition was selected, the checks run on the inputs and how each step's function was resolved - to this file, as JSON for a .json file and YAML otherwise. --explain-selection alone writes YAML to stderr.")
+	cobraCmd.Flag("explain-selection").NoOptDefVal = ExplainStderr
 
 	return cobraCmd
 }
@@ -96,111 +173,1161 @@ type renderCmd struct {
 	functions         string
 
 	// Flags
-	contextFiles          map[string]string
-	contextValues         map[string]string
+	contextFiles           map[string]string
+	contextValues          map[string]string
 	includeFunctionResults bool
-	includeFullXR         bool
-	observedResources     string
-	extraResources        string
-	includeContext        bool
-	functionCredentials   string
-	timeout               time.Duration
-	refreshCache          bool
-
-	fs afero.Fs
+	includeFullXR          bool
+	observedResources      string
+	extraResources         string
+	includeContext         bool
+	functionCredentials    string
+	profile                string
+	timeout                time.Duration
+	timeoutBehavior        string
+	refreshCache           bool
+	footprint              bool
+	outputDir              string
+	outputLayout           string
+	maxFileSize            string
+	requiredLabels         []string
+	requiredAnnotations    []string
+	checkReferences        bool
+	checkMetadata          bool
+	kyvernoPolicies        []string
+	assertions             []string
+	dryRunValidate         bool
+	dependencyOrder        bool
+	syncWaves              bool
+	snapshot               string
+	includeXRD             string
+	bundle                 string
+	updateSnapshots        bool
+	apiUpgrades            string
+	config                 string
+	githubAuthMode         string
+	runtime                string
+	dockerHost             string
+	pods                   podRuntime
+	compositionsDir        string
+	noNetwork              bool
+	pinDigests             bool
+	offline                bool
+	scanner                string
+	failOnSeverity         string
+	grpcMaxMessageSize     string
+	grpcKeepalive          time.Duration
+	grpcCompression        string
+	parallelSteps          bool
+	strictDecode           bool
+	interactive            bool
+	daemon                 bool
+	runtimeOverrides       string
+	pool                   *functionPool
+	observer               pipelineObserver
+	metaContext            bool
+	missingInputs          bool
+	timings                string
+	otelEndpoint           string
+	deleting               []string
+	absent                 []string
+	chaosFault             string
+	preset                 string
+	dumpIO                 string
+	debug                  []string
+	stopAfterStep          string
+	skipSteps              []string
+	stepInputs             map[string]string
+	stepInputMode          string
+	onlySteps              []string
+	mode                   string
+	failFast               bool
+	changedSince           string
+	failOn                 string
+	summaryFile            string
+	reports                map[string]string
+	explainSelection       string
+	lockFile               string
+	crossplaneLockSource   string
+	crossplaneVersion      string
+	extraFromCluster       bool
+	observedFromCluster    bool
+	baseline               string
+	writeBaseline          bool
+	sbom                   string
+	sbomFormat             string
+	sbomMerge              bool
+	compositionSelector    map[string]string
+	githubToken            string
+
+	cfg *Config
+	fs  afero.Fs
+
+	// ctx is the context the command runs in, which carries its trace.
+	ctx context.Context
+
+	// result records the outcome of the XR being rendered, for the
+	// --summary-file.
+	result *RunResult
+
+	// outputFiles are the files written to the --output-dir, indexed by its
+	// manifest.
+	outputFiles []OutputFile
+
+	// explain records the decisions made before rendering the XR being
+	// rendered, if --explain-selection is set.
+	explain *SelectionExplanation
+
+	// explanations are the --explain-selection records of every XR rendered.
+	explanations []SelectionExplanation
+
+	// layout is how the files of the --output-dir are laid out.
+	layout outputLayout
+
+	// resourceSteps records the pipeline step that first desired each
+	// composed resource, if set.
+	resourceSteps map[string]string
+
+	// renderedFunctions are the Functions used by renders, by name.
+	renderedFunctions map[string]pkgv1.Function
+
+	// compiledAssertions are the --assert expressions, compiled once before
+	// any XR is rendered.
+	compiledAssertions []assertion
+
+	// knownFindings is the --baseline, loaded when the first XR is checked.
+	// Its entries are used up as they suppress findings of each XR.
+	knownFindings *Baseline
+
+	// baselineFindings are the findings of every XR rendered, written as the
+	// --baseline once they're all rendered if --write-baseline is set.
+	baselineFindings []Finding
+
+	// checkedXRs is how many XRs were rendered and checked.
+	checkedXRs int
+
+	clusterFlags
+
+	// xpLock is the Crossplane Lock loaded from --crossplane-lock.
+	xpLock *pkgv1beta1.Lock
+
+	// xpVersion is the Crossplane version targeted by --crossplane-version.
+	xpVersion string
+
+	// fnCrossplaneConstraints are the Crossplane version constraints of
+	// Function packages, by package.
+	fnCrossplaneConstraints map[string]string
+
+	// xrd is the XRD loaded from --include-xrd.
+	xrd *unstructured.Unstructured
+
+	// packageXRDs are the XRDs of the Configuration package the Composition
+	// was loaded from, if any.
+	packageXRDs []*unstructured.Unstructured
+
+	// packageMeta is the metadata of the Configuration package the
+	// Composition was loaded from, if any.
+	packageMeta *pkgmetav1.Configuration
+}
+
+// networkDisabled returns whether outbound network access must be refused.
+func (c *renderCmd) networkDisabled() bool {
+	return c.noNetwork || c.offline
+}
+
+// addInputFlags registers the flags that control which inputs are passed to
+// the Function pipeline, and the configuration file. They're shared by every
+// command that renders an XR.
+func (c *renderCmd) addInputFlags(cobraCmd *cobra.Command) {
+	cobraCmd.Flags().StringVar(&c.config, "config", getConfigPath(), "Path to the crossbench configuration file. It's optional unless set explicitly.")
+	cobraCmd.Flags().StringToStringVar(&c.contextFiles, "context-files", nil, "Comma-separated context key-value pairs to pass to the Function pipeline. Values must be files containing JSON.")
+	cobraCmd.Flags().StringToStringVar(&c.contextValues, "context-values", nil, "Comma-separated context key-value pairs to pass to the Function pipeline. Values must be JSON. Keys take precedence over --context-files.")
+	cobraCmd.Flags().StringVarP(&c.observedResources, "observed-resources", "o", "", "A YAML file or directory of YAML files specifying the observed state of composed resources.")
+	cobraCmd.Flags().StringVarP(&c.extraResources, "extra-resources", "e", "", "A YAML file or directory of YAML files specifying extra resources to pass to the Function pipeline.")
+	cobraCmd.Flags().BoolVar(&c.observedFromCluster, "observed-from-cluster", false, "Use the resources the XR composed in the cluster selected by --kubeconfig and --kube-context as its observed resources, instead of --observed-resources.")
+	cobraCmd.Flags().BoolVar(&c.extraFromCluster, "resolve-extra-from-cluster", false, "Fetch the extra and required resources functions ask for from the cluster selected by --kubeconfig and --kube-context, and re-run them, when --extra-resources holds none matching.")
+	cobraCmd.Flags().StringSliceVar(&c.deleting, "deleting", nil, "Comma-separated composition resource names (or globs) of observed resources to mark as being deleted, by setting their deletionTimestamp.")
+	cobraCmd.Flags().StringSliceVar(&c.absent, "absent", nil, "Comma-separated composition resource names (or globs) of observed resources to leave out, as if they were deleted.")
+	cobraCmd.Flags().StringVar(&c.functionCredentials, "function-credentials", "", "A YAML file or directory of YAML files specifying credentials to use for Functions to render the XR.")
+	cobraCmd.Flags().StringVar(&c.profile, "profile", getProfile(), "Environment profile. Credentials are loaded from the subdirectory of --function-credentials named after the profile.")
+	cobraCmd.Flags().DurationVar(&c.timeout, "timeout", 1*time.Minute, "How long to run before timing out.")
+	cobraCmd.Flags().BoolVar(&c.refreshCache, "refresh-cache", false, "Force refresh of cached function versions from GitHub")
+	cobraCmd.Flags().StringVar(&c.lockFile, "lock-file", getLockPath(), "Function lock file. When it exists, functions extracted from the composition use the packages pinned in it.")
+	cobraCmd.Flags().StringVar(&c.crossplaneLockSource, "crossplane-lock", "", "Take function package versions from a Crossplane Lock (locks.pkg.crossplane.io) instead of the lock file: a file exported with kubectl get lock lock -o yaml, or cluster to read it from the cluster selected by --kubeconfig and --kube-context.")
+	cobraCmd.Flags().StringVar(&c.crossplaneVersion, "crossplane-version", getCrossplaneVersion(), "Check the Composition, its Configuration package and the packages of its functions are compatible with this Crossplane version, e.g. v2.1.0, or cluster to detect it from the cluster selected by --kubeconfig and --kube-context.")
+	cobraCmd.Flags().BoolVar(&c.pinDigests, "pin-digests", getPinDigests(), "Resolve each function's package tag to its OCI digest and run package@sha256:... instead, so a re-pushed tag can't change the render.")
+	cobraCmd.Flags().BoolVar(&c.offline, "offline", getOffline(), "Run without network access, e.g. on air-gapped agents. Function versions come from the cache (even if expired), the lock file or a functions file, and function images must already be present locally.")
+	cobraCmd.Flags().BoolVar(&c.noNetwork, "no-network", getNoNetwork(), "Refuse all outbound network access - GitHub version lookups, package and function image pulls - and fail listing every attempt. Proves the run is hermetic. Tools crossbench runs, such as gh, gcloud and trivy, aren't sandboxed.")
+	cobraCmd.Flags().StringVar(&c.scanner, "scanner", getScanner(), "Scan function images for vulnerabilities before running them: trivy, grype, or a command that's passed the image and exits non-zero to reject it.")
+	cobraCmd.Flags().StringVar(&c.failOnSeverity, "fail-on-severity", "critical", "Don't run functions whose images have vulnerabilities at or above this severity: low, medium, high or critical.")
+	cobraCmd.Flags().StringVar(&c.compositionsDir, "compositions-dir", "", "Select the Composition for the XR from this directory of Compositions, the way Crossplane does, instead of passing a composition argument.")
+	cobraCmd.Flags().StringToStringVar(&c.compositionSelector, "composition-selector", nil, "Comma-separated labels the Composition selected from --compositions-dir must have, in addition to the XR's compositionSelector.")
+	cobraCmd.Flags().StringVar(&c.githubAuthMode, "github-auth-mode", getGitHubAuthMode(), "How to authenticate to the GitHub API: auto (--github-token, env, config file, gh CLI, anonymous), no-gh (auto without the gh CLI), flag, env, config, gh or anonymous.")
+	cobraCmd.Flags().StringVar(&c.grpcMaxMessageSize, "grpc-max-message-size", "", "Largest gRPC message exchanged with functions, e.g. 64Mi, for huge desired states. Overrides grpc.maxMessageSize in the configuration file.")
+	cobraCmd.Flags().DurationVar(&c.grpcKeepalive, "grpc-keepalive", 0, "How often to ping idle function connections. Overrides grpc.keepalive in the configuration file.")
+	cobraCmd.Flags().StringVar(&c.grpcCompression, "grpc-compression", "", "Compress requests to functions: gzip or none. Overrides grpc.compression in the configuration file.")
+	cobraCmd.Flags().StringSliceVar(&c.skipSteps, "skip-step", nil, "Comma-separated pipeline steps (names or globs) to skip, passing the desired state through unchanged. Their functions aren't run, so their credentials aren't needed.")
+	cobraCmd.Flags().StringSliceVar(&c.onlySteps, "only-step", nil, "Comma-separated pipeline steps (names or globs) to run, skipping every other step like --skip-step.")
+	cobraCmd.Flags().StringToStringVar(&c.stepInputs, "step-input", nil, "Comma-separated pipeline step names and YAML files overriding their input for this render only, e.g. patch-and-transform=input.yaml.")
+	cobraCmd.Flags().StringVar(&c.stepInputMode, "step-input-mode", StepInputModeMerge, "How --step-input overrides a step's input: merge (objects merged field by field, null removes a field) or replace.")
+	cobraCmd.Flags().BoolVar(&c.parallelSteps, "parallel-steps", false, "Run consecutive pipeline steps listed in the Composition's crossbench.io/independent-steps annotation concurrently, merging their desired state.")
+	cobraCmd.Flags().BoolVar(&c.interactive, "interactive", false, "When inputs are missing, e.g. no Composition matches the XR or an environment context value the Composition needs, prompt for them instead of failing. Needs a terminal.")
+	cobraCmd.Flags().BoolVar(&c.strictDecode, "strict-decode", false, "Fail if the XR, Composition or Functions files have unknown fields, e.g. a misspelled compositeTypeRefs, instead of silently ignoring them.")
+	cobraCmd.Flags().StringVar(&c.runtime, "runtime", getContainerRuntime(), "Container runtime functions are run with: auto, docker, podman or kubernetes. Podman is used through its Docker compatible API socket; kubernetes runs each function as a pod and connects over a port-forward.")
+	cobraCmd.Flags().StringVar(&c.runtimeOverrides, "runtime-overrides", getRuntimeOverridesPath(), "A YAML file mapping function names or globs to render.crossplane.io annotations, e.g. runtime or pull policy, applied to the functions at render time.")
+	cobraCmd.Flags().BoolVar(&c.metaContext, "meta-context", getMetaContext(), "Pass crossbench metadata - the command, profile and version - to functions under the crossbench.io/meta context key, so they can tell they're rendered locally. It's left out of the rendered context.")
+	cobraCmd.Flags().BoolVar(&c.missingInputs, "missing-inputs", getMissingInputs(), "After rendering, report in one place the optional inputs the render went without - extra resources functions required, the environment, observed resources - and data functions reported missing, as inputs to provide for a more realistic render.")
+	cobraCmd.Flags().BoolVar(&c.daemon, "daemon", getUseDaemon(), "Run functions in the warm containers of crossbench daemon instead of starting a container per render.")
+	cobraCmd.Flags().StringVar(&c.dockerHost, "docker-host", "", "Docker API endpoint functions are run with, e.g. npipe:////./pipe/docker_engine or tcp://host:2376. Overrides DOCKER_HOST.")
+	cobraCmd.Flags().StringVar(&c.otelEndpoint, "otel-endpoint", getOTelEndpoint(), "Export OpenTelemetry traces of loading inputs, resolving functions, starting them and each function call to this OTLP gRPC endpoint, e.g. localhost:4317 (without TLS) or https://collector:4317.")
+	c.pods.addPodRuntimeFlags(cobraCmd)
+	c.addClusterFlags(cobraCmd)
+	cobraCmd.Flags().StringVar(&c.githubToken, "github-token", "", "GitHub token used to resolve function versions.")
 }
 
 func (c *renderCmd) run(cmd *cobra.Command, args []string) error {
-	c.compositeResource = args[0]
-	c.composition = args[1]
-	if len(args) > 2 {
-		c.functions = args[2]
+	if err := c.loadConfig(cmd); err != nil {
+		return err
 	}
 
-	log := logging.NewNopLogger()
+	switch {
+	case c.preset != "" && len(args) > 0:
+		return errors.New("arguments can't be used with --preset")
+	case c.preset != "":
+		var err error
+		if args, err = c.applyPreset(cmd); err != nil {
+			return err
+		}
+	case len(args) == 0:
+		return errors.New("requires a composite resource argument, or a --preset")
+	}
+
+	if c.sbomFormat != SBOMFormatCycloneDX && c.sbomFormat != SBOMFormatSPDX {
+		return errors.Errorf("unknown --sbom-format %q, must be %s or %s", c.sbomFormat, SBOMFormatCycloneDX, SBOMFormatSPDX)
+	}
+	if c.timings != "" && c.timings != TimingsStderr && c.timings != TimingsOutput {
+		return errors.Errorf("unknown --timings %q, must be %s or %s", c.timings, TimingsStderr, TimingsOutput)
+	}
+	if c.timeoutBehavior != TimeoutBehaviorFail && c.timeoutBehavior != TimeoutBehaviorPartial {
+		return errors.Errorf("unknown --timeout-behavior %q, must be %s or %s", c.timeoutBehavior, TimeoutBehaviorFail, TimeoutBehaviorPartial)
+	}
+	if err := validFailOn(c.failOn); err != nil {
+		return err
+	}
+	if err := validReports(c.reports); err != nil {
+		return err
+	}
+	var err error
+	if c.compiledAssertions, err = compileAssertions(c.assertions); err != nil {
+		return err
+	}
+	if c.outputDir != "" {
+		var err error
+		if c.layout, err = c.outputTarget(); err != nil {
+			return err
+		}
+	}
+
+	if c.includeXRD != "" {
+		var err error
+		if c.xrd, err = loadXRD(c.fs, c.includeXRD); err != nil {
+			return err
+		}
+	}
+	if v := c.crossplaneVersion; v != "" && v != CrossplaneVersionCluster && !semver.IsValid(canonicalVersion(v)) {
+		return errors.Errorf("invalid --crossplane-version %q, must be a version such as v2.1.0 or %s", v, CrossplaneVersionCluster)
+	}
+	if c.bundle != "" && (len(c.skipSteps) > 0 || len(c.onlySteps) > 0 || len(c.stepInputs) > 0) {
+		return errors.New("--bundle can't be used with --skip-step, --only-step or --step-input")
+	}
 
-	xr, err := render.LoadCompositeResource(c.fs, c.compositeResource)
+	xrs, err := expandCompositeResourcePaths(c.fs, args[0])
 	if err != nil {
-		return errors.Wrapf(err, "cannot load composite resource from %q", c.compositeResource)
+		return err
+	}
+	if c.changedSince != "" && len(xrs) == 1 {
+		return errors.New("--changed-since can only be used when rendering several composite resources")
+	}
+	if c.changedSince != "" && c.writeBaseline {
+		return errors.New("--changed-since can't be used with --write-baseline, which needs every composite resource rendered")
+	}
+	started := time.Now()
+	if len(xrs) == 1 {
+		c.result = &RunResult{Name: xrs[0]}
+		err := c.renderXR(append([]string{xrs[0]}, args[1:]...), c.outputDir)
+		c.result.Duration = time.Since(started)
+		if err != nil {
+			c.result.Failures = []string{err.Error()}
+		}
+		if serr := c.writeBaselineFile(1); serr != nil {
+			if err == nil {
+				return serr
+			}
+			warnf("%v", serr)
+		}
+		if serr := c.writeSBOM(); serr != nil {
+			return serr
+		}
+		if serr := c.writeOutputManifest(); serr != nil {
+			return serr
+		}
+		summary := NewRunSummary("render", started, []RunResult{*c.result})
+		if serr := writeSummaryFile(c.fs, c.summaryFile, summary); serr != nil {
+			return serr
+		}
+		if serr := writeReports(c.fs, c.reports, summary); serr != nil {
+			return serr
+		}
+		if serr := writeExplanations(c.fs, c.explainSelection, c.explanations); serr != nil {
+			return serr
+		}
+		return err
+	}
+
+	if c.snapshot != "" {
+		return errors.New("--snapshot can only be used when rendering a single composite resource")
+	}
+	if c.bundle != "" {
+		return errors.New("--bundle can only be used when rendering a single composite resource")
 	}
 
-	comp, err := render.LoadComposition(c.fs, c.composition)
+	// Render each XR against the same Composition, grouping its output under a
+	// comment naming the XR file, or in a subdirectory of the output directory.
+	// Every XR is rendered unless --fail-fast is set, or --changed-since finds
+	// the changes can't affect it, then a summary follows.
+	names := make([]string, len(xrs))
+	if c.outputDir != "" || c.dumpIO != "" {
+		if names, err = batchOutputNames(xrs); err != nil {
+			return err
+		}
+	}
+	var scope changeScope
+	if c.changedSince != "" {
+		if scope, err = c.changeScope(args); err != nil {
+			return err
+		}
+		switch {
+		case scope.All != "":
+			infof("Rendering every composite resource: %s since %s", scope.All, c.changedSince)
+		case len(scope.Fields) == 0:
+			infof("The Composition hasn't changed since %s", c.changedSince)
+		default:
+			infof("Only rendering composite resources setting %s, read by the patches changed since %s", strings.Join(scope.Fields, ", "), c.changedSince)
+		}
+	}
+	results := make([]RunResult, len(xrs))
+	failed := 0
+	dumpIO := c.dumpIO
+	for i, xr := range xrs {
+		results[i].Name = xr
+		if c.failFast && failed > 0 {
+			results[i].Skipped = true
+			continue
+		}
+		if c.changedSince != "" {
+			// An XR that can't be loaded is rendered, to report why.
+			if u, err := loadCompositeResource(c.fs, xr); err == nil && !scope.affects(&u.Unstructured) {
+				infof("Skipping composite resource %q: not affected by the changes since %s", xr, c.changedSince)
+				results[i].Skipped = true
+				results[i].SkipReason = fmt.Sprintf("not affected by the changes since %s", c.changedSince)
+				continue
+			}
+		}
+
+		infof("Rendering composite resource %q", xr)
+		name := names[i]
+		if dumpIO != "" {
+			c.dumpIO = filepath.Join(dumpIO, name)
+		}
+		dir := ""
+		if c.outputDir != "" {
+			dir = filepath.Join(c.outputDir, name)
+		} else {
+			_, _ = fmt.Fprintf(os.Stdout, "# Source: %s\n", xr)
+		}
+		start := time.Now()
+		c.result = &results[i]
+		if err := c.renderXR(append([]string{xr}, args[1:]...), dir); err != nil {
+			errorf("%s: %v", xr, err)
+			results[i].Failures = []string{err.Error()}
+			failed++
+		}
+		results[i].Duration = time.Since(start)
+	}
+	_ = PrintRunSummary(os.Stderr, results)
+	if err := c.writeBaselineFile(len(xrs)); err != nil {
+		return err
+	}
+	if err := c.writeSBOM(); err != nil {
+		return err
+	}
+	if err := c.writeOutputManifest(); err != nil {
+		return err
+	}
+	summary := NewRunSummary("render", started, results)
+	if err := writeSummaryFile(c.fs, c.summaryFile, summary); err != nil {
+		return err
+	}
+	if err := writeReports(c.fs, c.reports, summary); err != nil {
+		return err
+	}
+	if err := writeExplanations(c.fs, c.explainSelection, c.explanations); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d composite resources failed", failed, len(xrs))
+	}
+	return nil
+}
+
+// renderXR renders a single XR and runs the enabled checks against it. The
+// output is written to stdout, or to outputDir if it's set.
+func (c *renderCmd) renderXR(args []string, outputDir string) error {
+	if c.explainSelection != "" {
+		c.explain = newSelectionExplanation(args[0])
+	}
+	in, err := c.loadInputs(args)
+	c.recordExplanation(err)
 	if err != nil {
-		return errors.Wrapf(err, "cannot load Composition from %q", c.composition)
+		return err
 	}
 
-	// Validate that Composition's compositeTypeRef matches the XR's GroupVersionKind.
-	xrGVK := xr.GetObjectKind().GroupVersionKind()
-	compRef := comp.Spec.CompositeTypeRef
+	var timings *stepTimings
+	if c.timings != "" {
+		timings = newStepTimings()
+		c.observer = timings
+	}
+	layout := c.layout
+	if outputDir != "" {
+		layout.ResourceSteps = map[string]string{}
+		for _, s := range in.Composition.Spec.Pipeline {
+			layout.Pipeline = append(layout.Pipeline, s.Step)
+		}
+	}
+	c.resourceSteps = layout.ResourceSteps
+	start := time.Now()
+	out, err := c.render(in)
+	if timings != nil {
+		timings.Total = time.Since(start)
+	}
+	if err != nil {
+		if te, ok := asTimeoutError(err); ok && c.timeoutBehavior == TimeoutBehaviorPartial {
+			if werr := c.writePartialOutputs(te, outputDir, layout); werr != nil {
+				return werr
+			}
+		}
+		if _, ok := asFatalResultError(err); ok && c.failOn == FailOnNone {
+			warnf("Not rendering %q: %v", c.compositeResource, err)
+			return nil
+		}
+		return err
+	}
+	c.result.recordRender(in, out)
+	if c.sbom != "" {
+		if c.renderedFunctions == nil {
+			c.renderedFunctions = map[string]pkgv1.Function{}
+		}
+		for _, fn := range in.Functions {
+			c.renderedFunctions[fn.GetName()] = fn
+		}
+	}
 
-	if compRef.Kind != xrGVK.Kind {
-		return errors.Errorf("composition's compositeTypeRef.kind (%s) does not match XR's kind (%s)", compRef.Kind, xrGVK.Kind)
+	if c.dependencyOrder || c.syncWaves {
+		order := ComputeDependencyOrder(out.ComposedResources)
+		if c.syncWaves {
+			order.SetSyncWaves(out.ComposedResources)
+		}
+		if c.dependencyOrder {
+			order.Print(os.Stderr, out.ComposedResources)
+		}
 	}
 
-	if compRef.APIVersion != xrGVK.GroupVersion().String() {
-		return errors.Errorf("composition's compositeTypeRef.apiVersion (%s) does not match XR's apiVersion (%s)", compRef.APIVersion, xrGVK.GroupVersion().String())
+	if c.includeFullXR {
+		if err := includeFullXR(in, out); err != nil {
+			return err
+		}
 	}
 
-	// check if XR's matchLabels have corresponding label at composition
-	xrSelector := xr.GetCompositionSelector()
-	if xrSelector != nil {
-		for key, value := range xrSelector.MatchLabels {
-			compValue, exists := comp.Labels[key]
-			if !exists {
-				return fmt.Errorf("composition %q is missing required label %q", comp.GetName(), key)
+	if outputDir != "" {
+		files, err := writeOutputDir(c.fs, outputDir, out, c.includeFunctionResults, c.includeContext, layout)
+		if err != nil {
+			return err
+		}
+		if c.xrd != nil {
+			b := &strings.Builder{}
+			if err := writeXRD(b, c.xrd); err != nil {
+				return err
+			}
+			if err := afero.WriteFile(c.fs, filepath.Join(outputDir, BundleFileXRD), []byte(b.String()), 0644); err != nil {
+				return errors.Wrapf(err, "cannot write %q", filepath.Join(outputDir, BundleFileXRD))
+			}
+			files = append([]OutputFile{{Path: BundleFileXRD, APIVersion: c.xrd.GetAPIVersion(), Kind: c.xrd.GetKind(), Name: c.xrd.GetName()}}, files...)
+		}
+		inputs := OutputInputs{
+			XR:                c.compositeResource,
+			Composition:       in.Composition.GetName(),
+			CompositionFile:   c.composition,
+			Functions:         c.functions,
+			Profile:           c.profile,
+			ObservedResources: c.observedResources,
+			ExtraResources:    c.extraResources,
+		}
+		for _, f := range files {
+			if rel, err := filepath.Rel(c.outputDir, filepath.Join(outputDir, f.Path)); err == nil {
+				f.Path = filepath.ToSlash(rel)
 			}
-			if compValue != value {
-				return fmt.Errorf("composition %q has incorrect value for label %q: want %q, got %q",
-					comp.GetName(), key, value, compValue)
+			f.Inputs = inputs
+			c.outputFiles = append(c.outputFiles, f)
+		}
+	} else {
+		if c.xrd != nil {
+			if err := writeXRD(os.Stdout, c.xrd); err != nil {
+				return err
 			}
 		}
+		if err := writeOutputs(os.Stdout, out, c.includeFunctionResults, c.includeContext); err != nil {
+			return err
+		}
+	}
+	if c.bundle != "" {
+		xrd, err := c.bundleXRD(in.Composition)
+		if err != nil {
+			return err
+		}
+		if err := writeBundle(c.fs, c.bundle, xrd, in, c.compositeResource); err != nil {
+			return err
+		}
+	}
+	if timings != nil {
+		if err := c.writeTimings(timings, in.Composition, os.Stdout, os.Stderr, outputDir); err != nil {
+			return err
+		}
 	}
 
-	if comp.Spec.Mode != apiextensionsv1.CompositionModePipeline {
-		return errors.Errorf("render only supports Composition Function pipelines: Composition %q must use spec.mode: Pipeline", comp.GetName())
+	if c.footprint {
+		if err := ComputeFootprint(out.ComposedResources).Print(os.Stderr); err != nil {
+			return errors.Wrap(err, "cannot print resource footprint")
+		}
+	}
+
+	if c.snapshot != "" {
+		diff, err := checkSnapshot(c.fs, c.snapshot, out, c.updateSnapshots)
+		if err != nil {
+			return err
+		}
+		if diff != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "Rendered output differs from snapshot %q:\n%s", c.snapshot, diff)
+			return errors.Errorf("rendered output differs from snapshot %q (run with --update-snapshots to accept the changes)", c.snapshot)
+		}
+	}
+
+	findings, err := c.check(in, out)
+	if err != nil {
+		return err
+	}
+	c.locateFindings(in.Composition, findings)
+	findings, err = c.applyBaseline(findings)
+	if err != nil {
+		return err
+	}
+	c.checkedXRs++
+	missing := MissingInputs(in, out, c.extraFromCluster)
+	if c.result != nil {
+		c.result.Findings = findings
+		c.result.MissingInputs = missing
+	}
+	printFindings(os.Stderr, findings)
+	if c.missingInputs {
+		printMissingInputs(os.Stderr, missing)
+	}
+	return findingsError(findings)
+}
+
+// applyBaseline returns the findings of the XR being rendered that aren't
+// in the --baseline. If --write-baseline is set it instead collects them, to
+// be written by writeBaselineFile once every XR is rendered, and returns none.
+// Failed --assert expressions are never grandfathered: they're always
+// returned, and never written to the baseline.
+func (c *renderCmd) applyBaseline(all []Finding) ([]Finding, error) {
+	findings, asserted := []Finding{}, []Finding{}
+	for _, f := range all {
+		if f.Check == checkAssertions {
+			asserted = append(asserted, f)
+			continue
+		}
+		findings = append(findings, f)
+	}
+
+	if c.writeBaseline {
+		c.baselineFindings = append(c.baselineFindings, findings...)
+		return asserted, nil
+	}
+	if c.knownFindings == nil {
+		b, err := loadBaseline(c.fs, c.baseline)
+		if err != nil {
+			return nil, err
+		}
+		c.knownFindings = b
+	}
+	remaining, suppressed := c.knownFindings.Filter(findings)
+	if suppressed > 0 {
+		infof("%d finding(s) suppressed by baseline %s", suppressed, c.baseline)
+	}
+	return append(remaining, asserted...), nil
+}
+
+// writeBaselineFile writes the findings of every XR rendered as the
+// --baseline, if --write-baseline is set. It isn't written unless all the
+// supplied number of XRs were rendered and checked, since the baseline would
+// drop the findings of the others.
+func (c *renderCmd) writeBaselineFile(xrs int) error {
+	if !c.writeBaseline {
+		return nil
+	}
+	if n := xrs - c.checkedXRs; n > 0 {
+		return errors.Errorf("not writing baseline %s: %d composite resource(s) failed to render", c.baseline, n)
+	}
+	if err := writeBaseline(c.fs, c.baseline, c.baselineFindings); err != nil {
+		return err
+	}
+	infof("Wrote %d finding(s) to baseline %s", len(c.baselineFindings), c.baseline)
+	return nil
+}
+
+// recordExplanation records the --explain-selection explanation of the XR
+// being rendered, with the supplied error that stopped it being rendered.
+func (c *renderCmd) recordExplanation(err error) {
+	if c.explain == nil {
+		return
+	}
+	if err != nil {
+		c.explain.Error = err.Error()
+	}
+	c.explanations = append(c.explanations, *c.explain)
+	c.explain = nil
+}
+
+// includeFullXR copies the spec and metadata of the input XR to the rendered
+// XR.
+func includeFullXR(in render.Inputs, out render.Outputs) error {
+	xr := in.CompositeResource
+	xrSpec, err := fieldpath.Pave(xr.Object).GetValue("spec")
+	if err != nil {
+		return errors.Wrapf(err, "cannot get composite resource spec")
+	}
+
+	if err := fieldpath.Pave(out.CompositeResource.Object).SetValue("spec", xrSpec); err != nil {
+		return errors.Wrapf(err, "cannot set composite resource spec")
+	}
+
+	xrMeta, err := fieldpath.Pave(xr.Object).GetValue("metadata")
+	if err != nil {
+		return errors.Wrapf(err, "cannot get composite resource metadata")
+	}
+
+	if err := fieldpath.Pave(out.CompositeResource.Object).SetValue("metadata", xrMeta); err != nil {
+		return errors.Wrapf(err, "cannot set composite resource metadata")
+	}
+	return nil
+}
+
+// writeOutputManifest writes the manifest of the files every render wrote to
+// the --output-dir, if set.
+func (c *renderCmd) writeOutputManifest() error {
+	if c.outputDir == "" || len(c.outputFiles) == 0 {
+		return nil
+	}
+	return writeOutputManifest(c.fs, c.outputDir, c.outputFiles)
+}
+
+// writeSBOM writes an SBOM of the Function images used by every render to
+// the --sbom file, if set.
+func (c *renderCmd) writeSBOM() error {
+	if c.sbom == "" || len(c.renderedFunctions) == 0 {
+		return nil
+	}
+	fns := make([]pkgv1.Function, 0, len(c.renderedFunctions))
+	for _, fn := range c.renderedFunctions {
+		fns = append(fns, fn)
+	}
+	if err := writeSBOMFile(c.fs, c.sbom, c.sbomFormat, c.sbomMerge, fns); err != nil {
+		return err
+	}
+	infof("Wrote SBOM of %d function image(s) to %s", len(fns), c.sbom)
+	return nil
+}
+
+// check runs the enabled checks against the rendered output.
+func (c *renderCmd) check(in render.Inputs, out render.Outputs) ([]Finding, error) {
+	findings := CheckFunctionResults(out.Results, c.failOn)
+	if len(c.requiredLabels) > 0 || len(c.requiredAnnotations) > 0 {
+		findings = append(findings, AuditPropagation(in.CompositeResource, out.ComposedResources, c.requiredLabels, c.requiredAnnotations)...)
+	}
+	if c.checkReferences {
+		findings = append(findings, CheckReferences(out.ComposedResources)...)
+	}
+	if c.checkMetadata {
+		findings = append(findings, CheckMetadata(&out.CompositeResource.Unstructured, out.ComposedResources)...)
+	}
+	if c.apiUpgrades != "" {
+		m, err := loadAPIUpgradeMap(c.fs, c.apiUpgrades)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, CheckAPIUpgrades(m, out.ComposedResources)...)
+	}
+	if len(c.cfg.Naming) > 0 {
+		nf, err := CheckNaming(c.cfg.Naming, out.ComposedResources)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot check naming rules")
+		}
+		findings = append(findings, nf...)
+	}
+	if len(c.cfg.EnvironmentLiterals) > 0 {
+		lf, err := CheckEnvironmentLiterals(c.cfg.EnvironmentLiterals, in.Composition)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot check environment literals")
+		}
+		findings = append(findings, lf...)
+	}
+	if c.crossplaneVersion != "" {
+		vf, err := c.checkCrossplaneVersion(c.context(), in)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, vf...)
+	}
+	if c.dryRunValidate {
+		df, err := c.checkAdmission(in, out)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, df...)
+	}
+	if len(c.compiledAssertions) > 0 {
+		findings = append(findings, CheckAssertions(c.compiledAssertions, out)...)
+	}
+	if len(c.kyvernoPolicies) > 0 {
+		kf, err := c.checkKyverno(out)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, kf...)
+	}
+	if len(c.cfg.Rules) > 0 {
+		rf, err := c.evaluateRules(in, out)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, rf...)
+	}
+	return findings, nil
+}
+
+// checkKyverno evaluates the Kyverno policies against the rendered XR and
+// composed resources, printing the result of every rule.
+func (c *renderCmd) checkKyverno(out render.Outputs) ([]Finding, error) {
+	policies, err := loadKyvernoPolicies(c.fs, c.kyvernoPolicies)
+	if err != nil {
+		return nil, err
+	}
+	resources := []*unstructured.Unstructured{&out.CompositeResource.Unstructured}
+	for i := range out.ComposedResources {
+		resources = append(resources, &out.ComposedResources[i].Unstructured)
+	}
+	results, err := EvaluateKyvernoPolicies(policies, resources)
+	if err != nil {
+		return nil, err
+	}
+	if err := PrintKyvernoResults(os.Stderr, results); err != nil {
+		return nil, err
+	}
+	return CheckKyverno(results), nil
+}
+
+// locateFindings sets the file of the supplied findings that aren't located
+// in one to the file the supplied Composition was loaded from, if any, since
+// that's what findings about a render can be fixed in.
+func (c *renderCmd) locateFindings(comp *apiextensionsv1.Composition, findings []Finding) {
+	file := ""
+	switch {
+	case c.compositionsDir != "":
+		file = compositionFile(c.fs, c.compositionsDir, comp.GetName())
+	case c.composition == "" || isPackageSource(c.fs, c.composition):
+	default:
+		if _, _, ok := clusterObjectRef(c.fs, c.composition); !ok {
+			file = c.composition
+		}
+	}
+	for i := range findings {
+		if findings[i].File == "" {
+			findings[i].File = file
+		}
+	}
+}
+
+// evaluateRules evaluates the custom rules against the rendered XR and
+// composed resources, the Composition and any XRDs from its package.
+func (c *renderCmd) evaluateRules(in render.Inputs, out render.Outputs) ([]Finding, error) {
+	rules, err := compileRules(c.cfg.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	comp, err := runtime.DefaultUnstructuredConverter.ToUnstructured(in.Composition)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot convert Composition for rules")
+	}
+
+	xr := &out.CompositeResource.Unstructured
+	cds := make([]*unstructured.Unstructured, len(out.ComposedResources))
+	for i := range out.ComposedResources {
+		cds[i] = &out.ComposedResources[i].Unstructured
+	}
+
+	findings := EvaluateRules(rules, RuleTargetComposed, cds, xr)
+	findings = append(findings, EvaluateRules(rules, RuleTargetXR, []*unstructured.Unstructured{xr}, xr)...)
+	findings = append(findings, EvaluateRules(rules, RuleTargetComposition, []*unstructured.Unstructured{{Object: comp}}, xr)...)
+	findings = append(findings, EvaluateRules(rules, RuleTargetXRD, c.packageXRDs, xr)...)
+	return findings, nil
+}
+
+// writeOutputs writes the rendered XR and composed resources, and optionally
+// the Function results and context, as a YAML stream.
+func writeOutputs(w io.Writer, out render.Outputs, includeResults, includeContext bool) error {
+	s := json.NewSerializerWithOptions(json.DefaultMetaFactory, nil, nil, json.SerializerOptions{Yaml: true})
+
+	_, _ = fmt.Fprintln(w, "---")
+	if err := s.Encode(out.CompositeResource, w); err != nil {
+		return errors.Wrapf(err, "cannot marshal composite resource %q to YAML", out.CompositeResource.GetName())
+	}
+
+	for i := range out.ComposedResources {
+		_, _ = fmt.Fprintln(w, "---")
+		if err := s.Encode(&out.ComposedResources[i], w); err != nil {
+			return errors.Wrapf(err, "cannot marshal composed resource %q to YAML", out.ComposedResources[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName])
+		}
+	}
+
+	if includeResults {
+		for i := range out.Results {
+			_, _ = fmt.Fprintln(w, "---")
+			if err := s.Encode(&out.Results[i], w); err != nil {
+				return errors.Wrap(err, "cannot marshal result to YAML")
+			}
+		}
+	}
+
+	if includeContext {
+		_, _ = fmt.Fprintln(w, "---")
+		if err := s.Encode(out.Context, w); err != nil {
+			return errors.Wrap(err, "cannot marshal context to YAML")
+		}
+	}
+
+	return nil
+}
+
+// loadConfig loads the configuration file named by the --config flag.
+func (c *renderCmd) loadConfig(cmd *cobra.Command) error {
+	cfg, err := loadConfig(c.fs, c.config, cmd.Flags().Changed("config"))
+	if err != nil {
+		return err
+	}
+	c.cfg = cfg
+	c.mode = cmd.Name()
+
+	creds, err := newGitHubCredentialChain(c.githubAuthMode, c.githubToken, cfg.GitHub)
+	if err != nil {
+		return err
+	}
+	gitHubCredentials = creds
+	offline = c.offline
+	if c.runtime == ContainerRuntimeKubernetes {
+		return nil
+	}
+	if err := setDockerHost(c.dockerHost); err != nil {
+		return err
+	}
+	if err := setContainerRuntime(c.runtime); err != nil {
+		return err
+	}
+	return setFunctionResolution(cfg.Functions)
+}
+
+// loadInputs loads the XR, Composition, Functions and optional pipeline
+// inputs named by the supplied arguments and flags, and checks that the
+// Composition can be used to render the XR.
+func (c *renderCmd) loadInputs(args []string) (_ render.Inputs, err error) {
+	ctx, span := tracer.Start(c.context(), "load inputs")
+	defer func() {
+		recordSpanError(span, err)
+		span.End()
+	}()
+
+	c.compositeResource = args[0]
+	if c.observedFromCluster && c.observedResources != "" {
+		return render.Inputs{}, errors.New("--observed-from-cluster can't be used with --observed-resources")
+	}
+	observedResources, extraResources := c.observedResources, c.extraResources
+	compositionSource, observedSource, extraSource := "argument", "--observed-resources", "--extra-resources"
+	_, _, xrInCluster := clusterObjectRef(c.fs, args[0])
+	if len(args) == 1 && c.compositionsDir == "" && !xrInCluster {
+		// Look for the inputs of an XR passed on its own next to it.
+		sib, err := findSiblings(c.fs, args[0], c.cfg.Siblings)
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		if sib.Composition != "" {
+			compositionSource = "next to the XR"
+			args = append(args[:1:1], sib.Composition)
+			c.functions = sib.Functions
+			if sib.Functions != "" {
+				args = append(args, sib.Functions)
+			}
+		}
+		if observedResources == "" && !c.observedFromCluster {
+			observedResources, observedSource = sib.ObservedResources, "next to the XR"
+		}
+		if extraResources == "" {
+			extraResources, extraSource = sib.ExtraResources, "next to the XR"
+		}
+	}
+	if len(args) < 2 && c.compositionsDir == "" && c.interactive {
+		// Select one of the Compositions in the current directory.
+		c.compositionsDir = "."
+	}
+	if c.compositionsDir != "" {
+		// The Composition is discovered, so the remaining argument is the
+		// optional Functions.
+		if len(args) > 2 {
+			return render.Inputs{}, errors.New("the composition argument can't be used with --compositions-dir")
+		}
+		if len(args) > 1 {
+			c.functions = args[1]
+		}
+	} else {
+		if len(args) < 2 {
+			return render.Inputs{}, errors.Errorf("a composition argument or --compositions-dir is required, or a composition next to %q", args[0])
+		}
+		c.composition = args[1]
+		if len(args) > 2 {
+			c.functions = args[2]
+		}
+	}
+
+	e := c.explain
+	e.input("composite-resource", c.compositeResource, "argument")
+
+	if c.strictDecode {
+		if err := e.check("strict-decode", c.strictDecodeInputs()); err != nil {
+			return render.Inputs{}, err
+		}
+	}
+
+	xr, err := c.loadCompositeResource(ctx)
+	if err != nil {
+		return render.Inputs{}, err
+	}
+
+	var comp *apiextensionsv1.Composition
+	var pkg *configurationPackage
+	switch {
+	case c.compositionsDir != "":
+		comps, err := loadCompositions(c.fs, c.compositionsDir)
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		if gvk, ok := compositeForClaimIn(xr, comps); ok {
+			if xr, err = c.resolveClaim(xr, gvk); err != nil {
+				return render.Inputs{}, err
+			}
+		}
+		comp, err = selectComposition(xr, comps, c.compositionSelector, fmt.Sprintf("directory %q", c.compositionsDir))
+		if candidates := compositionsFor(xr, comps); err != nil && c.interactive && len(candidates) > 0 {
+			p, perr := newPrompter("--interactive")
+			if perr != nil {
+				return render.Inputs{}, errors.Wrap(perr, err.Error())
+			}
+			comp, err = p.chooseComposition(err, candidates)
+		}
+		e.input("compositions", c.compositionsDir, "--compositions-dir")
+		e.compositionSelection(xr, comps, c.compositionSelector, fmt.Sprintf("directory %q", c.compositionsDir), comp)
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		infof("Selected Composition %q from %q", comp.GetName(), c.compositionsDir)
+	case isPackageSource(c.fs, c.composition):
+		pkg, err = loadConfigurationPackage(c.fs, c.composition)
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		if gvk, ok := xrdCompositeForClaim(xr, pkg.XRDs); ok {
+			if xr, err = c.resolveClaim(xr, gvk); err != nil {
+				return render.Inputs{}, err
+			}
+		}
+		comp, err = pkg.CompositionFor(xr)
+		e.input("composition", c.composition, compositionSource)
+		e.compositionSelection(xr, pkg.Compositions, nil, fmt.Sprintf("package %q", c.composition), comp)
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		c.packageXRDs = pkg.XRDs
+		c.packageMeta = pkg.Meta
+		infof("Using Composition %q from package %q", comp.GetName(), c.composition)
+	default:
+		comp, err = c.loadComposition(ctx)
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		reason := "passed as an argument"
+		if compositionSource != "argument" {
+			reason = "found next to the XR"
+		}
+		source := fmt.Sprintf("file %q", c.composition)
+		if _, _, ok := clusterObjectRef(c.fs, c.composition); ok {
+			source = fmt.Sprintf("cluster resource %q", c.composition)
+		}
+		e.input("composition", c.composition, compositionSource)
+		e.compositionSelected(comp, source, reason)
+		if gvk, ok := compositeForClaim(xr, comp); ok {
+			if xr, err = c.resolveClaim(xr, gvk); err != nil {
+				return render.Inputs{}, err
+			}
+		}
+	}
+
+	if err := e.check("composite-type-ref", checkCompositeTypeRef(xr, comp)); err != nil {
+		return render.Inputs{}, err
+	}
+	if xr.GetCompositionSelector() != nil {
+		if err := e.check("composition-selector", checkCompositionLabels(xr, comp)); err != nil {
+			return render.Inputs{}, err
+		}
+	}
+	if err := e.check("pipeline-mode", checkPipelineMode(comp)); err != nil {
+		return render.Inputs{}, err
+	}
+	selected := comp
+	if len(c.skipSteps) > 0 || len(c.onlySteps) > 0 {
+		if comp, err = filterSteps(comp, c.skipSteps, c.onlySteps); err != nil {
+			return render.Inputs{}, err
+		}
+	}
+	e.inputFiles("step-input", c.stepInputs, "--step-input for step")
+	if len(c.stepInputs) > 0 {
+		if comp, err = overrideStepInputs(c.fs, comp, c.stepInputs, c.stepInputMode); err != nil {
+			return render.Inputs{}, err
+		}
 	}
 
 	// Load functions - either from file or extract from composition
+	e.input("functions", c.functions, compositionSource)
 	var fns []pkgv1.Function
 	if c.functions != "" {
 		// Load functions from file
 		fns, err = render.LoadFunctions(c.fs, c.functions)
 		if err != nil {
-			return errors.Wrapf(err, "cannot load functions from %q", c.functions)
+			return render.Inputs{}, errors.Wrapf(err, "cannot load functions from %q", c.functions)
+		}
+		for _, fn := range fns {
+			e.functionSource(fn.GetName(), fmt.Sprintf("functions file %q", c.functions))
+		}
+		if len(c.skipSteps) > 0 || len(c.onlySteps) > 0 {
+			// Don't start the Functions of skipped steps.
+			fns = pipelineFunctions(comp, fns)
 		}
 	} else {
-		// Extract functions from composition
-		fns, err = ExtractFunctionsFromComposition(comp, c.fs, c.refreshCache)
+		fns, err = c.resolveFunctions(ctx, comp, pkg)
 		if err != nil {
-			return errors.Wrapf(err, "cannot extract functions from composition")
+			return render.Inputs{}, err
 		}
-		_, _ = fmt.Fprintf(os.Stderr, "INFO: Extracted %d function(s) from composition pipeline\n", len(fns))
-		for _, fn := range fns {
-			_, _ = fmt.Fprintf(os.Stderr, "INFO: Using function %q with package %q\n", fn.GetName(), fn.Spec.Package)
+	}
+
+	resolved := make([]pkgv1.Function, len(fns))
+	for i := range fns {
+		fns[i].DeepCopyInto(&resolved[i])
+	}
+	if err := c.overrideFunctions(fns); err != nil {
+		return render.Inputs{}, err
+	}
+	if c.networkDisabled() {
+		neverPullFunctions(fns)
+	}
+	e.functions(selected, comp, resolved, fns)
+	if c.offline && c.runtime != ContainerRuntimeKubernetes {
+		if err := e.check("local-images", requireLocalImages(fns)); err != nil {
+			return render.Inputs{}, err
+		}
+	}
+	if c.scanner != "" {
+		if err := e.check("image-scan", scanFunctionImages(c.scanner, c.failOnSeverity, fns)); err != nil {
+			return render.Inputs{}, err
 		}
 	}
 
 	fcreds := []corev1.Secret{}
 	if c.functionCredentials != "" {
-		fcreds, err = render.LoadCredentials(c.fs, c.functionCredentials)
+		path, err := profileCredentialsPath(c.fs, c.functionCredentials, c.profile)
 		if err != nil {
-			return errors.Wrapf(err, "cannot load secrets from %q", c.functionCredentials)
+			return render.Inputs{}, err
 		}
+		fcreds, err = render.LoadCredentials(c.fs, path)
+		if err != nil {
+			return render.Inputs{}, errors.Wrapf(err, "cannot load secrets from %q", path)
+		}
+		e.input("function-credentials", path, "--function-credentials")
+	}
+
+	// Fail before running any Function if a step needs credentials we don't
+	// have, rather than at the first step that needs them.
+	missing := append(UnsatisfiedCredentialRequirements(comp, fns), MissingCredentials(comp, fcreds)...)
+	if len(missing) > 0 {
+		return render.Inputs{}, e.check("function-credentials", errors.Errorf("missing function credentials:\n  %s", strings.Join(missing, "\n  ")))
 	}
+	_ = e.check("function-credentials", nil)
 
 	ors := []composed.Unstructured{}
-	if c.observedResources != "" {
-		ors, err = render.LoadObservedResources(c.fs, c.observedResources)
+	switch {
+	case c.observedFromCluster:
+		if ors, err = c.fetchObservedResources(ctx, xr); err != nil {
+			return render.Inputs{}, err
+		}
+		e.input("observed-resources", "cluster", "--observed-from-cluster")
+	case observedResources != "":
+		ors, err = render.LoadObservedResources(c.fs, observedResources)
 		if err != nil {
-			return errors.Wrapf(err, "cannot load observed composed resources from %q", c.observedResources)
+			return render.Inputs{}, errors.Wrapf(err, "cannot load observed composed resources from %q", observedResources)
 		}
+		e.input("observed-resources", observedResources, observedSource)
+	}
+	if ors, err = simulateDeletion(ors, c.deleting, c.absent); err != nil {
+		return render.Inputs{}, err
 	}
 
 	ers := []unstructured.Unstructured{}
-	if c.extraResources != "" {
-		ers, err = render.LoadRequiredResources(c.fs, c.extraResources)
+	if extraResources != "" {
+		ers, err = render.LoadRequiredResources(c.fs, extraResources)
+		if err != nil {
+			return render.Inputs{}, errors.Wrapf(err, "cannot load extra resources from %q", extraResources)
+		}
+		e.input("extra-resources", extraResources, extraSource)
+	}
+
+	if _, ok := c.contextFiles[contextKeyEnvironment]; !ok && c.interactive && c.contextValues[contextKeyEnvironment] == "" && readsEnvironment(comp) {
+		p, err := newPrompter("--interactive")
+		if err != nil {
+			return render.Inputs{}, err
+		}
+		v, err := p.askJSON(fmt.Sprintf("Composition %q patches from the environment, but no %s context value is set. Enter it as JSON, or nothing to render without it", comp.GetName(), contextKeyEnvironment))
 		if err != nil {
-			return errors.Wrapf(err, "cannot load extra resources from %q", c.extraResources)
+			return render.Inputs{}, err
+		}
+		if v != "" {
+			if c.contextValues == nil {
+				c.contextValues = map[string]string{}
+			}
+			c.contextValues[contextKeyEnvironment] = v
 		}
 	}
 
@@ -208,18 +1335,28 @@ func (c *renderCmd) run(cmd *cobra.Command, args []string) error {
 	for k, filename := range c.contextFiles {
 		v, err := afero.ReadFile(c.fs, filename)
 		if err != nil {
-			return errors.Wrapf(err, "cannot read context value for key %q", k)
+			return render.Inputs{}, errors.Wrapf(err, "cannot read context value for key %q", k)
 		}
 		fctx[k] = v
 	}
+	e.inputFiles("context", c.contextFiles, "--context-files key")
 	for k, v := range c.contextValues {
 		fctx[k] = []byte(v)
 	}
+	schemas, err := contextSchemas(c.cfg.ContextSchemas, comp)
+	if err != nil {
+		return render.Inputs{}, err
+	}
+	if err := e.check("context-schemas", checkContext(schemas, fctx)); err != nil {
+		return render.Inputs{}, err
+	}
+	if c.injectsMeta() {
+		if fctx[contextKeyMeta], err = c.metaContextValue(); err != nil {
+			return render.Inputs{}, err
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
-	defer cancel()
-
-	out, err := render.Render(ctx, log, render.Inputs{
+	return render.Inputs{
 		CompositeResource:   xr,
 		Composition:         comp,
 		Functions:           fns,
@@ -227,61 +1364,415 @@ func (c *renderCmd) run(cmd *cobra.Command, args []string) error {
 		ObservedResources:   ors,
 		ExtraResources:      ers,
 		Context:             fctx,
-	})
-	if err != nil {
-		return errors.Wrap(err, "cannot render composite resource")
+	}, nil
+}
+
+// checkCompositeTypeRef returns an error if the supplied Composition isn't for
+// the supplied XR's kind.
+func checkCompositeTypeRef(xr *composite.Unstructured, comp *apiextensionsv1.Composition) error {
+	xrGVK := xr.GetObjectKind().GroupVersionKind()
+	compRef := comp.Spec.CompositeTypeRef
+
+	if compRef.Kind != xrGVK.Kind {
+		return errors.Errorf("composition's compositeTypeRef.kind (%s) does not match XR's kind (%s)", compRef.Kind, xrGVK.Kind)
 	}
 
-	s := json.NewSerializerWithOptions(json.DefaultMetaFactory, nil, nil, json.SerializerOptions{Yaml: true})
+	if compRef.APIVersion != xrGVK.GroupVersion().String() {
+		return errors.Errorf("composition's compositeTypeRef.apiVersion (%s) does not match XR's apiVersion (%s)", compRef.APIVersion, xrGVK.GroupVersion().String())
+	}
+	return nil
+}
 
-	if c.includeFullXR {
-		xrSpec, err := fieldpath.Pave(xr.Object).GetValue("spec")
-		if err != nil {
-			return errors.Wrapf(err, "cannot get composite resource spec")
+// checkCompositionLabels returns an error if the supplied Composition doesn't
+// have the labels the supplied XR's composition selector matches.
+func checkCompositionLabels(xr *composite.Unstructured, comp *apiextensionsv1.Composition) error {
+	xrSelector := xr.GetCompositionSelector()
+	if xrSelector == nil {
+		return nil
+	}
+	for key, value := range xrSelector.MatchLabels {
+		compValue, exists := comp.Labels[key]
+		if !exists {
+			return fmt.Errorf("composition %q is missing required label %q", comp.GetName(), key)
+		}
+		if compValue != value {
+			return fmt.Errorf("composition %q has incorrect value for label %q: want %q, got %q",
+				comp.GetName(), key, value, compValue)
 		}
+	}
+	return nil
+}
+
+// checkPipelineMode returns an error if the supplied Composition doesn't use
+// a Function pipeline.
+func checkPipelineMode(comp *apiextensionsv1.Composition) error {
+	if comp.Spec.Mode != apiextensionsv1.CompositionModePipeline {
+		return errors.Errorf("render only supports Composition Function pipelines: Composition %q must use spec.mode: Pipeline", comp.GetName())
+	}
+	return nil
+}
+
+// resolveFunctions extracts the Functions from the supplied Composition's
+// pipeline, preferring those pinned in the lock file, and pins them to the
+// versions the optional Configuration package depends on.
+func (c *renderCmd) resolveFunctions(ctx context.Context, comp *apiextensionsv1.Composition, pkg *configurationPackage) (_ []pkgv1.Function, err error) {
+	_, span := tracer.Start(ctx, "resolve functions", trace.WithAttributes(attribute.String("crossbench.composition", comp.GetName())))
+	defer func() {
+		recordSpanError(span, err)
+		span.End()
+	}()
 
-		if err := fieldpath.Pave(out.CompositeResource.Object).SetValue("spec", xrSpec); err != nil {
-			return errors.Wrapf(err, "cannot set composite resource spec")
+	if c.crossplaneLockSource != "" {
+		xl, err := c.crossplaneLock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		fns, unlocked, err := lockedFunctions(comp, lockFromCrossplane(comp, xl), c.fs, c.refreshCache)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot extract functions from composition")
+		}
+		if len(unlocked) > 0 {
+			warnf("Function(s) %s are not in %s, using their latest versions", strings.Join(unlocked, ", "), describeCrossplaneLock(c.crossplaneLockSource))
 		}
+		for _, fn := range fns {
+			infof("Using function %q with package %q", fn.GetName(), fn.Spec.Package)
+			c.explain.functionSource(fn.GetName(), describeCrossplaneLock(c.crossplaneLockSource))
+		}
+		for _, n := range unlocked {
+			c.explain.functionSource(n, "latest release, not in "+describeCrossplaneLock(c.crossplaneLockSource))
+		}
+		return fns, nil
+	}
 
-		xrMeta, err := fieldpath.Pave(xr.Object).GetValue("metadata")
+	lock, err := loadLock(c.fs, c.lockFile)
+	if err != nil {
+		return nil, err
+	}
+	var fns []pkgv1.Function
+	if lock != nil {
+		var unlocked []string
+		fns, unlocked, err = lockedFunctions(comp, lock, c.fs, c.refreshCache)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot extract functions from composition")
+		}
+		if len(unlocked) > 0 {
+			warnf("Function(s) %s are not in lock file %s, run crossbench lock to add them", strings.Join(unlocked, ", "), c.lockFile)
+		}
+		for _, fn := range fns {
+			c.explain.functionSource(fn.GetName(), fmt.Sprintf("lock file %q", c.lockFile))
+		}
+		for _, n := range unlocked {
+			c.explain.functionSource(n, fmt.Sprintf("latest release, not in lock file %q", c.lockFile))
+		}
+	} else {
+		fns, err = ExtractFunctionsFromComposition(comp, c.fs, c.refreshCache)
 		if err != nil {
-			return errors.Wrapf(err, "cannot get composite resource metadata")
+			return nil, errors.Wrapf(err, "cannot extract functions from composition")
 		}
+		for _, fn := range fns {
+			c.explain.functionSource(fn.GetName(), "latest release")
+		}
+	}
+	if pkg != nil {
+		packages := make([]string, len(fns))
+		for i := range fns {
+			packages[i] = fns[i].Spec.Package
+		}
+		pkg.PinFunctions(fns)
+		for i := range fns {
+			if fns[i].Spec.Package != packages[i] {
+				c.explain.functionSource(fns[i].GetName(), fmt.Sprintf("dependency of package %q", c.composition))
+			}
+		}
+	}
+	infof("Extracted %d function(s) from composition pipeline", len(fns))
+	for _, fn := range fns {
+		infof("Using function %q with package %q", fn.GetName(), fn.Spec.Package)
+		span.AddEvent("resolved function", trace.WithAttributes(attribute.String("crossbench.function", fn.GetName()), attribute.String("crossbench.package", fn.Spec.Package)))
+	}
+	return fns, nil
+}
 
-		if err := fieldpath.Pave(out.CompositeResource.Object).SetValue("metadata", xrMeta); err != nil {
-			return errors.Wrapf(err, "cannot set composite resource metadata")
+// overrideFunctions pins the supplied Functions to digests, and applies the
+// image and runtime overrides, as configured.
+func (c *renderCmd) overrideFunctions(fns []pkgv1.Function) error {
+	if c.pinDigests {
+		if err := pinFunctionDigests(fns, c.cfg.Functions); err != nil {
+			return err
+		}
+	}
+	applyFunctionOverrides(fns, c.cfg.Functions)
+	if c.runtimeOverrides != "" {
+		o, err := loadRuntimeOverrides(c.fs, c.runtimeOverrides)
+		if err != nil {
+			return err
 		}
+		o.apply(fns)
 	}
+	return nil
+}
 
-	_, _ = fmt.Fprintln(os.Stdout, "---")
-	if err := s.Encode(out.CompositeResource, os.Stdout); err != nil {
-		return errors.Wrapf(err, "cannot marshal composite resource %q to YAML", xr.GetName())
+// batchOutputNames returns the name of the subdirectory the output of each
+// XR file of a batch is written to: its path relative to the directory all of
+// them are in, without extension. XRs matched by examples/*/xr.yaml are
+// written to aws/xr, gcp/xr and so on. It returns an error if two XRs would
+// be written to the same subdirectory, e.g. xr.yaml and xr.yml.
+func batchOutputNames(xrs []string) ([]string, error) {
+	sep := string(filepath.Separator)
+	paths := make([][]string, len(xrs))
+	root := -1
+	for i, xr := range xrs {
+		paths[i] = strings.Split(filepath.Clean(xr), sep)
+		dir := paths[i][:len(paths[i])-1]
+		if root < 0 || root > len(dir) {
+			root = len(dir)
+		}
+		for n := range root {
+			if dir[n] != paths[0][n] {
+				root = n
+				break
+			}
+		}
 	}
 
-	for i := range out.ComposedResources {
-		_, _ = fmt.Fprintln(os.Stdout, "---")
-		if err := s.Encode(&out.ComposedResources[i], os.Stdout); err != nil {
-			return errors.Wrapf(err, "cannot marshal composed resource %q to YAML", out.ComposedResources[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName])
+	names := make([]string, len(xrs))
+	seen := map[string]string{}
+	for i, xr := range xrs {
+		name := filepath.Join(paths[i][root:]...)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		if other, ok := seen[name]; ok {
+			return nil, errors.Errorf("composite resources %q and %q would both be written to %q", other, xr, name)
 		}
+		seen[name] = xr
+		names[i] = name
 	}
+	return names, nil
+}
 
-	if c.includeFunctionResults {
-		for i := range out.Results {
-			_, _ = fmt.Fprintln(os.Stdout, "---")
-			if err := s.Encode(&out.Results[i], os.Stdout); err != nil {
-				return errors.Wrap(err, "cannot marshal result to YAML")
+// expandCompositeResourcePaths returns the XR files named by the supplied
+// argument, which may be a single file, a directory of YAML files, or a glob.
+func expandCompositeResourcePaths(fs afero.Fs, arg string) ([]string, error) {
+	if info, err := fs.Stat(arg); err == nil {
+		if !info.IsDir() {
+			return []string{arg}, nil
+		}
+		paths := []string{}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := afero.Glob(fs, filepath.Join(arg, pattern))
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot list composite resources in %q", arg)
 			}
+			paths = append(paths, matches...)
+		}
+		if len(paths) == 0 {
+			return nil, errors.Errorf("no composite resources found in %q", arg)
 		}
+		sort.Strings(paths)
+		return paths, nil
 	}
 
-	if c.includeContext {
-		_, _ = fmt.Fprintln(os.Stdout, "---")
-		if err := s.Encode(out.Context, os.Stdout); err != nil {
-			return errors.Wrap(err, "cannot marshal context to YAML")
-		}
+	paths, err := afero.Glob(fs, arg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid composite resource pattern %q", arg)
+	}
+	if len(paths) == 0 {
+		// Let loading the XR report that the file doesn't exist.
+		return []string{arg}, nil
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// loadCompositeResource loads the XR (or Claim) from its file, or from the
+// cluster if it's named as <type>/<name>.
+func (c *renderCmd) loadCompositeResource(ctx context.Context) (*composite.Unstructured, error) {
+	resource, name, ok := clusterObjectRef(c.fs, c.compositeResource)
+	if !ok {
+		xr, err := loadCompositeResource(c.fs, c.compositeResource)
+		return xr, errors.Wrapf(err, "cannot load composite resource from %q", c.compositeResource)
 	}
+	u, err := c.fetchObject(ctx, resource, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load composite resource %q from the cluster", c.compositeResource)
+	}
+	infof("Using %s %q from the cluster", u.GetKind(), u.GetName())
+	xr := composite.New()
+	xr.Object = u.Object
+	return xr, nil
+}
+
+// loadComposition loads the Composition from its file, or from the cluster if
+// it's named as <type>/<name>, e.g. composition/my-composition or
+// compositionrevision/my-composition-1a2b3c.
+func (c *renderCmd) loadComposition(ctx context.Context) (*apiextensionsv1.Composition, error) {
+	resource, name, ok := clusterObjectRef(c.fs, c.composition)
+	if !ok {
+		comp, err := loadComposition(c.fs, c.composition)
+		return comp, errors.Wrapf(err, "cannot load Composition from %q", c.composition)
+	}
+	u, err := c.fetchObject(ctx, resource, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load Composition %q from the cluster", c.composition)
+	}
+	data, err := encodeYAML(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal %s %q to YAML", u.GetKind(), u.GetName())
+	}
+	comps, _, err := compositionsFromYAML(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load Composition %q from the cluster", c.composition)
+	}
+	if len(comps) != 1 {
+		return nil, errors.Errorf("%s %q is not a Composition or CompositionRevision", u.GetKind(), u.GetName())
+	}
+	infof("Using Composition %q from the cluster", comps[0].GetName())
+	return comps[0], nil
+}
 
+// strictDecodeInputs checks the XR, Composition and Functions files for
+// unknown fields. Compositions from a directory or package, and resources
+// read from the cluster, aren't checked.
+func (c *renderCmd) strictDecodeInputs() error {
+	files := []string{}
+	if _, _, ok := clusterObjectRef(c.fs, c.compositeResource); !ok {
+		files = append(files, c.compositeResource)
+	}
+	if _, _, ok := clusterObjectRef(c.fs, c.composition); c.composition != "" && !isPackageSource(c.fs, c.composition) && !ok {
+		files = append(files, c.composition)
+	}
+	if c.functions != "" {
+		files = append(files, c.functions)
+	}
+	for _, f := range files {
+		if err := strictDecodeFile(c.fs, f); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// resolveClaim synthesizes the XR of the supplied Claim.
+func (c *renderCmd) resolveClaim(claim *composite.Unstructured, xrGVK schema.GroupVersionKind) (*composite.Unstructured, error) {
+	xr, err := claimToXR(claim, xrGVK)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot resolve %s %q to its composite resource", claim.GetKind(), claim.GetName())
+	}
+	if c.explain != nil {
+		c.explain.Claim = fmt.Sprintf("%s/%s/%s", claim.GetKind(), claim.GetNamespace(), claim.GetName())
+	}
+	infof("Resolved %s %s/%s to composite resource %s %q", claim.GetKind(), claim.GetNamespace(), claim.GetName(), xrGVK.Kind, xr.GetName())
+	return xr, nil
+}
+
+// render runs the Function pipeline with the supplied inputs.
+func (c *renderCmd) render(in render.Inputs) (_ render.Outputs, err error) {
+	log := newCrossplaneLogger()
+
+	started := time.Now()
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		rendersTotal.WithLabelValues(result).Inc()
+		renderDuration.Observe(time.Since(started).Seconds())
+	}()
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if len(c.debug) > 0 {
+		// Don't time out while the user is stepping through the pipeline.
+		ctx, cancel = context.WithCancel(c.context())
+	} else {
+		ctx, cancel = context.WithTimeout(c.context(), c.timeout)
+	}
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "render", trace.WithAttributes(
+		attribute.String("crossbench.composite", in.CompositeResource.GetName()),
+		attribute.String("crossbench.composition", in.Composition.GetName()),
+	))
+	defer func() {
+		recordSpanError(span, err)
+		span.End()
+	}()
+
+	if c.stopAfterStep != "" {
+		total := len(in.Composition.Spec.Pipeline)
+		if in.Composition, err = stopAfterStep(in.Composition, c.stopAfterStep); err != nil {
+			return render.Outputs{}, err
+		}
+		steps := in.Composition.Spec.Pipeline
+		infof("Stopping after pipeline step %q, step %d of %d", steps[len(steps)-1].Step, len(steps), total)
+	}
+
+	stop, err := startSourceFunctions(ctx, in.Functions, c.cfg.Functions)
+	if err != nil {
+		return render.Outputs{}, err
+	}
+	defer stop()
+
+	switch {
+	case c.runtime == ContainerRuntimeKubernetes:
+		stopPods, err := c.pods.startFunctionPods(ctx, in.Functions)
+		if err != nil {
+			return render.Outputs{}, err
+		}
+		defer stopPods()
+	case c.pool != nil:
+		if err := c.pool.useFunctions(ctx, in.Functions); err != nil {
+			return render.Outputs{}, err
+		}
+	case c.daemon:
+		if err := useDaemonFunctions(ctx, getDaemonSocket(), in.Functions); err != nil {
+			return render.Outputs{}, err
+		}
+	}
+
+	g := c.cfg.GRPC
+	if c.grpcMaxMessageSize != "" {
+		g.MaxMessageSize = c.grpcMaxMessageSize
+	}
+	if c.grpcKeepalive > 0 {
+		g.Keepalive = &metav1.Duration{Duration: c.grpcKeepalive}
+	}
+	if c.grpcCompression != "" {
+		g.Compression = c.grpcCompression
+	}
+	opts, err := g.pipelineOptions()
+	if err != nil {
+		return render.Outputs{}, err
+	}
+	opts.ParallelSteps = c.parallelSteps
+	opts.Timeout = c.timeout
+	opts.Observer = c.observer
+	opts.Chaos = c.chaosFault
+	opts.DumpIO = c.dumpIO
+	opts.ResourceSteps = c.resourceSteps
+	if c.extraFromCluster {
+		if c.networkDisabled() {
+			return render.Outputs{}, errors.New("--resolve-extra-from-cluster can't be used with --no-network or --offline")
+		}
+		if opts.Cluster, err = c.newClusterClient(); err != nil {
+			return render.Outputs{}, err
+		}
+	}
+	if len(c.debug) > 0 {
+		p, err := newPrompter("--debug")
+		if err != nil {
+			return render.Outputs{}, err
+		}
+		if opts.Debugger, err = newStepDebugger(p, c.debug); err != nil {
+			return render.Outputs{}, err
+		}
+	}
+
+	out, err := renderPipeline(ctx, log, in, opts)
+	if err != nil {
+		return render.Outputs{}, errors.Wrap(err, "cannot render composite resource")
+	}
+	if c.injectsMeta() {
+		stripMetaContext(out)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBatchOutputNames(t *testing.T) {
+	type want struct {
+		names []string
+		err   bool
+	}
+
+	cases := map[string]struct {
+		reason string
+		xrs    []string
+		want   want
+	}{
+		"Directory": {
+			reason: "XRs in the same directory should be named after their file.",
+			xrs:    []string{"examples/bucket.yaml", "examples/table.yml"},
+			want:   want{names: []string{"bucket", "table"}},
+		},
+		"GlobAcrossDirectories": {
+			reason: "XRs with the same file name in different directories should be named after their path below the directory they're all in.",
+			xrs:    []string{"examples/aws/xr.yaml", "examples/gcp/xr.yaml", "examples/gcp/prod/xr.yaml"},
+			want:   want{names: []string{"aws/xr", "gcp/xr", "gcp/prod/xr"}},
+		},
+		"Absolute": {
+			reason: "Absolute paths should be named after their path below the directory they're all in.",
+			xrs:    []string{"/src/examples/aws/xr.yaml", "/src/examples/gcp/xr.yaml"},
+			want:   want{names: []string{"aws/xr", "gcp/xr"}},
+		},
+		"WorkingDirectory": {
+			reason: "XRs in the working directory should be named after their file.",
+			xrs:    []string{"xr.yaml", "./examples/xr.yaml"},
+			want:   want{names: []string{"xr", "examples/xr"}},
+		},
+		"Clash": {
+			reason: "XRs that would be written to the same directory should be rejected.",
+			xrs:    []string{"examples/xr.yaml", "examples/xr.yml"},
+			want:   want{err: true},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			names, err := batchOutputNames(tc.xrs)
+			got := want{names: names, err: err != nil}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Errorf("\n%s\nbatchOutputNames(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// Formats of the reports written by --report.
+const (
+	// ReportJUnit is a JUnit XML report, displayed natively by the test
+	// panels of CI systems like GitLab and Jenkins.
+	ReportJUnit = "junit"
+
+	// ReportSARIF is a SARIF log of the findings, located in the input files
+	// they concern, uploaded to code scanning to annotate pull requests.
+	ReportSARIF = "sarif"
+)
+
+// reportFormats are the supported --report formats.
+var reportFormats = []string{ReportJUnit, ReportSARIF}
+
+// validReports returns an error if any of the supplied --report formats is
+// unknown, or has no path.
+func validReports(reports map[string]string) error {
+	for format, path := range reports {
+		known := false
+		for _, f := range reportFormats {
+			known = known || f == format
+		}
+		if !known {
+			return errors.Errorf("unknown --report format %q, must be one of %s", format, strings.Join(reportFormats, ", "))
+		}
+		if path == "" {
+			return errors.Errorf("--report %s needs a path, e.g. %s=report.xml", format, format)
+		}
+	}
+	return nil
+}
+
+// writeReports writes the supplied summary of a run as each of the supplied
+// reports, keyed by format.
+func writeReports(fs afero.Fs, reports map[string]string, s RunSummary) error {
+	formats := make([]string, 0, len(reports))
+	for f := range reports {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	for _, f := range formats {
+		var err error
+		switch f {
+		case ReportJUnit:
+			err = writeJUnitReport(fs, reports[f], s)
+		case ReportSARIF:
+			err = writeSARIFReport(fs, reports[f], s)
+		}
+		if err != nil {
+			return err
+		}
+		infof("Wrote %s report to %s", f, reports[f])
+	}
+	return nil
+}
+
+// A junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnitReport writes the supplied summary of a run to path as a JUnit
+// XML report: a test suite named after the command, with a test case per
+// rendered XR or test. Findings are included in the failure of their test
+// case if it failed, and in its output otherwise.
+func writeJUnitReport(fs afero.Fs, path string, s RunSummary) error {
+	suite := junitTestSuite{
+		Name:      "crossbench " + s.Command,
+		Tests:     len(s.Results),
+		Failures:  s.Failed,
+		Skipped:   s.Skipped,
+		Time:      junitSeconds(s.DurationSeconds),
+		Timestamp: s.StartedAt.Format("2006-01-02T15:04:05"),
+		Cases:     make([]junitTestCase, 0, len(s.Results)),
+	}
+	for _, r := range s.Results {
+		tc := junitTestCase{
+			Name:      r.Name,
+			ClassName: suite.Name,
+			Time:      junitSeconds(r.DurationSeconds),
+		}
+		findings := make([]string, 0, len(r.Findings))
+		for _, f := range r.Findings {
+			findings = append(findings, f.String())
+		}
+		switch r.Status {
+		case "SKIP":
+			tc.Skipped = &junitSkipped{Message: r.SkipReason}
+		case "FAIL":
+			message, _, _ := strings.Cut(r.Failures[0], "\n")
+			tc.Failure = &junitFailure{
+				Message: message,
+				Type:    "failure",
+				Text:    strings.Join(append(append([]string{}, r.Failures...), findings...), "\n"),
+			}
+		default:
+			tc.SystemOut = strings.Join(findings, "\n")
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	report := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal JUnit report")
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	return errors.Wrapf(afero.WriteFile(fs, path, data, 0644), "cannot write JUnit report %q", path)
+}
+
+// junitSeconds formats a duration in seconds the way JUnit reports do.
+func junitSeconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
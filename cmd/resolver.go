@@ -0,0 +1,399 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"golang.org/x/mod/semver"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// Version resolvers: where the latest version of a function is looked up.
+const (
+	// VersionResolverGitHub uses the latest GitHub release of the function's
+	// repository.
+	VersionResolverGitHub = "github"
+
+	// VersionResolverRegistry lists the tags of the function's package in its
+	// registry and picks the latest semver.
+	VersionResolverRegistry = "registry"
+
+	// VersionResolverMarketplace queries the Upbound Marketplace API, for
+	// functions published only on the marketplace. Their packages are pulled
+	// from the Upbound registry.
+	VersionResolverMarketplace = "marketplace"
+
+	// VersionResolverAuto uses GitHub, and falls back to the registry if
+	// GitHub can't resolve a version, e.g. because the function isn't hosted
+	// on GitHub.
+	VersionResolverAuto = "auto"
+)
+
+// getVersionResolver returns how the latest version of functions is resolved
+// Default: auto, configurable via CROSSBENCH_VERSION_RESOLVER env var
+func getVersionResolver() string {
+	if r := os.Getenv("CROSSBENCH_VERSION_RESOLVER"); r != "" {
+		return r
+	}
+	return VersionResolverAuto
+}
+
+// functionResolvers are the version resolvers configured for individual
+// functions in the configuration file, keyed by function name.
+var functionResolvers = map[string]string{}
+
+// functionConstraints are the semver constraints configured for individual
+// functions in the configuration file, keyed by function name.
+var functionConstraints = map[string]string{}
+
+// setFunctionResolution records the per-function version resolvers and
+// constraints of the supplied configuration.
+func setFunctionResolution(fns map[string]FunctionConfig) error {
+	functionResolvers = map[string]string{}
+	functionConstraints = map[string]string{}
+	for name, f := range fns {
+		if f.Resolver != "" {
+			functionResolvers[name] = f.Resolver
+		}
+		if f.Version != "" {
+			if _, err := parseVersionConstraint(f.Version); err != nil {
+				return errors.Wrapf(err, "invalid version constraint of function %q", name)
+			}
+			functionConstraints[name] = f.Version
+		}
+	}
+	return nil
+}
+
+// resolverFor returns the version resolver of the named function.
+func resolverFor(name string) string {
+	if r, ok := functionResolvers[name]; ok {
+		return r
+	}
+	return getVersionResolver()
+}
+
+// getUpboundMarketplaceAPIURL returns the base URL of the Upbound Marketplace API
+// Default: https://api.upbound.io, configurable via CROSSBENCH_UPBOUND_MARKETPLACE_API_URL env var
+func getUpboundMarketplaceAPIURL() string {
+	if url := os.Getenv("CROSSBENCH_UPBOUND_MARKETPLACE_API_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://api.upbound.io"
+}
+
+// fetchLatestVersion returns the latest version of the named function, whose
+// source is the supplied GitHub repository and whose packages are pushed to
+// the supplied registry, using the configured resolver. If the function has a
+// version constraint, the latest version satisfying it is returned.
+func fetchLatestVersion(ctx context.Context, name, registry, owner, repo string) (string, error) {
+	c, err := parseVersionConstraint(functionConstraints[name])
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid version constraint of function %q", name)
+	}
+	github := func() (string, error) {
+		if c == nil {
+			return fetchLatestReleaseVersion(ctx, owner, repo)
+		}
+		return fetchReleaseVersion(ctx, owner, repo, c)
+	}
+
+	switch r := resolverFor(name); r {
+	case VersionResolverGitHub:
+		return github()
+	case VersionResolverRegistry:
+		return fetchLatestTagVersion(fmt.Sprintf("%s/%s/%s", registry, owner, repo), c)
+	case VersionResolverMarketplace:
+		return fetchMarketplaceVersion(ctx, owner, repo, c)
+	case VersionResolverAuto:
+		v, err := github()
+		if err == nil {
+			return v, nil
+		}
+		pkg := fmt.Sprintf("%s/%s/%s", registry, owner, repo)
+		v, rerr := fetchLatestTagVersion(pkg, c)
+		if rerr != nil {
+			// Report the GitHub error, so a rate limit can still be handled.
+			return "", err
+		}
+		infof("Cannot resolve %s/%s on GitHub (%v), using latest tag %s of %s", owner, repo, err, v, pkg)
+		return v, nil
+	default:
+		return "", errors.Errorf("unknown version resolver %q, must be %s, %s, %s or %s", r, VersionResolverAuto, VersionResolverGitHub, VersionResolverRegistry, VersionResolverMarketplace)
+	}
+}
+
+// fetchReleaseVersion returns the latest version among the releases of the
+// supplied GitHub repository that satisfies the constraint. Drafts and
+// pre-releases are ignored.
+func fetchReleaseVersion(ctx context.Context, owner, repo string, c versionConstraint) (string, error) {
+	baseURL, token := gitHubCredentials.Endpoint(owner, repo)
+	url := fmt.Sprintf("%s/repos/%s/%s/releases?per_page=100", baseURL, owner, repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create GitHub request")
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: getGitHubAPITimeout()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch releases")
+	}
+	defer resp.Body.Close() //nolint:errcheck // Only reading.
+	recordGitHubRateLimit(resp)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusForbidden && strings.Contains(string(body), "rate limit") {
+			gitHubRateLimitedTotal.Inc()
+			return "", &RateLimitError{Message: fmt.Sprintf("GitHub API rate limit exceeded: %s", string(body))}
+		}
+		return "", errors.Errorf("GitHub API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	releases := []struct {
+		TagName    string `json:"tag_name"`
+		Draft      bool   `json:"draft"`
+		Prerelease bool   `json:"prerelease"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return "", errors.Wrap(err, "failed to decode response")
+	}
+	tags := []string{}
+	for _, r := range releases {
+		if !r.Draft && !r.Prerelease {
+			tags = append(tags, r.TagName)
+		}
+	}
+	v := latestSemver(tags, c)
+	if v == "" {
+		return "", errors.Errorf("no release of %s/%s satisfies %s", owner, repo, c)
+	}
+	return v, nil
+}
+
+// fetchLatestTagVersion lists the tags of the supplied package repository and
+// returns the latest semver tag satisfying the constraint, if any.
+// Pre-releases are ignored.
+func fetchLatestTagVersion(pkg string, c versionConstraint) (string, error) {
+	tags, err := crane.ListTags(pkg)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot list tags of %s", pkg)
+	}
+
+	latest := latestSemver(tags, c)
+	if latest == "" {
+		if c != nil {
+			return "", errors.Errorf("%s has no semver tags satisfying %s", pkg, c)
+		}
+		return "", errors.Errorf("%s has no semver tags", pkg)
+	}
+	return latest, nil
+}
+
+// latestSemver returns the latest of the supplied semver versions, with or
+// without a v prefix, that satisfies the constraint, if any. Pre-releases and
+// invalid versions are ignored.
+func latestSemver(versions []string, c versionConstraint) string {
+	latest, latestTag := "", ""
+	for _, t := range versions {
+		v := canonicalVersion(t)
+		if !semver.IsValid(v) || semver.Prerelease(v) != "" || semver.Build(v) != "" {
+			continue
+		}
+		if c != nil && !c.Allows(v) {
+			continue
+		}
+		if latest == "" || semver.Compare(v, latest) > 0 {
+			latest, latestTag = v, t
+		}
+	}
+	return latestTag
+}
+
+// canonicalVersion adds the v prefix semver comparisons need.
+func canonicalVersion(v string) string {
+	if !strings.HasPrefix(v, "v") {
+		return "v" + v
+	}
+	return v
+}
+
+// A versionConstraint is a semver range such as ">=0.8 <0.10". Comparators
+// separated by spaces or commas must all be satisfied; ranges separated by || are
+// alternatives. Besides =, !=, <, <=, > and >=, ~1.2 allows patch releases
+// of 1.2 and ^1.2 allows releases up to the next major version.
+type versionConstraint [][]versionComparator
+
+// constraintOperatorSpace matches the space between an operator and its
+// version, e.g. in ">= 0.8".
+var constraintOperatorSpace = regexp.MustCompile(`([<>=!~^]+)\s+`)
+
+type versionComparator struct {
+	op      string
+	version string
+}
+
+// parseVersionConstraint parses the supplied constraint. It returns nil for
+// an empty constraint.
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	c := versionConstraint{}
+	for _, alt := range strings.Split(constraintOperatorSpace.ReplaceAllString(s, "$1"), "||") {
+		and := []versionComparator{}
+		for _, f := range strings.Fields(strings.ReplaceAll(alt, ",", " ")) {
+			op := f[:len(f)-len(strings.TrimLeft(f, "<>=!~^"))]
+			v := canonicalVersion(strings.TrimPrefix(f, op))
+			if !semver.IsValid(v) {
+				return nil, errors.Errorf("invalid version %q in constraint %q", strings.TrimPrefix(f, op), s)
+			}
+			switch op {
+			case "", "=":
+				and = append(and, versionComparator{op: "=", version: v})
+			case "!=", "<", "<=", ">", ">=":
+				and = append(and, versionComparator{op: op, version: v})
+			case "~":
+				// ~1.2.3 and ~1.2 allow >=1.2.3 <1.3.0; ~1 allows <2.0.0.
+				upper := bumpVersion(semver.Major(v), minorVersion(v))
+				if !strings.Contains(strings.TrimPrefix(f, op), ".") {
+					upper = bumpVersion(semver.Major(v), "")
+				}
+				and = append(and, versionComparator{op: ">=", version: v}, versionComparator{op: "<", version: upper})
+			case "^":
+				// ^1.2.3 allows <2.0.0; ^0.2.3 allows <0.3.0, like Cargo and npm.
+				upper := bumpVersion(semver.Major(v), "")
+				if semver.Major(v) == "v0" {
+					upper = bumpVersion("v0", minorVersion(v))
+				}
+				and = append(and, versionComparator{op: ">=", version: v}, versionComparator{op: "<", version: upper})
+			default:
+				return nil, errors.Errorf("invalid operator %q in constraint %q", op, s)
+			}
+		}
+		if len(and) == 0 {
+			return nil, errors.Errorf("empty range in constraint %q", s)
+		}
+		c = append(c, and)
+	}
+	return c, nil
+}
+
+// minorVersion returns the minor version number of the supplied canonical
+// version, e.g. 2 for v1.2.3.
+func minorVersion(v string) string {
+	return strings.TrimPrefix(semver.MajorMinor(v), semver.Major(v)+".")
+}
+
+// bumpVersion returns the version after the supplied major version, or after
+// its minor version if minor is set, e.g. v1 to v2.0.0 or v0 and 2 to
+// v0.3.0.
+func bumpVersion(major, minor string) string {
+	m := 0
+	_, _ = fmt.Sscanf(major, "v%d", &m)
+	if minor == "" {
+		return fmt.Sprintf("v%d.0.0", m+1)
+	}
+	n := 0
+	_, _ = fmt.Sscanf(minor, "%d", &n)
+	return fmt.Sprintf("v%d.%d.0", m, n+1)
+}
+
+// Allows returns whether the supplied canonical version satisfies the
+// constraint.
+func (c versionConstraint) Allows(v string) bool {
+	for _, and := range c {
+		ok := true
+		for _, cmp := range and {
+			r := semver.Compare(v, cmp.version)
+			switch cmp.op {
+			case "=":
+				ok = r == 0
+			case "!=":
+				ok = r != 0
+			case "<":
+				ok = r < 0
+			case "<=":
+				ok = r <= 0
+			case ">":
+				ok = r > 0
+			case ">=":
+				ok = r >= 0
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the constraint in its parsed form.
+func (c versionConstraint) String() string {
+	alts := make([]string, 0, len(c))
+	for _, and := range c {
+		cmps := make([]string, 0, len(and))
+		for _, cmp := range and {
+			cmps = append(cmps, cmp.op+cmp.version)
+		}
+		alts = append(alts, strings.Join(cmps, " "))
+	}
+	return strings.Join(alts, " || ")
+}
+
+// fetchMarketplaceVersion returns the latest version of the supplied package
+// published on the Upbound Marketplace that satisfies the constraint, if any.
+// CROSSBENCH_UPBOUND_TOKEN is sent if set, for private packages.
+func fetchMarketplaceVersion(ctx context.Context, owner, repo string, c versionConstraint) (string, error) {
+	url := fmt.Sprintf("%s/v1/packageMetadata/%s/%s", getUpboundMarketplaceAPIURL(), owner, repo)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create Upbound Marketplace request")
+	}
+	req.Header.Set("Accept", "application/json")
+	if token := os.Getenv("CROSSBENCH_UPBOUND_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: getGitHubAPITimeout()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot query Upbound Marketplace for %s/%s", owner, repo)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Only reading.
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", errors.Errorf("Upbound Marketplace returned status %d for %s/%s: %s", resp.StatusCode, owner, repo, string(body))
+	}
+
+	meta := struct {
+		Version  string   `json:"version"`
+		Versions []string `json:"versions"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return "", errors.Wrap(err, "cannot decode Upbound Marketplace response")
+	}
+	v := latestSemver(append(meta.Versions, meta.Version), c)
+	if v == "" && c != nil {
+		return "", errors.Errorf("Upbound Marketplace has no versions of %s/%s satisfying %s", owner, repo, c)
+	}
+	if v == "" {
+		return "", errors.Errorf("Upbound Marketplace has no versions of %s/%s", owner, repo)
+	}
+	return v, nil
+}
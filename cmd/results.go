@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	fnv1 "github.com/crossplane/crossplane/v2/proto/fn/v1"
+)
+
+const checkFunctionResults = "function-results"
+
+// Values of --fail-on, the function results that fail a render.
+const (
+	// FailOnFatal fails only on fatal results, which stop the pipeline.
+	FailOnFatal = "fatal"
+
+	// FailOnWarning also fails on warning results.
+	FailOnWarning = "warning"
+
+	// FailOnResults fails on any result, including normal ones.
+	FailOnResults = "results"
+
+	// FailOnNone never fails because of results. A fatal result still stops
+	// the pipeline, but is only logged.
+	FailOnNone = "none"
+)
+
+// getFailOn returns the function results that fail a render
+// Default: fatal, configurable via CROSSBENCH_FAIL_ON env var
+func getFailOn() string {
+	if f := os.Getenv("CROSSBENCH_FAIL_ON"); f != "" {
+		return f
+	}
+	return FailOnFatal
+}
+
+// validFailOn returns an error if the supplied --fail-on value is unknown.
+func validFailOn(failOn string) error {
+	switch failOn {
+	case FailOnFatal, FailOnWarning, FailOnResults, FailOnNone:
+		return nil
+	}
+	return errors.Errorf("unknown --fail-on %q, must be %s, %s, %s or %s", failOn, FailOnFatal, FailOnWarning, FailOnResults, FailOnNone)
+}
+
+// A fatalResultError is returned when a pipeline step returns a fatal result.
+type fatalResultError struct {
+	Step    string
+	Message string
+}
+
+func (e *fatalResultError) Error() string {
+	return fmt.Sprintf("pipeline step %q returned a fatal result: %s", e.Step, e.Message)
+}
+
+// asFatalResultError returns the fatal result err wraps, if any.
+func asFatalResultError(err error) (*fatalResultError, bool) {
+	fe := &fatalResultError{}
+	ok := errors.As(err, &fe)
+	return fe, ok
+}
+
+// CheckFunctionResults returns an error finding for every supplied function
+// result that fails the render according to failOn. Fatal results never get
+// here, since they stop the pipeline.
+func CheckFunctionResults(results []unstructured.Unstructured, failOn string) []Finding {
+	findings := []Finding{}
+	if failOn != FailOnWarning && failOn != FailOnResults {
+		return findings
+	}
+	for _, r := range results {
+		severity, _, _ := unstructured.NestedString(r.Object, "severity")
+		if failOn == FailOnWarning && severity != fnv1.Severity_SEVERITY_WARNING.String() {
+			continue
+		}
+		step, _, _ := unstructured.NestedString(r.Object, "step")
+		message, _, _ := unstructured.NestedString(r.Object, "message")
+		findings = append(findings, Finding{
+			Check:    checkFunctionResults,
+			Severity: SeverityError,
+			Resource: "step " + step,
+			Message:  fmt.Sprintf("%s result: %s", resultSeverity(severity), message),
+		})
+	}
+	return findings
+}
+
+// resultSeverity returns the readable name of the supplied result severity,
+// e.g. warning for SEVERITY_WARNING.
+func resultSeverity(s string) string {
+	switch s {
+	case fnv1.Severity_SEVERITY_WARNING.String():
+		return "warning"
+	case fnv1.Severity_SEVERITY_NORMAL.String():
+		return "normal"
+	case fnv1.Severity_SEVERITY_FATAL.String():
+		return "fatal"
+	}
+	return s
+}
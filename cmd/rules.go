@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+const checkRules = "rules"
+
+// Lint rule targets: the documents a rule is evaluated against.
+const (
+	RuleTargetComposed    = "composed"
+	RuleTargetXR          = "xr"
+	RuleTargetComposition = "composition"
+	RuleTargetXRD         = "xrd"
+)
+
+// A LintRule is a custom check written as a CEL expression. The expression is
+// evaluated against each targeted document, available as the object
+// variable, and must return true for documents that pass. Rules targeting
+// composed resources can also refer to the XR they were rendered from as xr.
+type LintRule struct {
+	// Name of the rule, used in findings.
+	Name string `json:"name"`
+
+	// Target is the kind of document the rule checks. One of composed (the
+	// default), xr, composition or xrd.
+	Target string `json:"target,omitempty"`
+
+	// Kind and APIGroup restrict the rule to documents of a kind and group.
+	// Empty matches all.
+	Kind     string `json:"kind,omitempty"`
+	APIGroup string `json:"apiGroup,omitempty"`
+
+	// Expression is the CEL expression documents must satisfy.
+	Expression string `json:"expression"`
+
+	// Message describes a violation. Defaults to the expression.
+	Message string `json:"message,omitempty"`
+
+	// Severity of violations. Defaults to error.
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// A compiledRule is a LintRule ready to evaluate.
+type compiledRule struct {
+	LintRule
+	prg cel.Program
+}
+
+// compileRules compiles the supplied rules, checking each is well formed.
+func compileRules(rules []LintRule) ([]compiledRule, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("xr", cel.DynType),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create CEL environment")
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, errors.Errorf("rule %d has no name", i)
+		}
+		switch r.Target {
+		case "":
+			r.Target = RuleTargetComposed
+		case RuleTargetComposed, RuleTargetXR, RuleTargetComposition, RuleTargetXRD:
+		default:
+			return nil, errors.Errorf("rule %q has unknown target %q", r.Name, r.Target)
+		}
+		if r.Severity == "" {
+			r.Severity = SeverityError
+		}
+		if r.Message == "" {
+			r.Message = fmt.Sprintf("must satisfy %s", r.Expression)
+		}
+
+		ast, iss := env.Compile(r.Expression)
+		if iss.Err() != nil {
+			return nil, errors.Wrapf(iss.Err(), "rule %q has an invalid expression", r.Name)
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, errors.Errorf("rule %q expression must return a bool, not %s", r.Name, ast.OutputType())
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %q has an invalid expression", r.Name)
+		}
+		compiled = append(compiled, compiledRule{LintRule: r, prg: prg})
+	}
+	return compiled, nil
+}
+
+// EvaluateRules evaluates the rules for the supplied target against the
+// documents. The xr is made available to the expressions, and may be nil.
+func EvaluateRules(rules []compiledRule, target string, docs []*unstructured.Unstructured, xr *unstructured.Unstructured) []Finding {
+	var xrObj any
+	if xr != nil {
+		xrObj = xr.Object
+	}
+
+	findings := []Finding{}
+	for _, r := range rules {
+		if r.Target != target {
+			continue
+		}
+		for _, d := range docs {
+			gvk := d.GroupVersionKind()
+			if (r.Kind != "" && r.Kind != gvk.Kind) || (r.APIGroup != "" && r.APIGroup != gvk.Group) {
+				continue
+			}
+
+			f := Finding{
+				Check:    checkRules,
+				Severity: r.Severity,
+				Resource: resourceID(d),
+			}
+			out, _, err := r.prg.Eval(map[string]any{"object": d.Object, "xr": xrObj})
+			switch {
+			case err != nil:
+				f.Message = fmt.Sprintf("%s: cannot evaluate: %v", r.Name, err)
+			case out.Value() == true:
+				continue
+			case out.Value() == false:
+				f.Message = fmt.Sprintf("%s: %s", r.Name, r.Message)
+			default:
+				f.Message = fmt.Sprintf("%s: expression returned %v, not a bool", r.Name, out.Value())
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// Container runtimes functions can be run with.
+const (
+	ContainerRuntimeAuto   = "auto"
+	ContainerRuntimeDocker = "docker"
+	ContainerRuntimePodman = "podman"
+)
+
+// getContainerRuntime returns the container runtime used to run functions
+// Default: auto, configurable via CROSSBENCH_RUNTIME env var
+func getContainerRuntime() string {
+	if r := os.Getenv("CROSSBENCH_RUNTIME"); r != "" {
+		return r
+	}
+	return ContainerRuntimeAuto
+}
+
+// containerCLI is the CLI of the container runtime in use, used to inspect,
+// pull and build function images.
+var containerCLI = ContainerRuntimeDocker
+
+// setDockerHost points the Docker API client functions are run with at the
+// supplied endpoint, e.g. unix:///var/run/docker.sock, tcp://host:2376 or
+// npipe:////./pipe/docker_engine on Windows. Nothing changes if host is
+// empty.
+func setDockerHost(host string) error {
+	if host == "" {
+		return nil
+	}
+	return errors.Wrap(os.Setenv("DOCKER_HOST", host), "cannot set DOCKER_HOST")
+}
+
+// dockerEndpoint returns the endpoint Docker listens on by default.
+func dockerEndpoint() string {
+	if runtime.GOOS == "windows" {
+		return "npipe:////./pipe/docker_engine"
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// podmanEndpoints returns the endpoints Podman's API is served at, rootless
+// first. On Windows and macOS it's served by the default Podman machine.
+func podmanEndpoints() []string {
+	endpoints := []string{}
+	if host := os.Getenv("CONTAINER_HOST"); strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://") {
+		endpoints = append(endpoints, host)
+	}
+	if runtime.GOOS == "windows" {
+		return append(endpoints, "npipe:////./pipe/podman-machine-default")
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		endpoints = append(endpoints, "unix://"+filepath.Join(dir, "podman", "podman.sock"))
+	}
+	if home, err := os.UserHomeDir(); err == nil && runtime.GOOS == "darwin" {
+		endpoints = append(endpoints, "unix://"+filepath.Join(home, ".local", "share", "containers", "podman", "machine", "podman.sock"))
+	}
+	return append(endpoints, "unix:///run/podman/podman.sock")
+}
+
+// endpointExists returns whether the Unix socket or Windows named pipe of the
+// supplied endpoint exists. Other endpoints, e.g. tcp://, are assumed to.
+func endpointExists(endpoint string) bool {
+	var path string
+	switch {
+	case strings.HasPrefix(endpoint, "unix://"):
+		path = strings.TrimPrefix(endpoint, "unix://")
+	case strings.HasPrefix(endpoint, "npipe://"):
+		// npipe:////./pipe/docker_engine is the pipe \\.\pipe\docker_engine.
+		path = strings.ReplaceAll(strings.TrimPrefix(endpoint, "npipe://"), "/", `\`)
+	default:
+		return true
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// findPodmanEndpoint returns the first Podman API endpoint that exists.
+func findPodmanEndpoint() (string, bool) {
+	for _, e := range podmanEndpoints() {
+		if endpointExists(e) {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// setContainerRuntime selects the container runtime functions are run with.
+// Functions are always run through the Docker API, so Podman is used by
+// pointing DOCKER_HOST at its Docker compatible API. auto uses Docker if
+// DOCKER_HOST is set or Docker's default endpoint exists, and Podman if only
+// its endpoint exists.
+func setContainerRuntime(rt string) error {
+	switch rt {
+	case ContainerRuntimeDocker:
+		containerCLI = ContainerRuntimeDocker
+		return nil
+	case ContainerRuntimePodman:
+		containerCLI = ContainerRuntimePodman
+		if os.Getenv("DOCKER_HOST") != "" {
+			return nil
+		}
+		e, ok := findPodmanEndpoint()
+		if !ok {
+			return errors.Errorf("cannot find the Podman API at %s; start it with systemctl --user start podman.socket or podman machine start, or set --docker-host", strings.Join(podmanEndpoints(), ", "))
+		}
+		return setDockerHost(e)
+	case ContainerRuntimeAuto, "":
+		if os.Getenv("DOCKER_HOST") != "" || endpointExists(dockerEndpoint()) {
+			return setContainerRuntime(ContainerRuntimeDocker)
+		}
+		if _, ok := findPodmanEndpoint(); ok {
+			infof("Docker isn't running, running functions with Podman")
+			return setContainerRuntime(ContainerRuntimePodman)
+		}
+		return setContainerRuntime(ContainerRuntimeDocker)
+	default:
+		return errors.Errorf("unknown runtime %q, must be auto, docker or podman", rt)
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+)
+
+// runtimeAnnotationPrefix prefixes the annotations that control how
+// Functions are run.
+const runtimeAnnotationPrefix = "render.crossplane.io/"
+
+// RuntimeOverrides set the render.crossplane.io annotations of Functions at
+// render time, so how Functions are run can change per environment without
+// changing their manifests.
+type RuntimeOverrides struct {
+	// Functions are keyed by Function name, or a glob pattern such as
+	// function-*. Annotations of an exact name take precedence over those of
+	// patterns.
+	Functions map[string]map[string]string `json:"functions"`
+}
+
+// getRuntimeOverridesPath returns the path of the runtime overrides file
+// Default: none, configurable via CROSSBENCH_RUNTIME_OVERRIDES env var
+func getRuntimeOverridesPath() string {
+	return os.Getenv("CROSSBENCH_RUNTIME_OVERRIDES")
+}
+
+// loadRuntimeOverrides loads the runtime overrides file at the supplied path.
+func loadRuntimeOverrides(fs afero.Fs, file string) (*RuntimeOverrides, error) {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read runtime overrides %q", file)
+	}
+	o := &RuntimeOverrides{}
+	if err := yaml.Unmarshal(data, o); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse runtime overrides %q", file)
+	}
+	for name, annotations := range o.Functions {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, errors.Errorf("invalid function pattern %q in runtime overrides %q", name, file)
+		}
+		for k := range annotations {
+			if !strings.HasPrefix(k, runtimeAnnotationPrefix) {
+				return nil, errors.Errorf("annotation %q of function %q in runtime overrides %q isn't a %s annotation", k, name, file, runtimeAnnotationPrefix)
+			}
+		}
+	}
+	return o, nil
+}
+
+// apply sets the annotations of the supplied Functions that match an
+// override.
+func (o *RuntimeOverrides) apply(fns []pkgv1.Function) {
+	patterns := make([]string, 0, len(o.Functions))
+	for p := range o.Functions {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+
+	for i := range fns {
+		name := fns[i].GetName()
+		applied := false
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, name); ok && p != name {
+				meta.AddAnnotations(&fns[i], o.Functions[p])
+				applied = true
+			}
+		}
+		if a, ok := o.Functions[name]; ok {
+			meta.AddAnnotations(&fns[i], a)
+			applied = true
+		}
+		if applied {
+			infof("Applied runtime overrides to function %q", name)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// writeSARIFReport writes the findings of the supplied summary of a run to
+// path as a SARIF 2.1.0 log, with a rule per check. Findings are located at
+// the line of their file they concern where possible, so code scanning can
+// annotate it. A finding reported for several XRs is only included once.
+func writeSARIFReport(fs afero.Fs, path string, s RunSummary) error {
+	driver := sarifDriver{
+		Name:           "crossbench",
+		Version:        version,
+		InformationURI: "https://github.com/gjbravi/crossbench",
+		Rules:          []sarifRule{},
+	}
+	results := []sarifResult{}
+	checks := map[string]bool{}
+	seen := map[string]bool{}
+	l := &findingLocator{fs: fs, files: map[string][]*yaml.Node{}}
+	for _, r := range s.Results {
+		for _, f := range r.Findings {
+			line := l.line(f)
+			key := f.fingerprint() + "\x00" + f.File + "\x00" + strconv.Itoa(line)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			checks[f.Check] = true
+			fp := sha256.Sum256([]byte(f.fingerprint()))
+
+			text := f.Message
+			if f.Resource != "" {
+				text = f.Resource + ": " + f.Message
+			}
+			res := sarifResult{
+				RuleID:              f.Check,
+				Level:               sarifLevel(f.Severity),
+				Message:             sarifMessage{Text: text},
+				PartialFingerprints: map[string]string{"crossbench/v1": hex.EncodeToString(fp[:])},
+			}
+			if f.File != "" {
+				loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: sarifURI(f.File)}}}
+				if line > 0 {
+					loc.PhysicalLocation.Region = &sarifRegion{StartLine: line}
+				}
+				res.Locations = []sarifLocation{loc}
+			}
+			results = append(results, res)
+		}
+	}
+	for c := range checks {
+		driver.Rules = append(driver.Rules, sarifRule{ID: c, ShortDescription: sarifMessage{Text: "crossbench " + c + " check"}})
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool { return driver.Rules[i].ID < driver.Rules[j].ID })
+
+	report := sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal SARIF report")
+	}
+	return errors.Wrapf(afero.WriteFile(fs, path, append(data, '\n'), 0644), "cannot write SARIF report %q", path)
+}
+
+// sarifLevel returns the SARIF level of the supplied severity.
+func sarifLevel(s Severity) string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// sarifURI returns the supplied file as a URI relative to the working
+// directory, which code scanning resolves against the repository root, or as
+// a file URI if it's outside the working directory.
+func sarifURI(file string) string {
+	if filepath.IsAbs(file) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return (&url.URL{Scheme: "file", Path: filepath.ToSlash(file)}).String()
+		}
+		rel, err := filepath.Rel(wd, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return (&url.URL{Scheme: "file", Path: filepath.ToSlash(file)}).String()
+		}
+		file = rel
+	}
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+// A findingLocator finds the lines of findings in their files, parsing each
+// file once.
+type findingLocator struct {
+	fs    afero.Fs
+	files map[string][]*yaml.Node
+}
+
+// line returns the line of the supplied finding in its file, or 0 if it
+// can't be located. The finding's document is the one of the resource it
+// concerns, or the Composition. Within it, the finding is located at its
+// path, at the composition resource or step it concerns, or at the name of
+// the document.
+func (l *findingLocator) line(f Finding) int {
+	if f.File == "" {
+		return 0
+	}
+	docs, ok := l.files[f.File]
+	if !ok {
+		docs = parseYAMLNodes(l.fs, f.File)
+		l.files[f.File] = docs
+	}
+	if len(docs) == 0 {
+		return 0
+	}
+
+	id, hint, _ := strings.Cut(f.Resource, " (")
+	hint = strings.TrimSuffix(hint, ")")
+	if step, ok := strings.CutPrefix(f.Resource, "step "); ok {
+		hint = step
+	}
+	doc := docs[0]
+	for _, d := range docs {
+		if yamlValue(d, "kind") == nil {
+			continue
+		}
+		kind := yamlValue(d, "kind").Value
+		if name := yamlValue(yamlValue(d, "metadata"), "name"); name != nil && kind+"/"+name.Value == id {
+			doc = d
+			break
+		}
+		if kind == "Composition" && doc == docs[0] {
+			doc = d
+		}
+	}
+
+	if f.Path != "" {
+		return yamlPath(doc, f.Path).Line
+	}
+	if hint != "" {
+		if n := yamlScalar(doc, hint); n != nil {
+			return n.Line
+		}
+	}
+	if name := yamlValue(yamlValue(doc, "metadata"), "name"); name != nil {
+		return name.Line
+	}
+	return doc.Line
+}
+
+// parseYAMLNodes returns the root nodes of the documents in the supplied YAML
+// file, or none if it can't be read or parsed.
+func parseYAMLNodes(fs afero.Fs, path string) []*yaml.Node {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		debugf("Cannot read %q to locate findings: %v", path, err)
+		return nil
+	}
+	docs := []*yaml.Node{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		n := &yaml.Node{}
+		err := dec.Decode(n)
+		if errors.Is(err, io.EOF) {
+			return docs
+		}
+		if err != nil {
+			debugf("Cannot parse %q to locate findings: %v", path, err)
+			return docs
+		}
+		if len(n.Content) > 0 {
+			docs = append(docs, n.Content[0])
+		}
+	}
+}
+
+// yamlValue returns the value of the supplied key of a mapping node, or nil
+// if n isn't a mapping or has no such key.
+func yamlValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlPath returns the node at the supplied field path below n, e.g.
+// spec.pipeline[0].input, or the deepest node of the path that exists.
+func yamlPath(n *yaml.Node, path string) *yaml.Node {
+	for _, field := range strings.Split(path, ".") {
+		key, rest, _ := strings.Cut(field, "[")
+		next := yamlValue(n, key)
+		for next != nil && rest != "" {
+			var idx string
+			idx, rest, _ = strings.Cut(rest, "]")
+			rest = strings.TrimPrefix(rest, "[")
+			i, err := strconv.Atoi(idx)
+			if err != nil || next.Kind != yaml.SequenceNode || i >= len(next.Content) {
+				return next
+			}
+			n, next = next, next.Content[i]
+		}
+		if next == nil {
+			return n
+		}
+		n = next
+	}
+	return n
+}
+
+// yamlScalar returns the first scalar node below n with the supplied value.
+func yamlScalar(n *yaml.Node, value string) *yaml.Node {
+	if n.Kind == yaml.ScalarNode && n.Value == value {
+		return n
+	}
+	for _, c := range n.Content {
+		if s := yamlScalar(c, value); s != nil {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// SBOM formats.
+const (
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatSPDX      = "spdx"
+)
+
+// An SBOMImage is a Function image used in a render.
+type SBOMImage struct {
+	// Function is the name of the Function.
+	Function string
+
+	// Repository, Tag and Digest of the image. The tag or digest may be empty.
+	Repository string
+	Tag        string
+	Digest     string
+
+	// Local is true for images built locally, which aren't pulled.
+	Local bool
+
+	// Components are the components listed in the image's own CycloneDX SBOM,
+	// if it was merged.
+	Components []json.RawMessage
+}
+
+// sbomImage returns the image the supplied Function runs.
+func sbomImage(fn pkgv1.Function) SBOMImage {
+	img := SBOMImage{Function: fn.GetName()}
+	ref := fn.Spec.Package
+	if i := fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerImage]; i != "" {
+		ref = i
+	}
+	img.Local = fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerPullPolicy] == string(render.AnnotationValueRuntimeDockerPullPolicyNever) && ref != fn.Spec.Package
+
+	r, err := name.ParseReference(ref, name.WithDefaultRegistry(""))
+	if err != nil {
+		img.Repository = ref
+		return img
+	}
+	img.Repository = r.Context().Name()
+	switch r := r.(type) {
+	case name.Digest:
+		img.Digest = r.DigestStr()
+	case name.Tag:
+		img.Tag = r.TagStr()
+	}
+	return img
+}
+
+// resolveSBOMImages returns the images the supplied Functions run, resolving
+// the digest of remote images that aren't pinned to one. If merge is true the
+// image's own CycloneDX SBOM, attached as a cosign-style sha256-<hex>.sbom
+// tag, is merged when present.
+func resolveSBOMImages(fns []pkgv1.Function, merge bool) []SBOMImage {
+	imgs := make([]SBOMImage, 0, len(fns))
+	for _, fn := range fns {
+		img := sbomImage(fn)
+		if img.Digest == "" && !img.Local {
+			d, err := crane.Digest(img.ref())
+			if err != nil {
+				warnf("Cannot resolve digest of function %q image: %v", img.Function, err)
+			}
+			img.Digest = d
+		}
+		if merge && img.Digest != "" {
+			img.Components = imageSBOMComponents(img)
+		}
+		imgs = append(imgs, img)
+	}
+	sort.Slice(imgs, func(i, j int) bool { return imgs[i].Function < imgs[j].Function })
+	return imgs
+}
+
+// imageSBOMComponents returns the components of the CycloneDX SBOM attached to
+// the supplied image, or nil if it has none.
+func imageSBOMComponents(img SBOMImage) []json.RawMessage {
+	ref := img.Repository + ":" + strings.Replace(img.Digest, ":", "-", 1) + ".sbom"
+	sbom, err := crane.Pull(ref)
+	if err != nil {
+		return nil
+	}
+	layers, err := sbom.Layers()
+	if err != nil || len(layers) == 0 {
+		return nil
+	}
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close() //nolint:errcheck // Only reading.
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil
+	}
+	doc := struct {
+		BOMFormat  string            `json:"bomFormat"`
+		Components []json.RawMessage `json:"components"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil || doc.BOMFormat != "CycloneDX" {
+		warnf("SBOM of function %q image isn't CycloneDX JSON, not merging it", img.Function)
+		return nil
+	}
+	infof("Merged %d component(s) from the SBOM of function %q image", len(doc.Components), img.Function)
+	return doc.Components
+}
+
+// ref returns the reference of the image.
+func (i SBOMImage) ref() string {
+	switch {
+	case i.Digest != "":
+		return i.Repository + "@" + i.Digest
+	case i.Tag != "":
+		return i.Repository + ":" + i.Tag
+	}
+	return i.Repository
+}
+
+// purl returns the package URL of the image.
+func (i SBOMImage) purl() string {
+	segments := strings.Split(i.Repository, "/")
+	p := "pkg:oci/" + segments[len(segments)-1]
+	if i.Digest != "" {
+		p += "@" + strings.Replace(i.Digest, ":", "%3A", 1)
+	}
+	p += "?repository_url=" + i.Repository
+	if i.Tag != "" {
+		p += "&tag=" + i.Tag
+	}
+	return p
+}
+
+// WriteSBOM writes an SBOM in the supplied format listing the images.
+func WriteSBOM(w io.Writer, format string, imgs []SBOMImage) error {
+	var doc any
+	switch format {
+	case SBOMFormatCycloneDX:
+		doc = cycloneDXDocument(imgs)
+	case SBOMFormatSPDX:
+		doc = spdxDocument(imgs)
+	default:
+		return errors.Errorf("unknown SBOM format %q, must be %s or %s", format, SBOMFormatCycloneDX, SBOMFormatSPDX)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return errors.Wrap(enc.Encode(doc), "cannot write SBOM")
+}
+
+func cycloneDXDocument(imgs []SBOMImage) map[string]any {
+	components := []any{}
+	for _, img := range imgs {
+		c := map[string]any{
+			"type":       "container",
+			"bom-ref":    img.Function,
+			"name":       img.Repository,
+			"purl":       img.purl(),
+			"properties": []any{map[string]any{"name": "crossbench:function", "value": img.Function}},
+		}
+		if img.Tag != "" {
+			c["version"] = img.Tag
+		}
+		if algo, hex, ok := strings.Cut(img.Digest, ":"); ok && algo == "sha256" {
+			c["hashes"] = []any{map[string]any{"alg": "SHA-256", "content": hex}}
+		}
+		if img.Local {
+			c["properties"] = append(c["properties"].([]any), map[string]any{"name": "crossbench:local", "value": "true"})
+		}
+		if len(img.Components) > 0 {
+			c["components"] = img.Components
+		}
+		components = append(components, c)
+	}
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuid.NewString(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools":     []any{map[string]any{"name": "crossbench", "version": version}},
+		},
+		"components": components,
+	}
+}
+
+func spdxDocument(imgs []SBOMImage) map[string]any {
+	packages := []any{}
+	relationships := []any{}
+	for i, img := range imgs {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i)
+		p := map[string]any{
+			"SPDXID":           id,
+			"name":             img.Function,
+			"downloadLocation": "NOASSERTION",
+			"filesAnalyzed":    false,
+			"externalRefs": []any{map[string]any{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  img.purl(),
+			}},
+		}
+		if img.Tag != "" {
+			p["versionInfo"] = img.Tag
+		}
+		if algo, hex, ok := strings.Cut(img.Digest, ":"); ok && algo == "sha256" {
+			p["checksums"] = []any{map[string]any{"algorithm": "SHA256", "checksumValue": hex}}
+		}
+		packages = append(packages, p)
+		relationships = append(relationships, map[string]any{
+			"spdxElementId":      "SPDXRef-DOCUMENT",
+			"relationshipType":   "DESCRIBES",
+			"relatedSpdxElement": id,
+		})
+	}
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              "crossbench-render",
+		"documentNamespace": "https://crossbench.io/spdx/" + uuid.NewString(),
+		"creationInfo": map[string]any{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []any{"Tool: crossbench-" + version},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+// writeSBOMFile writes an SBOM of the images the supplied Functions run to
+// path.
+func writeSBOMFile(fs afero.Fs, path, format string, merge bool, fns []pkgv1.Function) error {
+	f, err := fs.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create SBOM %q", path)
+	}
+	defer f.Close() //nolint:errcheck // Closed below.
+	if err := WriteSBOM(f, format, resolveSBOMImages(fns, merge)); err != nil {
+		return err
+	}
+	return errors.Wrapf(f.Close(), "cannot write SBOM %q", path)
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// Built-in vulnerability scanners. Any other scanner is run as a command with
+// the image as its last argument, and fails the scan by exiting non-zero.
+const (
+	ScannerTrivy = "trivy"
+	ScannerGrype = "grype"
+)
+
+// vulnerabilitySeverities are the severities scanners report, least severe
+// first.
+var vulnerabilitySeverities = []string{"UNKNOWN", "NEGLIGIBLE", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// A Vulnerability reported by a scanner.
+type Vulnerability struct {
+	ID       string
+	Package  string
+	Severity string
+}
+
+// getScanner returns the vulnerability scanner run over function images
+// Default: none, configurable via CROSSBENCH_SCANNER env var
+func getScanner() string {
+	return os.Getenv("CROSSBENCH_SCANNER")
+}
+
+// severityRank returns the rank of the supplied severity, or -1 if it's
+// unknown.
+func severityRank(severity string) int {
+	for i, s := range vulnerabilitySeverities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// scanFunctionImages scans the image of each Function with the supplied
+// scanner before it runs, and returns an error if any has vulnerabilities at
+// or above the failOn severity. Functions that don't run in Docker are
+// skipped.
+func scanFunctionImages(scanner, failOn string, fns []pkgv1.Function) error {
+	threshold := severityRank(failOn)
+	if threshold < 0 {
+		return errors.Errorf("unknown severity %q, must be one of %s", failOn, strings.ToLower(strings.Join(vulnerabilitySeverities, ", ")))
+	}
+
+	failed := []string{}
+	for _, fn := range fns {
+		if fn.GetAnnotations()[render.AnnotationKeyRuntime] == string(render.AnnotationValueRuntimeDevelopment) {
+			continue
+		}
+		image := fn.Spec.Package
+		if i := fn.GetAnnotations()[render.AnnotationKeyRuntimeDockerImage]; i != "" {
+			image = i
+		}
+
+		infof("Scanning function %q image %q with %s", fn.GetName(), image, scanner)
+		vulns, err := scanImage(scanner, image)
+		if err != nil {
+			return errors.Wrapf(err, "cannot scan function %q image %q", fn.GetName(), image)
+		}
+
+		blocking := []Vulnerability{}
+		counts := map[string]int{}
+		for _, v := range vulns {
+			counts[strings.ToUpper(v.Severity)]++
+			if severityRank(v.Severity) >= threshold {
+				blocking = append(blocking, v)
+			}
+		}
+		if len(vulns) > 0 {
+			summary := []string{}
+			for i := len(vulnerabilitySeverities) - 1; i >= 0; i-- {
+				if n := counts[vulnerabilitySeverities[i]]; n > 0 {
+					summary = append(summary, fmt.Sprintf("%d %s", n, vulnerabilitySeverities[i]))
+				}
+			}
+			warnf("Function %q image %q has %d vulnerabilities: %s", fn.GetName(), image, len(vulns), strings.Join(summary, ", "))
+		}
+		if len(blocking) == 0 {
+			continue
+		}
+
+		sort.Slice(blocking, func(i, j int) bool { return blocking[i].ID < blocking[j].ID })
+		for _, v := range blocking {
+			errorf("%s: %s in %s (%s)", fn.GetName(), v.ID, v.Package, strings.ToUpper(v.Severity))
+		}
+		failed = append(failed, fmt.Sprintf("%s (%d)", fn.GetName(), len(blocking)))
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("function images have vulnerabilities at or above %s severity, not running them: %s", strings.ToLower(failOn), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// scanImage scans the supplied image with the scanner and returns the
+// vulnerabilities it reports.
+func scanImage(scanner, image string) ([]Vulnerability, error) {
+	switch scanner {
+	case ScannerTrivy:
+		out, err := exec.Command("trivy", "image", "--quiet", "--format", "json", image).Output()
+		if err != nil {
+			return nil, errors.Wrap(err, "trivy failed")
+		}
+		report := struct {
+			Results []struct {
+				Vulnerabilities []struct {
+					VulnerabilityID string `json:"VulnerabilityID"`
+					PkgName         string `json:"PkgName"`
+					Severity        string `json:"Severity"`
+				} `json:"Vulnerabilities"`
+			} `json:"Results"`
+		}{}
+		if err := json.Unmarshal(out, &report); err != nil {
+			return nil, errors.Wrap(err, "cannot parse trivy report")
+		}
+		vulns := []Vulnerability{}
+		for _, r := range report.Results {
+			for _, v := range r.Vulnerabilities {
+				vulns = append(vulns, Vulnerability{ID: v.VulnerabilityID, Package: v.PkgName, Severity: v.Severity})
+			}
+		}
+		return vulns, nil
+
+	case ScannerGrype:
+		out, err := exec.Command("grype", image, "--output", "json", "--quiet").Output()
+		if err != nil {
+			return nil, errors.Wrap(err, "grype failed")
+		}
+		report := struct {
+			Matches []struct {
+				Vulnerability struct {
+					ID       string `json:"id"`
+					Severity string `json:"severity"`
+				} `json:"vulnerability"`
+				Artifact struct {
+					Name string `json:"name"`
+				} `json:"artifact"`
+			} `json:"matches"`
+		}{}
+		if err := json.Unmarshal(out, &report); err != nil {
+			return nil, errors.Wrap(err, "cannot parse grype report")
+		}
+		vulns := []Vulnerability{}
+		for _, m := range report.Matches {
+			vulns = append(vulns, Vulnerability{ID: m.Vulnerability.ID, Package: m.Artifact.Name, Severity: m.Vulnerability.Severity})
+		}
+		return vulns, nil
+	}
+
+	// A custom scanner decides for itself, failing by exiting non-zero.
+	args := strings.Fields(scanner)
+	if len(args) == 0 {
+		return nil, errors.New("scanner command is empty")
+	}
+	cmd := exec.Command(args[0], append(args[1:], image)...) //nolint:gosec // The scanner is chosen by the user.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return nil, errors.Wrapf(cmd.Run(), "scanner %q rejected the image", scanner)
+}
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+
+	crossbenchv1 "github.com/gjbravi/crossbench/proto/crossbench/v1"
+)
+
+// maxRenderRequestSize is the largest render request accepted.
+const maxRenderRequestSize = 10 << 20
+
+// NewServeCommand creates a new serve command.
+func NewServeCommand() *cobra.Command {
+	cmd := &serveCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve renders over an HTTP API",
+		Long: `Serve renders composite resources on request over HTTP, for embedding
+rendering in other tools such as developer portals. Function containers are
+kept running between requests.
+
+POST /render takes a JSON object holding the inputs as YAML strings, and
+returns the rendered resources as a YAML stream:
+
+  {
+    "xr": "apiVersion: example.crossplane.io/v1\nkind: Bucket\n...",
+    "composition": "apiVersion: apiextensions.crossplane.io/v1\n...",
+    "functions": "...",
+    "observedResources": "...",
+    "extraResources": "...",
+    "context": {"apiextensions.crossplane.io/environment": {"region": "us-east-2"}},
+    "includeFunctionResults": false,
+    "includeFullXR": false,
+    "includeContext": false
+  }
+
+Only xr and composition are required. Errors are returned as {"error": "..."}.
+GET /healthz reports whether the server is up. GET /metrics serves Prometheus
+metrics: render counts and durations, Function call latencies, the warm pool's
+containers, version cache hits and the GitHub API rate limit.
+
+With --grpc-address, the crossbench.v1.RenderService gRPC API is served too.
+It renders (streaming each rendered document), validates and resolves the
+Functions of Compositions; see proto/crossbench/v1/render.proto.
+
+The input flags, such as --config, --lock-file and --timeout, apply to every
+request.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.run,
+	}
+
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().StringVar(&cmd.address, "address", "127.0.0.1:8080", "Address to serve on.")
+	cobraCmd.Flags().StringVar(&cmd.grpcAddress, "grpc-address", "", "Address to serve the gRPC API on, e.g. 127.0.0.1:9090. Not served if unset.")
+
+	return cobraCmd
+}
+
+type serveCmd struct {
+	renderCmd
+
+	address     string
+	grpcAddress string
+
+	// resolve serializes loading inputs, which resolves Functions and
+	// updates the version cache.
+	resolve sync.Mutex
+}
+
+// A ServeRenderRequest is the body of a POST /render request.
+type ServeRenderRequest struct {
+	XR                     string                     `json:"xr"`
+	Composition            string                     `json:"composition"`
+	Functions              string                     `json:"functions,omitempty"`
+	ObservedResources      string                     `json:"observedResources,omitempty"`
+	ExtraResources         string                     `json:"extraResources,omitempty"`
+	Context                map[string]json.RawMessage `json:"context,omitempty"`
+	IncludeFunctionResults bool                       `json:"includeFunctionResults,omitempty"`
+	IncludeFullXR          bool                       `json:"includeFullXR,omitempty"`
+	IncludeContext         bool                       `json:"includeContext,omitempty"`
+}
+
+func (c *serveCmd) run(cmd *cobra.Command, _ []string) error {
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+	if c.otelEndpoint != "" {
+		shutdown, err := setupTracing(context.Background(), c.otelEndpoint)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+	c.pool = newFunctionPool()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /render", c.handleRender)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+	mux.Handle("GET /metrics", metricsHandler())
+	srv := &http.Server{Addr: c.address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	var gs *grpc.Server
+	if c.grpcAddress != "" {
+		l, err := net.Listen("tcp", c.grpcAddress)
+		if err != nil {
+			return errors.Wrapf(err, "cannot listen on %q", c.grpcAddress)
+		}
+		gs = grpc.NewServer(
+			grpc.MaxRecvMsgSize(maxRenderRequestSize),
+			grpc.UnaryInterceptor(traceUnary),
+			grpc.StreamInterceptor(traceStream),
+		)
+		crossbenchv1.RegisterRenderServiceServer(gs, &renderServer{serve: c})
+		go func() {
+			if err := gs.Serve(l); err != nil {
+				errorf("Cannot serve gRPC: %v", err)
+			}
+		}()
+		infof("Serving the gRPC API on %s", c.grpcAddress)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		if gs != nil {
+			gs.GracefulStop()
+		}
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	infof("Serving renders on http://%s", c.address)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "cannot serve")
+	}
+	c.pool.stopAll()
+	return nil
+}
+
+// handleRender renders the XR of a render request.
+func (c *serveCmd) handleRender(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracer.Start(r.Context(), "POST /render")
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	req := &ServeRenderRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRenderRequestSize)).Decode(req); err != nil {
+		writeServeError(w, r, http.StatusBadRequest, errors.Wrap(err, "cannot decode request"))
+		return
+	}
+	if req.XR == "" || req.Composition == "" {
+		writeServeError(w, r, http.StatusBadRequest, errors.New("xr and composition are required"))
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "crossbench-serve-")
+	if err != nil {
+		writeServeError(w, r, http.StatusInternalServerError, errors.Wrap(err, "cannot create request directory"))
+		return
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck // Best effort cleanup.
+
+	si := serveInputs{
+		XR:                req.XR,
+		Composition:       req.Composition,
+		Functions:         req.Functions,
+		ObservedResources: req.ObservedResources,
+		ExtraResources:    req.ExtraResources,
+		Context:           map[string]string{},
+	}
+	for k, v := range req.Context {
+		si.Context[k] = string(v)
+	}
+	rc, args, err := c.requestCmd(r.Context(), dir, si)
+	if err != nil {
+		writeServeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	rc.includeFunctionResults = req.IncludeFunctionResults
+	rc.includeContext = req.IncludeContext
+
+	in, err := c.loadRequestInputs(rc, args)
+	if err != nil {
+		writeServeError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	out, err := rc.render(in)
+	if err == nil && req.IncludeFullXR {
+		err = includeFullXR(in, out)
+	}
+	if err != nil {
+		writeServeError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeOutputs(buf, out, rc.includeFunctionResults, rc.includeContext); err != nil {
+		writeServeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// serveInputs are the inputs of a render request, as YAML.
+type serveInputs struct {
+	XR                string
+	Composition       string
+	Functions         string
+	ObservedResources string
+	ExtraResources    string
+	Context           map[string]string
+}
+
+// requestCmd writes the supplied inputs to dir, and returns a copy of the
+// command configured by the flags pointed at them, along with the arguments
+// to load them with. The command runs in the supplied context.
+func (c *serveCmd) requestCmd(ctx context.Context, dir string, si serveInputs) (*renderCmd, []string, error) {
+	rc := c.renderCmd
+	rc.ctx = ctx
+	rc.compositionsDir = ""
+	rc.interactive = false
+	rc.contextValues = map[string]string{}
+	for k, v := range c.contextValues {
+		rc.contextValues[k] = v
+	}
+	for k, v := range si.Context {
+		rc.contextValues[k] = v
+	}
+
+	var err error
+	write := func(name, data string) string {
+		if data == "" || err != nil {
+			return ""
+		}
+		path := filepath.Join(dir, name)
+		err = afero.WriteFile(rc.fs, path, []byte(data), 0644)
+		return path
+	}
+	args := []string{write("xr.yaml", si.XR), write("composition.yaml", si.Composition)}
+	if fns := write("functions.yaml", si.Functions); fns != "" {
+		args = append(args, fns)
+	}
+	if path := write("observed-resources.yaml", si.ObservedResources); path != "" {
+		rc.observedResources = path
+	}
+	if path := write("extra-resources.yaml", si.ExtraResources); path != "" {
+		rc.extraResources = path
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot write request inputs")
+	}
+	return &rc, args, nil
+}
+
+// loadRequestInputs loads the inputs of a request. Requests load their inputs
+// one at a time.
+func (c *serveCmd) loadRequestInputs(rc *renderCmd, args []string) (render.Inputs, error) {
+	c.resolve.Lock()
+	defer c.resolve.Unlock()
+	return rc.loadInputs(args)
+}
+
+// writeServeError writes an error response, and records the error on the
+// request's span.
+func writeServeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	recordSpanError(trace.SpanFromContext(r.Context()), err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
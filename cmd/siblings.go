@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// SiblingsConfig configures how the inputs of an XR passed on its own are
+// found next to it. Each kind of input lists file or directory names, or
+// globs, looked for in the XR's directory and then in its parents.
+type SiblingsConfig struct {
+	Composition       []string `json:"composition,omitempty"`
+	Functions         []string `json:"functions,omitempty"`
+	ObservedResources []string `json:"observedResources,omitempty"`
+	ExtraResources    []string `json:"extraResources,omitempty"`
+
+	// Parents is how many parent directories of the XR's directory are
+	// searched. Defaults to 1.
+	Parents *int `json:"parents,omitempty"`
+
+	// Disabled turns finding inputs next to the XR off.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// defaultSiblings are the conventional names of the inputs of an XR.
+var defaultSiblings = SiblingsConfig{
+	Composition:       []string{"composition.yaml", "composition.yml"},
+	Functions:         []string{"functions.yaml", "functions.yml"},
+	ObservedResources: []string{"observed", "observed-resources.yaml"},
+	ExtraResources:    []string{"extra", "extra-resources.yaml"},
+}
+
+// siblingInputs are the inputs found next to an XR. Inputs that weren't found
+// are empty.
+type siblingInputs struct {
+	Composition       string
+	Functions         string
+	ObservedResources string
+	ExtraResources    string
+}
+
+// findSiblings looks for the inputs of the supplied XR file next to it, by
+// the names the supplied configuration sets, or the conventional ones.
+func findSiblings(fs afero.Fs, xr string, cfg SiblingsConfig) (siblingInputs, error) {
+	if cfg.Disabled {
+		return siblingInputs{}, nil
+	}
+	patterns := func(configured, def []string) []string {
+		if len(configured) > 0 {
+			return configured
+		}
+		return def
+	}
+	parents := 1
+	if cfg.Parents != nil {
+		parents = *cfg.Parents
+	}
+
+	dirs := []string{filepath.Dir(xr)}
+	for i := 0; i < parents; i++ {
+		parent := filepath.Dir(dirs[len(dirs)-1])
+		if parent == dirs[len(dirs)-1] {
+			break
+		}
+		dirs = append(dirs, parent)
+	}
+
+	var err error
+	find := func(kind string, names []string) string {
+		if err != nil {
+			return ""
+		}
+		for _, dir := range dirs {
+			found := []string{}
+			for _, n := range names {
+				matches, gerr := afero.Glob(fs, filepath.Join(dir, n))
+				if gerr != nil {
+					err = errors.Wrapf(gerr, "invalid %s pattern %q", kind, n)
+					return ""
+				}
+				for _, m := range matches {
+					// Never mistake the XR itself for one of its inputs.
+					if filepath.Clean(m) != filepath.Clean(xr) {
+						found = append(found, m)
+					}
+				}
+			}
+			switch len(found) {
+			case 0:
+				continue
+			case 1:
+				infof("Using %s %q found next to composite resource %q", kind, found[0], xr)
+				return found[0]
+			default:
+				err = errors.Errorf("found several candidates for the %s of composite resource %q: %s, pass one explicitly", kind, xr, strings.Join(found, ", "))
+				return ""
+			}
+		}
+		return ""
+	}
+
+	in := siblingInputs{
+		Composition:       find("composition", patterns(cfg.Composition, defaultSiblings.Composition)),
+		Functions:         find("functions file", patterns(cfg.Functions, defaultSiblings.Functions)),
+		ObservedResources: find("observed resources", patterns(cfg.ObservedResources, defaultSiblings.ObservedResources)),
+		ExtraResources:    find("extra resources", patterns(cfg.ExtraResources, defaultSiblings.ExtraResources)),
+	}
+	return in, err
+}
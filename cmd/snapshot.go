@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/load"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// checkSnapshot compares the rendered XR and composed resources with the
+// snapshot file at the supplied path, which may be a remote location. If
+// update is true the snapshot is written instead. It returns a diff describing
+// how the rendered output differs from the snapshot, which is empty if they
+// match.
+func checkSnapshot(fs afero.Fs, path string, out render.Outputs, update bool) (string, error) {
+	b := &bytes.Buffer{}
+	if err := writeOutputs(b, out, false, false); err != nil {
+		return "", err
+	}
+
+	if update {
+		if err := writeStoredFile(fs, path, b.Bytes()); err != nil {
+			return "", errors.Wrapf(err, "cannot write snapshot %q", path)
+		}
+		return "", nil
+	}
+
+	old, err := readStoredFile(fs, path)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot read snapshot %q (run with --update-snapshots to create it)", path)
+	}
+	if bytes.Equal(old, b.Bytes()) {
+		return "", nil
+	}
+
+	want, err := parseYAMLStream(old)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse snapshot %q", path)
+	}
+	got, err := parseYAMLStream(b.Bytes())
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse rendered output")
+	}
+
+	d := &strings.Builder{}
+	if !printChanges(d, DiffResources(got, want, DiffOptions{})) {
+		// The resources are equivalent, only their serialization differs.
+		return "", nil
+	}
+	return d.String(), nil
+}
+
+// parseYAMLStream parses a stream of YAML documents into resources, skipping
+// empty documents.
+func parseYAMLStream(data []byte) ([]unstructured.Unstructured, error) {
+	us := []unstructured.Unstructured{}
+	docs, err := load.YamlStream(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		u := unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return nil, err
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		us = append(us, u)
+	}
+	return us, nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/meta"
+
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// startSourceFunctions builds and starts the Functions configured with a local
+// Go module source, and points the Functions at the running processes. The
+// returned function stops the processes and removes their binaries.
+func startSourceFunctions(ctx context.Context, fns []pkgv1.Function, cfg map[string]FunctionConfig) (func(), error) {
+	procs := []*exec.Cmd{}
+	dirs := []string{}
+	stop := func() {
+		for _, p := range procs {
+			_ = p.Process.Kill()
+			_ = p.Wait()
+		}
+		for _, d := range dirs {
+			_ = os.RemoveAll(d)
+		}
+	}
+
+	for i := range fns {
+		name := fns[i].GetName()
+		src := cfg[name].Source
+		if src == "" {
+			continue
+		}
+
+		dir, err := os.MkdirTemp("", "crossbench-fn-")
+		if err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "cannot create build directory for function %q", name)
+		}
+		dirs = append(dirs, dir)
+
+		infof("Building function %q from source %q", name, src)
+		bin := filepath.Join(dir, "function")
+		if runtime.GOOS == "windows" {
+			bin += ".exe"
+		}
+		build := exec.CommandContext(ctx, "go", "build", "-o", bin, ".")
+		build.Dir = src
+		build.Stdout = os.Stderr
+		build.Stderr = os.Stderr
+		if err := build.Run(); err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "cannot build function %q from source %q", name, src)
+		}
+
+		addr, err := freeLocalAddress()
+		if err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "cannot find a free port for function %q", name)
+		}
+
+		p := exec.Command(bin, "--insecure", "--address="+addr)
+		p.Stdout = os.Stderr
+		p.Stderr = os.Stderr
+		if err := p.Start(); err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "cannot start function %q", name)
+		}
+		procs = append(procs, p)
+
+		if err := waitForListener(ctx, addr); err != nil {
+			stop()
+			return nil, errors.Wrapf(err, "function %q didn't start listening at %s", name, addr)
+		}
+
+		meta.AddAnnotations(&fns[i], map[string]string{
+			render.AnnotationKeyRuntime:                  string(render.AnnotationValueRuntimeDevelopment),
+			render.AnnotationKeyRuntimeDevelopmentTarget: "dns:///" + addr,
+		})
+		infof("Running function %q from source at %s", name, addr)
+	}
+
+	return stop, nil
+}
+
+// freeLocalAddress returns a localhost address with a port nothing is
+// listening on.
+func freeLocalAddress() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close() //nolint:errcheck // Only used to pick a port.
+	return l.Addr().String(), nil
+}
+
+// waitForListener waits until something accepts connections at the supplied
+// address.
+func waitForListener(ctx context.Context, addr string) error {
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
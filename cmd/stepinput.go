@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// How --step-input overrides the input of a pipeline step.
+const (
+	// StepInputModeMerge merges the override into the step's input, like a
+	// JSON merge patch: objects are merged field by field, other values and
+	// lists are replaced and null removes a field.
+	StepInputModeMerge = "merge"
+
+	// StepInputModeReplace replaces the step's input with the override.
+	StepInputModeReplace = "replace"
+)
+
+// overrideStepInputs returns a copy of the supplied Composition whose pipeline
+// steps have their input overridden by the YAML files the supplied map names
+// for them.
+func overrideStepInputs(fs afero.Fs, comp *apiextensionsv1.Composition, files map[string]string, mode string) (*apiextensionsv1.Composition, error) {
+	if mode != StepInputModeMerge && mode != StepInputModeReplace {
+		return nil, errors.Errorf("unknown --step-input-mode %q, must be %s or %s", mode, StepInputModeMerge, StepInputModeReplace)
+	}
+
+	c := comp.DeepCopy()
+	steps := map[string]*apiextensionsv1.PipelineStep{}
+	names := make([]string, len(c.Spec.Pipeline))
+	for i := range c.Spec.Pipeline {
+		steps[c.Spec.Pipeline[i].Step] = &c.Spec.Pipeline[i]
+		names[i] = c.Spec.Pipeline[i].Step
+	}
+
+	// Override in a stable order, so errors are repeatable.
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		s, ok := steps[name]
+		if !ok {
+			return nil, errors.Errorf("--step-input: composition %q has no pipeline step %q, must be one of %s", comp.GetName(), name, strings.Join(names, ", "))
+		}
+		data, err := afero.ReadFile(fs, files[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read input of pipeline step %q", name)
+		}
+		override := map[string]any{}
+		if err := yaml.Unmarshal(data, &override); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse input of pipeline step %q from %q", name, files[name])
+		}
+
+		input := override
+		if mode == StepInputModeMerge && s.Input != nil && len(s.Input.Raw) > 0 {
+			input = map[string]any{}
+			if err := json.Unmarshal(s.Input.Raw, &input); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse input of pipeline step %q", name)
+			}
+			mergeInput(input, override)
+		}
+		raw, err := json.Marshal(input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot marshal input of pipeline step %q", name)
+		}
+		s.Input = &runtime.RawExtension{Raw: raw}
+		infof("Overriding the input of pipeline step %q with %q (%s)", name, files[name], mode)
+	}
+	return c, nil
+}
+
+// mergeInput merges src into dst like a JSON merge patch.
+func mergeInput(dst, src map[string]any) {
+	for k, v := range src {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		sm, sok := v.(map[string]any)
+		dm, dok := dst[k].(map[string]any)
+		if sok && dok {
+			mergeInput(dm, sm)
+			continue
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// Schemes of the remote locations baselines and snapshots can be stored at.
+const (
+	StorageSchemeS3  = "s3"
+	StorageSchemeGCS = "gs"
+	StorageSchemeOCI = "oci"
+)
+
+// storageTimeout bounds each request to a remote store.
+const storageTimeout = 30 * time.Second
+
+// storageMediaType is the media type of the layer holding a file stored in
+// an OCI registry.
+const storageMediaType types.MediaType = "application/vnd.crossbench.file.v1"
+
+// getGCSToken returns the OAuth access token used for gs:// locations, or an
+// empty string to ask gcloud for one
+// Default: GOOGLE_OAUTH_ACCESS_TOKEN, configurable via CROSSBENCH_GCS_TOKEN env var
+func getGCSToken() string {
+	if t := os.Getenv("CROSSBENCH_GCS_TOKEN"); t != "" {
+		return t
+	}
+	return os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+}
+
+// A blobStore stores files somewhere other than the local filesystem. Get
+// returns an error satisfying errors.Is(err, os.ErrNotExist) if there is no
+// file at the supplied location.
+type blobStore interface {
+	Get(ctx context.Context, location *url.URL) ([]byte, error)
+	Put(ctx context.Context, location *url.URL, data []byte) error
+}
+
+// blobStores are the remote stores, by the scheme of their locations.
+var blobStores = map[string]blobStore{
+	StorageSchemeS3:  s3Store{},
+	StorageSchemeGCS: gcsStore{},
+	StorageSchemeOCI: ociStore{},
+}
+
+// remoteLocation returns the remote store of the supplied path and its
+// parsed location, or a nil store if it's a local path.
+func remoteLocation(path string) (blobStore, *url.URL, error) {
+	scheme, _, ok := strings.Cut(path, "://")
+	if !ok {
+		return nil, nil, nil
+	}
+	s, ok := blobStores[scheme]
+	if !ok {
+		return nil, nil, errors.Errorf("unsupported storage location %q, must be a local path or a %s://, %s:// or %s:// URL", path, StorageSchemeS3, StorageSchemeGCS, StorageSchemeOCI)
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid storage location %q", path)
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, nil, errors.Errorf("invalid storage location %q, must be %s://<host>/<path>", path, scheme)
+	}
+	return s, u, nil
+}
+
+// readStoredFile reads the file at the supplied local path or remote location.
+func readStoredFile(fs afero.Fs, path string) ([]byte, error) {
+	s, u, err := remoteLocation(path)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return afero.ReadFile(fs, path)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	return s.Get(ctx, u)
+}
+
+// writeStoredFile writes the file at the supplied local path or remote
+// location. Local parent directories are created as needed.
+func writeStoredFile(fs afero.Fs, path string, data []byte) error {
+	s, u, err := remoteLocation(path)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		if err := fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return afero.WriteFile(fs, path, data, 0644)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	return s.Put(ctx, u, data)
+}
+
+// doStorageRequest sends the supplied request, returning the body of a
+// successful response. A 404 is reported as os.ErrNotExist.
+func doStorageRequest(req *http.Request) ([]byte, error) {
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close() //nolint:errcheck // Only read from.
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case rsp.StatusCode == http.StatusNotFound:
+		return nil, os.ErrNotExist
+	case rsp.StatusCode >= 300:
+		return nil, errors.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), rsp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// An s3Store stores files in S3, or an S3 compatible store, at
+// s3://<bucket>/<key>. It's configured by the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION and
+// AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL) environment variables. Requests
+// are unsigned without credentials, for public buckets.
+type s3Store struct{}
+
+// Get reads the object at the supplied location.
+func (s s3Store) Get(ctx context.Context, location *url.URL) ([]byte, error) {
+	req, err := s.request(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doStorageRequest(req)
+}
+
+// Put writes the object at the supplied location.
+func (s s3Store) Put(ctx context.Context, location *url.URL, data []byte) error {
+	req, err := s.request(ctx, http.MethodPut, location, data)
+	if err != nil {
+		return err
+	}
+	_, err = doStorageRequest(req)
+	return err
+}
+
+// request builds a signed request for the object at the supplied location.
+func (s3Store) request(ctx context.Context, method string, location *url.URL, body []byte) (*http.Request, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	key := strings.TrimPrefix(location.Path, "/")
+
+	// Custom endpoints, e.g. MinIO, are addressed path-style, AWS
+	// virtual-hosted style.
+	var u string
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint != "" {
+		u = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), location.Host, s3EscapePath(key))
+	} else {
+		u = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", location.Host, region, s3EscapePath(key))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return req, nil
+	}
+	signS3Request(req, body, id, secret, os.Getenv("AWS_SESSION_TOKEN"), region, time.Now().UTC())
+	return req, nil
+}
+
+// s3EscapePath escapes each segment of the supplied object key the way
+// Signature Version 4 expects.
+func s3EscapePath(key string) string {
+	segs := strings.Split(key, "/")
+	for i, s := range segs {
+		segs[i] = awsURIEncode(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// awsURIEncode escapes the supplied string the way Signature Version 4
+// expects: every byte but the unreserved characters A-Z, a-z, 0-9, -, _, .
+// and ~ is percent-encoded, with upper case hex digits.
+func awsURIEncode(s string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		_, _ = fmt.Fprintf(b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// s3CanonicalQuery returns the query of the supplied URL as a Signature
+// Version 4 canonical query string: escaped name=value pairs, sorted by name
+// and then value. Parameters without a value, e.g. ?lifecycle, get an empty
+// one.
+func s3CanonicalQuery(u *url.URL) string {
+	type param struct{ name, value string }
+	params := []param{}
+	for name, values := range u.Query() {
+		for _, v := range values {
+			params = append(params, param{name: awsURIEncode(name), value: awsURIEncode(v)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.name + "=" + p.value
+	}
+	return strings.Join(pairs, "&")
+}
+
+// signS3Request signs the supplied request with AWS Signature Version 4.
+func signS3Request(req *http.Request, body []byte, id, secret, token, region string, now time.Time) {
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	canonical, signedHeaders := s3CanonicalRequest(req, payloadHash)
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, region)
+	toSign := s3StringToSign(amzDate, scope, canonical)
+	signature := s3Signature(secret, date, region, toSign)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", id, scope, signedHeaders, signature))
+}
+
+// s3CanonicalRequest returns the Signature Version 4 canonical request of
+// the supplied request, whose x-amz-* headers must already be set, and the
+// headers it signs.
+func s3CanonicalRequest(req *http.Request, payloadHash string) (canonical, signedHeaders string) {
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := []string{req.URL.Host, payloadHash, req.Header.Get("X-Amz-Date")}
+	if token := req.Header.Get("X-Amz-Security-Token"); token != "" {
+		headers = append(headers, "x-amz-security-token")
+		values = append(values, token)
+	}
+	canonicalHeaders := &strings.Builder{}
+	for i := range headers {
+		_, _ = fmt.Fprintf(canonicalHeaders, "%s:%s\n", headers[i], values[i])
+	}
+	signedHeaders = strings.Join(headers, ";")
+
+	canonical = strings.Join([]string{req.Method, req.URL.EscapedPath(), s3CanonicalQuery(req.URL), canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	return canonical, signedHeaders
+}
+
+// s3StringToSign returns the Signature Version 4 string to sign for the
+// supplied canonical request.
+func s3StringToSign(amzDate, scope, canonical string) string {
+	h := sha256.Sum256([]byte(canonical))
+	return strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(h[:])}, "\n")
+}
+
+// s3Signature returns the Signature Version 4 signature of the supplied
+// string to sign, with a key derived from the secret for the date and region.
+func s3Signature(secret, date, region, toSign string) string {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, "s3")
+	k = hmacSHA256(k, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(k, toSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// A gcsStore stores files in Google Cloud Storage at gs://<bucket>/<object>.
+// Requests are authorized with the token from getGCSToken, or gcloud auth
+// print-access-token. STORAGE_EMULATOR_HOST points it at an emulator.
+type gcsStore struct{}
+
+// Get reads the object at the supplied location.
+func (s gcsStore) Get(ctx context.Context, location *url.URL) ([]byte, error) {
+	req, err := s.request(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	return doStorageRequest(req)
+}
+
+// Put writes the object at the supplied location.
+func (s gcsStore) Put(ctx context.Context, location *url.URL, data []byte) error {
+	req, err := s.request(ctx, http.MethodPut, location, data)
+	if err != nil {
+		return err
+	}
+	_, err = doStorageRequest(req)
+	return err
+}
+
+// request builds an authorized request for the object at the supplied
+// location, using the XML API.
+func (gcsStore) request(ctx context.Context, method string, location *url.URL, body []byte) (*http.Request, error) {
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = host
+		if !strings.Contains(host, "://") {
+			endpoint = "http://" + host
+		}
+	}
+	u := fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), location.Host, s3EscapePath(strings.TrimPrefix(location.Path, "/")))
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	token := getGCSToken()
+	if token == "" {
+		if out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output(); err == nil {
+			token = strings.TrimSpace(string(out))
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// An ociStore stores files in an OCI registry at oci://<registry>/<repository>:<tag>,
+// as the single layer of an image. Registry credentials come from the Docker
+// configuration, like those used to pull function images.
+type ociStore struct{}
+
+// Get reads the file at the supplied location.
+func (ociStore) Get(ctx context.Context, location *url.URL) ([]byte, error) {
+	ref := location.Host + location.Path
+	img, err := crane.Pull(ref, crane.WithContext(ctx))
+	if err != nil {
+		terr := &transport.Error{}
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return nil, os.ErrNotExist
+		}
+		return nil, err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) != 1 {
+		return nil, errors.Errorf("%s has %d layers, want 1", ref, len(layers))
+	}
+	rc, err := layers[0].Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() //nolint:errcheck // Only read from.
+	return io.ReadAll(rc)
+}
+
+// Put writes the file at the supplied location.
+func (ociStore) Put(ctx context.Context, location *url.URL, data []byte) error {
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer(data, storageMediaType))
+	if err != nil {
+		return err
+	}
+	return crane.Push(img, location.Host+location.Path, crane.WithContext(ctx))
+}
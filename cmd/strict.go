@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/afero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/load"
+)
+
+// A strictObject is a resource whose schema crossbench doesn't know, such as
+// an XR. Only its top-level fields and metadata can be checked.
+type strictObject struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   json.RawMessage `json:"spec,omitempty"`
+	Status json.RawMessage `json:"status,omitempty"`
+}
+
+// strictDecodeFile returns an error listing the unknown fields of the
+// resources in the supplied file, which decoding would otherwise silently
+// drop. Compositions, CompositionRevisions and Functions are checked against
+// their schema; other resources only at the top level and in their metadata.
+func strictDecodeFile(fs afero.Fs, file string) error {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read %q", file)
+	}
+	docs, err := load.YamlStream(bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrapf(err, "cannot parse %q", file)
+	}
+
+	problems := []string{}
+	for _, doc := range docs {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return errors.Wrapf(err, "cannot parse %q", file)
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+
+		var obj any = &strictObject{}
+		switch u.GroupVersionKind() {
+		case apiextensionsv1.CompositionGroupVersionKind:
+			obj = &apiextensionsv1.Composition{}
+		case apiextensionsv1.CompositionRevisionGroupVersionKind:
+			obj = &apiextensionsv1.CompositionRevision{}
+		case pkgv1.FunctionGroupVersionKind:
+			obj = &pkgv1.Function{}
+		}
+		// Unknown fields are detected after the YAML is converted to JSON, so
+		// merge keys can override the fields they merge.
+		j, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return errors.Wrapf(err, "cannot parse %q", file)
+		}
+		d := json.NewDecoder(bytes.NewReader(j))
+		d.DisallowUnknownFields()
+		if err := d.Decode(obj); err != nil {
+			problems = append(problems, fmt.Sprintf("%s/%s: %v", u.GetKind(), u.GetName(), err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("%q has unknown fields (--strict-decode):\n  %s", file, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
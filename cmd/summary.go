@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// A RunResult is the outcome of one item of a batch run, such as a rendered
+// XR or a test.
+type RunResult struct {
+	Name     string
+	Duration time.Duration
+
+	// Failures describe why the item failed. It passed if there are none.
+	Failures []string
+
+	// Skipped items weren't run, because an earlier one failed with
+	// --fail-fast, or for the reason given by SkipReason.
+	Skipped    bool
+	SkipReason string
+
+	// Functions are the packages of the Functions that ran, by name.
+	Functions map[string]string
+
+	// Resources is the number of composed resources rendered.
+	Resources int
+
+	// Findings reported by the checks that ran.
+	Findings []Finding
+
+	// MissingInputs are the optional inputs the render went without.
+	MissingInputs []MissingInput
+
+	// Diff counts the composed resources by how they changed, for commands
+	// that compare the rendered output.
+	Diff map[ChangeType]int
+}
+
+// status returns PASS, FAIL or SKIP.
+func (r RunResult) status() string {
+	switch {
+	case r.Skipped:
+		return "SKIP"
+	case len(r.Failures) > 0:
+		return "FAIL"
+	}
+	return "PASS"
+}
+
+// skipReason returns why a skipped item wasn't run.
+func (r RunResult) skipReason() string {
+	if r.SkipReason != "" {
+		return r.SkipReason
+	}
+	return "not run after an earlier failure (--fail-fast)"
+}
+
+// recordRender records the Functions and composed resources of a render.
+func (r *RunResult) recordRender(in render.Inputs, out render.Outputs) {
+	if r == nil {
+		return
+	}
+	r.Functions = make(map[string]string, len(in.Functions))
+	for _, fn := range in.Functions {
+		r.Functions[fn.GetName()] = fn.Spec.Package
+	}
+	r.Resources = len(out.ComposedResources)
+}
+
+// PrintRunSummary writes the results as a table, one row per item, followed
+// by the totals. Only the first failure of each item is shown.
+func PrintRunSummary(w io.Writer, results []RunResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tNAME\tDURATION\tREASON")
+
+	passed, failed, skipped := 0, 0, 0
+	for _, r := range results {
+		status, reason := r.status(), "-"
+		switch status {
+		case "SKIP":
+			reason = r.skipReason()
+			skipped++
+		case "FAIL":
+			reason, _, _ = strings.Cut(r.Failures[0], "\n")
+			if len(r.Failures) > 1 {
+				reason += fmt.Sprintf(" (+%d more)", len(r.Failures)-1)
+			}
+			failed++
+		default:
+			passed++
+		}
+		d := "-"
+		if !r.Skipped {
+			d = r.Duration.Round(time.Millisecond).String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", status, r.Name, d, reason)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("%d total, %d passed, %d failed", len(results), passed, failed)
+	if skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped", skipped)
+	}
+	_, err := fmt.Fprintln(w, summary)
+	return err
+}
+
+// A RunSummary is the machine readable outcome of a run, written by
+// --summary-file for downstream CI jobs.
+type RunSummary struct {
+	Command         string             `json:"command"`
+	Version         string             `json:"version"`
+	StartedAt       time.Time          `json:"startedAt"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Passed          int                `json:"passed"`
+	Failed          int                `json:"failed"`
+	Skipped         int                `json:"skipped"`
+	Results         []RunSummaryResult `json:"results"`
+}
+
+// A RunSummaryResult is the outcome of one item of a run.
+type RunSummaryResult struct {
+	Name            string             `json:"name"`
+	Status          string             `json:"status"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Failures        []string           `json:"failures,omitempty"`
+	SkipReason      string             `json:"skipReason,omitempty"`
+	Resources       int                `json:"resources,omitempty"`
+	Functions       map[string]string  `json:"functions,omitempty"`
+	Findings        []Finding          `json:"findings,omitempty"`
+	MissingInputs   []MissingInput     `json:"missingInputs,omitempty"`
+	Diff            map[ChangeType]int `json:"diff,omitempty"`
+}
+
+// NewRunSummary summarizes the results of the supplied command, which
+// started at the supplied time.
+func NewRunSummary(command string, started time.Time, results []RunResult) RunSummary {
+	s := RunSummary{
+		Command:         command,
+		Version:         version,
+		StartedAt:       started.UTC(),
+		DurationSeconds: time.Since(started).Seconds(),
+		Results:         make([]RunSummaryResult, 0, len(results)),
+	}
+	for _, r := range results {
+		sr := RunSummaryResult{
+			Name:            r.Name,
+			Status:          r.status(),
+			DurationSeconds: r.Duration.Seconds(),
+			Failures:        r.Failures,
+			Resources:       r.Resources,
+			Functions:       r.Functions,
+			Findings:        r.Findings,
+			MissingInputs:   r.MissingInputs,
+			Diff:            r.Diff,
+		}
+		if r.Skipped {
+			sr.SkipReason = r.skipReason()
+		}
+		switch sr.Status {
+		case "SKIP":
+			s.Skipped++
+		case "FAIL":
+			s.Failed++
+		default:
+			s.Passed++
+		}
+		s.Results = append(s.Results, sr)
+	}
+	return s
+}
+
+// writeSummaryFile writes the summary of a run to path as JSON. It does
+// nothing if path is empty.
+func writeSummaryFile(fs afero.Fs, path string, s RunSummary) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal run summary")
+	}
+	return errors.Wrapf(afero.WriteFile(fs, path, append(data, '\n'), 0644), "cannot write summary file %q", path)
+}
@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// telemetryTimeout bounds how long reporting usage may delay a command.
+const telemetryTimeout = 2 * time.Second
+
+// Error classes reported by telemetry.
+const (
+	ErrorClassTimeout   = "timeout"
+	ErrorClassRateLimit = "rate-limit"
+	ErrorClassCanceled  = "canceled"
+	ErrorClassOther     = "other"
+)
+
+// getTelemetry returns whether usage telemetry is enabled, overriding
+// crossbench telemetry enable and disable when set
+// Default: unset, configurable via CROSSBENCH_TELEMETRY env var
+func getTelemetry() string {
+	return os.Getenv("CROSSBENCH_TELEMETRY")
+}
+
+// getTelemetryEndpoint returns the URL usage telemetry is sent to
+// Default: none, configurable via CROSSBENCH_TELEMETRY_ENDPOINT env var
+func getTelemetryEndpoint() string {
+	return os.Getenv("CROSSBENCH_TELEMETRY_ENDPOINT")
+}
+
+// getTelemetryFile returns the path of the telemetry settings
+// Default: ~/.crossbench/telemetry.json, configurable via CROSSBENCH_TELEMETRY_FILE env var
+func getTelemetryFile() string {
+	if path := os.Getenv("CROSSBENCH_TELEMETRY_FILE"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".crossbench-telemetry.json"
+	}
+	return filepath.Join(home, ".crossbench", "telemetry.json")
+}
+
+// TelemetrySettings are saved by crossbench telemetry enable and disable.
+type TelemetrySettings struct {
+	Enabled  bool   `json:"enabled"`
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// InstallID is random, and only tells events of one installation apart
+	// from another's.
+	InstallID string `json:"installID,omitempty"`
+}
+
+// A UsageEvent is reported after each command when telemetry is enabled. It
+// holds no arguments, flag values, paths or resource names.
+type UsageEvent struct {
+	InstallID  string   `json:"installID"`
+	Command    string   `json:"command"`
+	Flags      []string `json:"flags,omitempty"`
+	DurationMS int64    `json:"durationMs"`
+	Success    bool     `json:"success"`
+	ErrorClass string   `json:"errorClass,omitempty"`
+	Version    string   `json:"version"`
+	OS         string   `json:"os"`
+	Arch       string   `json:"arch"`
+}
+
+// telemetryStatus is the telemetry in effect, and where it was configured.
+type telemetryStatus struct {
+	TelemetrySettings
+
+	// Source is what enabled or disabled telemetry.
+	Source string
+}
+
+// loadTelemetrySettings loads the saved telemetry settings. Telemetry is
+// disabled if none were saved.
+func loadTelemetrySettings() (TelemetrySettings, error) {
+	s := TelemetrySettings{}
+	path := getTelemetryFile()
+	data, err := os.ReadFile(path) //nolint:gosec // The path is chosen by the user.
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, errors.Wrapf(err, "cannot read telemetry settings %q", path)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, errors.Wrapf(err, "cannot parse telemetry settings %q", path)
+	}
+	return s, nil
+}
+
+// saveTelemetrySettings saves the supplied telemetry settings.
+func saveTelemetrySettings(s TelemetrySettings) error {
+	path := getTelemetryFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create directory of %q", path)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal telemetry settings")
+	}
+	return errors.Wrapf(os.WriteFile(path, append(data, '\n'), 0600), "cannot write telemetry settings %q", path)
+}
+
+// currentTelemetry returns the telemetry in effect. The environment overrides
+// the saved settings.
+func currentTelemetry() (telemetryStatus, error) {
+	s, err := loadTelemetrySettings()
+	if err != nil {
+		return telemetryStatus{}, err
+	}
+	st := telemetryStatus{TelemetrySettings: s, Source: "default"}
+	if s.Enabled {
+		st.Source = "crossbench telemetry enable"
+	} else if s.InstallID != "" {
+		st.Source = "crossbench telemetry disable"
+	}
+	switch getTelemetry() {
+	case "true":
+		st.Enabled = true
+		st.Source = "CROSSBENCH_TELEMETRY"
+	case "false":
+		st.Enabled = false
+		st.Source = "CROSSBENCH_TELEMETRY"
+	}
+	if e := getTelemetryEndpoint(); e != "" {
+		st.Endpoint = e
+	}
+	// Telemetry is never sent without somewhere to send it to.
+	if st.Enabled && st.Endpoint == "" {
+		st.Enabled = false
+		st.Source = "no endpoint configured"
+	}
+	return st, nil
+}
+
+// NewTelemetryCommand creates a new telemetry command.
+func NewTelemetryCommand() *cobra.Command {
+	cobraCmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: "Manage opt-in anonymous usage telemetry",
+		Long: `Telemetry reports which commands are run, which flags they are run with (by
+name only), how long they took and the class of error they failed with, to an
+endpoint you configure. It is off by default, and only sent once enabled with
+crossbench telemetry enable --endpoint <url> or CROSSBENCH_TELEMETRY=true along
+with CROSSBENCH_TELEMETRY_ENDPOINT. CROSSBENCH_TELEMETRY=false always disables
+it.
+
+Arguments, flag values, file paths, file contents and resource names are never
+reported. Events are identified by a random installation ID only. Telemetry is
+not sent with --no-network or --offline.`,
+	}
+
+	cobraCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show whether telemetry is enabled, and what it reports",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			st, err := currentTelemetry()
+			if err != nil {
+				return err
+			}
+			return printTelemetryStatus(os.Stdout, st)
+		},
+	})
+
+	var endpoint string
+	enable := &cobra.Command{
+		Use:   "enable",
+		Short: "Enable reporting anonymous usage telemetry",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			s, err := loadTelemetrySettings()
+			if err != nil {
+				return err
+			}
+			if endpoint != "" {
+				s.Endpoint = endpoint
+			}
+			if s.Endpoint == "" && getTelemetryEndpoint() == "" {
+				return errors.New("no telemetry endpoint configured, set --endpoint or CROSSBENCH_TELEMETRY_ENDPOINT")
+			}
+			if s.InstallID == "" {
+				if s.InstallID, err = newInstallID(); err != nil {
+					return err
+				}
+			}
+			s.Enabled = true
+			if err := saveTelemetrySettings(s); err != nil {
+				return err
+			}
+			infof("Enabled telemetry, saved to %s", getTelemetryFile())
+			return nil
+		},
+	}
+	enable.Flags().StringVar(&endpoint, "endpoint", "", "URL usage events are posted to.")
+	cobraCmd.AddCommand(enable)
+
+	cobraCmd.AddCommand(&cobra.Command{
+		Use:   "disable",
+		Short: "Disable reporting anonymous usage telemetry",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			s, err := loadTelemetrySettings()
+			if err != nil {
+				return err
+			}
+			s.Enabled = false
+			if err := saveTelemetrySettings(s); err != nil {
+				return err
+			}
+			infof("Disabled telemetry, saved to %s", getTelemetryFile())
+			return nil
+		},
+	})
+
+	return cobraCmd
+}
+
+// printTelemetryStatus describes the telemetry in effect.
+func printTelemetryStatus(w io.Writer, st telemetryStatus) error {
+	b := &strings.Builder{}
+	state := "disabled"
+	if st.Enabled {
+		state = "enabled"
+	}
+	_, _ = fmt.Fprintf(b, "Telemetry:  %s (%s)\n", state, st.Source)
+	endpoint := st.Endpoint
+	if endpoint == "" {
+		endpoint = "none"
+	}
+	_, _ = fmt.Fprintf(b, "Endpoint:   %s\n", endpoint)
+	if st.InstallID != "" {
+		_, _ = fmt.Fprintf(b, "Install ID: %s\n", st.InstallID)
+	}
+	_, _ = fmt.Fprintf(b, "Settings:   %s\n", getTelemetryFile())
+	b.WriteString("\nWhen enabled, each command reports its name, the names of the flags set,\n")
+	b.WriteString("its duration, whether it succeeded and its error class, along with the\n")
+	b.WriteString("crossbench version, OS and architecture. Arguments, flag values, paths and\n")
+	b.WriteString("resource names are never reported.\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// newInstallID returns a random installation ID.
+func newInstallID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "cannot generate installation ID")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// errorClass classifies an error without revealing its message.
+func errorClass(err error) string {
+	if _, ok := asTimeoutError(err); ok {
+		return ErrorClassTimeout
+	}
+	rle := &RateLimitError{}
+	switch {
+	case errors.As(err, &rle):
+		return ErrorClassRateLimit
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	}
+	return ErrorClassOther
+}
+
+// newUsageEvent describes a run of the supplied command.
+func newUsageEvent(installID string, c *cobra.Command, d time.Duration, err error) UsageEvent {
+	e := UsageEvent{
+		InstallID:  installID,
+		Command:    strings.TrimPrefix(c.CommandPath(), c.Root().Name()+" "),
+		DurationMS: d.Milliseconds(),
+		Success:    err == nil,
+		Version:    version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	}
+	if c == c.Root() {
+		e.Command = ""
+	}
+	c.Flags().Visit(func(f *pflag.Flag) {
+		e.Flags = append(e.Flags, f.Name)
+	})
+	if err != nil {
+		e.ErrorClass = errorClass(err)
+	}
+	return e
+}
+
+// ReportUsage reports a run of the supplied command, if telemetry is
+// enabled. Failing to report is never an error.
+func ReportUsage(c *cobra.Command, d time.Duration, err error) {
+	if c == nil || c.Name() == "help" || c.Name() == "telemetry" || (c.HasParent() && c.Parent().Name() == "telemetry") {
+		return
+	}
+	if getNoNetwork() || getOffline() {
+		return
+	}
+	for _, name := range []string{"no-network", "offline"} {
+		if f := c.Flags().Lookup(name); f != nil && f.Value.String() == "true" {
+			return
+		}
+	}
+	st, serr := currentTelemetry()
+	if serr != nil {
+		debugf("Cannot load telemetry settings: %v", serr)
+		return
+	}
+	if !st.Enabled {
+		return
+	}
+
+	data, merr := json.Marshal(newUsageEvent(st.InstallID, c, d, err))
+	if merr != nil {
+		debugf("Cannot marshal usage event: %v", merr)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+	req, rerr := http.NewRequestWithContext(ctx, http.MethodPost, st.Endpoint, bytes.NewReader(data))
+	if rerr != nil {
+		debugf("Cannot report usage to %s: %v", st.Endpoint, rerr)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rsp, rerr := http.DefaultClient.Do(req)
+	if rerr != nil {
+		debugf("Cannot report usage to %s: %v", st.Endpoint, rerr)
+		return
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode >= 300 {
+		debugf("Cannot report usage to %s: %s", st.Endpoint, rsp.Status)
+	}
+}
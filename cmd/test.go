@@ -0,0 +1,572 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// A TestSuite is a file of render tests.
+type TestSuite struct {
+	Tests []TestCase `json:"tests"`
+}
+
+// A TestCase renders an XR and asserts on the rendered output. Paths are
+// relative to the file the test is defined in.
+type TestCase struct {
+	Name                string             `json:"name"`
+	XR                  string             `json:"xr"`
+	Composition         string             `json:"composition"`
+	Functions           string             `json:"functions,omitempty"`
+	ObservedResources   string             `json:"observedResources,omitempty"`
+	ExtraResources      string             `json:"extraResources,omitempty"`
+	FunctionCredentials string             `json:"functionCredentials,omitempty"`
+	Profile             string             `json:"profile,omitempty"`
+	ContextFiles        map[string]string  `json:"contextFiles,omitempty"`
+	ContextValues       map[string]string  `json:"contextValues,omitempty"`
+	Deleting            []string           `json:"deleting,omitempty"`
+	Absent              []string           `json:"absent,omitempty"`
+	Snapshot            string             `json:"snapshot,omitempty"`
+	Expect              TestExpectations   `json:"expect"`
+	Chaos               []ChaosExpectation `json:"chaos,omitempty"`
+}
+
+// TestExpectations are the assertions made on the rendered output.
+type TestExpectations struct {
+	// Count is the expected number of composed resources.
+	Count *int `json:"count,omitempty"`
+
+	// Resources are partial composed resources that must be rendered. They're
+	// matched by composition resource name, or by kind and name (and
+	// apiVersion and namespace, if set), or by kind alone if they have no
+	// name. Only the fields they set are compared.
+	Resources []map[string]any `json:"resources,omitempty"`
+
+	// ResourcesFile is a YAML file of partial resources, compared like
+	// Resources.
+	ResourcesFile string `json:"resourcesFile,omitempty"`
+
+	// Fields are single field assertions.
+	Fields []FieldAssertion `json:"fields,omitempty"`
+
+	// Assert are CEL expressions that must be true of the rendered output,
+	// like render's --assert.
+	Assert []string `json:"assert,omitempty"`
+}
+
+// A FieldAssertion asserts the value of a field of a rendered resource.
+type FieldAssertion struct {
+	// Resource is the composition resource name of a composed resource, or
+	// "xr" for the rendered composite resource.
+	Resource string `json:"resource"`
+	Path     string `json:"path"`
+	Value    any    `json:"value"`
+}
+
+// NewTestCommand creates a new test command.
+func NewTestCommand() *cobra.Command {
+	cmd := &testCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "test <test-file-or-directory>...",
+		Short: "Render compositions and assert on the rendered output",
+		Long: `Test runs the render tests defined in the supplied files. Directories are
+searched recursively for files ending in .test.yaml.
+
+A test file holds a list of tests. Each test names the inputs to render, using
+paths relative to the test file, and the expectations the output must meet:
+
+  tests:
+    - name: bucket uses the requested region
+      xr: xr.yaml
+      composition: composition.yaml
+      functions: functions.yaml
+      expect:
+        count: 1
+        resources:
+          - apiVersion: s3.aws.crossplane.io/v1beta1
+            kind: Bucket
+            metadata:
+              annotations:
+                crossplane.io/composition-resource-name: s3bucket
+            spec:
+              forProvider:
+                locationConstraint: us-east-2
+        fields:
+          - resource: s3bucket
+            path: metadata.name
+            value: my-example-bucket
+
+Expected resources only need to contain the fields under test. A failing test
+prints a diff of every mismatched field.
+
+Tests are rendered with the crossbench configuration file like render, so
+function image overrides, Source builds and resolvers apply to them too.
+
+A test may also name a snapshot file, e.g. snapshot: snapshots/bucket.yaml. The
+rendered XR and composed resources must then match the snapshot exactly. Run
+with --update-snapshots to create or update snapshots after an intended change.
+
+With --chaos, every test is also rendered with each fault injected, to check
+how its composition handles failures:
+
+  function-timeout     every Function call fails with a deadline exceeded error
+  empty-response       every Function call returns an empty response
+  malformed-observed   the spec and status of observed resources are strings
+                       (only tests with observedResources)
+
+A fault must fail the render, unless the test expects otherwise:
+
+      chaos:
+        - fault: function-timeout
+          error: timed out
+        - fault: empty-response
+          expect:
+            count: 0`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: hermetic(func() bool { return cmd.noNetwork || cmd.offline }, cmd.run),
+	}
+
+	// Flags
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Path to the crossbench configuration file, applied to every test like render. It's optional unless set explicitly.")
+	cobraCmd.Flags().DurationVar(&cmd.timeout, "timeout", 1*time.Minute, "How long to run each test before timing out.")
+	cobraCmd.Flags().BoolVar(&cmd.refreshCache, "refresh-cache", false, "Force refresh of cached function versions from GitHub")
+	cobraCmd.Flags().BoolVar(&cmd.noNetwork, "no-network", getNoNetwork(), "Refuse all outbound network access and fail listing every attempt, proving the tests are hermetic. Tools crossbench runs, such as gh, gcloud and trivy, aren't sandboxed.")
+	cobraCmd.Flags().BoolVar(&cmd.offline, "offline", getOffline(), "Run without network access. Function versions come from the cache or lock file, and function images must already be present locally.")
+	cobraCmd.Flags().BoolVar(&cmd.updateSnapshots, "update-snapshots", false, "Write the rendered output of tests with a snapshot to their snapshot file instead of comparing them.")
+	cobraCmd.Flags().BoolVar(&cmd.daemon, "daemon", getUseDaemon(), "Run functions in the warm containers of crossbench daemon instead of starting containers for every test.")
+	cobraCmd.Flags().BoolVar(&cmd.failFast, "fail-fast", false, "Stop at the first failing test instead of running them all.")
+	cobraCmd.Flags().StringSliceVar(&cmd.chaos, "chaos", nil, "Also run every test with these faults injected: all, function-timeout, empty-response or malformed-observed.")
+	cobraCmd.Flag("chaos").NoOptDefVal = "all"
+	cobraCmd.Flags().StringVar(&cmd.summaryFile, "summary-file", "", "Write a JSON summary of the run - per-test outcome, duration and function versions - to this file for CI jobs.")
+	cobraCmd.Flags().StringToStringVar(&cmd.reports, "report", nil, "Write a report of the run, with a test case per test, as <format>=<path>. Repeatable; formats: junit.")
+
+	return cobraCmd
+}
+
+type testCmd struct {
+	// Flags
+	config          string
+	timeout         time.Duration
+	refreshCache    bool
+	updateSnapshots bool
+	noNetwork       bool
+	offline         bool
+	failFast        bool
+	daemon          bool
+	summaryFile     string
+	reports         map[string]string
+	chaos           []string
+
+	cfg *Config
+	fs  afero.Fs
+}
+
+func (c *testCmd) run(cmd *cobra.Command, args []string) error {
+	// Tests are rendered with the same configuration as crossbench render:
+	// function overrides and resolution, GitHub credentials, gRPC tuning and
+	// the container runtime.
+	rc := &renderCmd{
+		config:         c.config,
+		githubAuthMode: getGitHubAuthMode(),
+		runtime:        getContainerRuntime(),
+		offline:        c.offline,
+		fs:             c.fs,
+	}
+	if err := rc.loadConfig(cmd); err != nil {
+		return err
+	}
+	c.cfg = rc.cfg
+
+	faults, err := parseChaosFaults(c.chaos)
+	if err != nil {
+		return err
+	}
+	if err := validReports(c.reports); err != nil {
+		return err
+	}
+
+	files, err := findTestFiles(c.fs, args)
+	if err != nil {
+		return err
+	}
+
+	// Every test runs unless --fail-fast is set. Test files that can't be
+	// loaded fail like a test, so they don't hide the other results.
+	results := []RunResult{}
+	failed := 0
+	started := time.Now()
+	for _, file := range files {
+		if c.failFast && failed > 0 {
+			break
+		}
+
+		suite, err := loadTestSuite(c.fs, file)
+		if err != nil {
+			failed++
+			results = append(results, RunResult{Name: file, Failures: []string{err.Error()}})
+			_, _ = fmt.Fprintf(os.Stdout, "FAIL %s\n    %s\n", file, err)
+			continue
+		}
+
+		for _, tc := range suite.Tests {
+			// The test itself runs first, then once per fault injected.
+			for _, fault := range append([]string{""}, testFaults(tc, faults)...) {
+				name := tc.Name
+				if fault != "" {
+					name = fmt.Sprintf("%s [chaos: %s]", tc.Name, fault)
+				}
+				if c.failFast && failed > 0 {
+					results = append(results, RunResult{Name: name, Skipped: true})
+					continue
+				}
+
+				start := time.Now()
+				r := RunResult{Name: name}
+				failures, err := c.runTest(filepath.Dir(file), tc, fault, &r)
+				if err != nil {
+					failures = append(failures, err.Error())
+				}
+				r.Duration, r.Failures = time.Since(start), failures
+				results = append(results, r)
+				d := r.Duration.Round(time.Millisecond)
+
+				if len(failures) == 0 {
+					_, _ = fmt.Fprintf(os.Stdout, "PASS %s (%s)\n", name, d)
+					continue
+				}
+
+				failed++
+				_, _ = fmt.Fprintf(os.Stdout, "FAIL %s (%s)\n", name, d)
+				for _, f := range failures {
+					_, _ = fmt.Fprintf(os.Stdout, "    %s\n", strings.ReplaceAll(f, "\n", "\n    "))
+				}
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintln(os.Stdout)
+	if err := PrintRunSummary(os.Stdout, results); err != nil {
+		return err
+	}
+	summary := NewRunSummary("test", started, results)
+	if err := writeSummaryFile(c.fs, c.summaryFile, summary); err != nil {
+		return err
+	}
+	if err := writeReports(c.fs, c.reports, summary); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d tests failed", failed, len(results))
+	}
+	return nil
+}
+
+// testFaults returns the supplied faults that apply to a test case.
+func testFaults(tc TestCase, faults []string) []string {
+	out := make([]string, 0, len(faults))
+	for _, f := range faults {
+		if f == ChaosMalformedObserved && tc.ObservedResources == "" {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// relPath returns the supplied path of a test case relative to the directory
+// of its test file. Absolute paths and remote locations are left as they are.
+func relPath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) || strings.Contains(p, "://") {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// newRenderCmd returns the render command a test case defined in dir is
+// rendered with, configured like crossbench render by the configuration
+// file, and the arguments to render it with.
+func (c *testCmd) newRenderCmd(dir string, tc TestCase, fault string) (*renderCmd, []string) {
+	contextFiles := make(map[string]string, len(tc.ContextFiles))
+	for k, v := range tc.ContextFiles {
+		contextFiles[k] = relPath(dir, v)
+	}
+
+	rc := &renderCmd{
+		contextFiles:        contextFiles,
+		contextValues:       tc.ContextValues,
+		observedResources:   relPath(dir, tc.ObservedResources),
+		deleting:            tc.Deleting,
+		absent:              tc.Absent,
+		extraResources:      relPath(dir, tc.ExtraResources),
+		functionCredentials: relPath(dir, tc.FunctionCredentials),
+		profile:             tc.Profile,
+		timeout:             c.timeout,
+		refreshCache:        c.refreshCache,
+		noNetwork:           c.noNetwork,
+		offline:             c.offline,
+		daemon:              c.daemon,
+		runtimeOverrides:    getRuntimeOverridesPath(),
+		metaContext:         getMetaContext(),
+		mode:                "test",
+		chaosFault:          fault,
+		lockFile:            getLockPath(),
+		pinDigests:          getPinDigests(),
+		scanner:             getScanner(),
+		failOnSeverity:      "critical",
+		cfg:                 c.cfg,
+		fs:                  c.fs,
+	}
+
+	args := []string{relPath(dir, tc.XR), relPath(dir, tc.Composition)}
+	if tc.Functions != "" {
+		args = append(args, relPath(dir, tc.Functions))
+	}
+	return rc, args
+}
+
+// runTest renders a test case, with the supplied chaos fault injected if it's
+// set, and returns a description of each failed expectation. What was
+// rendered is recorded in r.
+func (c *testCmd) runTest(dir string, tc TestCase, fault string, r *RunResult) ([]string, error) {
+	rel := func(p string) string { return relPath(dir, p) }
+	rc, args := c.newRenderCmd(dir, tc, fault)
+
+	in, err := rc.loadInputs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if fault != "" {
+		if fault == ChaosMalformedObserved {
+			malformObserved(in.ObservedResources)
+		}
+		e := tc.chaosExpectation(fault)
+		var expected []map[string]any
+		if e.Expect != nil {
+			if expected, err = loadExpectedResources(c.fs, *e.Expect, rel); err != nil {
+				return nil, err
+			}
+		}
+		out, err := rc.render(in)
+		if err == nil {
+			r.recordRender(in, out)
+		}
+		return checkChaos(e, expected, out, err), nil
+	}
+
+	out, err := rc.render(in)
+	if err != nil {
+		return nil, err
+	}
+	r.recordRender(in, out)
+
+	expected, err := loadExpectedResources(c.fs, tc.Expect, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	failures := CheckExpectations(tc.Expect, expected, out)
+	if tc.Snapshot != "" {
+		diff, err := checkSnapshot(c.fs, rel(tc.Snapshot), out, c.updateSnapshots)
+		if err != nil {
+			return nil, err
+		}
+		if diff != "" {
+			failures = append(failures, fmt.Sprintf("rendered output differs from snapshot %q:\n%s", tc.Snapshot, strings.TrimSpace(diff)))
+		}
+	}
+	return failures, nil
+}
+
+// loadExpectedResources returns the expected resources of the supplied
+// expectations, including those of its resources file. rel resolves the path
+// of the file.
+func loadExpectedResources(fs afero.Fs, e TestExpectations, rel func(string) string) ([]map[string]any, error) {
+	expected := slices.Clone(e.Resources)
+	if e.ResourcesFile == "" {
+		return expected, nil
+	}
+	docs, err := render.LoadYAMLStream(fs, rel(e.ResourcesFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load expected resources from %q", e.ResourcesFile)
+	}
+	for _, d := range docs {
+		r := map[string]any{}
+		if err := yaml.Unmarshal(d, &r); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse expected resources from %q", e.ResourcesFile)
+		}
+		expected = append(expected, r)
+	}
+	return expected, nil
+}
+
+// CheckExpectations compares the rendered output with the expectations and
+// returns a description of each one that isn't met.
+func CheckExpectations(e TestExpectations, expected []map[string]any, out render.Outputs) []string {
+	failures := []string{}
+
+	if e.Count != nil && *e.Count != len(out.ComposedResources) {
+		failures = append(failures, fmt.Sprintf("expected %d composed resources, got %d", *e.Count, len(out.ComposedResources)))
+	}
+
+	rendered := make([]unstructured.Unstructured, len(out.ComposedResources))
+	for i := range out.ComposedResources {
+		rendered[i] = out.ComposedResources[i].Unstructured
+	}
+
+	for i := range expected {
+		want := &unstructured.Unstructured{Object: expected[i]}
+		candidates := expectedMatches(want, rendered)
+		if len(candidates) == 0 {
+			failures = append(failures, fmt.Sprintf("expected resource %s was not rendered", resourceID(want)))
+			continue
+		}
+		// Without a name, any rendered resource of the kind may match.
+		var fields []FieldChange
+		for j, u := range candidates {
+			f := diffFields("", want.Object, u.Object, DiffOptions{Subset: true})
+			if len(f) == 0 {
+				fields = nil
+				break
+			}
+			if j == 0 {
+				fields = f
+			}
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		b := &strings.Builder{}
+		printChanges(b, []ResourceChange{{Type: ChangeChanged, Resource: resourceID(want), Fields: fields}})
+		// Drop the summary line, it's only useful for whole diffs.
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		failures = append(failures, fmt.Sprintf("resource %s does not match (rendered -> expected):\n%s", resourceID(want), strings.Join(lines[1:len(lines)-1], "\n")))
+	}
+
+	byName := map[string]*unstructured.Unstructured{"xr": &out.CompositeResource.Unstructured}
+	for i := range rendered {
+		byName[rendered[i].GetAnnotations()[render.AnnotationKeyCompositionResourceName]] = &rendered[i]
+	}
+	for _, f := range e.Fields {
+		u, ok := byName[f.Resource]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("resource %q was not rendered", f.Resource))
+			continue
+		}
+		got, err := fieldpath.Pave(u.Object).GetValue(f.Path)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s %s: %v", f.Resource, f.Path, err))
+			continue
+		}
+		if !equalValues(f.Value, got) {
+			failures = append(failures, fmt.Sprintf("%s %s: expected %s, got %s", f.Resource, f.Path, diffValue(f.Value), diffValue(got)))
+		}
+	}
+
+	assertions, err := compileAssertions(e.Assert)
+	if err != nil {
+		return append(failures, err.Error())
+	}
+	failures = append(failures, EvaluateAssertions(assertions, out)...)
+
+	return failures
+}
+
+// expectedMatches returns the rendered resources an expected resource may be
+// compared with. An expected resource with a composition resource name
+// matches the resource of that name. Otherwise it matches by kind and name,
+// and by apiVersion and namespace if it sets them, or by kind alone if it has
+// no name.
+func expectedMatches(want *unstructured.Unstructured, rendered []unstructured.Unstructured) []*unstructured.Unstructured {
+	name := want.GetAnnotations()[render.AnnotationKeyCompositionResourceName]
+	matches := []*unstructured.Unstructured{}
+	for i := range rendered {
+		u := &rendered[i]
+		switch {
+		case name != "":
+			if u.GetAnnotations()[render.AnnotationKeyCompositionResourceName] != name {
+				continue
+			}
+		case want.GetKind() != u.GetKind(),
+			want.GetAPIVersion() != "" && want.GetAPIVersion() != u.GetAPIVersion(),
+			want.GetName() != "" && want.GetName() != u.GetName(),
+			want.GetNamespace() != "" && want.GetNamespace() != u.GetNamespace():
+			continue
+		}
+		matches = append(matches, u)
+	}
+	return matches
+}
+
+// findTestFiles expands the supplied files and directories to a list of test
+// files.
+func findTestFiles(fs afero.Fs, paths []string) ([]string, error) {
+	files := []string{}
+	for _, p := range paths {
+		info, err := fs.Stat(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot stat %q", p)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = afero.Walk(fs, p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(path, ".test.yaml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot find test files in %q", p)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no test files found")
+	}
+	return files, nil
+}
+
+func loadTestSuite(fs afero.Fs, file string) (*TestSuite, error) {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read test file %q", file)
+	}
+	suite := &TestSuite{}
+	if err := yaml.Unmarshal(data, suite); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse test file %q", file)
+	}
+	for i := range suite.Tests {
+		if suite.Tests[i].Name == "" {
+			suite.Tests[i].Name = fmt.Sprintf("%s#%d", file, i)
+		}
+		if suite.Tests[i].XR == "" || suite.Tests[i].Composition == "" {
+			return nil, errors.Errorf("test %q in %q must specify an xr and a composition", suite.Tests[i].Name, file)
+		}
+		for _, e := range suite.Tests[i].Chaos {
+			if !slices.Contains(chaosFaults, e.Fault) {
+				return nil, errors.Errorf("test %q in %q expects unknown chaos fault %q", suite.Tests[i].Name, file, e.Fault)
+			}
+		}
+	}
+	return suite, nil
+}
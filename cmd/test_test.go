@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/spf13/afero"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+func TestTestRenderCmdUsesConfig(t *testing.T) {
+	config := filepath.Join(t.TempDir(), ".crossbench.yaml")
+	data := `functions:
+  crossplane-contrib-function-patch-and-transform:
+    image: function-patch-and-transform:dev
+`
+	if err := os.WriteFile(config, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs := afero.NewOsFs()
+	cfg, err := loadConfig(fs, config, true)
+	if err != nil {
+		t.Fatalf("loadConfig(...): %v", err)
+	}
+
+	c := &testCmd{cfg: cfg, fs: fs}
+	tc := TestCase{XR: "xr.yaml", Composition: "composition.yaml", Functions: "functions.yaml"}
+	rc, args := c.newRenderCmd(filepath.Join("..", "testdata"), tc, "")
+	in, err := rc.loadInputs(args)
+	if err != nil {
+		t.Fatalf("loadInputs(...): %v", err)
+	}
+	if len(in.Functions) != 1 {
+		t.Fatalf("loadInputs(...): want 1 function, got %d", len(in.Functions))
+	}
+
+	want := map[string]string{
+		render.AnnotationKeyRuntimeDockerImage:      "function-patch-and-transform:dev",
+		render.AnnotationKeyRuntimeDockerPullPolicy: string(render.AnnotationValueRuntimeDockerPullPolicyNever),
+	}
+	got := map[string]string{}
+	for k := range want {
+		got[k] = in.Functions[0].GetAnnotations()[k]
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("\nA test should run the image the configuration file overrides a function with.\nloadInputs(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestTestCommandLoadsConfig(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	cmd := NewTestCommand()
+	cmd.SetArgs([]string{"--config", missing, filepath.Join("..", "testdata", "bucket.test.yaml")})
+	cmd.SetOut(&strings.Builder{})
+	cmd.SetErr(&strings.Builder{})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("\nA test run should fail before rendering when the configuration file it's explicitly given can't be loaded.\nExecute(): want error about %q, got %v", missing, err)
+	}
+}
+
+func TestCheckExpectations(t *testing.T) {
+	bucket := func(resourceName, name, region string) composed.Unstructured {
+		u := composed.New()
+		u.SetAPIVersion("s3.aws.upbound.io/v1beta1")
+		u.SetKind("Bucket")
+		u.SetName(name)
+		u.SetAnnotations(map[string]string{render.AnnotationKeyCompositionResourceName: resourceName})
+		u.Object["spec"] = map[string]any{"forProvider": map[string]any{"region": region}}
+		return *u
+	}
+	out := render.Outputs{
+		CompositeResource: composite.New(),
+		ComposedResources: []composed.Unstructured{
+			bucket("primary", "bucket-primary", "us-east-2"),
+			bucket("replica", "bucket-replica", "us-west-2"),
+		},
+	}
+	region := func(r string) map[string]any {
+		return map[string]any{"forProvider": map[string]any{"region": r}}
+	}
+
+	cases := map[string]struct {
+		reason   string
+		expected map[string]any
+		want     []string
+	}{
+		"CompositionResourceName": {
+			reason: "Expected resources with a composition resource name should match the resource of that name.",
+			expected: map[string]any{
+				"metadata": map[string]any{"annotations": map[string]any{render.AnnotationKeyCompositionResourceName: "replica"}},
+				"spec":     region("us-west-2"),
+			},
+			want: []string{},
+		},
+		"KindAndName": {
+			reason: "Expected resources without a composition resource name should match by apiVersion, kind and name.",
+			expected: map[string]any{
+				"apiVersion": "s3.aws.upbound.io/v1beta1",
+				"kind":       "Bucket",
+				"metadata":   map[string]any{"name": "bucket-replica"},
+				"spec":       region("us-west-2"),
+			},
+			want: []string{},
+		},
+		"KindAndNameDiffers": {
+			reason: "Expected resources matched by kind and name should report the fields that differ.",
+			expected: map[string]any{
+				"kind":     "Bucket",
+				"metadata": map[string]any{"name": "bucket-primary"},
+				"spec":     region("us-west-2"),
+			},
+			want: []string{"resource Bucket/bucket-primary does not match (rendered -> expected):\n    ~ spec.forProvider.region: \"us-east-2\" -> \"us-west-2\""},
+		},
+		"OtherAPIVersion": {
+			reason: "Expected resources shouldn't match resources of another apiVersion.",
+			expected: map[string]any{
+				"apiVersion": "s3.aws.crossplane.io/v1beta1",
+				"kind":       "Bucket",
+				"metadata":   map[string]any{"name": "bucket-primary"},
+			},
+			want: []string{"expected resource Bucket/bucket-primary was not rendered"},
+		},
+		"Kind": {
+			reason: "Expected resources without a name should match any resource of their kind.",
+			expected: map[string]any{
+				"kind": "Bucket",
+				"spec": region("us-west-2"),
+			},
+			want: []string{},
+		},
+		"KindDiffers": {
+			reason: "Expected resources without a name should fail if no resource of their kind matches.",
+			expected: map[string]any{
+				"kind": "Bucket",
+				"spec": region("eu-west-1"),
+			},
+			want: []string{"resource Bucket does not match (rendered -> expected):\n    ~ spec.forProvider.region: \"us-east-2\" -> \"eu-west-1\""},
+		},
+		"OtherKind": {
+			reason: "Expected resources shouldn't match resources of another kind.",
+			expected: map[string]any{
+				"kind": "Table",
+			},
+			want: []string{"expected resource Table was not rendered"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CheckExpectations(TestExpectations{}, []map[string]any{tc.expected}, out)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("\n%s\nCheckExpectations(...): -want, +got:\n%s", tc.reason, diff)
+			}
+		})
+	}
+}
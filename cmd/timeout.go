@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+// What to do when a render times out.
+const (
+	// TimeoutBehaviorFail discards the output of the render.
+	TimeoutBehaviorFail = "fail"
+
+	// TimeoutBehaviorPartial writes what the completed pipeline steps
+	// rendered, followed by a timeout report, before failing.
+	TimeoutBehaviorPartial = "partial"
+)
+
+// getTimeoutBehavior returns what to do when a render times out
+// Default: fail, configurable via CROSSBENCH_TIMEOUT_BEHAVIOR env var
+func getTimeoutBehavior() string {
+	if b := os.Getenv("CROSSBENCH_TIMEOUT_BEHAVIOR"); b != "" {
+		return b
+	}
+	return TimeoutBehaviorFail
+}
+
+// A TimeoutReport describes where the Function pipeline was when a render
+// timed out.
+type TimeoutReport struct {
+	// Timeout is the --timeout that was hit.
+	Timeout string `json:"timeout"`
+
+	// InProgress are the steps that were running. More than one step runs at
+	// once with --parallel-steps. Empty if the render timed out starting the
+	// Functions.
+	InProgress []string `json:"inProgress,omitempty"`
+
+	// Completed are the steps that completed. The partial output is what
+	// they rendered.
+	Completed []string `json:"completed,omitempty"`
+
+	// Pending are the steps that didn't start.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// newTimeoutReport reports a timeout of the supplied Composition's pipeline
+// while the steps in inProgress were running.
+func newTimeoutReport(comp *apiextensionsv1.Composition, timeout time.Duration, completed []string, inProgress []apiextensionsv1.PipelineStep) TimeoutReport {
+	r := TimeoutReport{Timeout: timeout.String(), Completed: completed}
+	running := map[string]bool{}
+	for _, s := range inProgress {
+		r.InProgress = append(r.InProgress, s.Step)
+		running[s.Step] = true
+	}
+	for _, s := range comp.Spec.Pipeline[len(completed):] {
+		if !running[s.Step] {
+			r.Pending = append(r.Pending, s.Step)
+		}
+	}
+	return r
+}
+
+// Object returns the report as a resource of kind: TimeoutReport, to be
+// written with the partial output.
+func (r TimeoutReport) Object() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "render.crossplane.io/v1beta1",
+		"kind":       "TimeoutReport",
+		"timeout":    r.Timeout,
+	}}
+	for k, steps := range map[string][]string{"inProgress": r.InProgress, "completed": r.Completed, "pending": r.Pending} {
+		if len(steps) > 0 {
+			_ = unstructured.SetNestedStringSlice(u.Object, steps, k)
+		}
+	}
+	return u
+}
+
+// A pipelineTimeoutError is returned when the Function pipeline times out.
+// It carries the output of the steps that completed.
+type pipelineTimeoutError struct {
+	Report TimeoutReport
+
+	// Outputs rendered by the completed steps. Empty if no step completed.
+	Outputs render.Outputs
+}
+
+func (e *pipelineTimeoutError) Error() string {
+	if len(e.Report.InProgress) == 0 {
+		return fmt.Sprintf("timed out after %s starting functions", e.Report.Timeout)
+	}
+	return fmt.Sprintf("timed out after %s running pipeline step(s) %s", e.Report.Timeout, strings.Join(e.Report.InProgress, ", "))
+}
+
+// writePartialOutputs writes what the completed steps of a render that timed
+// out rendered, followed by the timeout report, to stdout or the output
+// directory.
+func (c *renderCmd) writePartialOutputs(te *pipelineTimeoutError, outputDir string, layout outputLayout) error {
+	warnf("Render %v, writing the output of %d completed step(s)", te, len(te.Report.Completed))
+	if c.injectsMeta() {
+		stripMetaContext(te.Outputs)
+	}
+	report, err := encodeYAML(te.Report.Object())
+	if err != nil {
+		return err
+	}
+
+	if outputDir != "" {
+		if te.Outputs.CompositeResource != nil {
+			if _, err := writeOutputDir(c.fs, outputDir, te.Outputs, c.includeFunctionResults, c.includeContext, layout); err != nil {
+				return err
+			}
+		}
+		if err := c.fs.MkdirAll(outputDir, 0755); err != nil {
+			return errors.Wrapf(err, "cannot create output directory %q", outputDir)
+		}
+		path := filepath.Join(outputDir, "timeout-report.yaml")
+		return errors.Wrapf(afero.WriteFile(c.fs, path, report, 0644), "cannot write %q", path)
+	}
+
+	if te.Outputs.CompositeResource != nil {
+		if err := writeOutputs(os.Stdout, te.Outputs, c.includeFunctionResults, c.includeContext); err != nil {
+			return err
+		}
+	}
+	_, _ = fmt.Fprintln(os.Stdout, "---")
+	_, err = os.Stdout.Write(report)
+	return err
+}
+
+// asTimeoutError returns the pipeline timeout err wraps, if any.
+func asTimeoutError(err error) (*pipelineTimeoutError, bool) {
+	te := &pipelineTimeoutError{}
+	ok := errors.As(err, &te)
+	return te, ok
+}
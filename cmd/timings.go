@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+)
+
+// Where --timings are written.
+const (
+	// TimingsStderr prints a table of the step timings to stderr.
+	TimingsStderr = "stderr"
+
+	// TimingsOutput adds a kind: Timing document to the rendered output.
+	TimingsOutput = "output"
+)
+
+// stepTimings record how long the functions took to start, and each pipeline
+// step took to run, for --timings.
+type stepTimings struct {
+	mu      sync.Mutex
+	startup time.Duration
+	steps   map[string]time.Duration
+
+	// Total is the wall time of the render.
+	Total time.Duration
+}
+
+// newStepTimings returns empty timings.
+func newStepTimings() *stepTimings {
+	return &stepTimings{steps: map[string]time.Duration{}}
+}
+
+func (t *stepTimings) FunctionsStarted(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.startup = d
+}
+
+func (t *stepTimings) StepRan(step apiextensionsv1.PipelineStep, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.steps[step.Step] = d
+}
+
+// Print writes the timings of the supplied Composition's steps as a table,
+// in pipeline order.
+func (t *stepTimings) Print(w io.Writer, comp *apiextensionsv1.Composition) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STEP\tFUNCTION\tDURATION")
+	_, _ = fmt.Fprintf(tw, "(startup)\t-\t%s\n", t.startup.Round(time.Millisecond/10))
+	for _, s := range comp.Spec.Pipeline {
+		if d, ok := t.steps[s.Step]; ok {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Step, s.FunctionRef.Name, d.Round(time.Millisecond/10))
+		}
+	}
+	_, _ = fmt.Fprintf(tw, "(total)\t-\t%s\n", t.Total.Round(time.Millisecond/10))
+	return tw.Flush()
+}
+
+// Object returns the timings of the supplied Composition's steps as a
+// resource of kind: Timing, in pipeline order.
+func (t *stepTimings) Object(comp *apiextensionsv1.Composition) *unstructured.Unstructured {
+	steps := []any{}
+	for _, s := range comp.Spec.Pipeline {
+		if d, ok := t.steps[s.Step]; ok {
+			steps = append(steps, map[string]any{
+				"step":     s.Step,
+				"function": s.FunctionRef.Name,
+				"duration": d.Round(time.Millisecond / 10).String(),
+			})
+		}
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "render.crossplane.io/v1beta1",
+		"kind":       "Timing",
+		"startup":    t.startup.Round(time.Millisecond / 10).String(),
+		"steps":      steps,
+		"total":      t.Total.Round(time.Millisecond / 10).String(),
+	}}
+}
+
+// writeTimings writes the timings of a render where --timings says: to
+// stderr, or as a document after the rendered output on stdout or in the
+// output directory.
+func (c *renderCmd) writeTimings(t *stepTimings, comp *apiextensionsv1.Composition, stdout, stderr io.Writer, outputDir string) error {
+	if c.timings == TimingsStderr {
+		return t.Print(stderr, comp)
+	}
+	b, err := encodeYAML(t.Object(comp))
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal timings to YAML")
+	}
+	if outputDir != "" {
+		path := filepath.Join(outputDir, "timing.yaml")
+		return errors.Wrapf(afero.WriteFile(c.fs, path, b, 0644), "cannot write %q", path)
+	}
+	_, _ = fmt.Fprintln(stdout, "---")
+	_, err = stdout.Write(b)
+	return err
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+)
+
+// tracer creates the spans of crossbench. It doesn't record anything unless
+// tracing is set up with --otel-endpoint.
+var tracer = otel.Tracer("github.com/gjbravi/crossbench")
+
+// getOTelEndpoint returns the OTLP gRPC endpoint traces are exported to
+// Default: none, configurable via CROSSBENCH_OTEL_ENDPOINT env var
+func getOTelEndpoint() string {
+	return os.Getenv("CROSSBENCH_OTEL_ENDPOINT")
+}
+
+// setupTracing exports traces to the OTLP gRPC collector at the supplied
+// endpoint. Endpoints without a scheme, e.g. localhost:4317, are connected to
+// without TLS. The returned function flushes the traces that are left.
+func setupTracing(ctx context.Context, endpoint string) (func(), error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpointURL(endpoint)}
+	if !strings.Contains(endpoint, "://") {
+		opts = []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure()}
+	}
+	exp, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot export traces to %q", endpoint)
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "crossbench"),
+		attribute.String("service.version", version),
+	)
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			warnf("Cannot export traces to %s: %v", endpoint, err)
+		}
+	}, nil
+}
+
+// traced runs a command in a span named after it, exporting the trace to the
+// --otel-endpoint, if set.
+func (c *renderCmd) traced(run func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if c.otelEndpoint == "" {
+			return run(cmd, args)
+		}
+		shutdown, err := setupTracing(context.Background(), c.otelEndpoint)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+
+		ctx, span := tracer.Start(context.Background(), cmd.CommandPath(), trace.WithAttributes(attribute.StringSlice("crossbench.args", args)))
+		defer span.End()
+		c.ctx = ctx
+		err = run(cmd, args)
+		recordSpanError(span, err)
+		return err
+	}
+}
+
+// context returns the context the command runs in, which carries its trace.
+func (c *renderCmd) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// recordSpanError records the supplied error, if any, on the span.
+func recordSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/afero"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composed"
+)
+
+const checkAPIUpgrade = "api-upgrade"
+
+// An APIUpgrade describes how a provider changes the schema of a kind
+// between two API versions.
+type APIUpgrade struct {
+	// APIVersion and Kind of the resources that are being upgraded.
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// To is the API version the resources are upgraded to.
+	To string `json:"to"`
+
+	// RenamedFields maps field paths in the old version to their path in the
+	// new version. Paths may contain [*] wildcards.
+	RenamedFields map[string]string `json:"renamedFields,omitempty"`
+
+	// RemovedFields are field paths that no longer exist in the new version.
+	RemovedFields []string `json:"removedFields,omitempty"`
+}
+
+// An APIUpgradeMap is a file of APIUpgrades.
+type APIUpgradeMap struct {
+	Upgrades []APIUpgrade `json:"upgrades"`
+}
+
+// loadAPIUpgradeMap loads the API upgrade map at the supplied path.
+func loadAPIUpgradeMap(fs afero.Fs, path string) (*APIUpgradeMap, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read API upgrade map %q", path)
+	}
+	m := &APIUpgradeMap{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse API upgrade map %q", path)
+	}
+	return m, nil
+}
+
+// CheckAPIUpgrades reports every composed resource that would need changes
+// to its composition when its provider is upgraded: resources of an upgraded
+// kind, and the renamed or removed fields they set.
+func CheckAPIUpgrades(m *APIUpgradeMap, cds []composed.Unstructured) []Finding {
+	findings := []Finding{}
+	for _, u := range m.Upgrades {
+		for i := range cds {
+			if cds[i].GetAPIVersion() != u.APIVersion || cds[i].GetKind() != u.Kind {
+				continue
+			}
+			id := resourceID(&cds[i].Unstructured)
+			findings = append(findings, Finding{
+				Check:    checkAPIUpgrade,
+				Severity: SeverityWarning,
+				Resource: id,
+				Message:  fmt.Sprintf("apiVersion must change from %s to %s", u.APIVersion, u.To),
+			})
+
+			p := fieldpath.Pave(cds[i].Object)
+			renamed := make([]string, 0, len(u.RenamedFields))
+			for from := range u.RenamedFields {
+				renamed = append(renamed, from)
+			}
+			sort.Strings(renamed)
+			for _, from := range renamed {
+				to := u.RenamedFields[from]
+				for _, f := range setFields(p, from) {
+					findings = append(findings, Finding{
+						Check:    checkAPIUpgrade,
+						Severity: SeverityWarning,
+						Resource: id,
+						Message:  fmt.Sprintf("field %s is renamed to %s in %s", f, to, u.To),
+					})
+				}
+			}
+			for _, removed := range u.RemovedFields {
+				for _, f := range setFields(p, removed) {
+					findings = append(findings, Finding{
+						Check:    checkAPIUpgrade,
+						Severity: SeverityWarning,
+						Resource: id,
+						Message:  fmt.Sprintf("field %s is removed in %s", f, u.To),
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// setFields returns the concrete paths matching the supplied, possibly
+// wildcarded, path that are set.
+func setFields(p *fieldpath.Paved, path string) []string {
+	paths, err := p.ExpandWildcards(path)
+	if err != nil {
+		return nil
+	}
+	set := []string{}
+	for _, path := range paths {
+		if _, err := p.GetValue(path); err == nil {
+			set = append(set, path)
+		}
+	}
+	return set
+}
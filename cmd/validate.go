@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	extv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	"github.com/crossplane/crossplane/v2/cmd/crank/beta/validate"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/crd"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/load"
+)
+
+// NewValidateCommand creates a new validate command.
+func NewValidateCommand() *cobra.Command {
+	cmd := &validateCmd{
+		renderCmd: renderCmd{
+			fs: afero.NewOsFs(),
+		},
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "validate <composite-resource> [composition] [functions]",
+		Short: "Render a Crossplane composition and validate the output against schemas",
+		Long: `Validate renders the XR exactly like the render command does, then validates
+the input XR and every rendered composed resource against the supplied XRD and
+CRD schemas. Each schema or CEL validation error is reported per resource.
+
+Schemas are read from the --schemas flag, which accepts a YAML file, a
+directory of YAML files, or a comma-separated list of both. XRDs are converted
+to their composite (and claim) CRDs before validation.`,
+		Args: cobra.RangeArgs(1, 3),
+		RunE: cmd.traced(hermetic(cmd.networkDisabled, cmd.run)),
+	}
+
+	// Flags
+	cmd.addInputFlags(cobraCmd)
+	cobraCmd.Flags().StringVarP(&cmd.schemas, "schemas", "s", "", "A YAML file, directory of YAML files, or comma-separated list of both containing XRDs and CRDs to validate against.")
+	cobraCmd.Flags().BoolVar(&cmd.errorOnMissingSchemas, "error-on-missing-schemas", false, "Fail if a schema is missing for any validated resource.")
+	cobraCmd.Flags().BoolVar(&cmd.skipSuccessResults, "skip-success-results", false, "Only print resources that failed validation.")
+	_ = cobraCmd.MarkFlagRequired("schemas")
+
+	return cobraCmd
+}
+
+type validateCmd struct {
+	renderCmd
+
+	// Flags
+	schemas               string
+	errorOnMissingSchemas bool
+	skipSuccessResults    bool
+}
+
+func (c *validateCmd) run(cmd *cobra.Command, args []string) error {
+	if err := c.loadConfig(cmd); err != nil {
+		return err
+	}
+
+	crds, err := loadSchemas(c.schemas)
+	if err != nil {
+		return err
+	}
+
+	in, err := c.loadInputs(args)
+	if err != nil {
+		return err
+	}
+
+	if len(c.packageXRDs) > 0 {
+		pcrds, err := crd.ConvertToCRDs(c.packageXRDs)
+		if err != nil {
+			return errors.Wrap(err, "cannot convert package XRDs to CRDs")
+		}
+		crds = append(crds, pcrds...)
+	}
+
+	out, err := c.render(in)
+	if err != nil {
+		return err
+	}
+
+	resources := make([]*unstructured.Unstructured, 0, len(out.ComposedResources)+1)
+	resources = append(resources, &in.CompositeResource.Unstructured)
+	for i := range out.ComposedResources {
+		resources = append(resources, &out.ComposedResources[i].Unstructured)
+	}
+
+	return validate.SchemaValidation(context.Background(), resources, crds, c.errorOnMissingSchemas, c.skipSuccessResults, os.Stdout)
+}
+
+// loadSchemas loads XRDs and CRDs from the supplied sources and converts them
+// all to CRDs.
+func loadSchemas(sources string) ([]*extv1.CustomResourceDefinition, error) {
+	loader, err := load.NewLoader(sources)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load schemas from %q", sources)
+	}
+
+	exts, err := loader.Load()
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load schemas from %q", sources)
+	}
+
+	crds, err := crd.ConvertToCRDs(exts)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot convert XRDs to CRDs")
+	}
+
+	return crds, nil
+}
@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+)
+
+// The apiVersion and kind of a versions manifest. They make dependency update
+// tools that only update Kubernetes manifests, such as the kubernetes manager
+// of Renovate, recognize it.
+const (
+	VersionsManifestAPIVersion = "crossbench.io/v1alpha1"
+	VersionsManifestKind       = "FunctionVersions"
+)
+
+// getVersionsManifestPath returns the path of the function versions manifest
+// Default: functions-versions.yaml, configurable via CROSSBENCH_VERSIONS_MANIFEST env var
+func getVersionsManifestPath() string {
+	if path := os.Getenv("CROSSBENCH_VERSIONS_MANIFEST"); path != "" {
+		return path
+	}
+	return "functions-versions.yaml"
+}
+
+// A VersionsManifest lists the version of each Function, as image references
+// dependency update tools like Renovate and Dependabot can update. The lock
+// file is synced to it with crossbench functions apply-manifest.
+type VersionsManifest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	Functions []VersionedFunction `json:"functions"`
+}
+
+// A VersionedFunction is a Function and the package version it should use.
+type VersionedFunction struct {
+	// Name of the Function, as referenced by Composition pipeline steps.
+	Name string `json:"name"`
+
+	// Image is the Function's package, tagged with its version, e.g.
+	// xpkg.crossplane.io/crossplane-contrib/function-auto-ready:v0.5.0.
+	Image string `json:"image"`
+}
+
+// loadVersionsManifest loads the versions manifest at the supplied path. It
+// returns nil if the file doesn't exist.
+func loadVersionsManifest(fs afero.Fs, path string) (*VersionsManifest, error) {
+	data, err := afero.ReadFile(fs, path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read versions manifest %q", path)
+	}
+	m := &VersionsManifest{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse versions manifest %q", path)
+	}
+	if m.APIVersion != VersionsManifestAPIVersion || m.Kind != VersionsManifestKind {
+		return nil, errors.Errorf("%q is not a versions manifest: must be apiVersion: %s, kind: %s", path, VersionsManifestAPIVersion, VersionsManifestKind)
+	}
+	for _, f := range m.Functions {
+		if _, err := name.NewTag(f.Image, name.StrictValidation); err != nil {
+			return nil, errors.Wrapf(err, "invalid image %q of function %q in versions manifest %q, must be a tagged package", f.Image, f.Name, path)
+		}
+	}
+	return m, nil
+}
+
+// saveVersionsManifest writes the versions manifest to the supplied path,
+// with its Functions sorted by name. Each Function is preceded by a renovate
+// comment naming its package, for Renovate regex managers.
+func saveVersionsManifest(fs afero.Fs, path string, m *VersionsManifest) error {
+	sort.Slice(m.Functions, func(i, j int) bool { return m.Functions[i].Name < m.Functions[j].Name })
+	b := &strings.Builder{}
+	b.WriteString("# Versions of the functions used by the compositions. Update an image's tag,\n")
+	b.WriteString("# by hand or with Renovate or Dependabot, then run crossbench functions\n")
+	b.WriteString("# apply-manifest to pin it in the lock file.\n")
+	fmt.Fprintf(b, "apiVersion: %s\nkind: %s\nfunctions:\n", VersionsManifestAPIVersion, VersionsManifestKind)
+	for _, f := range m.Functions {
+		ref, err := name.NewTag(f.Image)
+		if err != nil {
+			return errors.Wrapf(err, "invalid image %q of function %q", f.Image, f.Name)
+		}
+		fmt.Fprintf(b, "# renovate: datasource=docker depName=%s\n", ref.Context().Name())
+		fmt.Fprintf(b, "- name: %s\n  image: %s\n", f.Name, f.Image)
+	}
+	return errors.Wrapf(afero.WriteFile(fs, path, []byte(b.String()), 0644), "cannot write versions manifest %q", path)
+}
+
+func newFunctionsManifestCommand() *cobra.Command {
+	cmd := &functionsManifestCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "manifest <composition-file-or-dir>...",
+		Short: "Write the versions of the functions used by compositions to a manifest",
+		Long: `Manifest writes the version of every function used by the supplied compositions
+to a versions manifest, an image reference per function that dependency update
+tools such as Renovate and Dependabot can keep up to date:
+
+  apiVersion: crossbench.io/v1alpha1
+  kind: FunctionVersions
+  functions:
+  # renovate: datasource=docker depName=xpkg.crossplane.io/crossplane-contrib/function-auto-ready
+  - name: function-auto-ready
+    image: xpkg.crossplane.io/crossplane-contrib/function-auto-ready:v0.5.0
+
+Functions already in the manifest keep their version unless --update is set.
+Others take the version pinned in the lock file, or the latest release.
+
+Run crossbench functions apply-manifest after the manifest changes to pin the
+new versions in the lock file.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.config, "config", getConfigPath(), "Path to the crossbench configuration file, for per-function resolvers and version constraints. It's optional unless set explicitly.")
+	cobraCmd.Flags().StringVar(&cmd.manifest, "manifest", getVersionsManifestPath(), "Path to the versions manifest.")
+	cobraCmd.Flags().StringVar(&cmd.lockFile, "lock-file", getLockPath(), "Path to the function lock file. Functions in it take its versions.")
+	cobraCmd.Flags().BoolVar(&cmd.update, "update", false, "Look up the latest release of every function again, including those already in the manifest.")
+
+	return cobraCmd
+}
+
+type functionsManifestCmd struct {
+	config   string
+	manifest string
+	lockFile string
+	update   bool
+
+	fs afero.Fs
+}
+
+func (c *functionsManifestCmd) run(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(c.fs, c.config, cmd.Flags().Changed("config"))
+	if err != nil {
+		return err
+	}
+	if err := setFunctionResolution(cfg.Functions); err != nil {
+		return err
+	}
+	creds, err := newGitHubCredentialChain(getGitHubAuthMode(), "", cfg.GitHub)
+	if err != nil {
+		return err
+	}
+	gitHubCredentials = creds
+
+	comps, err := loadCompositionPaths(c.fs, args)
+	if err != nil {
+		return err
+	}
+	m, err := loadVersionsManifest(c.fs, c.manifest)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &VersionsManifest{APIVersion: VersionsManifestAPIVersion, Kind: VersionsManifestKind}
+	}
+	images := map[string]string{}
+	if !c.update {
+		for _, f := range m.Functions {
+			images[f.Name] = f.Image
+		}
+	}
+	l, err := loadLock(c.fs, c.lockFile)
+	if err != nil {
+		return err
+	}
+	if c.update || l == nil {
+		l = &Lock{Functions: map[string]LockedFunction{}}
+	}
+
+	for _, comp := range comps {
+		if comp.Spec.Mode != apiextensionsv1.CompositionModePipeline {
+			continue
+		}
+		fns, _, err := lockedFunctions(comp, l, c.fs, c.update)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve functions of Composition %q", comp.GetName())
+		}
+		for _, fn := range fns {
+			if _, ok := images[fn.GetName()]; ok {
+				continue
+			}
+			image, err := taggedPackage(fn, l)
+			if err != nil {
+				return err
+			}
+			images[fn.GetName()] = image
+			infof("Function %q is at %s", fn.GetName(), image)
+		}
+	}
+
+	m.Functions = make([]VersionedFunction, 0, len(images))
+	for n, image := range images {
+		m.Functions = append(m.Functions, VersionedFunction{Name: n, Image: image})
+	}
+	if err := saveVersionsManifest(c.fs, c.manifest, m); err != nil {
+		return err
+	}
+	infof("Wrote %d function(s) to %s", len(m.Functions), c.manifest)
+	return nil
+}
+
+// taggedPackage returns the tagged package of the supplied Function, taking
+// the tag from the lock if it's pinned to a digest there.
+func taggedPackage(fn pkgv1.Function, l *Lock) (string, error) {
+	pkg := fn.Spec.Package
+	if lf, ok := l.Functions[fn.GetName()]; ok {
+		pkg = lf.Package
+	}
+	ref, err := name.NewTag(pkg, name.StrictValidation)
+	if err != nil {
+		return "", errors.Wrapf(err, "function %q package %q has no version tag", fn.GetName(), pkg)
+	}
+	return ref.String(), nil
+}
+
+func newFunctionsApplyManifestCommand() *cobra.Command {
+	cmd := &functionsApplyManifestCmd{
+		fs: afero.NewOsFs(),
+	}
+
+	cobraCmd := &cobra.Command{
+		Use:   "apply-manifest",
+		Short: "Pin the function versions of the versions manifest in the lock file",
+		Long: `Apply-manifest syncs the lock file to the versions manifest written by
+crossbench functions manifest: every function whose image in the manifest
+differs from its locked package is resolved to its OCI digest and locked.
+Functions in the lock file but not in the manifest are left alone.
+
+Run it after Renovate or Dependabot updates the manifest, e.g. as a Renovate
+postUpgradeTask, so renders use the new versions. With --check nothing is
+resolved or written; it fails listing the functions that are out of sync,
+to catch a manifest update whose lock file wasn't updated in CI.`,
+		Args: cobra.NoArgs,
+		RunE: cmd.run,
+	}
+
+	cobraCmd.Flags().StringVar(&cmd.manifest, "manifest", getVersionsManifestPath(), "Path to the versions manifest.")
+	cobraCmd.Flags().StringVar(&cmd.lockFile, "lock-file", getLockPath(), "Path to the function lock file to update.")
+	cobraCmd.Flags().BoolVar(&cmd.check, "check", false, "Fail if the lock file isn't in sync with the manifest instead of updating it.")
+
+	return cobraCmd
+}
+
+type functionsApplyManifestCmd struct {
+	manifest string
+	lockFile string
+	check    bool
+
+	fs afero.Fs
+}
+
+func (c *functionsApplyManifestCmd) run(_ *cobra.Command, _ []string) error {
+	m, err := loadVersionsManifest(c.fs, c.manifest)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.Errorf("versions manifest %q doesn't exist, write it with crossbench functions manifest", c.manifest)
+	}
+	l, err := loadLock(c.fs, c.lockFile)
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		l = &Lock{Functions: map[string]LockedFunction{}}
+	}
+
+	stale := []string{}
+	for _, f := range m.Functions {
+		if lf, ok := l.Functions[f.Name]; ok && lf.Package == f.Image {
+			continue
+		}
+		if c.check {
+			locked := "not locked"
+			if lf, ok := l.Functions[f.Name]; ok {
+				locked = "locked " + lf.Package
+			}
+			stale = append(stale, fmt.Sprintf("%s (%s, %s)", f.Name, f.Image, locked))
+			continue
+		}
+		digest, err := crane.Digest(f.Image)
+		if err != nil {
+			return errors.Wrapf(err, "cannot resolve digest of function %q image %q", f.Name, f.Image)
+		}
+		l.Functions[f.Name] = LockedFunction{Package: f.Image, Digest: digest}
+		stale = append(stale, f.Name)
+		infof("Locked function %q to %s@%s", f.Name, f.Image, digest)
+	}
+
+	if c.check {
+		if len(stale) > 0 {
+			return errors.Errorf("lock file %q is out of sync with versions manifest %q, run crossbench functions apply-manifest:\n  %s", c.lockFile, c.manifest, strings.Join(stale, "\n  "))
+		}
+		infof("Lock file %s is in sync with %s", c.lockFile, c.manifest)
+		return nil
+	}
+	if len(stale) == 0 {
+		infof("Lock file %s is already in sync with %s", c.lockFile, c.manifest)
+		return nil
+	}
+	if err := saveLock(c.fs, c.lockFile, l); err != nil {
+		return err
+	}
+	infof("Updated %d function(s) in %s", len(stale), c.lockFile)
+	return nil
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	conregv1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource/unstructured/composite"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgmetav1 "github.com/crossplane/crossplane/v2/apis/pkg/meta/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/load"
+)
+
+// packageStreamFile is the file in a Crossplane package image that holds its
+// metadata and objects.
+const packageStreamFile = "package.yaml"
+
+// A configurationPackage holds the contents of a Crossplane Configuration
+// package that matter for rendering.
+type configurationPackage struct {
+	Source       string
+	Meta         *pkgmetav1.Configuration
+	Compositions []*apiextensionsv1.Composition
+	XRDs         []*unstructured.Unstructured
+}
+
+// isPackageSource returns true if the supplied composition source is a
+// Configuration package: a local .xpkg file, or an OCI reference with an
+// explicit tag or digest that isn't a local file.
+func isPackageSource(fs afero.Fs, src string) bool {
+	if strings.HasSuffix(src, ".xpkg") {
+		return true
+	}
+	if ok, _ := afero.Exists(fs, src); ok {
+		return false
+	}
+	_, err := name.ParseReference(src, name.StrictValidation)
+	return err == nil
+}
+
+// loadConfigurationPackage loads a Configuration package from a local .xpkg
+// file or an OCI reference.
+func loadConfigurationPackage(fs afero.Fs, src string) (*configurationPackage, error) {
+	var img conregv1.Image
+	if ok, _ := afero.Exists(fs, src); ok {
+		i, err := tarball.Image(func() (io.ReadCloser, error) { return fs.Open(src) }, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read package %q", src)
+		}
+		img = i
+	} else {
+		i, err := crane.Pull(src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot pull package %q", src)
+		}
+		img = i
+	}
+
+	stream, err := packageStream(img)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read package %q", src)
+	}
+
+	docs, err := load.YamlStream(bytes.NewReader(stream))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot parse %s of package %q", packageStreamFile, src)
+	}
+
+	pkg := &configurationPackage{Source: src}
+	for _, doc := range docs {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal(doc, &u.Object); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s of package %q", packageStreamFile, src)
+		}
+		if doc, err = convertLegacyDocument(doc, u); err != nil {
+			return nil, err
+		}
+		gvk := u.GroupVersionKind()
+		switch {
+		case gvk.Group == pkgmetav1.Group && gvk.Kind == pkgmetav1.ConfigurationKind:
+			pkg.Meta = &pkgmetav1.Configuration{}
+			if err := yaml.Unmarshal(doc, pkg.Meta); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse metadata of package %q", src)
+			}
+		case gvk == apiextensionsv1.CompositionGroupVersionKind:
+			comp := &apiextensionsv1.Composition{}
+			if err := yaml.Unmarshal(doc, comp); err != nil {
+				return nil, errors.Wrapf(err, "cannot parse Composition in package %q", src)
+			}
+			pkg.Compositions = append(pkg.Compositions, comp)
+		case gvk.Group == apiextensionsv1.Group && gvk.Kind == apiextensionsv1.CompositeResourceDefinitionKind:
+			pkg.XRDs = append(pkg.XRDs, u)
+		}
+	}
+
+	if pkg.Meta == nil {
+		return nil, errors.Errorf("package %q is not a Configuration package", src)
+	}
+	return pkg, nil
+}
+
+// packageStream returns the contents of the package.yaml file in the
+// supplied package image.
+func packageStream(img conregv1.Image) ([]byte, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get image layers")
+	}
+	for _, l := range layers {
+		rc, err := l.Uncompressed()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read image layer")
+		}
+		data, found, err := findTarFile(rc, packageStreamFile)
+		_ = rc.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read image layer")
+		}
+		if found {
+			return data, nil
+		}
+	}
+	return nil, errors.Errorf("image has no %s", packageStreamFile)
+}
+
+// findTarFile returns the contents of the named file in a tar stream.
+func findTarFile(r io.Reader, file string) ([]byte, bool, error) {
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if path.Clean(h.Name) != file {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		return data, true, err
+	}
+}
+
+// CompositionFor selects the package's Composition for the supplied XR.
+func (p *configurationPackage) CompositionFor(xr *composite.Unstructured) (*apiextensionsv1.Composition, error) {
+	return selectComposition(xr, p.Compositions, nil, fmt.Sprintf("package %q", p.Source))
+}
+
+// PinFunctions sets the package of each supplied Function to the version of
+// the matching Function dependency of the package, if the dependency names an
+// exact version. Dependencies match Functions named after the last element of
+// their package, optionally prefixed by its owner, e.g. function-auto-ready or
+// crossplane-contrib-function-auto-ready.
+func (p *configurationPackage) PinFunctions(fns []pkgv1.Function) {
+	for _, d := range p.Meta.Spec.DependsOn {
+		pkg := ""
+		switch {
+		case d.Function != nil:
+			pkg = *d.Function
+		case d.Package != nil && d.Kind != nil && *d.Kind == pkgv1.FunctionKind:
+			pkg = *d.Package
+		default:
+			continue
+		}
+		if !exactVersion(d.Version) {
+			continue
+		}
+
+		for i := range fns {
+			n := fns[i].GetName()
+			if path.Base(pkg) != n && !strings.HasSuffix(strings.ReplaceAll(pkg, "/", "-"), "-"+n) {
+				continue
+			}
+			sep := ":"
+			if strings.HasPrefix(d.Version, "sha256:") {
+				sep = "@"
+			}
+			fns[i].Spec.Package = pkg + sep + d.Version
+		}
+	}
+}
+
+// exactVersion returns true if the supplied dependency version is a tag or
+// digest rather than a constraint.
+func exactVersion(v string) bool {
+	return strings.HasPrefix(v, "sha256:") || (strings.HasPrefix(v, "v") && len(v) > 1 && v[1] >= '0' && v[1] <= '9')
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+
+	apiextensionsv1 "github.com/crossplane/crossplane/v2/apis/apiextensions/v1"
+	pkgv1 "github.com/crossplane/crossplane/v2/apis/pkg/v1"
+	pkgv1beta1 "github.com/crossplane/crossplane/v2/apis/pkg/v1beta1"
+)
+
+// CrossplaneLockCluster is the --crossplane-lock value reading the Lock of the
+// cluster instead of a file.
+const CrossplaneLockCluster = "cluster"
+
+// crossplaneLockName is the name of the Lock the Crossplane package manager
+// maintains.
+const crossplaneLockName = "lock"
+
+// loadCrossplaneLock loads a Crossplane Lock exported from a cluster, e.g. with
+// kubectl get lock lock -o yaml. A List holding the Lock is accepted too.
+func loadCrossplaneLock(fs afero.Fs, file string) (*pkgv1beta1.Lock, error) {
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read Crossplane lock %q", file)
+	}
+	l := &struct {
+		pkgv1beta1.Lock `json:",inline"`
+		Items           []pkgv1beta1.Lock `json:"items,omitempty"`
+	}{}
+	if err := yaml.Unmarshal(data, l); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse Crossplane lock %q", file)
+	}
+	switch {
+	case l.Kind == pkgv1beta1.LockKind:
+		return &l.Lock, nil
+	case l.Kind == "List" && len(l.Items) == 1:
+		return &l.Items[0], nil
+	}
+	return nil, errors.Errorf("%q must hold a single %s", file, pkgv1beta1.LockGroupKind)
+}
+
+// fetchCrossplaneLock reads the Lock of the cluster selected by the cluster
+// flags.
+func (c *renderCmd) fetchCrossplaneLock(ctx context.Context) (*pkgv1beta1.Lock, error) {
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return nil, err
+	}
+	u, err := cc.get(ctx, pkgv1beta1.LockGroupVersionKind, "", crossplaneLockName)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, errors.Errorf("the cluster has no %s %q, is Crossplane installed?", pkgv1beta1.LockGroupKind, crossplaneLockName)
+	}
+	l := &pkgv1beta1.Lock{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, l); err != nil {
+		return nil, errors.Wrapf(err, "cannot convert %s %q", pkgv1beta1.LockGroupKind, crossplaneLockName)
+	}
+	return l, nil
+}
+
+// crossplaneLock returns the Crossplane Lock named by --crossplane-lock. It's
+// loaded once, and reused for every XR rendered.
+func (c *renderCmd) crossplaneLock(ctx context.Context) (*pkgv1beta1.Lock, error) {
+	if c.xpLock != nil {
+		return c.xpLock, nil
+	}
+	var err error
+	if c.crossplaneLockSource == CrossplaneLockCluster {
+		c.xpLock, err = c.fetchCrossplaneLock(ctx)
+	} else {
+		c.xpLock, err = loadCrossplaneLock(c.fs, c.crossplaneLockSource)
+	}
+	return c.xpLock, err
+}
+
+// lockFromCrossplane returns a Lock holding the Functions of the supplied
+// Composition's pipeline that the Crossplane Lock resolved, pinned to the
+// version the package manager resolved.
+func lockFromCrossplane(comp *apiextensionsv1.Composition, xl *pkgv1beta1.Lock) *Lock {
+	l := &Lock{Functions: map[string]LockedFunction{}}
+	for _, step := range comp.Spec.Pipeline {
+		n := step.FunctionRef.Name
+		if _, ok := l.Functions[n]; ok {
+			continue
+		}
+		for _, p := range xl.Packages {
+			if !isFunctionPackage(p) || !lockPackageMatches(p, n) {
+				continue
+			}
+			lf := LockedFunction{Package: p.Source + ":" + p.Version}
+			if strings.HasPrefix(p.Version, "sha256:") {
+				lf = LockedFunction{Package: p.Source, Digest: p.Version}
+			}
+			l.Functions[n] = lf
+			break
+		}
+	}
+	return l
+}
+
+// isFunctionPackage returns true if the supplied locked package is a Function.
+func isFunctionPackage(p pkgv1beta1.LockPackage) bool {
+	if p.Kind != nil {
+		return *p.Kind == pkgv1.FunctionKind
+	}
+	return p.Type != nil && *p.Type == pkgv1beta1.FunctionPackageType
+}
+
+// lockPackageMatches returns true if the supplied locked package is of the
+// named Function. Functions are named after the last element of their
+// package, optionally prefixed by its owner, e.g. function-auto-ready or
+// crossplane-contrib-function-auto-ready, and their revisions after the
+// Function, followed by a hash.
+func lockPackageMatches(p pkgv1beta1.LockPackage, fn string) bool {
+	if path.Base(p.Source) == fn || strings.HasSuffix(strings.ReplaceAll(p.Source, "/", "-"), "-"+fn) {
+		return true
+	}
+	hash, ok := strings.CutPrefix(p.Name, fn+"-")
+	return ok && hash != "" && !strings.Contains(hash, "-")
+}
+
+// describeCrossplaneLock names the source of a Crossplane Lock in messages.
+func describeCrossplaneLock(source string) string {
+	if source == CrossplaneLockCluster {
+		return "the cluster's Crossplane lock"
+	}
+	return "Crossplane lock " + source
+}
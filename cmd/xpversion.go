@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
+	"golang.org/x/mod/semver"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/fieldpath"
+
+	pkgmetav1 "github.com/crossplane/crossplane/v2/apis/pkg/meta/v1"
+	"github.com/crossplane/crossplane/v2/cmd/crank/common/load"
+	"github.com/crossplane/crossplane/v2/cmd/crank/render"
+)
+
+const checkCrossplaneVersion = "crossplane-version"
+
+// AnnotationKeyCrossplaneVersion declares the Crossplane versions a
+// Composition is compatible with, as a semver constraint like the
+// spec.crossplane.version of a package, e.g. ">=v2.0.0".
+const AnnotationKeyCrossplaneVersion = "crossbench.io/crossplane-version"
+
+// CrossplaneVersionCluster is the --crossplane-version value detecting the
+// version of Crossplane installed in the cluster.
+const CrossplaneVersionCluster = "cluster"
+
+// getCrossplaneVersion returns the Crossplane version the render targets
+// Default: none, configurable via CROSSBENCH_CROSSPLANE_VERSION env var
+func getCrossplaneVersion() string {
+	return os.Getenv("CROSSBENCH_CROSSPLANE_VERSION")
+}
+
+// A crossplaneRequirement is a Crossplane version constraint declared by one
+// of the inputs of a render.
+type crossplaneRequirement struct {
+	// Resource declaring the constraint, e.g. Function/function-kcl.
+	Resource string
+
+	// Source of the constraint, e.g. the package declaring it.
+	Source string
+
+	Constraint string
+}
+
+// CheckCrossplaneVersion verifies that the supplied Crossplane version
+// satisfies every supplied requirement.
+func CheckCrossplaneVersion(version string, reqs []crossplaneRequirement) []Finding {
+	findings := []Finding{}
+	v := canonicalVersion(version)
+	for _, r := range reqs {
+		c, err := parseVersionConstraint(r.Constraint)
+		if err != nil {
+			findings = append(findings, Finding{
+				Check:    checkCrossplaneVersion,
+				Severity: SeverityWarning,
+				Resource: r.Resource,
+				Message:  fmt.Sprintf("cannot check %s: %v", r.Source, err),
+			})
+			continue
+		}
+		if c == nil || c.Allows(v) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Check:    checkCrossplaneVersion,
+			Severity: SeverityError,
+			Resource: r.Resource,
+			Message:  fmt.Sprintf("%s requires Crossplane %s, but the target is %s", r.Source, r.Constraint, version),
+		})
+	}
+	return findings
+}
+
+// checkCrossplaneVersion checks the Composition, its Configuration package
+// and the packages of its Functions are compatible with the Crossplane version
+// targeted by --crossplane-version. Functions whose package can't be read are
+// reported as warnings.
+func (c *renderCmd) checkCrossplaneVersion(ctx context.Context, in render.Inputs) ([]Finding, error) {
+	version, err := c.targetCrossplaneVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := []crossplaneRequirement{}
+	if v := in.Composition.GetAnnotations()[AnnotationKeyCrossplaneVersion]; v != "" {
+		reqs = append(reqs, crossplaneRequirement{
+			Resource:   "Composition/" + in.Composition.GetName(),
+			Source:     fmt.Sprintf("the %s annotation", AnnotationKeyCrossplaneVersion),
+			Constraint: v,
+		})
+	}
+	if m := c.packageMeta; m != nil && m.Spec.Crossplane != nil {
+		reqs = append(reqs, crossplaneRequirement{
+			Resource:   "Configuration/" + m.GetName(),
+			Source:     fmt.Sprintf("package %q", c.composition),
+			Constraint: m.Spec.Crossplane.Version,
+		})
+	}
+
+	findings := []Finding{}
+	for _, fn := range in.Functions {
+		constraint, err := c.functionCrossplaneConstraint(fn.Spec.Package)
+		if err != nil {
+			findings = append(findings, Finding{
+				Check:    checkCrossplaneVersion,
+				Severity: SeverityWarning,
+				Resource: "Function/" + fn.GetName(),
+				Message:  fmt.Sprintf("cannot read the Crossplane versions package %q is compatible with: %v", fn.Spec.Package, err),
+			})
+			continue
+		}
+		reqs = append(reqs, crossplaneRequirement{
+			Resource:   "Function/" + fn.GetName(),
+			Source:     fmt.Sprintf("package %q", fn.Spec.Package),
+			Constraint: constraint,
+		})
+	}
+	return append(findings, CheckCrossplaneVersion(version, reqs)...), nil
+}
+
+// targetCrossplaneVersion returns the Crossplane version --crossplane-version
+// names, detecting it from the cluster if asked to. It's detected once, and
+// reused for every XR rendered.
+func (c *renderCmd) targetCrossplaneVersion(ctx context.Context) (string, error) {
+	if c.xpVersion != "" {
+		return c.xpVersion, nil
+	}
+	v := c.crossplaneVersion
+	if v == CrossplaneVersionCluster {
+		var err error
+		if v, err = c.detectCrossplaneVersion(ctx); err != nil {
+			return "", err
+		}
+		infof("Detected Crossplane %s in the cluster", v)
+	}
+	if !semver.IsValid(canonicalVersion(v)) {
+		return "", errors.Errorf("invalid --crossplane-version %q, must be a version such as v2.1.0 or %s", v, CrossplaneVersionCluster)
+	}
+	c.xpVersion = v
+	return v, nil
+}
+
+// detectCrossplaneVersion returns the version of Crossplane installed in the
+// cluster selected by the cluster flags, from the image tag of its Deployment.
+func (c *renderCmd) detectCrossplaneVersion(ctx context.Context) (string, error) {
+	if c.networkDisabled() {
+		return "", errors.Errorf("--crossplane-version %s can't be used with --no-network or --offline", CrossplaneVersionCluster)
+	}
+	cc, err := c.newClusterClient()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	ds, err := cc.list(ctx, schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "", map[string]string{"app": "crossplane"})
+	if err != nil {
+		return "", errors.Wrap(err, "cannot find the Crossplane deployment")
+	}
+	for i := range ds {
+		if v := crossplaneImageVersion(&ds[i]); v != "" {
+			return v, nil
+		}
+	}
+	return "", errors.New("cannot detect the Crossplane version of the cluster: no deployment labeled app=crossplane runs a tagged crossplane image, set --crossplane-version instead")
+}
+
+// crossplaneImageVersion returns the tag of the crossplane image the supplied
+// Deployment runs, if any.
+func crossplaneImageVersion(d *unstructured.Unstructured) string {
+	v, err := fieldpath.Pave(d.Object).GetValue("spec.template.spec.containers")
+	if err != nil {
+		return ""
+	}
+	cs, _ := v.([]any)
+	for _, ct := range cs {
+		m, _ := ct.(map[string]any)
+		image, _ := m["image"].(string)
+		ref, err := name.NewTag(image)
+		if err != nil || !strings.HasSuffix(ref.RepositoryStr(), "/crossplane") {
+			continue
+		}
+		if semver.IsValid(canonicalVersion(ref.TagStr())) {
+			return ref.TagStr()
+		}
+	}
+	return ""
+}
+
+// functionCrossplaneConstraint returns the spec.crossplane.version constraint
+// of the supplied Function package, or an empty constraint if it has none.
+// Each package is only read once.
+func (c *renderCmd) functionCrossplaneConstraint(pkg string) (string, error) {
+	if v, ok := c.fnCrossplaneConstraints[pkg]; ok {
+		return v, nil
+	}
+	if c.networkDisabled() {
+		return "", errors.New("package metadata can't be pulled with --no-network or --offline")
+	}
+	img, err := crane.Pull(pkg)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot pull package")
+	}
+	stream, err := packageStream(img)
+	if err != nil {
+		return "", err
+	}
+	docs, err := load.YamlStream(bytes.NewReader(stream))
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse %s", packageStreamFile)
+	}
+	constraint := ""
+	for _, doc := range docs {
+		m := &pkgmetav1.Function{}
+		if err := yaml.Unmarshal(doc, m); err != nil {
+			return "", errors.Wrapf(err, "cannot parse %s", packageStreamFile)
+		}
+		if m.GroupVersionKind().Group == pkgmetav1.Group && m.Kind == pkgmetav1.FunctionKind && m.Spec.Crossplane != nil {
+			constraint = m.Spec.Crossplane.Version
+		}
+	}
+	if c.fnCrossplaneConstraints == nil {
+		c.fnCrossplaneConstraints = map[string]string{}
+	}
+	c.fnCrossplaneConstraints[pkg] = constraint
+	return constraint, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/gjbravi/crossbench/cmd"
 	"github.com/spf13/cobra"
@@ -17,9 +18,34 @@ func main() {
 
 	// Add commands
 	rootCmd.AddCommand(cmd.NewRenderCommand())
+	rootCmd.AddCommand(cmd.NewValidateCommand())
+	rootCmd.AddCommand(cmd.NewDiffCommand())
+	rootCmd.AddCommand(cmd.NewObserveCommand())
+	rootCmd.AddCommand(cmd.NewApplyCommand())
+	rootCmd.AddCommand(cmd.NewParityCommand())
+	rootCmd.AddCommand(cmd.NewChangelogCommand())
+	rootCmd.AddCommand(cmd.NewInventoryCommand())
+	rootCmd.AddCommand(cmd.NewTestCommand())
+	rootCmd.AddCommand(cmd.NewBenchCommand())
+	rootCmd.AddCommand(cmd.NewLintCommand())
+	rootCmd.AddCommand(cmd.NewFunctionsCommand())
+	rootCmd.AddCommand(cmd.NewLockCommand())
+	rootCmd.AddCommand(cmd.NewCacheCommand())
+	rootCmd.AddCommand(cmd.NewDaemonCommand())
+	rootCmd.AddCommand(cmd.NewServeCommand())
+	rootCmd.AddCommand(cmd.NewImportCrankCommand())
+	rootCmd.AddCommand(cmd.NewCapabilitiesCommand())
+	rootCmd.AddCommand(cmd.NewTelemetryCommand())
 	rootCmd.AddCommand(cmd.NewVersionCommand())
+	cmd.AddLoggingFlags(rootCmd)
+	if cmd.IsKubectlPlugin(os.Args[0]) {
+		cmd.ConfigureKubectlPlugin(rootCmd)
+	}
 
-	if err := rootCmd.Execute(); err != nil {
+	started := time.Now()
+	c, err := rootCmd.ExecuteC()
+	cmd.ReportUsage(c, time.Since(started), err)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,4 @@
+// Package v1 contains the generated code of the crossbench gRPC API.
+package v1
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative crossbench/v1/render.proto
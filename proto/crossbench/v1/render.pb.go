@@ -0,0 +1,702 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: crossbench/v1/render.proto
+
+// Package crossbench.v1 exposes the crossbench render pipeline to other
+// services, e.g. developer portals and CI tooling written in Go or
+// TypeScript. Serve it with crossbench serve --grpc-address.
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DocumentType is the type of a rendered document.
+type DocumentType int32
+
+const (
+	DocumentType_DOCUMENT_TYPE_UNSPECIFIED DocumentType = 0
+	// The rendered composite resource. Always streamed first.
+	DocumentType_DOCUMENT_TYPE_COMPOSITE_RESOURCE DocumentType = 1
+	// A composed resource.
+	DocumentType_DOCUMENT_TYPE_COMPOSED_RESOURCE DocumentType = 2
+	// A result returned by a Function.
+	DocumentType_DOCUMENT_TYPE_RESULT DocumentType = 3
+	// The context the pipeline produced.
+	DocumentType_DOCUMENT_TYPE_CONTEXT DocumentType = 4
+)
+
+// Enum value maps for DocumentType.
+var (
+	DocumentType_name = map[int32]string{
+		0: "DOCUMENT_TYPE_UNSPECIFIED",
+		1: "DOCUMENT_TYPE_COMPOSITE_RESOURCE",
+		2: "DOCUMENT_TYPE_COMPOSED_RESOURCE",
+		3: "DOCUMENT_TYPE_RESULT",
+		4: "DOCUMENT_TYPE_CONTEXT",
+	}
+	DocumentType_value = map[string]int32{
+		"DOCUMENT_TYPE_UNSPECIFIED":        0,
+		"DOCUMENT_TYPE_COMPOSITE_RESOURCE": 1,
+		"DOCUMENT_TYPE_COMPOSED_RESOURCE":  2,
+		"DOCUMENT_TYPE_RESULT":             3,
+		"DOCUMENT_TYPE_CONTEXT":            4,
+	}
+)
+
+func (x DocumentType) Enum() *DocumentType {
+	p := new(DocumentType)
+	*p = x
+	return p
+}
+
+func (x DocumentType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DocumentType) Descriptor() protoreflect.EnumDescriptor {
+	return file_crossbench_v1_render_proto_enumTypes[0].Descriptor()
+}
+
+func (DocumentType) Type() protoreflect.EnumType {
+	return &file_crossbench_v1_render_proto_enumTypes[0]
+}
+
+func (x DocumentType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DocumentType.Descriptor instead.
+func (DocumentType) EnumDescriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{0}
+}
+
+// RenderInputs are the inputs of a render.
+type RenderInputs struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The composite resource (or Claim) to render. Required.
+	CompositeResource string `protobuf:"bytes,1,opt,name=composite_resource,json=compositeResource,proto3" json:"composite_resource,omitempty"`
+	// The Composition, or CompositionRevision, to render with. Required.
+	Composition string `protobuf:"bytes,2,opt,name=composition,proto3" json:"composition,omitempty"`
+	// The Functions to run. Extracted from the Composition's pipeline if
+	// unset.
+	Functions string `protobuf:"bytes,3,opt,name=functions,proto3" json:"functions,omitempty"`
+	// Observed composed resources, to simulate an update.
+	ObservedResources string `protobuf:"bytes,4,opt,name=observed_resources,json=observedResources,proto3" json:"observed_resources,omitempty"`
+	// Extra resources Functions request.
+	ExtraResources string `protobuf:"bytes,5,opt,name=extra_resources,json=extraResources,proto3" json:"extra_resources,omitempty"`
+	// Context values passed to the Functions, as JSON by key.
+	Context       map[string]string `protobuf:"bytes,6,rep,name=context,proto3" json:"context,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderInputs) Reset() {
+	*x = RenderInputs{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderInputs) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderInputs) ProtoMessage() {}
+
+func (x *RenderInputs) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderInputs.ProtoReflect.Descriptor instead.
+func (*RenderInputs) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RenderInputs) GetCompositeResource() string {
+	if x != nil {
+		return x.CompositeResource
+	}
+	return ""
+}
+
+func (x *RenderInputs) GetComposition() string {
+	if x != nil {
+		return x.Composition
+	}
+	return ""
+}
+
+func (x *RenderInputs) GetFunctions() string {
+	if x != nil {
+		return x.Functions
+	}
+	return ""
+}
+
+func (x *RenderInputs) GetObservedResources() string {
+	if x != nil {
+		return x.ObservedResources
+	}
+	return ""
+}
+
+func (x *RenderInputs) GetExtraResources() string {
+	if x != nil {
+		return x.ExtraResources
+	}
+	return ""
+}
+
+func (x *RenderInputs) GetContext() map[string]string {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+// RenderRequest asks for a composite resource to be rendered.
+type RenderRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Inputs *RenderInputs          `protobuf:"bytes,1,opt,name=inputs,proto3" json:"inputs,omitempty"`
+	// Stream the results Functions returned.
+	IncludeFunctionResults bool `protobuf:"varint,2,opt,name=include_function_results,json=includeFunctionResults,proto3" json:"include_function_results,omitempty"`
+	// Include the XR's spec and metadata in the rendered XR.
+	IncludeFullXr bool `protobuf:"varint,3,opt,name=include_full_xr,json=includeFullXr,proto3" json:"include_full_xr,omitempty"`
+	// Stream the context the pipeline produced.
+	IncludeContext bool `protobuf:"varint,4,opt,name=include_context,json=includeContext,proto3" json:"include_context,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RenderRequest) Reset() {
+	*x = RenderRequest{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderRequest) ProtoMessage() {}
+
+func (x *RenderRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderRequest.ProtoReflect.Descriptor instead.
+func (*RenderRequest) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RenderRequest) GetInputs() *RenderInputs {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *RenderRequest) GetIncludeFunctionResults() bool {
+	if x != nil {
+		return x.IncludeFunctionResults
+	}
+	return false
+}
+
+func (x *RenderRequest) GetIncludeFullXr() bool {
+	if x != nil {
+		return x.IncludeFullXr
+	}
+	return false
+}
+
+func (x *RenderRequest) GetIncludeContext() bool {
+	if x != nil {
+		return x.IncludeContext
+	}
+	return false
+}
+
+// RenderResponse is one rendered document.
+type RenderResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  DocumentType           `protobuf:"varint,1,opt,name=type,proto3,enum=crossbench.v1.DocumentType" json:"type,omitempty"`
+	// The document as YAML.
+	Yaml          string `protobuf:"bytes,2,opt,name=yaml,proto3" json:"yaml,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RenderResponse) Reset() {
+	*x = RenderResponse{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RenderResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RenderResponse) ProtoMessage() {}
+
+func (x *RenderResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RenderResponse.ProtoReflect.Descriptor instead.
+func (*RenderResponse) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *RenderResponse) GetType() DocumentType {
+	if x != nil {
+		return x.Type
+	}
+	return DocumentType_DOCUMENT_TYPE_UNSPECIFIED
+}
+
+func (x *RenderResponse) GetYaml() string {
+	if x != nil {
+		return x.Yaml
+	}
+	return ""
+}
+
+// ValidateRequest asks for a composite resource to be rendered and
+// validated.
+type ValidateRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Inputs *RenderInputs          `protobuf:"bytes,1,opt,name=inputs,proto3" json:"inputs,omitempty"`
+	// The XRDs and CRDs to validate against, as a YAML stream. Required.
+	Schemas string `protobuf:"bytes,2,opt,name=schemas,proto3" json:"schemas,omitempty"`
+	// Fail validation if a schema is missing for any validated resource.
+	ErrorOnMissingSchemas bool `protobuf:"varint,3,opt,name=error_on_missing_schemas,json=errorOnMissingSchemas,proto3" json:"error_on_missing_schemas,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *ValidateRequest) Reset() {
+	*x = ValidateRequest{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateRequest) ProtoMessage() {}
+
+func (x *ValidateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateRequest.ProtoReflect.Descriptor instead.
+func (*ValidateRequest) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ValidateRequest) GetInputs() *RenderInputs {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *ValidateRequest) GetSchemas() string {
+	if x != nil {
+		return x.Schemas
+	}
+	return ""
+}
+
+func (x *ValidateRequest) GetErrorOnMissingSchemas() bool {
+	if x != nil {
+		return x.ErrorOnMissingSchemas
+	}
+	return false
+}
+
+// ValidateResponse reports the outcome of a validation.
+type ValidateResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Whether every resource is valid.
+	Valid bool `protobuf:"varint,1,opt,name=valid,proto3" json:"valid,omitempty"`
+	// The validation report, as printed by crossbench validate.
+	Report        string `protobuf:"bytes,2,opt,name=report,proto3" json:"report,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValidateResponse) Reset() {
+	*x = ValidateResponse{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValidateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValidateResponse) ProtoMessage() {}
+
+func (x *ValidateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValidateResponse.ProtoReflect.Descriptor instead.
+func (*ValidateResponse) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ValidateResponse) GetValid() bool {
+	if x != nil {
+		return x.Valid
+	}
+	return false
+}
+
+func (x *ValidateResponse) GetReport() string {
+	if x != nil {
+		return x.Report
+	}
+	return ""
+}
+
+// ResolveFunctionsRequest asks for the Functions of a Composition.
+type ResolveFunctionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The Composition, or CompositionRevision. Required.
+	Composition   string `protobuf:"bytes,1,opt,name=composition,proto3" json:"composition,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveFunctionsRequest) Reset() {
+	*x = ResolveFunctionsRequest{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveFunctionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveFunctionsRequest) ProtoMessage() {}
+
+func (x *ResolveFunctionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveFunctionsRequest.ProtoReflect.Descriptor instead.
+func (*ResolveFunctionsRequest) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResolveFunctionsRequest) GetComposition() string {
+	if x != nil {
+		return x.Composition
+	}
+	return ""
+}
+
+// ResolveFunctionsResponse holds the Functions of a Composition.
+type ResolveFunctionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Functions     []*Function            `protobuf:"bytes,1,rep,name=functions,proto3" json:"functions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveFunctionsResponse) Reset() {
+	*x = ResolveFunctionsResponse{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveFunctionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveFunctionsResponse) ProtoMessage() {}
+
+func (x *ResolveFunctionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveFunctionsResponse.ProtoReflect.Descriptor instead.
+func (*ResolveFunctionsResponse) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ResolveFunctionsResponse) GetFunctions() []*Function {
+	if x != nil {
+		return x.Functions
+	}
+	return nil
+}
+
+// Function is a Function a Composition's pipeline runs.
+type Function struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The name the pipeline refers to the Function by.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The OCI package the Function runs, pinned to a version or digest.
+	Package string `protobuf:"bytes,2,opt,name=package,proto3" json:"package,omitempty"`
+	// The annotations of the Function, e.g. its runtime.
+	Annotations   map[string]string `protobuf:"bytes,3,rep,name=annotations,proto3" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Function) Reset() {
+	*x = Function{}
+	mi := &file_crossbench_v1_render_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Function) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Function) ProtoMessage() {}
+
+func (x *Function) ProtoReflect() protoreflect.Message {
+	mi := &file_crossbench_v1_render_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Function.ProtoReflect.Descriptor instead.
+func (*Function) Descriptor() ([]byte, []int) {
+	return file_crossbench_v1_render_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Function) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Function) GetPackage() string {
+	if x != nil {
+		return x.Package
+	}
+	return ""
+}
+
+func (x *Function) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.Annotations
+	}
+	return nil
+}
+
+var File_crossbench_v1_render_proto protoreflect.FileDescriptor
+
+const file_crossbench_v1_render_proto_rawDesc = "" +
+	"\n" +
+	"\x1acrossbench/v1/render.proto\x12\rcrossbench.v1\"\xd5\x02\n" +
+	"\fRenderInputs\x12-\n" +
+	"\x12composite_resource\x18\x01 \x01(\tR\x11compositeResource\x12 \n" +
+	"\vcomposition\x18\x02 \x01(\tR\vcomposition\x12\x1c\n" +
+	"\tfunctions\x18\x03 \x01(\tR\tfunctions\x12-\n" +
+	"\x12observed_resources\x18\x04 \x01(\tR\x11observedResources\x12'\n" +
+	"\x0fextra_resources\x18\x05 \x01(\tR\x0eextraResources\x12B\n" +
+	"\acontext\x18\x06 \x03(\v2(.crossbench.v1.RenderInputs.ContextEntryR\acontext\x1a:\n" +
+	"\fContextEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xcf\x01\n" +
+	"\rRenderRequest\x123\n" +
+	"\x06inputs\x18\x01 \x01(\v2\x1b.crossbench.v1.RenderInputsR\x06inputs\x128\n" +
+	"\x18include_function_results\x18\x02 \x01(\bR\x16includeFunctionResults\x12&\n" +
+	"\x0finclude_full_xr\x18\x03 \x01(\bR\rincludeFullXr\x12'\n" +
+	"\x0finclude_context\x18\x04 \x01(\bR\x0eincludeContext\"U\n" +
+	"\x0eRenderResponse\x12/\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1b.crossbench.v1.DocumentTypeR\x04type\x12\x12\n" +
+	"\x04yaml\x18\x02 \x01(\tR\x04yaml\"\x99\x01\n" +
+	"\x0fValidateRequest\x123\n" +
+	"\x06inputs\x18\x01 \x01(\v2\x1b.crossbench.v1.RenderInputsR\x06inputs\x12\x18\n" +
+	"\aschemas\x18\x02 \x01(\tR\aschemas\x127\n" +
+	"\x18error_on_missing_schemas\x18\x03 \x01(\bR\x15errorOnMissingSchemas\"@\n" +
+	"\x10ValidateResponse\x12\x14\n" +
+	"\x05valid\x18\x01 \x01(\bR\x05valid\x12\x16\n" +
+	"\x06report\x18\x02 \x01(\tR\x06report\";\n" +
+	"\x17ResolveFunctionsRequest\x12 \n" +
+	"\vcomposition\x18\x01 \x01(\tR\vcomposition\"Q\n" +
+	"\x18ResolveFunctionsResponse\x125\n" +
+	"\tfunctions\x18\x01 \x03(\v2\x17.crossbench.v1.FunctionR\tfunctions\"\xc4\x01\n" +
+	"\bFunction\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\apackage\x18\x02 \x01(\tR\apackage\x12J\n" +
+	"\vannotations\x18\x03 \x03(\v2(.crossbench.v1.Function.AnnotationsEntryR\vannotations\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01*\xad\x01\n" +
+	"\fDocumentType\x12\x1d\n" +
+	"\x19DOCUMENT_TYPE_UNSPECIFIED\x10\x00\x12$\n" +
+	" DOCUMENT_TYPE_COMPOSITE_RESOURCE\x10\x01\x12#\n" +
+	"\x1fDOCUMENT_TYPE_COMPOSED_RESOURCE\x10\x02\x12\x18\n" +
+	"\x14DOCUMENT_TYPE_RESULT\x10\x03\x12\x19\n" +
+	"\x15DOCUMENT_TYPE_CONTEXT\x10\x042\x8a\x02\n" +
+	"\rRenderService\x12G\n" +
+	"\x06Render\x12\x1c.crossbench.v1.RenderRequest\x1a\x1d.crossbench.v1.RenderResponse0\x01\x12K\n" +
+	"\bValidate\x12\x1e.crossbench.v1.ValidateRequest\x1a\x1f.crossbench.v1.ValidateResponse\x12c\n" +
+	"\x10ResolveFunctions\x12&.crossbench.v1.ResolveFunctionsRequest\x1a'.crossbench.v1.ResolveFunctionsResponseB6Z4github.com/gjbravi/crossbench/proto/crossbench/v1;v1b\x06proto3"
+
+var (
+	file_crossbench_v1_render_proto_rawDescOnce sync.Once
+	file_crossbench_v1_render_proto_rawDescData []byte
+)
+
+func file_crossbench_v1_render_proto_rawDescGZIP() []byte {
+	file_crossbench_v1_render_proto_rawDescOnce.Do(func() {
+		file_crossbench_v1_render_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_crossbench_v1_render_proto_rawDesc), len(file_crossbench_v1_render_proto_rawDesc)))
+	})
+	return file_crossbench_v1_render_proto_rawDescData
+}
+
+var file_crossbench_v1_render_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_crossbench_v1_render_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_crossbench_v1_render_proto_goTypes = []any{
+	(DocumentType)(0),                // 0: crossbench.v1.DocumentType
+	(*RenderInputs)(nil),             // 1: crossbench.v1.RenderInputs
+	(*RenderRequest)(nil),            // 2: crossbench.v1.RenderRequest
+	(*RenderResponse)(nil),           // 3: crossbench.v1.RenderResponse
+	(*ValidateRequest)(nil),          // 4: crossbench.v1.ValidateRequest
+	(*ValidateResponse)(nil),         // 5: crossbench.v1.ValidateResponse
+	(*ResolveFunctionsRequest)(nil),  // 6: crossbench.v1.ResolveFunctionsRequest
+	(*ResolveFunctionsResponse)(nil), // 7: crossbench.v1.ResolveFunctionsResponse
+	(*Function)(nil),                 // 8: crossbench.v1.Function
+	nil,                              // 9: crossbench.v1.RenderInputs.ContextEntry
+	nil,                              // 10: crossbench.v1.Function.AnnotationsEntry
+}
+var file_crossbench_v1_render_proto_depIdxs = []int32{
+	9,  // 0: crossbench.v1.RenderInputs.context:type_name -> crossbench.v1.RenderInputs.ContextEntry
+	1,  // 1: crossbench.v1.RenderRequest.inputs:type_name -> crossbench.v1.RenderInputs
+	0,  // 2: crossbench.v1.RenderResponse.type:type_name -> crossbench.v1.DocumentType
+	1,  // 3: crossbench.v1.ValidateRequest.inputs:type_name -> crossbench.v1.RenderInputs
+	8,  // 4: crossbench.v1.ResolveFunctionsResponse.functions:type_name -> crossbench.v1.Function
+	10, // 5: crossbench.v1.Function.annotations:type_name -> crossbench.v1.Function.AnnotationsEntry
+	2,  // 6: crossbench.v1.RenderService.Render:input_type -> crossbench.v1.RenderRequest
+	4,  // 7: crossbench.v1.RenderService.Validate:input_type -> crossbench.v1.ValidateRequest
+	6,  // 8: crossbench.v1.RenderService.ResolveFunctions:input_type -> crossbench.v1.ResolveFunctionsRequest
+	3,  // 9: crossbench.v1.RenderService.Render:output_type -> crossbench.v1.RenderResponse
+	5,  // 10: crossbench.v1.RenderService.Validate:output_type -> crossbench.v1.ValidateResponse
+	7,  // 11: crossbench.v1.RenderService.ResolveFunctions:output_type -> crossbench.v1.ResolveFunctionsResponse
+	9,  // [9:12] is the sub-list for method output_type
+	6,  // [6:9] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_crossbench_v1_render_proto_init() }
+func file_crossbench_v1_render_proto_init() {
+	if File_crossbench_v1_render_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_crossbench_v1_render_proto_rawDesc), len(file_crossbench_v1_render_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_crossbench_v1_render_proto_goTypes,
+		DependencyIndexes: file_crossbench_v1_render_proto_depIdxs,
+		EnumInfos:         file_crossbench_v1_render_proto_enumTypes,
+		MessageInfos:      file_crossbench_v1_render_proto_msgTypes,
+	}.Build()
+	File_crossbench_v1_render_proto = out.File
+	file_crossbench_v1_render_proto_goTypes = nil
+	file_crossbench_v1_render_proto_depIdxs = nil
+}
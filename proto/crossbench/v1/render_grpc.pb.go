@@ -0,0 +1,227 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: crossbench/v1/render.proto
+
+// Package crossbench.v1 exposes the crossbench render pipeline to other
+// services, e.g. developer portals and CI tooling written in Go or
+// TypeScript. Serve it with crossbench serve --grpc-address.
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RenderService_Render_FullMethodName           = "/crossbench.v1.RenderService/Render"
+	RenderService_Validate_FullMethodName         = "/crossbench.v1.RenderService/Validate"
+	RenderService_ResolveFunctions_FullMethodName = "/crossbench.v1.RenderService/ResolveFunctions"
+)
+
+// RenderServiceClient is the client API for RenderService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RenderService renders composite resources, validates them and resolves the
+// Functions of Compositions. Inputs are YAML, exactly as they'd be passed to
+// the crossbench CLI, and are rendered with the flags crossbench serve was
+// started with.
+type RenderServiceClient interface {
+	// Render renders a composite resource, and streams each rendered
+	// document in the order crossbench render writes them.
+	Render(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RenderResponse], error)
+	// Validate renders a composite resource, then validates it and its
+	// composed resources against the supplied schemas.
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error)
+	// ResolveFunctions returns the Functions the pipeline of a Composition
+	// runs, with the packages they resolve to.
+	ResolveFunctions(ctx context.Context, in *ResolveFunctionsRequest, opts ...grpc.CallOption) (*ResolveFunctionsResponse, error)
+}
+
+type renderServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRenderServiceClient(cc grpc.ClientConnInterface) RenderServiceClient {
+	return &renderServiceClient{cc}
+}
+
+func (c *renderServiceClient) Render(ctx context.Context, in *RenderRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RenderResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RenderService_ServiceDesc.Streams[0], RenderService_Render_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RenderRequest, RenderResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RenderService_RenderClient = grpc.ServerStreamingClient[RenderResponse]
+
+func (c *renderServiceClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidateResponse)
+	err := c.cc.Invoke(ctx, RenderService_Validate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *renderServiceClient) ResolveFunctions(ctx context.Context, in *ResolveFunctionsRequest, opts ...grpc.CallOption) (*ResolveFunctionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResolveFunctionsResponse)
+	err := c.cc.Invoke(ctx, RenderService_ResolveFunctions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RenderServiceServer is the server API for RenderService service.
+// All implementations must embed UnimplementedRenderServiceServer
+// for forward compatibility.
+//
+// RenderService renders composite resources, validates them and resolves the
+// Functions of Compositions. Inputs are YAML, exactly as they'd be passed to
+// the crossbench CLI, and are rendered with the flags crossbench serve was
+// started with.
+type RenderServiceServer interface {
+	// Render renders a composite resource, and streams each rendered
+	// document in the order crossbench render writes them.
+	Render(*RenderRequest, grpc.ServerStreamingServer[RenderResponse]) error
+	// Validate renders a composite resource, then validates it and its
+	// composed resources against the supplied schemas.
+	Validate(context.Context, *ValidateRequest) (*ValidateResponse, error)
+	// ResolveFunctions returns the Functions the pipeline of a Composition
+	// runs, with the packages they resolve to.
+	ResolveFunctions(context.Context, *ResolveFunctionsRequest) (*ResolveFunctionsResponse, error)
+	mustEmbedUnimplementedRenderServiceServer()
+}
+
+// UnimplementedRenderServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRenderServiceServer struct{}
+
+func (UnimplementedRenderServiceServer) Render(*RenderRequest, grpc.ServerStreamingServer[RenderResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Render not implemented")
+}
+func (UnimplementedRenderServiceServer) Validate(context.Context, *ValidateRequest) (*ValidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedRenderServiceServer) ResolveFunctions(context.Context, *ResolveFunctionsRequest) (*ResolveFunctionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveFunctions not implemented")
+}
+func (UnimplementedRenderServiceServer) mustEmbedUnimplementedRenderServiceServer() {}
+func (UnimplementedRenderServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeRenderServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RenderServiceServer will
+// result in compilation errors.
+type UnsafeRenderServiceServer interface {
+	mustEmbedUnimplementedRenderServiceServer()
+}
+
+func RegisterRenderServiceServer(s grpc.ServiceRegistrar, srv RenderServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRenderServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RenderService_ServiceDesc, srv)
+}
+
+func _RenderService_Render_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RenderRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RenderServiceServer).Render(m, &grpc.GenericServerStream[RenderRequest, RenderResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RenderService_RenderServer = grpc.ServerStreamingServer[RenderResponse]
+
+func _RenderService_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RenderServiceServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RenderService_Validate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RenderServiceServer).Validate(ctx, req.(*ValidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RenderService_ResolveFunctions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveFunctionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RenderServiceServer).ResolveFunctions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RenderService_ResolveFunctions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RenderServiceServer).ResolveFunctions(ctx, req.(*ResolveFunctionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RenderService_ServiceDesc is the grpc.ServiceDesc for RenderService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RenderService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "crossbench.v1.RenderService",
+	HandlerType: (*RenderServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Validate",
+			Handler:    _RenderService_Validate_Handler,
+		},
+		{
+			MethodName: "ResolveFunctions",
+			Handler:    _RenderService_ResolveFunctions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Render",
+			Handler:       _RenderService_Render_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "crossbench/v1/render.proto",
+}